@@ -0,0 +1,190 @@
+package app
+
+import (
+	"bookget/config"
+	"bookget/pkg/events"
+	"bookget/pkg/gohttp"
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The adapter tests replay testdata/<host>/. To refresh the fixtures against the
+// live sites run: BOOKGET_FIXTURES=record go test ./app -run TestAdapters
+func TestMain(m *testing.M) {
+	gohttp.Fixtures = gohttp.FixturePolicy{Mode: gohttp.FixtureModeFromEnv(), Dir: "testdata"}
+	config.Conf = config.Input{
+		Format:    "full/full/0/default.jpg",
+		FileExt:   ".jpg",
+		UserAgent: "bookget-test",
+		Threads:   1,
+		PageRate:  1,
+		PageCheck: "off",
+	}
+	os.Exit(m.Run())
+}
+
+type routerInit interface {
+	GetRouterInit(sUrl string) (map[string]interface{}, error)
+}
+
+func TestAdapters(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter func() routerInit
+		url     string
+		bookId  func(r routerInit) string
+		wantId  string
+		title   string
+		files   []string // relative to the book directory
+		pages   []string // image URLs requested, in order
+	}{
+		{
+			name:    "iiif v2",
+			adapter: func() routerInit { return NewIiifRouter(context.Background()) },
+			url:     "https://dcollections.lib.keio.ac.jp/sites/default/files/iiif/KAN/110X-24-1/manifest.json",
+			bookId:  func(r routerInit) string { return r.(*IIIF).dt.BookId },
+			wantId:  "110X-24-1",
+			title:   "論語集解 巻一",
+			files:   []string{"0001.jpg", "0002.jpg", "0003.jpg"},
+			pages: []string{
+				"https://dcollections.lib.keio.ac.jp/iiif/2/110X-24-1_0001/full/full/0/default.jpg",
+				"https://dcollections.lib.keio.ac.jp/iiif/2/110X-24-1_0002/full/full/0/default.jpg",
+				"https://dcollections.lib.keio.ac.jp/iiif/2/110X-24-1_0003/full/full/0/default.jpg",
+			},
+		},
+		{
+			name:    "iiif v3",
+			adapter: func() routerInit { return NewIiifRouter(context.Background()) },
+			url:     "https://catalog.lib.kyushu-u.ac.jp/image/manifest/1/820/1446033.json",
+			bookId:  func(r routerInit) string { return r.(*IIIF).dt.BookId },
+			wantId:  getBookId("https://catalog.lib.kyushu-u.ac.jp/image/manifest/1/820/1446033.json"),
+			title:   "九州大学附属図書館 細川文庫",
+			files:   []string{"0001.jpg", "0002.jpg"},
+			pages: []string{
+				"https://catalog.lib.kyushu-u.ac.jp/image/iiif/820/1446033/001.tif/full/full/0/default.jpg",
+				"https://catalog.lib.kyushu-u.ac.jp/image/iiif/820/1446033/002.tif/full/full/0/default.jpg",
+			},
+		},
+		{
+			name:    "ndl.go.jp volumes",
+			adapter: func() routerInit { return NewNdlJP(context.Background()) },
+			url:     "https://dl.ndl.go.jp/pid/1287288",
+			bookId:  func(r routerInit) string { return r.(*NdlJP).dt.BookId },
+			wantId:  "1287288",
+			files:   []string{"vol.0001/0001.jpg", "vol.0001/0002.jpg", "vol.0002/0001.jpg"},
+			pages: []string{
+				"https://dl.ndl.go.jp/api/iiif/1287289/R0000001/full/full/0/default.jpg",
+				"https://dl.ndl.go.jp/api/iiif/1287289/R0000002/full/full/0/default.jpg",
+				"https://dl.ndl.go.jp/api/iiif/1287290/R0000001/full/full/0/default.jpg",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Conf.Directory = t.TempDir()
+			var mu sync.Mutex
+			var pages []string
+			gohttp.Fixtures.Served = func(req *http.Request) {
+				if strings.HasSuffix(req.URL.Path, ".jpg") {
+					mu.Lock()
+					pages = append(pages, req.URL.String())
+					mu.Unlock()
+				}
+			}
+			defer func() { gohttp.Fixtures.Served = nil }()
+			planned := 0
+			cancel := events.Subscribe(func(e events.Event) {
+				if e.Kind == events.PagePlanned {
+					mu.Lock()
+					planned++
+					mu.Unlock()
+				}
+			})
+			defer cancel()
+
+			r := tt.adapter()
+			result, err := r.GetRouterInit(tt.url)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantId, tt.bookId(r))
+			if tt.title != "" {
+				assert.Equal(t, tt.title, result["title"])
+			}
+			assert.Equal(t, tt.pages, pages)
+			assert.Equal(t, len(tt.pages), planned, "each page is planned once, when it is queued")
+			assert.Equal(t, tt.files, bookFiles(t, config.Conf.Directory))
+		})
+	}
+}
+
+func TestExportBook(t *testing.T) {
+	saved := config.Conf
+	defer func() { config.Conf = saved }()
+	config.Conf.Directory = t.TempDir()
+	config.Conf.Export = "mets"
+
+	// A book of a batch is saved and exported in its own folder; without a
+	// title from the site it is described by its id and URL
+	sUrl := "https://dl.ndl.go.jp/pid/1287288"
+	book := WithOwnDir(NewBookContext(context.Background(), sUrl))
+	result, err := NewNdlJP(book).GetRouterInit(sUrl)
+	require.NoError(t, err)
+	ExportBook(book, sUrl, result)
+
+	entries, err := os.ReadDir(config.Conf.Directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "1287288", entries[0].Name())
+	assert.Equal(t, []string{"mets.xml", "vol.0001/0001.jpg", "vol.0001/0002.jpg", "vol.0002/0001.jpg"},
+		bookFiles(t, filepath.Join(config.Conf.Directory, "1287288")))
+	bs, err := os.ReadFile(filepath.Join(config.Conf.Directory, "1287288", "mets.xml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), "<mods:title>1287288</mods:title>")
+	assert.Contains(t, string(bs), sUrl)
+}
+
+// bookFiles lists the files under dir, slash separated and sorted
+func bookFiles(t *testing.T, dir string) []string {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestGetBookId(t *testing.T) {
+	tests := []struct {
+		name   string
+		bookId func(string) string
+		url    string
+		want   string
+	}{
+		{"iiif manifest.json", NewIiifRouter(context.Background()).getBookId, "https://example.org/iiif/abc-123/manifest.json", "abc-123"},
+		{"ndl.go.jp", NewNdlJP(context.Background()).getBookId, "https://dl.ndl.go.jp/pid/2592420/1/5", "2592420"},
+		{"tianyige searchpage", NewTianyige(context.Background()).getBookId, "https://gj.tianyige.com.cn/#/searchPage/4f9d2b7c0a5e?x=1", "4f9d2b7c0a5e"},
+		{"tianyige catalogid", NewTianyige(context.Background()).getBookId, "https://gj.tianyige.com.cn/detail?catalogId=a1b2_c3", "a1b2_c3"},
+		{"khirin", NewKhirin(context.Background()).getBookId, "https://khirin-a.rekihaku.ac.jp/nkmmkr/h-1303-1", "nkmmkr.h-1303-1"},
+		{"unknown", NewNdlJP(context.Background()).getBookId, "https://dl.ndl.go.jp/search", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.bookId(tt.url))
+		})
+	}
+}
@@ -3,16 +3,25 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/chttp"
-	"crypto/tls"
+	"bookget/pkg/events"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
+	"bookget/pkg/phash"
+	"bookget/pkg/postprocess"
+	"context"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 )
 
 func BuildRequestHeader() map[string]string {
 	httpHeaders := map[string]string{"User-Agent": config.Conf.UserAgent}
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		httpHeaders["Cookie"] = cookies
-	}
 
 	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
 	if err == nil {
@@ -23,13 +32,176 @@ func BuildRequestHeader() map[string]string {
 	return httpHeaders
 }
 
-// NewHttpTransport creates a new HTTP transport with proxy support
-func NewHttpTransport() *http.Transport {
-	return &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-		DisableKeepAlives: true,
-		Proxy:             http.ProxyFromEnvironment,
+// withCookieFile adds the cookies of --cookies to jar. Each cookie is sent only
+// to the hosts it belongs to, and cookies refreshed by a site are written back.
+func withCookieFile(jar *cookiejar.Jar) http.CookieJar {
+	if config.Conf.CookieFile == "" {
+		return jar
+	}
+	return chttp.JoinJars(jar, cookieFileJar{})
+}
+
+// cookieFileJar is the jar of the current --cookies, which a batch row may
+// change for its book after the adapters were created
+type cookieFileJar struct{}
+
+func (cookieFileJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if j := chttp.FileJar(config.Conf.CookieFile); j != nil {
+		j.SetCookies(u, cookies)
+	}
+}
+
+func (cookieFileJar) Cookies(u *url.URL) []*http.Cookie {
+	if j := chttp.FileJar(config.Conf.CookieFile); j != nil {
+		return j.Cookies(u)
+	}
+	return nil
+}
+
+// NewBookContext returns the context of one book download from pageUrl.
+// The router gives it to the book's adapter; what the adapter learns about
+// the book is kept there, apart from the books downloaded next to it.
+func NewBookContext(ctx context.Context, pageUrl string) context.Context {
+	ctx = gohttp.WithBook(ctx, gohttp.Book{PageURL: pageUrl})
+	return withFinishers(withSessions(config.WithVolume(ctx)))
+}
+
+type ownDirKey struct{}
+
+// WithOwnDir saves the book of ctx in a folder of its own under --dir, named
+// after its book id, for books sharing --dir with others (batch files)
+func WithOwnDir(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownDirKey{}, true)
+}
+
+// bookDir returns the directory the book of ctx is saved in, its volumes in
+// vol.* sub-directories. It is --dir, unless the book has a folder of its own.
+func bookDir(ctx context.Context) string {
+	dir := config.Conf.Directory
+	if own, _ := ctx.Value(ownDirKey{}).(bool); own {
+		if id := gohttp.BookOf(ctx).ID; id != "" {
+			dir = filepath.Join(dir, safeDirName(id))
+		}
+	}
+	_ = os.MkdirAll(dir, os.ModePerm)
+	return dir
+}
+
+// safeDirName turns a book id such as drs:53262215 or uc2.ark:/13960/t0ns0pf9x into a folder name
+func safeDirName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, id)
+}
+
+// setBookId makes the book id available to the {book_id} of header profiles
+func setBookId(ctx context.Context, bookId string) {
+	gohttp.SetBookID(ctx, bookId)
+}
+
+// pagePlanned tells the dashboard a page of the book is queued for download
+func pagePlanned(ctx context.Context) {
+	events.Emit(ctx, events.Event{Kind: events.PagePlanned})
+}
+
+// NewHttpTransport returns the shared keep-alive transport (HTTP/2, per-host proxies).
+// Every adapter gets the same one, so connections to a host are reused across pages and tiles.
+func NewHttpTransport() http.RoundTripper {
+	return gohttp.DefaultTransport()
+}
+
+// hostOf returns the host[:port] of rawUrl, or "" when it cannot be parsed
+func hostOf(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// pageFinisher checks and post-processes every page right after it is saved
+type pageFinisher struct {
+	checker  *phash.Checker
+	pipeline *postprocess.Pipeline
+}
+
+func newPageFinisher(host string) *pageFinisher {
+	return &pageFinisher{
+		checker:  phash.NewChecker(host),
+		pipeline: postprocess.New(host),
+	}
+}
+
+// bookFinishers are the page finishers of one book, one for each directory
+// it saves pages in: the page check compares pages with their neighbours in
+// the same volume.
+type bookFinishers struct {
+	mu sync.Mutex
+	of map[string]*pageFinisher
+}
+
+type finishersKey struct{}
+
+func withFinishers(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, finishersKey{}, &bookFinishers{of: make(map[string]*pageFinisher)})
+	return gohttp.WithPageFinisher(ctx, finishPage)
+}
+
+// bookFinisher returns the finisher of the pages of the book of ctx saved next to dest
+func bookFinisher(ctx context.Context, dest string) *pageFinisher {
+	host := hostOf(gohttp.BookOf(ctx).PageURL)
+	b, ok := ctx.Value(finishersKey{}).(*bookFinishers)
+	if !ok {
+		return newPageFinisher(host)
+	}
+	dir := filepath.Dir(dest)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	f, ok := b.of[dir]
+	if !ok {
+		f = newPageFinisher(host)
+		b.of[dir] = f
+	}
+	return f
+}
+
+var pageNumber = regexp.MustCompile(`\d+`)
+
+// pageIndex is the number in the file name of a page, e.g. 12 for 0012.jpg.
+// Pages without one are never compared with other pages.
+func pageIndex(dest string) int {
+	name := strings.TrimSuffix(filepath.Base(dest), filepath.Ext(dest))
+	m := pageNumber.FindAllString(name, -1)
+	if m == nil {
+		return -2
+	}
+	n, err := strconv.Atoi(m[len(m)-1])
+	if err != nil {
+		return -2
+	}
+	return n
+}
+
+// finishPage checks and post-processes each page saved by gohttp
+func finishPage(ctx context.Context, dest string, redo func() error) {
+	bookFinisher(ctx, dest).Finish(pageIndex(dest), dest, redo)
+}
+
+// Finish runs the page check and the post-processing pipeline on the page saved at dest.
+// redo downloads the page again, for the page check's retry action. kept is
+// false when the page check deleted the page.
+func (f *pageFinisher) Finish(index int, dest string, redo func() error) (kept bool) {
+	if f == nil {
+		return true
+	}
+	if !f.checker.Handle(index, dest, redo) {
+		return false
+	}
+	if _, err := f.pipeline.Apply(dest); err != nil {
+		i18n.Logln("postprocess.failed", dest, err)
 	}
+	return true
 }
@@ -3,9 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -13,17 +13,31 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"time"
 )
 
 type Berkeley struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewBerkeley() *Berkeley {
+func init() {
+	addNormalizer(normalizer{
+		site:      "berkeley",
+		canonical: "https://digicoll.lib.berkeley.edu/record/%s",
+		shapes: []urlShape{
+			// record/74092?ln=en, record/74092/files/..., record/74092/export/xm
+			shape(`^digicoll\.lib\.berkeley\.edu/record/(\d+)`),
+		},
+	})
+}
+
+func NewBerkeley(ctx context.Context) *Berkeley {
 	return &Berkeley{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -46,6 +60,7 @@ func (r *Berkeley) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -62,14 +77,14 @@ func (r *Berkeley) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Berkeley) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	canvases, err := r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil || canvases == nil {
 		return "requested URL was not found.", err
 	}
-	log.Printf(" %d files \n", len(canvases))
+	log.Println(i18n.N("count.files", len(canvases), len(canvases)))
 	r.do(canvases)
 	return "", nil
 }
@@ -82,7 +97,7 @@ func (r *Berkeley) do(canvases []string) (msg string, err error) {
 	referer := r.dt.Url
 	size := len(canvases)
 	for i, dUrl := range canvases {
-		if dUrl == "" || !config.PageRange(i, size) {
+		if dUrl == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -92,8 +107,8 @@ func (r *Berkeley) do(canvases []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d,  URL: %s\n", i+1, size, dUrl)
-		ctx := context.Background()
+		i18n.Logln("get.page", i+1, size, dUrl)
+		ctx := r.ctx
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -106,6 +121,7 @@ func (r *Berkeley) do(canvases []string) (msg string, err error) {
 				"Referer":    referer,
 			},
 		}
+		pagePlanned(r.ctx)
 		gohttp.FastGet(ctx, dUrl, opts)
 		fmt.Println()
 	}
@@ -120,15 +136,15 @@ func (r *Berkeley) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string
 func (r *Berkeley) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/api/v1/file?recid=" + r.dt.BookId +
-		"&file_types=%5B%5D&hidden_types=%5B%22pdf%3Bpdfa%22%2C%22hocr%22%5D&ln=en&hr=1&_=" + string(time.Now().Unix())
-	bs, err := r.getBody(apiUrl, jar)
+		"&file_types=%5B%5D&hidden_types=%5B%22pdf%3Bpdfa%22%2C%22hocr%22%5D&ln=en&hr=1&_=" + strconv.FormatInt(time.Now().Unix(), 10)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
 
 	var resT = make([]BerkeleyResponse, 0, 64)
 	if err = json.Unmarshal(bs, &resT); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, ret := range resT {
@@ -136,25 +152,3 @@ func (r *Berkeley) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []stri
 	}
 	return
 }
-
-func (r *Berkeley) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
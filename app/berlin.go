@@ -3,14 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/iiif"
-	"bookget/pkg/chttp"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -39,8 +37,20 @@ type Berlin struct {
 	bookId    string
 }
 
-func NewBerlin() *Berlin {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "sbb",
+		canonical: "https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=%s",
+		shapes: []urlShape{
+			shape(`^digital\.staatsbibliothek-berlin\.de/werkansicht/?\?(?:.*&)?PPN=(?:PPN)?([0-9]+X?)`, prefixed("PPN")),
+			// The IIIF manifest
+			shape(`^content\.staatsbibliothek-berlin\.de/dc/PPN([0-9]+X?)/manifest`, prefixed("PPN")),
+		},
+	})
+}
+
+func NewBerlin(ctx context.Context) *Berlin {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -49,7 +59,7 @@ func NewBerlin() *Berlin {
 	return &Berlin{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -78,10 +88,11 @@ func (r *Berlin) getBookId(sUrl string) (bookId string) {
 
 func (r *Berlin) Run() (err error) {
 	r.bookId = r.getBookId(r.rawUrl)
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	r.urlsFile = path.Join(r.savePath, "urls.txt")
 
 	apiUrl := fmt.Sprintf("https://content.staatsbibliothek-berlin.de/dc/%s/manifest", r.bookId)
@@ -113,7 +124,7 @@ func (r *Berlin) do(canvases []string) (err error) {
 	// 创建下载器实例
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -130,7 +141,8 @@ func (r *Berlin) do(canvases []string) (err error) {
 		r.bufBuilder.Write(r.bufBody)
 		r.bufBuilder.WriteString("\n")
 
-		log.Printf("Get %d/%d  %s\n", i+1, size, dziUrl)
+		i18n.Logln("get.page", i+1, size, dziUrl)
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, dziUrl, dest, args)
 	}
 	return nil
@@ -143,7 +155,7 @@ func (r *Berlin) getCanvases(sUrl string) (canvases []string, err error) {
 	}
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -164,43 +176,5 @@ func (r *Berlin) getCanvases(sUrl string) (canvases []string, err error) {
 }
 
 func (r *Berlin) getBody(rawUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", rawUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
-}
-
-func (r *Berlin) postBody(rawUrl string, postData interface{}) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	return clientSession(r.ctx, r.client).Get(rawUrl)
 }
@@ -0,0 +1,41 @@
+package app
+
+import "strings"
+
+// bookIder is implemented by the adapters that read the book ID from the URL
+type bookIder interface {
+	getBookId(sUrl string) (bookId string)
+}
+
+// BookIdOf returns the book ID adapter would use for sUrl, without going online.
+// ok is false when the adapter finds the ID only in the page it downloads.
+func BookIdOf(adapter interface{}, sUrl string) (bookId string, ok bool) {
+	switch r := adapter.(type) {
+	case *HannomNlv, *Huawen, *ImageDownloader:
+		// 从网页里取，或没有 ID
+		return "", false
+	case *SiEdu:
+		if strings.Contains(sUrl, "/object/") && !strings.Contains(sUrl, "manifest/") {
+			return "", false
+		}
+		return r.getBookId(sUrl), true
+	case *Waseda, *DziCnLib:
+		return getBookId(sUrl), true
+	case *Keio:
+		bookId, _ = r.getBookId(sUrl)
+		return bookId, true
+	case *Cuhk:
+		return (&Cuhk{rawUrl: sUrl}).getBookId(), true
+	case *Gzlib:
+		return (&Gzlib{rawUrl: sUrl}).getBookId(), true
+	case *Loc:
+		return (&Loc{rawUrl: sUrl}).getBookId(), true
+	case *Sdlib:
+		return (&Sdlib{rawUrl: sUrl}).getBookId(sUrl), true
+	case *NlcGuji:
+		return (&NlcGuji{rawUrl: sUrl}).getBookId(), true
+	case bookIder:
+		return r.getBookId(sUrl), true
+	}
+	return "", false
+}
@@ -4,10 +4,10 @@ import (
 	"bookget/config"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -55,11 +55,11 @@ type CafaEdu struct {
 	ctx       context.Context
 }
 
-func NewCafaEdu() *CafaEdu {
+func NewCafaEdu(ctx context.Context) *CafaEdu {
 	return &CafaEdu{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -75,6 +75,7 @@ func (r *CafaEdu) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -92,7 +93,7 @@ func (r *CafaEdu) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *CafaEdu) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -101,14 +102,14 @@ func (r *CafaEdu) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			r.dt.SavePath = config.Conf.Directory
+			r.dt.SavePath = bookDir(r.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = CreateDirectory(vid)
+			r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		}
 
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
@@ -116,7 +117,7 @@ func (r *CafaEdu) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -142,13 +143,13 @@ func (r *CafaEdu) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 }
 
 func (r *CafaEdu) getCanvases(apiUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
 	var manifest = new(CafaEduResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	canvases = make([]string, 0, len(manifest.Item.Tiles))
@@ -168,35 +169,8 @@ func (r *CafaEdu) getCanvases(apiUrl string, jar *cookiejar.Jar) (canvases []str
 	return canvases, nil
 }
 
-func (r *CafaEdu) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (r *CafaEdu) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
 func (r *CafaEdu) getMediaImageId(sUrl string, jar *cookiejar.Jar) (iiifId string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return "", err
 	}
@@ -212,7 +186,7 @@ func (r *CafaEdu) doDezoomify(iiifUrls []string) bool {
 	if iiifUrls == nil {
 		return false
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	args := []string{
 		"-H", "Origin:" + referer,
 		"-H", "Referer:" + referer,
@@ -221,7 +195,7 @@ func (r *CafaEdu) doDezoomify(iiifUrls []string) bool {
 	// 创建下载器实例
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -230,7 +204,8 @@ func (r *CafaEdu) doDezoomify(iiifUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, uri, dest, args)
 	}
 	return true
@@ -245,7 +220,7 @@ func (r *CafaEdu) doNormal(imgUrls []string) bool {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -257,11 +232,12 @@ func (r *CafaEdu) doNormal(imgUrls []string) bool {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
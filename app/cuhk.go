@@ -3,7 +3,7 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/cuhk"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/sharedmemory"
 	"bookget/pkg/util"
@@ -11,7 +11,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -40,15 +39,26 @@ type Cuhk struct {
 	bookId    string
 }
 
-func NewCuhk() *Cuhk {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "cuhk",
+		canonical: "https://repository.lib.cuhk.edu.hk/en/item/%s",
+		shapes: []urlShape{
+			shape(`^repository\.lib\.cuhk\.edu\.hk/(?:[a-z]{2}/)?item/cuhk-(\d+)`, prefixed("cuhk-")),
+			shape(`^repository\.lib\.cuhk\.edu\.hk/(?:[a-z]{2}/)?islandora/object/cuhk(?::|%3A)(\d+)`, prefixed("cuhk-")),
+		},
+	})
+}
+
+func NewCuhk(ctx context.Context) *Cuhk {
+	ctx, cancel := context.WithCancel(ctx)
 
 	// 创建自定义 Transport 忽略 SSL 验证
 	tr := NewHttpTransport()
 	jar, _ := cookiejar.New(nil)
 	return &Cuhk{
 		// 初始化字段
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -96,15 +106,16 @@ func (r *Cuhk) getBookId() string {
 
 func (r *Cuhk) Run() (msg string, err error) {
 	r.bookId = r.getBookId()
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "[err=getBookId]", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 
 	if util.OpenWebBrowser([]string{"-i", r.rawUrl}) {
-		fmt.Println("已启动 bookget-gui 浏览器，请注意完成「真人验证」。")
+		i18n.Println("gui.started")
 		for i := 0; i < 10; i++ {
-			fmt.Printf("等待 bookget-gui 加载完成，还有 %d 秒 \r", 10-i)
+			fmt.Print(i18n.N("gui.wait", 10-i, 10-i) + " \r")
 			time.Sleep(time.Second * 1)
 		}
 	}
@@ -115,13 +126,14 @@ func (r *Cuhk) Run() (msg string, err error) {
 		return "", err
 	}
 
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	r.urlsFile = path.Join(r.savePath, "urls.txt")
 	err = os.WriteFile(r.urlsFile, []byte(r.bufBuilder.String()), os.ModePerm)
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("\n已生成图片URLs文件[%s]\n 可复制到 bookget-gui.exe 目录下，或使用其它软件下载。\n", r.urlsFile)
+	fmt.Println()
+	i18n.Println("gui.urls_file", r.urlsFile)
 
 	r.do(r.canvases)
 	return "", nil
@@ -130,9 +142,9 @@ func (r *Cuhk) Run() (msg string, err error) {
 func (r *Cuhk) do(canvases []string) (msg string, err error) {
 	fmt.Println()
 	sizeVol := len(canvases)
-	bar := progressbar.Default(int64(sizeVol), "downloading")
+	bar := progressbar.Default(int64(sizeVol), i18n.T("download.progress"))
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, sizeVol) {
+		if uri == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			bar.Add(1)
 			continue
 		}
@@ -143,6 +155,7 @@ func (r *Cuhk) do(canvases []string) (msg string, err error) {
 			bar.Add(1)
 			continue
 		}
+		pagePlanned(r.ctx)
 		ok, err := r.imageDownloader(uri, targetFilePath)
 		if err == nil && ok {
 			bar.Add(1)
@@ -184,7 +197,7 @@ func (r *Cuhk) getCanvases(sUrl string) (canvases []string, err error) {
 	}
 	data := []byte("{\"pages\":" + string(matches[1]) + "]}")
 	if err = json.Unmarshal(data, &resp); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 	}
 	for _, page := range resp.ImagePage {
 		var imgUrl string
@@ -204,7 +217,7 @@ func (r *Cuhk) getCanvases(sUrl string) (canvases []string, err error) {
 func (r *Cuhk) getBodyByGui(apiUrl string) (bs []byte, err error) {
 	err = sharedmemory.WriteURLToSharedMemory(apiUrl)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -220,7 +233,7 @@ func (r *Cuhk) getBodyByGui(apiUrl string) (bs []byte, err error) {
 func (r *Cuhk) imageDownloader(imgUrl, targetFilePath string) (ok bool, err error) {
 	err = sharedmemory.WriteURLImagePathToSharedMemory(imgUrl, targetFilePath)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -233,25 +246,3 @@ func (r *Cuhk) imageDownloader(imgUrl, targetFilePath string) (ok bool, err erro
 	}
 	return ok, nil
 }
-
-func (r *Cuhk) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() == 202 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
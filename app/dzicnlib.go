@@ -4,13 +4,11 @@ import (
 	"bookget/config"
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -32,11 +30,11 @@ type DziCnLib struct {
 	Canvases map[int]string
 }
 
-func NewDziCnLib() *DziCnLib {
+func NewDziCnLib(ctx context.Context) *DziCnLib {
 	return &DziCnLib{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -56,6 +54,7 @@ func (r DziCnLib) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -64,13 +63,13 @@ func (r DziCnLib) Run(sUrl string) (msg string, err error) {
 }
 
 func (r DziCnLib) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	r.ServerUrl = r.getServerUri()
 	if r.ServerUrl == "" {
 		return "requested URL was not found.", err
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	r.Canvases, err = r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err.Error())
@@ -82,7 +81,7 @@ func (r DziCnLib) download() (msg string, err error) {
 func (r DziCnLib) dezoomify() (msg string, err error) {
 
 	storePath := r.dt.SavePath
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -103,7 +102,7 @@ func (r DziCnLib) dezoomify() (msg string, err error) {
 	}
 
 	for i, xml := range r.Canvases {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		target := path.Join(storePath, fmt.Sprintf("%04d", i+1)+config.Conf.FileExt)
@@ -111,6 +110,7 @@ func (r DziCnLib) dezoomify() (msg string, err error) {
 			continue
 		}
 
+		pagePlanned(r.ctx)
 		err = iiifDownloader.DezoomifyWithContent(r.ctx, xml, target, args)
 		if err != nil {
 			return "[err=iiifDownloader.Dezoomify]", err
@@ -127,7 +127,7 @@ func (r DziCnLib) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 
 func (r DziCnLib) getCanvases(apiUrl string, jar *cookiejar.Jar) (map[int]string, error) {
 	//apiUrl := fmt.Sprintf("%s/tiles/infos.json", r.ServerHost)
-	bs, err := r.getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -182,25 +182,3 @@ func (r DziCnLib) getCanvases(apiUrl string, jar *cookiejar.Jar) (map[int]string
 func (r DziCnLib) getServerUri() string {
 	return strings.Split(r.dt.Url, "/tiles/")[0]
 }
-
-func (r DziCnLib) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() == 202 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
@@ -5,10 +5,10 @@ import (
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -24,11 +24,11 @@ type Emuseum struct {
 	ctx context.Context
 }
 
-func NewEmuseum() *Emuseum {
+func NewEmuseum(ctx context.Context) *Emuseum {
 	return &Emuseum{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -44,6 +44,7 @@ func (d *Emuseum) Run(sUrl string) (msg string, err error) {
 	d.dt.UrlParsed, err = url.Parse(sUrl)
 	d.dt.Url = sUrl
 	d.dt.BookId = d.getBookId(d.dt.Url)
+	setBookId(d.ctx, d.dt.BookId)
 	if d.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -63,7 +64,7 @@ func (d *Emuseum) getBookId(sUrl string) (bookId string) {
 }
 
 func (d *Emuseum) download() (msg string, err error) {
-	log.Printf("Get %s\n", d.dt.Url)
+	i18n.Logln("get", d.dt.Url)
 
 	respVolume, err := d.getVolumes(d.dt.Url, d.dt.Jar)
 	if err != nil {
@@ -72,14 +73,14 @@ func (d *Emuseum) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(d.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			d.dt.SavePath = config.Conf.Directory
+			d.dt.SavePath = bookDir(d.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			d.dt.SavePath = CreateDirectory(vid)
+			d.dt.SavePath = CreateDirectory(d.ctx, vid)
 		}
 
 		canvases, err := d.getCanvases(vol, d.dt.Jar)
@@ -87,7 +88,7 @@ func (d *Emuseum) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		d.do(canvases)
 	}
 	return "", nil
@@ -103,7 +104,7 @@ func (d *Emuseum) do(imgUrls []string) (msg string, err error) {
 }
 
 func (d *Emuseum) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := d.getBody(sUrl, jar)
+	bs, err := sessionFor(d.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -117,13 +118,13 @@ func (d *Emuseum) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 }
 
 func (d *Emuseum) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := d.getBody(sUrl, jar)
+	bs, err := sessionFor(d.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -152,33 +153,11 @@ func (d *Emuseum) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []strin
 
 }
 
-func (d *Emuseum) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
 func (d *Emuseum) doDezoomify(iiifUrls []string) bool {
 	if iiifUrls == nil {
 		return false
 	}
-	referer := url.QueryEscape(d.dt.Url)
+	referer := d.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -187,7 +166,7 @@ func (d *Emuseum) doDezoomify(iiifUrls []string) bool {
 	size := len(iiifUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(d.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -196,7 +175,8 @@ func (d *Emuseum) doDezoomify(iiifUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
+		pagePlanned(d.ctx)
 		iiifDownloader.Dezoomify(d.ctx, uri, dest, args)
 	}
 	return true
@@ -211,7 +191,7 @@ func (d *Emuseum) doNormal(imgUrls []string) bool {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(d.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -223,11 +203,12 @@ func (d *Emuseum) doNormal(imgUrls []string) bool {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(d.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := d.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bookget/config"
+	"bookget/pkg/ebook"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
+	"bookget/pkg/mets"
+	"context"
+	"strings"
+)
+
+// exportEnabled reports whether --export lists format
+func exportEnabled(format string) bool {
+	for _, v := range strings.Split(config.Conf.Export, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), format) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExportBook writes the files selected with --export for the book of ctx just
+// downloaded from sUrl. Adapters may describe the book in their router result
+// with the keys "bookId", "title" and "metadata" ([]mets.Field); without them
+// the book is described by the id its adapter read and its URL.
+func ExportBook(ctx context.Context, sUrl string, result map[string]interface{}) {
+	if config.Conf.Export == "" {
+		return
+	}
+	book := &mets.Book{
+		ID:  gohttp.BookOf(ctx).ID,
+		URL: sUrl,
+		Dir: bookDir(ctx),
+	}
+	if v, ok := result["bookId"].(string); ok && v != "" {
+		book.ID = v
+	}
+	if book.ID == "" {
+		book.ID = getBookId(sUrl)
+	}
+	if v, ok := result["title"].(string); ok {
+		book.Title = strings.TrimSpace(v)
+	}
+	if book.Title == "" {
+		book.Title = book.ID
+	}
+	if v, ok := result["metadata"].([]mets.Field); ok {
+		book.Metadata = v
+	}
+	if len(book.Metadata) == 0 {
+		book.Metadata = []mets.Field{{Label: "Identifier", Value: book.ID}, {Label: "URL", Value: sUrl}}
+	}
+	// Only METS records checksums, hashing every page is slow on big books
+	scan := book.ScanPages
+	if exportEnabled("mets") {
+		scan = book.Scan
+	}
+	if err := scan(); err != nil {
+		i18n.Logln("export.failed", err)
+		return
+	}
+
+	if exportEnabled("mets") {
+		dest, err := mets.Write(book)
+		logExport("mets", []string{dest}, err)
+	}
+	progression := config.Conf.PageProgression
+	if v := config.SiteValue(hostOf(sUrl), "page_progression"); v != "" {
+		progression = v
+	}
+	opt := ebook.Options{RightToLeft: strings.EqualFold(progression, "rtl")}
+	if exportEnabled("cbz") {
+		outputs, err := ebook.WriteCBZ(book, opt)
+		logExport("cbz", outputs, err)
+	}
+	if exportEnabled("epub") {
+		outputs, err := ebook.WriteEPUB(book, opt)
+		logExport("epub", outputs, err)
+	}
+}
+
+func logExport(format string, outputs []string, err error) {
+	for _, dest := range outputs {
+		if dest != "" {
+			i18n.Logln("export.written", format, dest)
+		}
+	}
+	if err != nil {
+		i18n.Logln("export.format_failed", format, err)
+	}
+}
@@ -3,16 +3,13 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/family"
-	"bookget/pkg/chttp"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -48,8 +45,19 @@ type Familysearch struct {
 	apiUrl      string
 }
 
-func NewFamilysearch() *Familysearch {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "familysearch",
+		canonical: "https://www.familysearch.org/ark:/61903/%s",
+		shapes: []urlShape{
+			// ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3?i=5&cat=1234, also under a language prefix
+			shape(`^(?:www\.)?familysearch\.org/(?:[a-z]{2}/)?ark:/61903/(3:1:[0-9A-Z-]+)`),
+		},
+	})
+}
+
+func NewFamilysearch(ctx context.Context) *Familysearch {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -59,7 +67,7 @@ func NewFamilysearch() *Familysearch {
 	return &Familysearch{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -103,14 +111,15 @@ func (r *Familysearch) getBaseUrl(sUrl string) (string, string, error) {
 
 func (r *Familysearch) Run() (msg string, err error) {
 	r.bookId = r.getBookId(r.rawUrl)
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "requested URL was not found.", err
 	}
 	if os.PathSeparator == '\\' {
 		if util.OpenWebBrowser([]string{"-i", r.rawUrl}) {
-			fmt.Println("已启动 bookget-gui 浏览器，请注意完成「真人验证」或「账号登录」。")
+			i18n.Println("gui.started_login")
 			for i := 0; i < 10; i++ {
-				fmt.Printf("等待 bookget-gui 加载完成，还有 %d 秒 \r", 10-i)
+				fmt.Print(i18n.N("gui.wait", 10-i, 10-i) + " \r")
 				time.Sleep(time.Second * 1)
 			}
 		}
@@ -129,7 +138,7 @@ func (r *Familysearch) Run() (msg string, err error) {
 	if err != nil || r.canvases == nil {
 		return "", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	r.urlsFile = path.Join(r.savePath, "urls.txt")
 	err = os.WriteFile(r.urlsFile, []byte(r.bufBuilder.String()), os.ModePerm)
 	if err != nil {
@@ -145,7 +154,7 @@ func (r *Familysearch) do(canvases []string) (err error) {
 	if sizeVol <= 0 {
 		return errors.New("[err=do]")
 	}
-	referer := url.QueryEscape(r.rawUrl)
+	referer := r.rawUrl
 	sid := r.getSessionId()
 	args := []string{
 		"-H", "authority:www.familysearch.org",
@@ -162,7 +171,7 @@ func (r *Familysearch) do(canvases []string) (err error) {
 		"ServerBaseURL": r.sgBaseUrl,
 	}
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, sizeVol) {
+		if uri == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -170,7 +179,8 @@ func (r *Familysearch) do(canvases []string) (err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, sizeVol, uri)
+		i18n.Logln("get.page", i+1, sizeVol, uri)
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, uri, dest, args)
 		util.PrintSleepTime(config.Conf.Sleep)
 	}
@@ -189,7 +199,7 @@ func (r *Familysearch) getImageData(sUrl string) (imageData family.ImageData, er
 
 	bs, err := r.postBody(sUrl, data)
 	if err != nil {
-		fmt.Println("请求失败，cookie 可能已失效。")
+		i18n.Println("cookie.expired")
 		return
 	}
 	var resultError family.ResultError
@@ -266,122 +276,38 @@ func (r *Familysearch) getCanvases(sUrl string, imageData family.ImageData) (can
 }
 
 func (r *Familysearch) getSessionId() string {
-	cookies, err := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if err != nil {
+	if r.parsedUrl == nil {
 		return ""
 	}
 	//fssessionid=e10ce618-f7f7-45de-b2c3-d1a31d080d58-prod;
-	m := regexp.MustCompile(`fssessionid=([^;]+);`).FindStringSubmatch(cookies)
-	if m != nil {
-		return "bearer " + m[1]
+	for _, c := range r.client.Jar.Cookies(r.parsedUrl) {
+		if c.Name == "fssessionid" {
+			return "bearer " + c.Value
+		}
 	}
 	return ""
 }
 
 func (r *Familysearch) getBody(sUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	req.Header.Set("accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
-	req.Header.Set("authority", "www.familysearch.org")
-	req.Header.Set("origin", r.baseUrl)
-	req.Header.Set("referer", r.rawUrl)
-
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-		//sid := r.getSessionId(cookies)
-		//req.Header.Set("authorization", sid)
-	}
-
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Do(http.MethodGet, sUrl, nil, http.Header{
+		"Accept":    {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7"},
+		"Authority": {"www.familysearch.org"},
+		"Origin":    {r.baseUrl},
+		"Referer":   {r.rawUrl},
+	})
 }
 
 func (r *Familysearch) postBody(sUrl string, postData interface{}) ([]byte, error) {
-	var bodyData []byte
-	var err error
-
-	var isJSON = false
-	// 根据传入的数据类型处理
-	switch v := postData.(type) {
-	case []byte:
-		bodyData = v // 直接使用 []byte
-	case string:
-		bodyData = []byte(v) // 将 string 转为 []byte
-	default:
-		// 其他类型尝试转为 JSON
-		bodyData, err = json.Marshal(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
+	return clientSession(r.ctx, r.client).PostWith(sUrl, postData, func(h http.Header, isJSON bool) {
+		if isJSON {
+			h.Set("Accept", "application/json, text/plain, */*")
 		}
-		isJSON = true
-	}
-	req, err := http.NewRequest("POST", sUrl, bytes.NewBuffer(bodyData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	// 设置请求头
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	if isJSON {
-		req.Header.Set("accept", "application/json, text/plain, */*")
-		req.Header.Set("Content-Type", "application/json")
-	} else {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
-	req.Header.Set("authority", "www.familysearch.org")
-	req.Header.Set("origin", r.baseUrl)
-	req.Header.Set("referer", r.rawUrl)
-
-	// 添加cookie
-	cookies, err := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if err == nil && cookies != "" {
-		req.Header.Set("Cookie", cookies)
-		sid := r.getSessionId()
-		req.Header.Set("authorization", sid)
-	}
-
-	// 发送请求
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("warning: failed to close response body: %v", err)
+		h.Set("Authority", "www.familysearch.org")
+		h.Set("Origin", r.baseUrl)
+		h.Set("Referer", r.rawUrl)
+		// 会话 cookie 同时用作 authorization
+		if sid := r.getSessionId(); sid != "" {
+			h.Set("Authorization", sid)
 		}
-	}()
-
-	// 检查响应状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	return body, nil
+	})
 }
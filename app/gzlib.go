@@ -2,12 +2,9 @@ package app
 
 import (
 	"bookget/config"
-	"bookget/pkg/chttp"
 	"bookget/pkg/downloader"
 	"context"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -27,8 +24,8 @@ type Gzlib struct {
 	bookId    string
 }
 
-func NewGzlib() *Gzlib {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewGzlib(ctx context.Context) *Gzlib {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -37,7 +34,7 @@ func NewGzlib() *Gzlib {
 	return &Gzlib{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -63,10 +60,11 @@ func (r *Gzlib) getBookId() (bookId string) {
 
 func (r *Gzlib) Run() (msg string, err error) {
 	r.bookId = r.getBookId()
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "[err=getBookId]", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 
 	apiUrl := fmt.Sprintf("https://%s/attach/GZDD/Attach/%s.pdf", r.parsedUrl.Hostname(), r.bookId)
 	fileName := fmt.Sprintf("%s.pdf", r.bookId)
@@ -89,43 +87,8 @@ func (r *Gzlib) Run() (msg string, err error) {
 }
 
 func (r *Gzlib) getBody(sUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	req.Header.Set("Origin", "https://"+r.parsedUrl.Host)
-	req.Header.Set("Referer", r.rawUrl)
-
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Do(http.MethodGet, sUrl, nil, http.Header{
+		"Origin":  {"https://" + r.parsedUrl.Host},
+		"Referer": {r.rawUrl},
+	})
 }
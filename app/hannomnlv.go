@@ -3,6 +3,7 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"errors"
 	"fmt"
@@ -16,13 +17,15 @@ import (
 
 type HannomNlv struct {
 	dt   *DownloadTask
+	ctx  context.Context
 	body []byte
 }
 
-func NewHannomNlv() *HannomNlv {
+func NewHannomNlv(ctx context.Context) *HannomNlv {
 	return &HannomNlv{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -39,6 +42,7 @@ func (r *HannomNlv) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 	r.dt.Jar, _ = cookiejar.New(nil)
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -47,7 +51,7 @@ func (r *HannomNlv) Run(sUrl string) (msg string, err error) {
 
 func (r *HannomNlv) getBookId(sUrl string) (bookId string) {
 	var err error
-	r.body, err = getBody(sUrl, r.dt.Jar)
+	r.body, err = sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 	if err != nil {
 		return ""
 	}
@@ -59,13 +63,13 @@ func (r *HannomNlv) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *HannomNlv) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
-	r.dt.SavePath = config.Conf.Directory
+	i18n.Logln("get", r.dt.Url)
+	r.dt.SavePath = bookDir(r.ctx)
 	canvases, err := r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil || canvases == nil {
 		fmt.Println(err)
 	}
-	log.Printf(" %d pages \n", len(canvases))
+	log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 	r.do(canvases)
 	return "", nil
 }
@@ -79,7 +83,7 @@ func (r *HannomNlv) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -90,11 +94,12 @@ func (r *HannomNlv) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -138,13 +143,3 @@ func (r *HannomNlv) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []str
 	}
 	return canvases, err
 }
-
-func (r *HannomNlv) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (r *HannomNlv) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
@@ -3,8 +3,8 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/iiif"
-	"bookget/pkg/chttp"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/sharedmemory"
 	"bookget/pkg/util"
@@ -13,8 +13,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -44,8 +42,20 @@ type Harvard struct {
 	bookId    string
 }
 
-func NewHarvard() *Harvard {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "harvard",
+		canonical: "https://iiif.lib.harvard.edu/manifests/view/%s",
+		shapes: []urlShape{
+			// manifests/view/drs:53262215$5i is page 5 of the viewer
+			shape(`^iiif\.lib\.harvard\.edu/manifests/(?:view/)?(drs:\d+)`),
+			shape(`^listview\.lib\.harvard\.edu/lists/drs-(\d+)`, prefixed("drs:")),
+		},
+	})
+}
+
+func NewHarvard(ctx context.Context) *Harvard {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -54,7 +64,7 @@ func NewHarvard() *Harvard {
 	return &Harvard{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -85,15 +95,16 @@ func (r *Harvard) getBookId(sUrl string) (bookId string) {
 
 func (r *Harvard) Run() (msg string, err error) {
 	r.bookId = r.getBookId(r.rawUrl)
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "requested URL was not found.", err
 	}
 
 	if os.PathSeparator == '\\' {
 		if util.OpenWebBrowser([]string{"-i", r.rawUrl}) {
-			fmt.Println("已启动 bookget-gui 浏览器，请注意完成「真人验证」。")
+			i18n.Println("gui.started")
 			for i := 0; i < 10; i++ {
-				fmt.Printf("等待 bookget-gui 加载完成，还有 %d 秒 \r", 10-i)
+				fmt.Print(i18n.N("gui.wait", 10-i, 10-i) + " \r")
 				time.Sleep(time.Second * 1)
 			}
 		}
@@ -104,13 +115,14 @@ func (r *Harvard) Run() (msg string, err error) {
 	if err != nil || r.canvases == nil {
 		return "", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	r.urlsFile = path.Join(r.savePath, "urls.txt")
 	err = os.WriteFile(r.urlsFile, []byte(r.bufBuilder.String()), os.ModePerm)
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("\n已生成图片URLs文件[%s]\n 可复制到 bookget-gui.exe 目录下，或使用其它软件下载。\n", r.urlsFile)
+	fmt.Println()
+	i18n.Println("gui.urls_file", r.urlsFile)
 
 	r.do(r.canvases)
 	return "", nil
@@ -142,7 +154,7 @@ func (r *Harvard) getCanvases() (canvases []string, err error) {
 
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(r.bufBody, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -174,10 +186,10 @@ func (r *Harvard) doDezoomify(canvases []string) (err error) {
 	if sizeVol <= 0 {
 		return errors.New("[err=doByGUI]")
 	}
-	referer := url.QueryEscape(r.rawUrl)
+	referer := r.rawUrl
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, sizeVol) {
+		if uri == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -186,12 +198,13 @@ func (r *Harvard) doDezoomify(canvases []string) (err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, sizeVol, uri)
+		i18n.Logln("get.page", i+1, sizeVol, uri)
 
 		args := []string{
 			"-H", "Origin:" + referer,
 			"-H", "Referer:" + referer,
 		}
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, uri, dest, args)
 	}
 	return nil
@@ -205,7 +218,7 @@ func (r *Harvard) doNormal(canvases []string) (err error) {
 	fmt.Println()
 	counter := 0
 	for i, imgUrl := range canvases {
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		ext := util.FileExt(imgUrl)
@@ -240,13 +253,13 @@ func (r *Harvard) doByGUI(canvases []string) (err error) {
 		return errors.New("[err=doByGUI]")
 	}
 	fmt.Println()
-	bar := progressbar.Default(int64(sizeVol), "downloading")
+	bar := progressbar.Default(int64(sizeVol), i18n.T("download.progress"))
 	for i, imgUrl := range canvases {
 		i++
 		sortId := fmt.Sprintf("%04d", i)
 		fileName := sortId + config.Conf.FileExt
 
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			bar.Add(1)
 			continue
 		}
@@ -257,6 +270,7 @@ func (r *Harvard) doByGUI(canvases []string) (err error) {
 			continue
 		}
 
+		pagePlanned(r.ctx)
 		ok, err := r.imageDownloader(imgUrl, targetFilePath)
 		if err == nil && ok {
 			bar.Add(1)
@@ -276,7 +290,7 @@ func (r *Harvard) tryGetBody(sUrl string) (bs []byte, err error) {
 func (r *Harvard) getBodyByGui(apiUrl string) (bs []byte, err error) {
 	err = sharedmemory.WriteURLToSharedMemory(apiUrl)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -293,7 +307,7 @@ func (r *Harvard) getBodyByGui(apiUrl string) (bs []byte, err error) {
 func (r *Harvard) imageDownloader(imgUrl, targetFilePath string) (ok bool, err error) {
 	err = sharedmemory.WriteURLImagePathToSharedMemory(imgUrl, targetFilePath)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -308,95 +322,9 @@ func (r *Harvard) imageDownloader(imgUrl, targetFilePath string) (ok bool, err e
 }
 
 func (r *Harvard) getBody(sUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Get(sUrl)
 }
 
 func (r *Harvard) postBody(sUrl string, postData interface{}) ([]byte, error) {
-	var bodyData []byte
-	var err error
-
-	var isJSON = false
-	// 根据传入的数据类型处理
-	switch v := postData.(type) {
-	case []byte:
-		bodyData = v // 直接使用 []byte
-	case string:
-		bodyData = []byte(v) // 将 string 转为 []byte
-	default:
-		// 其他类型尝试转为 JSON
-		bodyData, err = json.Marshal(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
-		}
-		isJSON = true
-	}
-	req, err := http.NewRequest("POST", sUrl, bytes.NewBuffer(bodyData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	// 设置请求头
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	if isJSON {
-		req.Header.Set("Content-Type", "application/json")
-	} else {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
-	// 添加cookie
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-
-	// 发送请求
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %v", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("warning: failed to close response body: %v", err)
-		}
-	}()
-
-	// 检查响应状态码
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	return body, nil
+	return clientSession(r.ctx, r.client).Post(sUrl, postData)
 }
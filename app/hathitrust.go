@@ -3,12 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -17,13 +17,28 @@ import (
 )
 
 type Hathitrust struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewHathitrust() *Hathitrust {
+func init() {
+	addNormalizer(normalizer{
+		site:      "hathitrust",
+		canonical: "https://babel.hathitrust.org/cgi/pt?id=%s",
+		shapes: []urlShape{
+			// pt?id=uc1.b3656924&seq=7, the older pt?id=uc1.b3656924;view=1up;seq=7, pt/search?q1=x;id=...
+			shape(`^babel\.hathitrust\.org/cgi/pt(?:/search)?\?(?:.*[&;])?id=([^&;]+)`, unescaped),
+			// Permalink, also for ark: IDs such as uc2.ark:/13960/t0ns0pf9x
+			shape(`^hdl\.handle\.net/2027/([^?]+)`, unescaped),
+		},
+	})
+}
+
+func NewHathitrust(ctx context.Context) *Hathitrust {
 	return &Hathitrust{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -39,6 +54,7 @@ func (r Hathitrust) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -47,7 +63,7 @@ func (r Hathitrust) Run(sUrl string) (msg string, err error) {
 }
 
 func (r Hathitrust) getBookId(sUrl string) (bookId string) {
-	m := regexp.MustCompile(`id=([^&]+)`).FindStringSubmatch(sUrl)
+	m := regexp.MustCompile(`id=([^&;]+)`).FindStringSubmatch(sUrl)
 	if m != nil {
 		bookId = m[1]
 	}
@@ -55,13 +71,13 @@ func (r Hathitrust) getBookId(sUrl string) (bookId string) {
 }
 
 func (r Hathitrust) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	canvases, err := r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err.Error())
 		return "requested URL was not found.", err
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	msg, err = r.do(canvases)
 	return msg, err
 }
@@ -71,10 +87,10 @@ func (r Hathitrust) do(imgUrls []string) (msg string, err error) {
 		return
 	}
 	fmt.Println()
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	size := len(imgUrls)
 	for i, uri := range imgUrls {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		if uri == "" {
@@ -86,7 +102,7 @@ func (r Hathitrust) do(imgUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -99,7 +115,8 @@ func (r Hathitrust) do(imgUrls []string) (msg string, err error) {
 				"Referer":    referer,
 			},
 		}
-		ctx := context.Background()
+		ctx := r.ctx
+		pagePlanned(r.ctx)
 		for {
 			_, err := gohttp.FastGet(ctx, uri, opts)
 			if err != nil {
@@ -121,7 +138,7 @@ func (r Hathitrust) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []strin
 }
 
 func (r Hathitrust) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(r.dt.Url, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(r.dt.Url)
 	if err != nil || bs == nil {
 		return nil, err
 	}
@@ -150,24 +167,3 @@ func (r Hathitrust) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []str
 	}
 	return canvases, err
 }
-
-func (r Hathitrust) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		err = errors.New(resp.GetReasonPhrase())
-		return nil, err
-	}
-	return bs, nil
-}
@@ -4,10 +4,10 @@ import (
 	"bookget/config"
 	"bookget/model/iiif"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -19,13 +19,25 @@ import (
 
 type Hkulib struct {
 	dt     *DownloadTask
+	ctx    context.Context
 	apiUrl string
 }
 
-func NewHkulib() *Hkulib {
+func init() {
+	addNormalizer(normalizer{
+		site:      "hku",
+		canonical: "https://digitalrepository.lib.hku.hk/catalog/%s",
+		shapes: []urlShape{
+			shape(`^digitalrepository\.lib\.hku\.hk/catalog/([a-z0-9]+)`),
+		},
+	})
+}
+
+func NewHkulib(ctx context.Context) *Hkulib {
 	return &Hkulib{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -44,6 +56,7 @@ func (r *Hkulib) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -61,7 +74,7 @@ func (r *Hkulib) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Hkulib) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -69,17 +82,17 @@ func (r *Hkulib) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -90,11 +103,11 @@ func (r *Hkulib) do(imgUrls []string) (msg string, err error) {
 		return
 	}
 	fmt.Println()
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := r.ctx
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -103,7 +116,7 @@ func (r *Hkulib) do(imgUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -116,6 +129,7 @@ func (r *Hkulib) do(imgUrls []string) (msg string, err error) {
 				"Referer":    referer,
 			},
 		}
+		pagePlanned(r.ctx)
 		_, err := gohttp.FastGet(ctx, uri, opts)
 		if err != nil {
 			fmt.Println(err)
@@ -130,7 +144,7 @@ func (r *Hkulib) do(imgUrls []string) (msg string, err error) {
 }
 
 func (r *Hkulib) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -147,13 +161,13 @@ func (r *Hkulib) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 }
 
 func (r *Hkulib) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	var manifest = new(iiif.ManifestResponse)
 	if err != nil {
 		return nil, err
 	}
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -171,25 +185,3 @@ func (r *Hkulib) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
 	}
 	return canvases, nil
 }
-
-func (r *Hkulib) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() == 202 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
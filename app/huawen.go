@@ -3,11 +3,10 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
-	"errors"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -16,13 +15,25 @@ import (
 )
 
 type Huawen struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewHuawen() *Huawen {
+func init() {
+	addNormalizer(normalizer{
+		site:      "ncl",
+		canonical: "https://taiwanebook.ncl.edu.tw/zh-tw/book/%s/reader",
+		shapes: []urlShape{
+			shape(`^taiwanebook\.ncl\.edu\.tw/(?:[a-z]{2}(?:-[a-z]{2})?/)?book/([A-Z]+-\d+)`),
+		},
+	})
+}
+
+func NewHuawen(ctx context.Context) *Huawen {
 	return &Huawen{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -43,6 +54,7 @@ func (r *Huawen) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -56,7 +68,7 @@ func (r *Huawen) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Huawen) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -64,11 +76,11 @@ func (r *Huawen) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
-		r.dt.SavePath = config.Conf.Directory
-		log.Printf(" %d/%d PDFs \n", i+1, len(respVolume))
+		r.dt.SavePath = bookDir(r.ctx)
+		i18n.Logln("get.pdf", i+1, len(respVolume))
 		r.do(vol)
 	}
 	return "", nil
@@ -81,7 +93,7 @@ func (r *Huawen) do(pdfUrl string) (msg string, err error) {
 		return "", nil
 	}
 	u, err := url.Parse(pdfUrl)
-	ctx := context.Background()
+	ctx := r.ctx
 	opts := gohttp.Options{
 		DestFile:    dest,
 		Overwrite:   false,
@@ -104,7 +116,7 @@ func (r *Huawen) do(pdfUrl string) (msg string, err error) {
 }
 
 func (r *Huawen) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -127,25 +139,3 @@ func (r *Huawen) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
 	//TODO implement me
 	panic("implement me")
 }
-
-func (r *Huawen) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
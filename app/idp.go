@@ -3,9 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -16,13 +16,15 @@ import (
 
 type Idp struct {
 	dt  *DownloadTask
+	ctx context.Context
 	bar *progressbar.ProgressBar
 }
 
-func NewIdp() *Idp {
+func NewIdp(ctx context.Context) *Idp {
 	return &Idp{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -38,6 +40,7 @@ func (r *Idp) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -54,7 +57,7 @@ func (r *Idp) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Idp) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	canvases, err := r.getCanvases(r.dt.BookId, r.dt.Jar)
 	if err != nil || canvases == nil {
@@ -62,18 +65,19 @@ func (r *Idp) download() (msg string, err error) {
 		return "requested URL was not found.", err
 	}
 	//不按卷下载，所有图片存一个目录
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	sizeCanvases := len(canvases)
 	fmt.Println()
 	ext := ".jpg"
-	r.bar = progressbar.Default(int64(sizeCanvases), "downloading")
-	ctx := context.Background()
+	r.bar = progressbar.Default(int64(sizeCanvases), i18n.T("download.progress"))
+	ctx := r.ctx
 	for i, imgUrl := range canvases {
-		if !config.PageRange(i, sizeCanvases) || imgUrl == "" {
+		if !config.PageRange(r.ctx, i, sizeCanvases) || imgUrl == "" {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
 		dest := filepath.Join(r.dt.SavePath, sortId+ext)
+		pagePlanned(r.ctx)
 		cli := gohttp.NewClient(ctx, gohttp.Options{
 			DestFile:   dest,
 			CookieJar:  r.dt.Jar,
@@ -103,7 +107,7 @@ func (r *Idp) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err
 }
 
 func (r *Idp) getCanvases(sUrl string, jar *cookiejar.Jar) ([]string, error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -122,28 +126,3 @@ func (r *Idp) getCanvases(sUrl string, jar *cookiejar.Jar) ([]string, error) {
 	}
 	return canvases, nil
 }
-
-func (r *Idp) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (r *Idp) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
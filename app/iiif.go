@@ -5,18 +5,20 @@ import (
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
+	"bookget/pkg/mets"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/queue"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -28,20 +30,24 @@ type IIIF struct {
 	bookId     string
 }
 
-func NewIiifRouter() *IIIF {
+func NewIiifRouter(ctx context.Context) *IIIF {
 	return &IIIF{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
 func (i *IIIF) GetRouterInit(sUrl string) (map[string]interface{}, error) {
 	msg, err := i.Run(sUrl)
+	title, metadata := i.describe()
 	return map[string]interface{}{
-		"type": "iiif",
-		"url":  sUrl,
-		"msg":  msg,
+		"type":     "iiif",
+		"url":      sUrl,
+		"msg":      msg,
+		"bookId":   i.dt.BookId,
+		"title":    title,
+		"metadata": metadata,
 	}, err
 }
 
@@ -50,6 +56,7 @@ func (i *IIIF) Run(sUrl string) (msg string, err error) {
 	i.dt.Url = sUrl
 	i.dt.Jar, _ = cookiejar.New(nil)
 	i.dt.BookId = i.getBookId(i.dt.Url)
+	setBookId(i.ctx, i.dt.BookId)
 	if i.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -93,7 +100,7 @@ func (i *IIIF) download() (msg string, err error) {
 	if err != nil || canvases == nil {
 		return
 	}
-	i.dt.SavePath = config.Conf.Directory
+	i.dt.SavePath = bookDir(i.ctx)
 	return i.do(canvases)
 }
 
@@ -109,7 +116,7 @@ func (i *IIIF) do(imgUrls []string) (msg string, err error) {
 func (i *IIIF) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(i.xmlContent, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -136,7 +143,7 @@ func (i *IIIF) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 func (i *IIIF) getCanvasesV3(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	var manifest = new(iiif.ManifestV3Response)
 	if err = json.Unmarshal(i.xmlContent, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Canvases) == 0 {
@@ -165,34 +172,12 @@ func (i *IIIF) getCanvasesV3(sUrl string, jar *cookiejar.Jar) (canvases []string
 }
 
 func (i *IIIF) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(sUrl)
+	bs, err := sessionFor(i.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		err = errors.New(resp.GetReasonPhrase())
-		return nil, err
-	}
 	//fix bug https://www.dh-jac.net/db1/books/results-iiif.php?f1==nar-h13-01-01&f12=1&enter=portal
-	//delete '?'
-	if bs[0] != 123 {
-		for i := 0; i < len(bs); i++ {
-			if bs[i] == 123 {
-				bs = bs[i:]
-				break
-			}
-		}
-	}
-	return bs, nil
+	return trimToJSON(bs), nil
 }
 
 func (i *IIIF) doDezoomify(iiifUrls []string) bool {
@@ -208,7 +193,7 @@ func (i *IIIF) doDezoomify(iiifUrls []string) bool {
 }
 
 func (i *IIIF) doDezoomifySequential(iiifUrls []string) bool {
-	referer := url.QueryEscape(i.dt.Url)
+	referer := i.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -217,7 +202,7 @@ func (i *IIIF) doDezoomifySequential(iiifUrls []string) bool {
 	size := len(iiifUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for k, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", k+1)
@@ -227,11 +212,14 @@ func (i *IIIF) doDezoomifySequential(iiifUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", k+1, size, uri)
+		i18n.Logln("get.page", k+1, size, uri)
 
+		pagePlanned(i.ctx)
+		// The page is checked and post-processed once it is saved
 		err := iiifDownloader.Dezoomify(i.ctx, uri, dest, args)
 		if err != nil {
-			log.Printf("\nDezoomify failed: %s\n", err)
+			log.Printf("\n%s\n", i18n.T("dezoomify.failed", err))
+			continue
 		}
 
 	}
@@ -243,7 +231,7 @@ func (i *IIIF) doDezoomifyConcurrent(iiifUrls []string) bool {
 		return false
 	}
 	
-	referer := url.QueryEscape(i.dt.Url)
+	referer := i.dt.Url
 	args := []string{
 		"-H", "Origin:" + referer,
 		"-H", "Referer:" + referer,
@@ -253,7 +241,7 @@ func (i *IIIF) doDezoomifyConcurrent(iiifUrls []string) bool {
 	// Count valid pages to download
 	validPages := 0
 	for k, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", k+1)
@@ -269,14 +257,14 @@ func (i *IIIF) doDezoomifyConcurrent(iiifUrls []string) bool {
 	}
 	
 	// Create page-level progress bar
-	pageBar := progressbar.Default(int64(validPages), "downloading pages")
+	pageBar := progressbar.Default(int64(validPages), i18n.T("download.pages"))
 	
 	// Create concurrent queue with page-rate limit
 	q := queue.NewConcurrentQueue(config.Conf.PageRate)
 	var wg sync.WaitGroup
 	
 	for k, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		
@@ -288,6 +276,7 @@ func (i *IIIF) doDezoomifyConcurrent(iiifUrls []string) bool {
 			continue
 		}
 		
+		pagePlanned(i.ctx)
 		wg.Add(1)
 		// Capture variables for closure
 		pageURL := uri
@@ -299,8 +288,8 @@ func (i *IIIF) doDezoomifyConcurrent(iiifUrls []string) bool {
 			// Create a separate downloader instance for each goroutine to avoid race conditions
 			iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 			iiifDownloader.SetQuiet(true) // Enable quiet mode to suppress individual tile progress bars
-			_ = iiifDownloader.Dezoomify(i.ctx, pageURL, dest, args)
 			// Suppress errors in concurrent mode to keep progress bar clean
+			_ = iiifDownloader.Dezoomify(i.ctx, pageURL, dest, args)
 		})
 	}
 	
@@ -323,9 +312,9 @@ func (i *IIIF) doNormal(imgUrls []string) bool {
 
 func (i *IIIF) doNormalSequential(imgUrls []string) bool {
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := i.ctx
 	for k, uri := range imgUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -335,7 +324,7 @@ func (i *IIIF) doNormalSequential(imgUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", k+1, size, uri)
+		i18n.Logln("get.page", k+1, size, uri)
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -347,8 +336,9 @@ func (i *IIIF) doNormalSequential(imgUrls []string) bool {
 				"User-Agent": config.Conf.UserAgent,
 			},
 		}
-		_, err := gohttp.FastGet(ctx, uri, opts)
-		if err != nil {
+		pagePlanned(i.ctx)
+		// gohttp checks and post-processes the page once it is saved
+		if _, err := gohttp.FastGet(ctx, uri, opts); err != nil {
 			fmt.Println(err)
 		}
 		fmt.Println()
@@ -362,12 +352,12 @@ func (i *IIIF) doNormalConcurrent(imgUrls []string) bool {
 	}
 	
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := i.ctx
 	
 	// Count valid pages to download
 	validPages := 0
 	for k, uri := range imgUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -384,14 +374,14 @@ func (i *IIIF) doNormalConcurrent(imgUrls []string) bool {
 	}
 	
 	// Create page-level progress bar
-	pageBar := progressbar.Default(int64(validPages), "downloading pages")
+	pageBar := progressbar.Default(int64(validPages), i18n.T("download.pages"))
 	
 	// Create concurrent queue with page-rate limit
 	q := queue.NewConcurrentQueue(config.Conf.PageRate)
 	var wg sync.WaitGroup
 	
 	for k, uri := range imgUrls {
-		if uri == "" || !config.PageRange(k, size) {
+		if uri == "" || !config.PageRange(i.ctx, k, size) {
 			continue
 		}
 		
@@ -404,6 +394,7 @@ func (i *IIIF) doNormalConcurrent(imgUrls []string) bool {
 			continue
 		}
 		
+		pagePlanned(i.ctx)
 		wg.Add(1)
 		// Capture variables for closure
 		pageURL := uri
@@ -424,8 +415,8 @@ func (i *IIIF) doNormalConcurrent(imgUrls []string) bool {
 				},
 			}
 			
-			_, _ = gohttp.FastGet(ctx, pageURL, opts)
 			// Suppress errors in concurrent mode to keep progress bar clean
+			_, _ = gohttp.FastGet(ctx, pageURL, opts)
 		})
 	}
 	
@@ -433,6 +424,55 @@ func (i *IIIF) doNormalConcurrent(imgUrls []string) bool {
 	return true
 }
 
+// describe returns the manifest label and metadata, for --export
+func (i *IIIF) describe() (title string, fields []mets.Field) {
+	var d iiif.Descriptive
+	if i.xmlContent == nil || json.Unmarshal(i.xmlContent, &d) != nil {
+		return "", nil
+	}
+	for _, m := range d.Metadata {
+		fields = append(fields, mets.Field{Label: iiifText(m.Label), Value: iiifText(m.Value)})
+	}
+	return iiifText(d.Label), fields
+}
+
+// iiifText flattens a IIIF property value: a string, a list, an @value object or a v3 language map
+func iiifText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		var parts []string
+		for _, v := range list {
+			if t := iiifText(v); t != "" {
+				parts = append(parts, t)
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) != nil {
+		return ""
+	}
+	if v, ok := obj["@value"]; ok {
+		return iiifText(v)
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		if t := iiifText(obj[k]); t != "" {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
 func (i *IIIF) checkVersion(bs []byte) (int, error) {
 	var presentation iiif.ManifestPresentation
 	if err := json.Unmarshal(bs, &presentation); err != nil {
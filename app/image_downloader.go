@@ -3,6 +3,8 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/chttp"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bufio"
 	"bytes"
 	"context"
@@ -27,29 +29,24 @@ const (
 )
 
 type ImageDownloader struct {
-	client            *http.Client
-	reader            *bufio.Reader
-	hasVolPlaceholder bool   // 是否包含[VOL]或[PAGE]占位符
-	hasABPlaceholder  bool   // 是否包含[AB]或[ab]占位符
-	abPlaceholder     string // 存储实际的占位符形式（"[AB]"或"[ab]"）
-	abIsLowercase     bool   // 占位符是否为小写
-	maxConcurrent     int
+	client        *http.Client
+	reader        *bufio.Reader
+	maxConcurrent int
 
 	ctx context.Context
 }
 
-func NewImageDownloader() *ImageDownloader {
+func NewImageDownloader(ctx context.Context) *ImageDownloader {
 	// 创建自定义 Transport 忽略 SSL 验证
 	tr := NewHttpTransport()
 	jar, _ := cookiejar.New(nil)
 
 	return &ImageDownloader{
 		// 初始化字段
-		client:            &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
-		reader:            bufio.NewReader(os.Stdin),
-		hasVolPlaceholder: false,
-		maxConcurrent:     config.Conf.MaxConcurrent,
-		ctx:               context.Background(),
+		client:        &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
+		reader:        bufio.NewReader(os.Stdin),
+		maxConcurrent: config.Conf.MaxConcurrent,
+		ctx:           ctx,
 	}
 }
 
@@ -63,49 +60,48 @@ func (i *ImageDownloader) GetRouterInit(rawUrl string) (map[string]interface{},
 	}, nil
 }
 
+// Run downloads --template or rawUrl when it is a template, or asks for
+// templates until exit is typed
 func (i *ImageDownloader) Run(rawUrl string) {
+	template := config.Conf.Template
+	if template == "" && IsTemplate(rawUrl) {
+		template = rawUrl
+	}
+	if template != "" {
+		if err := i.RunTemplate(template, config.Conf.Pad, config.Conf.VolRange, config.Conf.PageList); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
 	for {
-		fmt.Println("\n=== 当前模式：图片批量下载 ===")
-		fmt.Println("输入 'exit' 退出程序")
+		fmt.Println()
+		i18n.Println("image.mode")
+		i18n.Println("image.exit_hint")
 
 		// 1. 获取URL模板
-		urlTemplate, err := i.getInput("请输入图片URL模板（必须包含[PAGE]，可选[VOL]和[AB]）: ")
+		urlTemplate, err := i.getInput(i18n.T("image.ask_template") + " ")
 		if err != nil || strings.ToLower(urlTemplate) == "exit" {
 			break
 		}
 
-		// 检查必须包含[PAGE]占位符
-		if !strings.Contains(urlTemplate, "[PAGE]") {
-			fmt.Println("错误: URL模板必须包含[PAGE]占位符")
+		// 2. 获取页码格式化位数
+		pad, err := i.getInputInt(i18n.T("image.ask_pad") + " ")
+		if err != nil || pad < 0 {
+			i18n.Println("image.need_pad")
 			continue
 		}
-
-		// 检查占位符
-		i.hasVolPlaceholder = strings.Contains(urlTemplate, "[VOL]")
-		i.hasABPlaceholder = strings.Contains(urlTemplate, "[AB]") || strings.Contains(urlTemplate, "[ab]")
-
-		// 存储实际的占位符形式
-		if strings.Contains(urlTemplate, "[AB]") {
-			i.abPlaceholder = "[AB]"
-			i.abIsLowercase = false
-		} else if strings.Contains(urlTemplate, "[ab]") {
-			i.abPlaceholder = "[ab]"
-			i.abIsLowercase = true
-		}
-
-		// 2. 获取页码格式化位数
-		pageFormat, err := i.getInput("请输入页码格式化位数（如04表示0001，03表示001）: ")
-		if err != nil || pageFormat == "" {
-			fmt.Println("输入错误: 必须指定页码格式化位数")
+		t, err := parseTemplate(urlTemplate, pad)
+		if err != nil {
+			i18n.Println("image.error", err)
 			continue
 		}
 
 		// 3. 获取扩展名（从URL模板中提取或用户指定）
-		ext := filepath.Ext(urlTemplate)
+		ext := templateExt(urlTemplate)
 		if ext == "" {
-			ext, err = i.getInput("无法从URL中识别扩展名，请手动输入（如.jpg、.png）: ")
+			ext, err = i.getInput(i18n.T("image.ask_ext") + " ")
 			if err != nil || ext == "" {
-				fmt.Println("输入错误: 必须指定文件扩展名")
+				i18n.Println("image.need_ext")
 				continue
 			}
 			if !strings.HasPrefix(ext, ".") {
@@ -113,49 +109,93 @@ func (i *ImageDownloader) Run(rawUrl string) {
 			}
 		}
 
-		var startVol, endVol int
-		if i.hasVolPlaceholder {
+		startVol, endVol := 1, 1
+		if t.hasVol {
 			// 4. 获取册数范围
 			startVol, endVol, err = i.getVolumeRange()
 			if err != nil {
-				fmt.Println("输入错误:", err)
+				i18n.Println("image.bad_input", err)
 				continue
 			}
-		} else {
-			startVol, endVol = 1, 1
 		}
 
-		// 5. 获取总页数
-		totalPages, err := i.getInputInt("请输入全部册数的总页数: ")
-		if err != nil || totalPages <= 0 {
-			fmt.Println("输入错误: 总页数必须大于0")
-			continue
+		// 5. 获取每册页数
+		plan := pagePlan{counts: []int{1}}
+		if t.hasPage {
+			answer, err := i.getInput(i18n.T("image.ask_pages") + " ")
+			if err != nil {
+				continue
+			}
+			if plan, err = parsePagePlan(answer, endVol-startVol+1); err != nil {
+				i18n.Println("image.bad_input", err)
+				continue
+			}
 		}
 
 		// 6. 确认并开始下载
-		if i.hasVolPlaceholder {
-			fmt.Printf("\n即将开始下载:\nURL模板: %s\n册数范围: %04d-%04d\n总页数: %d\n页码格式: %%0%sd\n扩展名: %s\n",
-				urlTemplate, startVol, endVol, totalPages, pageFormat, ext)
-		} else {
-			fmt.Printf("\n即将开始下载:\nURL模板: %s\n总页数: %d\n页码格式: %%0%sd\n扩展名: %s\n",
-				urlTemplate, totalPages, pageFormat, ext)
+		fmt.Println()
+		i18n.Println("image.summary_template", urlTemplate)
+		if t.hasVol {
+			i18n.Println("image.summary_volumes", startVol, endVol)
 		}
-		confirm, _ := i.getInput("确认开始下载？(y/n): ")
+		i18n.Println("image.summary_pages", plan)
+		i18n.Println("image.summary_ext", ext)
+		confirm, _ := i.getInput(i18n.T("image.ask_confirm") + " ")
 		if strings.ToLower(confirm) != "y" {
 			continue
 		}
 
 		// 7. 执行下载
-		i.downloadAll(urlTemplate, startVol, endVol, totalPages, pageFormat, ext)
+		i.downloadAll(t, startVol, endVol, plan, ext)
 
 		// 8. 询问是否继续
-		cont, _ := i.getInput("\n下载完成！是否继续下载其他URL模板？(y/n): ")
+		cont, _ := i.getInput("\n" + i18n.T("image.ask_continue") + " ")
 		if strings.ToLower(cont) != "y" {
 			break
 		}
 	}
 
-	fmt.Println("程序退出")
+	i18n.Println("image.bye")
+}
+
+// RunTemplate downloads the pages of a URL template without prompts:
+// volumes is the range of [VOL], pages the pages of each volume (see parsePagePlan).
+func (i *ImageDownloader) RunTemplate(template string, pad int, volumes, pages string) error {
+	t, err := parseTemplate(template, pad)
+	if err != nil {
+		return err
+	}
+	startVol, endVol := 1, 1
+	if t.hasVol {
+		if startVol, endVol, err = parseVolRange(volumes); err != nil {
+			return err
+		}
+	}
+	plan := pagePlan{counts: []int{1}}
+	if t.hasPage {
+		if plan, err = parsePagePlan(pages, endVol-startVol+1); err != nil {
+			return err
+		}
+	}
+	ext := templateExt(template)
+	if ext == "" {
+		ext = config.Conf.FileExt
+	}
+	i.downloadAll(t, startVol, endVol, plan, ext)
+	return nil
+}
+
+// templateExt is the file extension of the template's path, "" if it has none
+func templateExt(template string) string {
+	path := template
+	if k := strings.IndexAny(path, "?#"); k >= 0 {
+		path = path[:k]
+	}
+	ext := filepath.Ext(path)
+	if strings.ContainsAny(ext, "[]{}/") {
+		return ""
+	}
+	return ext
 }
 
 func (i *ImageDownloader) getInput(prompt string) (string, error) {
@@ -176,31 +216,30 @@ func (i *ImageDownloader) getInputInt(prompt string) (int, error) {
 }
 
 func (i *ImageDownloader) getVolumeRange() (int, int, error) {
-	startVol, err := i.getInputInt("请输入起始册号: ")
+	startVol, err := i.getInputInt(i18n.T("image.ask_first_volume") + " ")
 	if err != nil {
 		return 0, 0, err
 	}
 
-	endVol, err := i.getInputInt("请输入结束册号: ")
+	endVol, err := i.getInputInt(i18n.T("image.ask_last_volume") + " ")
 	if err != nil {
 		return 0, 0, err
 	}
 
 	if startVol > endVol {
-		return 0, 0, fmt.Errorf("起始册号不能大于结束册号")
+		return 0, 0, errors.New(i18n.T("image.bad_volume_range"))
 	}
 
 	return startVol, endVol, nil
 }
 
-func (i *ImageDownloader) downloadAll(urlTemplate string, startVol, endVol, totalPages int, pageFormat, ext string) {
+func (i *ImageDownloader) downloadAll(t *urlTemplate, startVol, endVol int, plan pagePlan, ext string) {
 	totalVolumes := endVol - startVol + 1
-	//totalExpected := totalPages * 2 // 假设每页都有A/B两面
 
 	var totalDownloaded int64
 	globalBar := progressbar.NewOptions64(
-		int64(totalPages),
-		progressbar.OptionSetDescription("总下载进度"),
+		plan.total(totalVolumes),
+		progressbar.OptionSetDescription(i18n.T("image.progress")),
 		progressbar.OptionSetTheme(progressbar.Theme{
 			Saucer:        "=",
 			SaucerHead:    ">",
@@ -211,121 +250,145 @@ func (i *ImageDownloader) downloadAll(urlTemplate string, startVol, endVol, tota
 		progressbar.OptionShowCount(),
 		progressbar.OptionSetWidth(50),
 		progressbar.OptionOnCompletion(func() {
-			fmt.Printf("\n下载完成！共成功下载 %d 个文件\n", atomic.LoadInt64(&totalDownloaded))
+			n := int(atomic.LoadInt64(&totalDownloaded))
+			fmt.Println()
+			fmt.Println(i18n.N("image.done", n, n))
 		}),
 	)
 
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, i.maxConcurrent)
 
-	// 计算每册页数（简单平均分配）
-	pagesPerVol := totalPages / totalVolumes
-	remainingPages := totalPages % totalVolumes
-
 	for vol := startVol; vol <= endVol; vol++ {
 		wg.Add(1)
 		semaphore <- struct{}{}
 
-		// 当前册的页数
-		currentPages := pagesPerVol
-		if vol <= startVol+remainingPages-1 {
-			currentPages++
-		}
-
 		go func(volume, pagesThisVol int) {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			volStr := fmt.Sprintf("%04d", volume)
-			var dirPath string
-			if i.hasVolPlaceholder {
-				dirPath = filepath.Join(config.Conf.Directory, volStr)
-			} else {
-				dirPath = config.Conf.Directory
+			dirPath := bookDir(i.ctx)
+			if t.hasVol {
+				dirPath = filepath.Join(dirPath, fmt.Sprintf("%04d", volume))
 			}
 
 			if err := os.MkdirAll(dirPath, 0755); err != nil {
-				fmt.Printf("\n创建目录 %s 失败: %v\n", dirPath, err)
+				fmt.Println()
+				i18n.Println("mkdir_failed", dirPath, err)
 				return
 			}
 
-			for page := 1; page <= pagesThisVol; page++ {
-				i.downloadPageSmart(urlTemplate, volStr, page, dirPath, pageFormat, ext, globalBar, &totalDownloaded)
+			finisher := newPageFinisher(hostOf(t.raw))
+			misses, saved := 0, 0
+			for page := 1; plan.probe || page <= pagesThisVol; page++ {
+				// 探测模式：连续 N 页 404/410 即为本册结束，其它错误不算
+				if err := i.downloadPageSmart(t, volume, page, dirPath, ext, finisher, &saved, globalBar, &totalDownloaded); !isMissing(err) {
+					misses = 0
+					continue
+				}
+				if misses++; plan.probe && misses >= plan.misses {
+					if t.hasVol {
+						fmt.Println()
+						fmt.Println(i18n.N("image.volume_pages", page-misses, volume, page-misses))
+					}
+					break
+				}
 			}
-		}(vol, currentPages)
+		}(vol, plan.pages(vol-startVol))
 	}
 
 	wg.Wait()
 	globalBar.Finish()
 }
 
-func (i *ImageDownloader) downloadPageSmart(urlTemplate, volStr string, page int, dirPath, pageFormat, ext string, globalBar *progressbar.ProgressBar, totalDownloaded *int64) {
-	// 构建页码格式
-	pageNum := fmt.Sprintf("%0"+pageFormat+"d", page)
+// statusError is the status of an image answered other than 200
+type statusError int
 
-	// 构建基础URL
-	url := urlTemplate
-	if i.hasVolPlaceholder {
-		url = strings.Replace(url, "[VOL]", volStr, 1)
-	}
-
-	if i.hasABPlaceholder {
-		// 智能处理AB面（只检测一次）
-		abSuffix := "A" // 默认使用大写
-		if i.abIsLowercase {
-			abSuffix = "a"
-		}
+func (e statusError) Error() string {
+	return i18n.T("image.http_status", int(e))
+}
 
-		// 构建A面URL
-		urlA := strings.Replace(url, "[PAGE]", pageNum, 1)
-		urlA = strings.Replace(urlA, i.abPlaceholder, abSuffix, 1)
-		err := i.downloadAndValidate(urlA, filepath.Join(dirPath, fmt.Sprintf("%s%s%s", pageNum, abSuffix, ext)), globalBar, totalDownloaded)
+// isMissing tells whether err is the server saying the file is not there
+func isMissing(err error) bool {
+	var se statusError
+	return errors.As(err, &se) && (se == http.StatusNotFound || se == http.StatusGone)
+}
 
-		if err == nil {
-			// 如果A面存在，下载B面
-			abSuffix = "B" // 默认使用大写
-			if i.abIsLowercase {
-				abSuffix = "b"
+// downloadPageSmart downloads the files of one page; err is nil when any was
+// there, else the failure of the page, a missing one only if every file is.
+// saved counts the files of the volume so far, the page check compares each
+// file with the one saved before it.
+func (i *ImageDownloader) downloadPageSmart(t *urlTemplate, volume, page int, dirPath, ext string, finisher *pageFinisher, saved *int, globalBar *progressbar.ProgressBar, totalDownloaded *int64) (err error) {
+	ok := false
+	failed := func(e error) {
+		i18n.Println("image.page_failed", e)
+		if err == nil || isMissing(err) {
+			err = e
+		}
+	}
+	for _, values := range t.combos() {
+		get := func(ab string) error {
+			url := t.fill(volume, page, ab, values)
+			kept, err := i.downloadAndValidate(url, filepath.Join(dirPath, t.fileName(page, ab, values, ext)), finisher, *saved, globalBar, totalDownloaded)
+			if kept {
+				*saved++
 			}
+			return err
+		}
 
-			urlB := strings.Replace(url, "[PAGE]", pageNum, 1)
-			urlB = strings.Replace(urlB, i.abPlaceholder, abSuffix, 1)
-			err = i.downloadAndValidate(urlB, filepath.Join(dirPath, fmt.Sprintf("%s%s%s", pageNum, abSuffix, ext)), globalBar, totalDownloaded)
-			if err != nil {
-				fmt.Printf("[err=downloadAndValidate]+%v\n", err)
-				return
-			}
-		} else {
-			urlPlain := strings.Replace(url, "[PAGE]", pageNum, 1)
-			urlPlain = strings.Replace(urlPlain, i.abPlaceholder, "", 1)
-			s := filepath.Join(dirPath, fmt.Sprintf("%s%s", pageNum, ext))
-			err = i.downloadAndValidate(urlPlain, s, globalBar, totalDownloaded)
-			if err != nil {
-				fmt.Printf("[err=downloadAndValidate]+%v\n", err)
-				return
+		if !t.hasAB {
+			if e := get(""); e != nil {
+				failed(e)
+				continue
 			}
+			ok = true
+			continue
 		}
 
-	} else {
-		urlPlain := strings.Replace(url, "[PAGE]", pageNum, 1)
-		err := i.downloadAndValidate(urlPlain, filepath.Join(dirPath, fmt.Sprintf("%s%s", pageNum, ext)), globalBar, totalDownloaded)
-		if err != nil {
-			fmt.Printf("[err=downloadAndValidate]+%v\n", err)
-			return
+		// 智能处理AB面：A面存在才下载B面，否则去掉占位符
+		sideA, sideB := t.sides()
+		if get(sideA) != nil {
+			if e := get(""); e != nil {
+				failed(e)
+				continue
+			}
+			ok = true
+			continue
 		}
+		ok = true
+		if e := get(sideB); e != nil {
+			i18n.Println("image.page_failed", e)
+		}
+	}
+	if ok {
+		return nil
+	}
+	return err
+}
+
+// downloadAndValidate downloads the file at url; kept is false when the page
+// check deleted it
+func (i *ImageDownloader) downloadAndValidate(url, filePath string, finisher *pageFinisher, index int, globalBar *progressbar.ProgressBar, totalDownloaded *int64) (kept bool, err error) {
+	if err = i.fetch(url, filePath); err != nil {
+		return false, err
+	}
+	// 检测占位图、空白页和重复页，然后执行后处理
+	if kept = finisher.Finish(index, filePath, func() error { return i.fetch(url, filePath) }); kept {
+		atomic.AddInt64(totalDownloaded, 1)
 	}
+	globalBar.Add(1)
+	if s := gohttp.RateStatus(); s != "" {
+		globalBar.Describe(i18n.T("image.progress") + " " + s)
+	}
+	return kept, nil
 }
 
-func (i *ImageDownloader) downloadAndValidate(url, filePath string, globalBar *progressbar.ProgressBar, totalDownloaded *int64) error {
+func (i *ImageDownloader) fetch(url, filePath string) error {
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
 	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
 	if err == nil {
 		for key, value := range headers {
@@ -340,7 +403,7 @@ func (i *ImageDownloader) downloadAndValidate(url, filePath string, globalBar *p
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return err
+		return statusError(resp.StatusCode)
 	}
 
 	buf := bytes.NewBuffer(make([]byte, 0, 10*1024*1024))
@@ -348,27 +411,11 @@ func (i *ImageDownloader) downloadAndValidate(url, filePath string, globalBar *p
 		return err
 	}
 	if buf.Len() < minFileSize {
-		return errors.New("发现0字节文件")
+		return errors.New(i18n.T("image.too_small"))
 	}
 
-	//file, err := os.Create(filePath)
-	//if err != nil {
-	//	return err
-	//}
-	//defer file.Close()
-	//
-	//
-	//if _, err := buf.WriteTo(file); err != nil {
-	//	os.Remove(filePath)
-	//	return err
-	//}
-
 	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
+		return errors.New(i18n.T("image.write_failed", err))
 	}
-
-	atomic.AddInt64(totalDownloaded, 1)
-	globalBar.Add(1)
-
 	return nil
 }
@@ -0,0 +1,335 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// urlTemplate is a parsed URL template of the image downloader. Placeholders:
+//
+//	[PAGE] [PAGE:4]    page number, zero padded to --pad digits or to 4
+//	[VOL] [VOL:3]      volume number, likewise
+//	[AB] [ab]          side A then B of a leaf, or none when there is no A side
+//	[001-120] [1-9:2]  numbers, zero padded like the first one, with a step
+//	[a-z] [A-F]        letters
+//	{r,v,front}        the listed words
+//
+// Numbers, letters and words make one URL each, in the order they appear.
+// Anything else in brackets, e.g. an IPv6 host, is kept as is.
+type urlTemplate struct {
+	raw     string
+	parts   []templatePart
+	hasPage bool
+	hasVol  bool
+	hasAB   bool
+	abLower bool
+	seqs    int // Number of parts with values
+}
+
+type partKind int
+
+const (
+	partText partKind = iota
+	partPage
+	partVol
+	partAB
+	partValues
+)
+
+type templatePart struct {
+	kind   partKind
+	text   string   // partText
+	width  int      // partPage, partVol
+	values []string // partValues
+}
+
+// parseTemplate parses s; pad is the width of [PAGE] and [VOL] without their own
+func parseTemplate(s string, pad int) (*urlTemplate, error) {
+	t := &urlTemplate{raw: s}
+	text := func(str string) {
+		if n := len(t.parts); n > 0 && t.parts[n-1].kind == partText {
+			t.parts[n-1].text += str
+			return
+		}
+		t.parts = append(t.parts, templatePart{kind: partText, text: str})
+	}
+	for rest := s; rest != ""; {
+		open := strings.IndexAny(rest, "[{")
+		if open < 0 {
+			text(rest)
+			break
+		}
+		text(rest[:open])
+		closer := "]"
+		if rest[open] == '{' {
+			closer = "}"
+		}
+		end := strings.Index(rest[open:], closer)
+		if end < 0 {
+			text(rest[open:])
+			break
+		}
+		inner := rest[open+1 : open+end]
+		token := rest[open : open+end+1]
+		rest = rest[open+end+1:]
+
+		part, ok, err := parsePlaceholder(inner, closer == "}", pad)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %w", token, err)
+		}
+		if !ok {
+			text(token)
+			continue
+		}
+		switch part.kind {
+		case partPage:
+			t.hasPage = true
+		case partVol:
+			t.hasVol = true
+		case partAB:
+			if t.hasAB {
+				return nil, fmt.Errorf("template %s: only one [AB] is allowed", token)
+			}
+			t.hasAB, t.abLower = true, inner == "ab"
+		case partValues:
+			t.seqs++
+		}
+		t.parts = append(t.parts, part)
+	}
+	if !t.hasPage && t.seqs == 0 {
+		return nil, fmt.Errorf("template %q needs [PAGE] or a range like [001-120]", s)
+	}
+	return t, nil
+}
+
+// IsTemplate tells whether sUrl has placeholders of the image downloader
+func IsTemplate(sUrl string) bool {
+	_, err := parseTemplate(sUrl, 0)
+	return err == nil
+}
+
+// parsePlaceholder parses the inside of [...] or {...}; ok is false for plain text
+func parsePlaceholder(inner string, list bool, pad int) (part templatePart, ok bool, err error) {
+	if list {
+		if !strings.Contains(inner, ",") {
+			return part, false, nil
+		}
+		return templatePart{kind: partValues, values: strings.Split(inner, ",")}, true, nil
+	}
+
+	name, arg, hasArg := strings.Cut(inner, ":")
+	switch name {
+	case "PAGE", "VOL":
+		kind := partPage
+		if name == "VOL" {
+			kind = partVol
+		}
+		width := pad
+		if hasArg {
+			if width, err = strconv.Atoi(arg); err != nil || width < 0 {
+				return part, false, fmt.Errorf("bad width %q", arg)
+			}
+		}
+		return templatePart{kind: kind, width: width}, true, nil
+	case "AB", "ab":
+		return templatePart{kind: partAB}, !hasArg, nil
+	}
+
+	from, to, isRange := strings.Cut(name, "-")
+	if !isRange || from == "" || to == "" {
+		return part, false, nil
+	}
+	step := 1
+	if hasArg {
+		if step, err = strconv.Atoi(arg); err != nil || step < 1 {
+			return part, false, fmt.Errorf("bad step %q", arg)
+		}
+	}
+	values, err := rangeValues(from, to, step)
+	if values == nil && err == nil {
+		return part, false, nil
+	}
+	return templatePart{kind: partValues, values: values}, err == nil, err
+}
+
+// rangeValues lists from..to for numbers or single letters; nil for neither
+func rangeValues(from, to string, step int) ([]string, error) {
+	a, errA := strconv.Atoi(from)
+	b, errB := strconv.Atoi(to)
+	if errA == nil && errB == nil {
+		if a < 0 || b < a {
+			return nil, fmt.Errorf("bad range %s-%s", from, to)
+		}
+		width := 0
+		if len(from) > 1 && from[0] == '0' {
+			width = len(from)
+		}
+		var values []string
+		for n := a; n <= b; n += step {
+			values = append(values, fmt.Sprintf("%0*d", width, n))
+		}
+		return values, nil
+	}
+	if len(from) == 1 && len(to) == 1 && isLetter(from[0]) && isLetter(to[0]) {
+		if (from[0] >= 'a') != (to[0] >= 'a') || to[0] < from[0] {
+			return nil, fmt.Errorf("bad range %s-%s", from, to)
+		}
+		var values []string
+		for c := from[0]; c <= to[0]; c += byte(step) {
+			values = append(values, string(c))
+			if int(c)+step > 'z' {
+				break
+			}
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// combos lists one value of each range, list or letter part per URL, in order
+func (t *urlTemplate) combos() [][]string {
+	combos := [][]string{nil}
+	for _, p := range t.parts {
+		if p.kind != partValues {
+			continue
+		}
+		var next [][]string
+		for _, c := range combos {
+			for _, v := range p.values {
+				next = append(next, append(append([]string(nil), c...), v))
+			}
+		}
+		combos = next
+	}
+	return combos
+}
+
+// fill builds the URL of a page; ab replaces [AB], values the other parts in order
+func (t *urlTemplate) fill(vol, page int, ab string, values []string) string {
+	var b strings.Builder
+	k := 0
+	for _, p := range t.parts {
+		switch p.kind {
+		case partText:
+			b.WriteString(p.text)
+		case partPage:
+			fmt.Fprintf(&b, "%0*d", p.width, page)
+		case partVol:
+			fmt.Fprintf(&b, "%0*d", p.width, vol)
+		case partAB:
+			b.WriteString(ab)
+		case partValues:
+			b.WriteString(values[k])
+			k++
+		}
+	}
+	return b.String()
+}
+
+// sides are the values of [AB]; a page without an A side has none
+func (t *urlTemplate) sides() (a, b string) {
+	if t.abLower {
+		return "a", "b"
+	}
+	return "A", "B"
+}
+
+// fileName names the file of a page, so that files sort in reading order
+func (t *urlTemplate) fileName(page int, ab string, values []string, ext string) string {
+	if !t.hasPage {
+		return strings.Join(values, "_") + ab + ext
+	}
+	return strings.Join(append([]string{fmt.Sprintf("%04d", page) + ab}, values...), "_") + ext
+}
+
+// pagePlan is how many pages each volume has, from --pages
+type pagePlan struct {
+	counts []int // Per volume; one count is used for all
+	probe  bool  // Go on until misses pages in a row are missing
+	misses int
+}
+
+const defaultProbeMisses = 3
+
+// parsePagePlan parses 120 (every volume), 120,98,143 (volume by volume),
+// probe or probe:5 (stop after 5 missing pages in a row) for volumes volumes
+func parsePagePlan(s string, volumes int) (pagePlan, error) {
+	s = strings.TrimSpace(s)
+	if s == "probe" || strings.HasPrefix(s, "probe:") {
+		plan := pagePlan{probe: true, misses: defaultProbeMisses}
+		if _, arg, ok := strings.Cut(s, ":"); ok {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				return pagePlan{}, fmt.Errorf("--pages %s: bad number of misses", s)
+			}
+			plan.misses = n
+		}
+		return plan, nil
+	}
+	var plan pagePlan
+	for _, v := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n < 1 {
+			return pagePlan{}, fmt.Errorf("--pages %q: expected 120, 120,98,143 or probe", s)
+		}
+		plan.counts = append(plan.counts, n)
+	}
+	if len(plan.counts) > 1 && len(plan.counts) != volumes {
+		return pagePlan{}, fmt.Errorf("--pages lists %d volumes, --vol has %d", len(plan.counts), volumes)
+	}
+	return plan, nil
+}
+
+func (p pagePlan) String() string {
+	if p.probe {
+		return fmt.Sprintf("probe:%d", p.misses)
+	}
+	counts := make([]string, len(p.counts))
+	for i, n := range p.counts {
+		counts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(counts, ",")
+}
+
+// pages is the number of pages of volume index (from 0), 0 when probing
+func (p pagePlan) pages(index int) int {
+	switch {
+	case p.probe:
+		return 0
+	case len(p.counts) == 1:
+		return p.counts[0]
+	}
+	return p.counts[index]
+}
+
+// total is the number of pages of volumes volumes, -1 when probing
+func (p pagePlan) total(volumes int) int64 {
+	if p.probe {
+		return -1
+	}
+	var n int64
+	for i := 0; i < volumes; i++ {
+		n += int64(p.pages(i))
+	}
+	return n
+}
+
+// parseVolRange parses --vol: 3 or 1-12
+func parseVolRange(s string) (start, end int, err error) {
+	a, b, isRange := strings.Cut(strings.TrimSpace(s), "-")
+	if start, err = strconv.Atoi(a); err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("--vol %q: expected 3 or 1-12", s)
+	}
+	end = start
+	if isRange {
+		if end, err = strconv.Atoi(b); err != nil || end < start {
+			return 0, 0, fmt.Errorf("--vol %q: expected 3 or 1-12", s)
+		}
+	}
+	return start, end, nil
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"bookget/config"
+	"bookget/pkg/gohttp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tpl, err := parseTemplate("https://a.example/[VOL:3]/p[PAGE][ab]_{r,v}.jpg", 4)
+	require.NoError(t, err)
+	assert.True(t, tpl.hasVol && tpl.hasPage && tpl.hasAB && tpl.abLower)
+	assert.Equal(t, [][]string{{"r"}, {"v"}}, tpl.combos())
+	assert.Equal(t, "https://a.example/002/p0012b_v.jpg", tpl.fill(2, 12, "b", []string{"v"}))
+	assert.Equal(t, "0012b_v.jpg", tpl.fileName(12, "b", []string{"v"}, ".jpg"))
+
+	tpl, err = parseTemplate("http://[::1]/img[008-012:2]-[a-c].png", 0)
+	require.NoError(t, err)
+	assert.False(t, tpl.hasPage)
+	assert.Equal(t, [][]string{{"008", "a"}, {"008", "b"}, {"008", "c"}, {"010", "a"}, {"010", "b"}, {"010", "c"}, {"012", "a"}, {"012", "b"}, {"012", "c"}}, tpl.combos())
+	assert.Equal(t, "http://[::1]/img010-b.png", tpl.fill(1, 1, "", []string{"010", "b"}))
+
+	for _, bad := range []string{"https://a.example/1.jpg", "https://a.example/[PAGE:x]", "https://a.example/[9-1]", "https://a.example/[a-Z]/[PAGE]", "https://a.example/[PAGE][AB][ab]"} {
+		_, err = parseTemplate(bad, 0)
+		assert.Error(t, err, bad)
+	}
+	assert.True(t, IsTemplate("https://a.example/[VOL]/[PAGE].jpg"))
+	assert.False(t, IsTemplate("https://a.example/book/1"))
+}
+
+func TestParsePagePlan(t *testing.T) {
+	plan, err := parsePagePlan("120,98,143", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{120, 98, 143}, []int{plan.pages(0), plan.pages(1), plan.pages(2)})
+	assert.EqualValues(t, 361, plan.total(3))
+
+	plan, err = parsePagePlan("50", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 50, plan.pages(2))
+
+	plan, err = parsePagePlan("probe:5", 3)
+	require.NoError(t, err)
+	assert.True(t, plan.probe)
+	assert.Equal(t, 5, plan.misses)
+	assert.EqualValues(t, -1, plan.total(3))
+
+	for _, bad := range []string{"", "0", "1,2", "probe:0", "x"} {
+		_, err = parsePagePlan(bad, 3)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRunTemplateProbe(t *testing.T) {
+	// Volume 1 has pages 1-5 without page 3, volume 2 has pages 1-2 and 6;
+	// its page 4 fails with 503, which is not a missing page
+	last := map[string]int{"1": 5, "2": 6}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var vol string
+		var page int
+		_, err := fmt.Sscanf(strings.ReplaceAll(r.URL.Path, "/", " "), " v%s p%d.jpg", &vol, &page)
+		if vol == "2" && page == 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if err != nil || page > last[vol] || (vol == "1" && page == 3) || (vol == "2" && page > 2 && page < 6) {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(strings.Repeat("x", 2048)))
+	}))
+	defer srv.Close()
+
+	saved, savedConf := gohttp.Fixtures, config.Conf
+	gohttp.Fixtures = gohttp.FixturePolicy{}
+	defer func() { gohttp.Fixtures, config.Conf = saved, savedConf }()
+	dir := t.TempDir()
+	config.Conf.Directory = dir
+	config.Conf.MaxConcurrent = 2
+
+	d := NewImageDownloader(context.Background())
+	require.NoError(t, d.RunTemplate(srv.URL+"/v[VOL]/p[PAGE].jpg", 0, "1-2", "probe:2"))
+
+	var files []string
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"0001/0001.jpg", "0001/0002.jpg", "0001/0004.jpg", "0001/0005.jpg", "0002/0001.jpg", "0002/0002.jpg", "0002/0006.jpg"}, files)
+}
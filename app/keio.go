@@ -5,10 +5,10 @@ import (
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -24,11 +24,11 @@ type Keio struct {
 	ctx context.Context
 }
 
-func NewKeio() *Keio {
+func NewKeio(ctx context.Context) *Keio {
 	return &Keio{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -47,6 +47,7 @@ func (r *Keio) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId, r.dt.VolumeId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -69,7 +70,7 @@ func (r *Keio) getBookId(sUrl string) (bookId, volumeId string) {
 func (r *Keio) getManifestUrl(sUrl string) (uri string, err error) {
 	//https://db2.sido.keio.ac.jp/kanseki/bib_image?id=
 	apiUrl := "https://db2.sido.keio.ac.jp/kanseki/bib_image?id=" + r.dt.BookId
-	bs, err := r.getBody(apiUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
@@ -84,7 +85,7 @@ func (r *Keio) getManifestUrl(sUrl string) (uri string, err error) {
 }
 
 func (r *Keio) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -92,17 +93,17 @@ func (r *Keio) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -118,7 +119,7 @@ func (r *Keio) do(imgUrls []string) (msg string, err error) {
 }
 
 func (r *Keio) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := r.getBody(sUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 	matches := regexp.MustCompile(`data-folid=['|"]([A-z0-9]+)['|"]`).FindAllSubmatch(bs, -1)
 	if matches == nil {
 		return
@@ -138,13 +139,13 @@ func (r *Keio) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, er
 }
 
 func (r *Keio) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -168,28 +169,6 @@ func (r *Keio) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 	return canvases, nil
 }
 
-func (r *Keio) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
 func (r *Keio) makeId(childId string, bookId string, isFolid4Digit bool) string {
 	childIDfmt := ""
 	iLen := 3
@@ -212,7 +191,7 @@ func (r *Keio) doNormal(imgUrls []string) bool {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, dUrl := range imgUrls {
-		if dUrl == "" || !config.PageRange(i, size) {
+		if dUrl == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(dUrl)
@@ -223,11 +202,12 @@ func (r *Keio) doNormal(imgUrls []string) bool {
 			continue
 		}
 		imgUrl := dUrl
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -250,7 +230,7 @@ func (r *Keio) doDezoomify(iiifUrls []string) bool {
 	if iiifUrls == nil {
 		return false
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -259,7 +239,7 @@ func (r *Keio) doDezoomify(iiifUrls []string) bool {
 	size := len(iiifUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -268,7 +248,8 @@ func (r *Keio) doDezoomify(iiifUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, uri, dest, args)
 	}
 	return true
@@ -5,9 +5,9 @@ import (
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -25,11 +25,11 @@ type Khirin struct {
 	ctx    context.Context
 }
 
-func NewKhirin() *Khirin {
+func NewKhirin(ctx context.Context) *Khirin {
 	return &Khirin{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -48,6 +48,7 @@ func (r *Khirin) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -65,8 +66,8 @@ func (r *Khirin) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Khirin) download() (msg string, err error) {
-	log.Printf("Get %s  %s\n", r.dt.Url)
-	r.dt.SavePath = config.Conf.Directory
+	i18n.Logln("get", r.dt.Url)
+	r.dt.SavePath = bookDir(r.ctx)
 	manifestUrl, err := r.getManifestUrl(r.dt.Url)
 	if err != nil {
 		return "requested URL was not found.", err
@@ -75,7 +76,7 @@ func (r *Khirin) download() (msg string, err error) {
 	if err != nil || canvases == nil {
 		return "requested URL was not found.", err
 	}
-	log.Printf(" %d pages \n", len(canvases))
+	log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 	return r.do(canvases)
 }
 
@@ -92,7 +93,7 @@ func (r *Khirin) doDezoomify(canvases []string) bool {
 	if canvases == nil {
 		return false
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -101,13 +102,13 @@ func (r *Khirin) doDezoomify(canvases []string) bool {
 	size := len(canvases)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
 		filename := sortId + config.Conf.FileExt
 		inputUri := filepath.Join(r.dt.SavePath, sortId+"_info.json")
-		bs, err := r.getBody(uri, r.dt.Jar)
+		bs, err := sessionFor(r.ctx, r.dt.Jar).Get(uri)
 		if err != nil {
 			continue
 		}
@@ -117,8 +118,9 @@ func (r *Khirin) doDezoomify(canvases []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %s  %s\n", sortId, uri)
+		i18n.Logln("get.named", sortId, uri)
 
+		pagePlanned(r.ctx)
 		if err := iiifDownloader.Dezoomify(r.ctx, inputUri, dest, args); err == nil {
 			os.Remove(inputUri)
 		}
@@ -132,9 +134,9 @@ func (r *Khirin) doNormal(canvases []string) bool {
 	}
 	fmt.Println()
 	size := len(canvases)
-	ctx := context.Background()
+	ctx := r.ctx
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -143,7 +145,7 @@ func (r *Khirin) doNormal(canvases []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -155,6 +157,7 @@ func (r *Khirin) doNormal(canvases []string) bool {
 				"User-Agent": config.Conf.UserAgent,
 			},
 		}
+		pagePlanned(r.ctx)
 		gohttp.FastGet(ctx, uri, opts)
 		fmt.Println()
 		//util.PrintSleepTime(config.Conf.Sleep)
@@ -169,13 +172,13 @@ func (r *Khirin) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 }
 
 func (r *Khirin) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	var manifest = new(iiif.ManifestResponse)
 	if err != nil {
 		return nil, err
 	}
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -198,7 +201,7 @@ func (r *Khirin) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
 	return canvases, nil
 }
 func (r *Khirin) getManifestUrl(sUrl string) (uri string, err error) {
-	bs, err := r.getBody(sUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -215,25 +218,3 @@ func (r *Khirin) getManifestUrl(sUrl string) (uri string, err error) {
 	}
 	return
 }
-
-func (r *Khirin) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
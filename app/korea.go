@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/model/korea"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
@@ -18,13 +19,15 @@ import (
 
 type Korea struct {
 	dt   *DownloadTask
+	ctx  context.Context
 	body []byte
 }
 
-func NewKorea() *Korea {
+func NewKorea(ctx context.Context) *Korea {
 	return &Korea{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -41,6 +44,7 @@ func (r *Korea) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -57,7 +61,7 @@ func (r *Korea) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Korea) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -66,19 +70,19 @@ func (r *Korea) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			r.dt.SavePath = config.Conf.Directory
+			r.dt.SavePath = bookDir(r.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = CreateDirectory(vid)
+			r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		}
 		if err != nil || vol.Canvases == nil {
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(vol.Canvases))
+		log.Println(i18n.N("volume.pages", len(vol.Canvases), i+1, sizeVol, len(vol.Canvases)))
 		r.do(vol.Canvases)
 	}
 	return "", nil
@@ -94,7 +98,7 @@ func (r *Korea) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -104,11 +108,12 @@ func (r *Korea) do(imgUrls []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d, %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -131,7 +136,7 @@ func (r *Korea) do(imgUrls []string) (msg string, err error) {
 }
 
 func (r *Korea) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []korea.PartialCanvases, err error) {
-	bs, err := getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -3,8 +3,8 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -17,13 +17,15 @@ import (
 )
 
 type Kyotou struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewKyotou() *Kyotou {
+func NewKyotou(ctx context.Context) *Kyotou {
 	return &Kyotou{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -40,6 +42,7 @@ func (r *Kyotou) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 	r.dt.Jar, _ = cookiejar.New(nil)
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -54,7 +57,7 @@ func (r *Kyotou) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Kyotou) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -63,21 +66,21 @@ func (r *Kyotou) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			r.dt.SavePath = config.Conf.Directory
+			r.dt.SavePath = bookDir(r.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = CreateDirectory(vid)
+			r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		}
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -92,7 +95,7 @@ func (r *Kyotou) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -103,11 +106,12 @@ func (r *Kyotou) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -129,7 +133,7 @@ func (r *Kyotou) do(imgUrls []string) (msg string, err error) {
 }
 
 func (r *Kyotou) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -153,7 +157,7 @@ func (r *Kyotou) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 }
 
 func (r *Kyotou) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -181,33 +185,6 @@ func (r *Kyotou) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
 	return canvases, err
 }
 
-func (r *Kyotou) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (r *Kyotou) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
 func (r *Kyotou) getBookNumber(bs []byte) (bookNumber string, ok bool) {
 	//当前开始位置
 	match := regexp.MustCompile(`var[\s]+bookNum[\s]+=["'\s]*([A-z0-9]+)["'\s]*;`).FindStringSubmatch(string(bs))
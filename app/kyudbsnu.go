@@ -3,6 +3,7 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"bytes"
 	"context"
@@ -10,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -21,14 +23,16 @@ import (
 
 type KyudbSnu struct {
 	dt     *DownloadTask
+	ctx    context.Context
 	itemId string
 	entry  string
 }
 
-func NewKyudbSnu() *KyudbSnu {
+func NewKyudbSnu(ctx context.Context) *KyudbSnu {
 	return &KyudbSnu{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -46,6 +50,7 @@ func (r *KyudbSnu) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -81,19 +86,19 @@ func (r *KyudbSnu) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *KyudbSnu) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
-	bs, err := r.getBody(r.dt.Url, r.dt.Jar)
+	i18n.Logln("get", r.dt.Url)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(r.dt.Url)
 	if err != nil || bs == nil {
 		return "requested URL was not found.", err
 	}
 	//PDF
 	if bytes.Contains(bs, []byte("name=\"mfpdf_link\"")) {
-		r.dt.SavePath = config.Conf.Directory
+		r.dt.SavePath = bookDir(r.ctx)
 		canvases, err := r.getPdfUrls(r.dt.Url)
 		if err != nil || canvases == nil {
 			return "requested URL was not found.", err
 		}
-		log.Printf(" %d volumes \n", len(canvases))
+		log.Println(i18n.N("count.volumes", len(canvases), len(canvases)))
 		r.doPdf(canvases)
 		return "", nil
 	}
@@ -110,15 +115,15 @@ func (r *KyudbSnu) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
-		r.dt.SavePath = CreateDirectory(vol)
+		r.dt.SavePath = CreateDirectory(r.ctx, vol)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -131,9 +136,9 @@ func (r *KyudbSnu) do(imgUrls []string) (msg string, err error) {
 	fmt.Println()
 	referer := fmt.Sprintf("%s://%s/pf01/rendererImg.do", r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host)
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := r.ctx
 	for i, uri := range imgUrls {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		if uri == "" {
@@ -141,7 +146,7 @@ func (r *KyudbSnu) do(imgUrls []string) (msg string, err error) {
 		}
 		ext := util.FileExt(uri)
 		sortId := fmt.Sprintf("%04d", i+1)
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, len(imgUrls), uri)
+		i18n.Logln("get.page", i+1, len(imgUrls), uri)
 		filename := sortId + ext
 		dest := path.Join(r.dt.SavePath, filename)
 		opts := gohttp.Options{
@@ -156,6 +161,7 @@ func (r *KyudbSnu) do(imgUrls []string) (msg string, err error) {
 				"Referer":    referer,
 			},
 		}
+		pagePlanned(r.ctx)
 		_, err = gohttp.FastGet(ctx, uri, opts)
 		if err != nil {
 			fmt.Println(err)
@@ -171,12 +177,12 @@ func (r *KyudbSnu) doPdf(imgUrls []string) (msg string, err error) {
 		return
 	}
 	fmt.Println()
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	size := len(imgUrls)
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -186,11 +192,12 @@ func (r *KyudbSnu) doPdf(imgUrls []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -214,30 +221,22 @@ func (r *KyudbSnu) doPdf(imgUrls []string) (msg string, err error) {
 }
 
 func (r *KyudbSnu) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	d := map[string]interface{}{
-		"item_cd":       r.itemId,
-		"book_cd":       r.dt.BookId,
-		"vol_no":        "",
-		"page_no":       "",
-		"imgFileNm":     "",
-		"tbl_conts_seq": "",
-		"mokNm":         "",
-		"add_page_no":   "",
+	d := url.Values{
+		"item_cd":       {r.itemId},
+		"book_cd":       {r.dt.BookId},
+		"vol_no":        {""},
+		"page_no":       {""},
+		"imgFileNm":     {""},
+		"tbl_conts_seq": {""},
+		"mokNm":         {""},
+		"add_page_no":   {""},
 	}
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Referer":      url.PathEscape(sUrl),
-			"Content-Type": "application/x-www-form-urlencoded",
-		},
-		FormParams: d,
+	apiUrl := fmt.Sprintf("%s://%s/pf01/rendererImg.do", r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host)
+	bs, err := sessionFor(r.ctx, jar).Do(http.MethodPost, apiUrl, []byte(d.Encode()), http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Referer":      {sUrl},
 	})
-	resp, err := cli.Post(fmt.Sprintf("%s://%s/pf01/rendererImg.do", r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host))
-	bs, err := resp.GetBody()
-	if bs == nil || err != nil {
+	if err != nil {
 		return nil, err
 	}
 	matches := regexp.MustCompile(`<option\s+value=["']([A-z0-9]+)["']`).FindAllSubmatch(bs, -1)
@@ -253,33 +252,20 @@ func (r *KyudbSnu) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string
 
 func (r *KyudbSnu) getCanvases(vol string, jar *cookiejar.Jar) (canvases []string, err error) {
 	sUrl := fmt.Sprintf("%s://%s/pf01/rendererImg.do", r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host)
-	d := map[string]interface{}{
-		"item_cd": r.itemId,
-		"book_cd": r.dt.BookId,
-		"vol_no":  vol,
-		"page_no": "",
-		"tool":    "1",
+	d := url.Values{
+		"item_cd": {r.itemId},
+		"book_cd": {r.dt.BookId},
+		"vol_no":  {vol},
+		"page_no": {""},
+		"tool":    {"1"},
 	}
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Referer":      url.PathEscape(sUrl),
-			"Content-Type": "application/x-www-form-urlencoded",
-		},
-		FormParams: d,
+	bs, err := sessionFor(r.ctx, jar).Do(http.MethodPost, sUrl, []byte(d.Encode()), http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Referer":      {sUrl},
 	})
-	resp, err := cli.Post(sUrl)
 	if err != nil {
 		return nil, err
 	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		err = errors.New(resp.GetReasonPhrase())
-		return nil, err
-	}
 	var fromPage string
 	m := regexp.MustCompile(`first_page_no\s+=\s+['"]([A-z0-9]+)['"];`).FindSubmatch(bs)
 	if m != nil {
@@ -323,22 +309,13 @@ func (r *KyudbSnu) getPdfUrls(sUrl string) (canvases []string, err error) {
 	}
 
 	d := []byte("book_cd=" + r.dt.BookId)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Content-Type": "application/x-www-form-urlencoded",
-			"Referer":      sUrl,
-		},
-		Body: d,
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Do(http.MethodPost, "https://"+r.dt.UrlParsed.Host+"/ajax/book/mfPdfList.do", d, http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Referer":      {sUrl},
 	})
-	resp, err := cli.Post("https://" + r.dt.UrlParsed.Host + "/ajax/book/mfPdfList.do")
 	if err != nil {
 		return nil, err
 	}
-	bs, _ := resp.GetBody()
 
 	var res Response
 	if err = json.Unmarshal(bs, &res); err != nil {
@@ -352,24 +329,3 @@ func (r *KyudbSnu) getPdfUrls(sUrl string) (canvases []string, err error) {
 	}
 	return canvases, nil
 }
-
-func (r *KyudbSnu) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		err = errors.New(resp.GetReasonPhrase())
-		return nil, err
-	}
-	return bs, nil
-}
@@ -4,14 +4,13 @@ import (
 	"bookget/config"
 	"bookget/model/loc"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/sharedmemory"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -41,8 +40,20 @@ type Loc struct {
 	bookId    string
 }
 
-func NewLoc() *Loc {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "loc",
+		canonical: "https://www.loc.gov/item/%s/",
+		shapes: []urlShape{
+			shape(`^(?:www\.)?loc\.gov/item/([a-z0-9]+)`),
+			// Permalink by LCCN
+			shape(`^lccn\.loc\.gov/([a-z0-9]+)`),
+		},
+	})
+}
+
+func NewLoc(ctx context.Context) *Loc {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -51,7 +62,7 @@ func NewLoc() *Loc {
 	return &Loc{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -78,19 +89,20 @@ func (r *Loc) getBookId() (bookId string) {
 func (r *Loc) Run() (msg string, err error) {
 
 	r.bookId = r.getBookId()
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "[err=getBookId]", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 
 	apiUrl := fmt.Sprintf("https://www.loc.gov/item/%s/?fo=json", r.bookId)
 
 	//windows 处理
 	if os.PathSeparator == '\\' {
 		if util.OpenWebBrowser([]string{"-i", r.rawUrl}) {
-			fmt.Println("已启动 bookget-gui 浏览器，，请注意完成「真人验证」。")
+			i18n.Println("gui.started")
 			for i := 0; i < 10; i++ {
-				fmt.Printf("等待 bookget-gui 加载完成，还有 %d 秒 \r", 10-i)
+				fmt.Print(i18n.N("gui.wait", 10-i, 10-i) + " \r")
 				time.Sleep(time.Second * 1)
 			}
 		}
@@ -114,14 +126,15 @@ func (r *Loc) Run() (msg string, err error) {
 	if err != nil || r.canvases == nil {
 		return "", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	if os.PathSeparator == '\\' {
 		r.urlsFile = path.Join(r.savePath, "urls.txt")
 		err = os.WriteFile(r.urlsFile, []byte(r.bufBuilder.String()), os.ModePerm)
 		if err != nil {
 			return "", err
 		}
-		fmt.Printf("\n已生成图片URLs文件[%s]\n 可复制到 bookget-gui.exe 目录下，或使用其它软件下载。\n", r.urlsFile)
+		fmt.Println()
+		i18n.Println("gui.urls_file", r.urlsFile)
 		r.do(r.canvases)
 	} else {
 		r.letsGo(r.canvases)
@@ -135,13 +148,13 @@ func (r *Loc) do(canvases []string) (msg string, err error) {
 		return "[err=letsGo]", err
 	}
 
-	bar := progressbar.Default(int64(sizeVol), "downloading")
+	bar := progressbar.Default(int64(sizeVol), i18n.T("download.progress"))
 	for i, imgUrl := range canvases {
 		i++
 		sortId := fmt.Sprintf("%04d", i)
 		fileName := sortId + config.Conf.FileExt
 
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			bar.Add(1)
 			continue
 		}
@@ -152,6 +165,7 @@ func (r *Loc) do(canvases []string) (msg string, err error) {
 			continue
 		}
 
+		pagePlanned(r.ctx)
 		ok, err := r.imageDownloader(imgUrl, targetFilePath)
 		if err == nil && ok {
 			bar.Add(1)
@@ -173,7 +187,7 @@ func (r *Loc) letsGo(canvases []string) (msg string, err error) {
 		sortId := fmt.Sprintf("%04d", i)
 		fileName := sortId + config.Conf.FileExt
 
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		//跳过存在的文件
@@ -221,7 +235,7 @@ func (r *Loc) getCanvases() (canvases []string, err error) {
 //func (r *Loc) getVolumes() (volumes []string, err error) {
 //	var manifests = new(loc.ManifestsJson)
 //	if err = json.Unmarshal(r.responseBody, manifests); err != nil {
-//		log.Printf("json.Unmarshal failed: %s\n", err)
+//		i18n.Logln("json.failed", err)
 //		return
 //	}
 //	//一本书有N卷
@@ -255,30 +269,7 @@ func (r *Loc) getCanvases() (canvases []string, err error) {
 //}
 
 func (r *Loc) getBody(sUrl string) (bs []byte, err error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Get(sUrl)
 }
 
 func (r *Loc) getImagePage(fileUrls []loc.ImageFile) (downloadUrl string, ok bool) {
@@ -305,7 +296,7 @@ func (r *Loc) getImagePage(fileUrls []loc.ImageFile) (downloadUrl string, ok boo
 func (r *Loc) getBodyByGui(apiUrl string) (buf string, err error) {
 	err = sharedmemory.WriteURLToSharedMemory(apiUrl)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -321,7 +312,7 @@ func (r *Loc) getBodyByGui(apiUrl string) (buf string, err error) {
 func (r *Loc) imageDownloader(imgUrl, targetFilePath string) (ok bool, err error) {
 	err = sharedmemory.WriteURLImagePathToSharedMemory(imgUrl, targetFilePath)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
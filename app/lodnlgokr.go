@@ -3,14 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"bookget/pkg/sharedmemory"
 	"bookget/pkg/util"
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -40,8 +38,8 @@ type LodNLGoKr struct {
 	fileExt   string
 }
 
-func NewLodNLGoKr() *LodNLGoKr {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewLodNLGoKr(ctx context.Context) *LodNLGoKr {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -51,7 +49,7 @@ func NewLodNLGoKr() *LodNLGoKr {
 	return &LodNLGoKr{
 		// 初始化字段
 		dm:        dm,
-		client:    &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client:    &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:       ctx,
 		cancel:    cancel,
 		ServerUrl: "http://viewer.nl.go.kr:8080", //"https://viewer.nl.go.kr"
@@ -84,16 +82,17 @@ func (r *LodNLGoKr) getBookId(sUrl string) (bookId string) {
 
 func (r *LodNLGoKr) Run() (msg string, err error) {
 	r.bookId = r.getBookId(r.rawUrl)
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return "[err=getBookId]", err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 
 	webPageUrl := r.ServerUrl + "/nlmivs/viewWonmun_js.jsp?card_class=L&cno=" + r.bookId
 	if util.OpenWebBrowser([]string{"-i", webPageUrl}) {
-		fmt.Println("已启动 bookget-gui 浏览器，请注意完成「真人验证」。")
+		i18n.Println("gui.started")
 		for i := 0; i < 10; i++ {
-			fmt.Printf("等待 bookget-gui 加载完成，还有 %d 秒 \r", 10-i)
+			fmt.Print(i18n.N("gui.wait", 10-i, 10-i) + " \r")
 			time.Sleep(time.Second * 1)
 		}
 	}
@@ -104,7 +103,7 @@ func (r *LodNLGoKr) Run() (msg string, err error) {
 		return "[err=getBodyByGui]", err
 	}
 
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 
 	//PDF
 	if strings.Contains(r.bufBody, "extention = \"PDF\";") {
@@ -128,11 +127,11 @@ func (r *LodNLGoKr) Run() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.savePath = CreateDirectory(vid)
+		r.savePath = CreateDirectory(r.ctx, vid)
 		r.canvases, err = r.getCanvasesByUrl(i, vol.Url)
 		if err != nil || r.canvases == nil {
 			fmt.Println(err)
@@ -154,7 +153,7 @@ func (r *LodNLGoKr) do(canvases []string) (msg string, err error) {
 	}
 	counter := 0
 	for i, imgUrl := range canvases {
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -225,67 +224,20 @@ func (r *LodNLGoKr) getCanvasesByUrl(volId int, sUrl string) ([]string, error) {
 }
 
 func (r *LodNLGoKr) getBody(sUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Get(sUrl)
 }
 
 func (r *LodNLGoKr) postBody(sUrl string, postData []byte) ([]byte, error) {
-
-	req, err := http.NewRequest("POST", sUrl, bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Referer", r.ServerUrl+"/main.wviewer")
-
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(r.ctx, r.client).Do(http.MethodPost, sUrl, postData, http.Header{
+		"Content-Type": {"application/x-www-form-urlencoded"},
+		"Referer":      {r.ServerUrl + "/main.wviewer"},
+	})
 }
 
 func (r *LodNLGoKr) getBodyByGui(apiUrl string) (buf string, err error) {
 	err = sharedmemory.WriteURLToSharedMemory(apiUrl)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -3,11 +3,10 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
-	"errors"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path/filepath"
@@ -15,13 +14,15 @@ import (
 )
 
 type Luoyang struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewLuoyang() *Luoyang {
+func NewLuoyang(ctx context.Context) *Luoyang {
 	return &Luoyang{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -37,6 +38,7 @@ func (p *Luoyang) Run(sUrl string) (msg string, err error) {
 	p.dt.UrlParsed, err = url.Parse(sUrl)
 	p.dt.Url = sUrl
 	p.dt.BookId = p.getBookId(p.dt.Url)
+	setBookId(p.ctx, p.dt.BookId)
 	if p.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -52,18 +54,18 @@ func (p *Luoyang) getBookId(sUrl string) (bookId string) {
 }
 
 func (p *Luoyang) download() (msg string, err error) {
-	log.Printf("Get %s\n", p.dt.Url)
+	i18n.Logln("get", p.dt.Url)
 	respVolume, err := p.getVolumes(p.dt.Url, p.dt.Jar)
 	if err != nil {
 		fmt.Println(err)
 		return "getVolumes", err
 	}
-	p.dt.SavePath = config.Conf.Directory
+	p.dt.SavePath = bookDir(p.ctx)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(p.ctx, i, len(respVolume)) {
 			continue
 		}
-		log.Printf(" %d/%d volume, %s \n", i+1, len(respVolume), vol)
+		i18n.Logln("volume.of", i+1, len(respVolume), vol)
 		fName := util.FileName(vol)
 		sortId := fmt.Sprintf("%04d", i+1)
 		dest := filepath.Join(p.dt.SavePath, sortId+"."+fName)
@@ -74,7 +76,7 @@ func (p *Luoyang) download() (msg string, err error) {
 }
 
 func (p *Luoyang) do(dest, pdfUrl string) (msg string, err error) {
-	ctx := context.Background()
+	ctx := p.ctx
 	opts := gohttp.Options{
 		DestFile:    dest,
 		Overwrite:   false,
@@ -94,7 +96,7 @@ func (p *Luoyang) do(dest, pdfUrl string) (msg string, err error) {
 }
 
 func (p *Luoyang) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := p.getBody(sUrl, jar)
+	bs, err := sessionFor(p.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -120,30 +122,3 @@ func (p *Luoyang) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []strin
 	//TODO implement me
 	panic("implement me")
 }
-
-func (p *Luoyang) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (p *Luoyang) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
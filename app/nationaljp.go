@@ -3,9 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -14,13 +14,15 @@ import (
 
 type Nationaljp struct {
 	dt    *DownloadTask
+	ctx   context.Context
 	extId string
 }
 
-func NewNationaljp() *Nationaljp {
+func NewNationaljp(ctx context.Context) *Nationaljp {
 	return &Nationaljp{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -36,6 +38,7 @@ func (r *Nationaljp) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -53,16 +56,16 @@ func (r *Nationaljp) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Nationaljp) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes()
 	if err != nil {
 		fmt.Println(err)
 		return "getVolumes", err
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
@@ -71,7 +74,7 @@ func (r *Nationaljp) download() (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf(" %d/%d volume, %s\n", i+1, len(respVolume), r.extId)
+		i18n.Logln("volume.of", i+1, len(respVolume), r.extId)
 		r.do(i+1, vol, dest)
 		fmt.Println()
 	}
@@ -81,7 +84,7 @@ func (r *Nationaljp) download() (msg string, err error) {
 func (r *Nationaljp) do(index int, id, dest string) (msg string, err error) {
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/acv/auto_conversion/download"
 	data := fmt.Sprintf("DL_TYPE=%s&id_%d=%s", r.extId, index, id)
-	ctx := context.Background()
+	ctx := r.ctx
 	opts := gohttp.Options{
 		DestFile:    dest,
 		Overwrite:   false,
@@ -101,7 +104,7 @@ func (r *Nationaljp) do(index int, id, dest string) (msg string, err error) {
 
 func (r *Nationaljp) getVolumes() (volumes []string, err error) {
 	apiUrl := fmt.Sprintf("https://%s/DAS/meta/listPhoto?LANG=default&BID=%s&ID=&NO=&TYPE=dljpeg&DL_TYPE=jpeg", r.dt.UrlParsed.Host, r.dt.BookId)
-	bs, err := getBody(apiUrl, nil)
+	bs, err := sessionFor(r.ctx, nil).Get(apiUrl)
 	if err != nil {
 		return
 	}
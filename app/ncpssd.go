@@ -3,12 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"path/filepath"
@@ -17,13 +17,15 @@ import (
 )
 
 type Ncpssd struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewNcpssd() *Ncpssd {
+func NewNcpssd(ctx context.Context) *Ncpssd {
 	return &Ncpssd{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -61,17 +63,17 @@ func (r *Ncpssd) download() (msg string, err error) {
 		fmt.Println(err)
 		return "requested URL was not found.", err
 	}
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	bookId := r.dt.UrlParsed.Query().Get("type")
 	if bookId == "" {
 		bookId = "ncpssd"
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
-		log.Printf(" %d/%d volume, %s \n", i+1, len(respVolume), vol)
+		i18n.Logln("volume.of", i+1, len(respVolume), vol)
 		r.do(vol)
 		util.PrintSleepTime(config.Conf.Sleep)
 		fmt.Println()
@@ -84,7 +86,7 @@ func (r *Ncpssd) do(pdfUrl string) (msg string, err error) {
 	ext := util.FileExt(pdfUrl)
 	dest := filepath.Join(r.dt.SavePath, r.dt.BookId+ext)
 	jar, _ := cookiejar.New(nil)
-	ctx := context.Background()
+	ctx := r.ctx
 	referer := "https://" + r.dt.UrlParsed.Host
 	gohttp.FastGet(ctx, pdfUrl, gohttp.Options{
 		DestFile:    dest,
@@ -108,11 +110,13 @@ func (r *Ncpssd) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 	if strings.Contains(sUrl, "fullTextRead?filePath=") {
 		dUrl := r.getPdfUrl(sUrl)
 		r.dt.BookId = r.getBookId(dUrl)
+		setBookId(r.ctx, r.dt.BookId)
 		volumes = append(volumes, dUrl)
 	} else {
 		r.dt.BookId = r.getBookId(sUrl)
+		setBookId(r.ctx, r.dt.BookId)
 		name := fmt.Sprintf("%04d", r.dt.Index)
-		log.Printf("Get %s  %s\n", name, sUrl)
+		i18n.Logln("get.named", name, sUrl)
 		dUrl, err := r.getReadUrl(r.dt.BookId)
 		if err != nil {
 			return nil, err
@@ -128,46 +132,17 @@ func (r *Ncpssd) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
 }
 
 func (r *Ncpssd) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(r.dt.Url)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":       config.Conf.UserAgent,
-			"Referer":          referer,
-			"X-Requested-With": "XMLHttpRequest",
-			"Content-Type":     "application/json; charset=utf-8",
-		},
+	return sessionFor(r.ctx, jar).Do(http.MethodGet, sUrl, nil, http.Header{
+		"Referer":          {r.dt.Url},
+		"X-Requested-With": {"XMLHttpRequest"},
+		"Content-Type":     {"application/json; charset=utf-8"},
 	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
 }
 
 func (r *Ncpssd) postBody(sUrl string, d []byte) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Content-Type": "application/json; charset=utf-8",
-		},
-		Body: d,
+	return sessionFor(r.ctx, r.dt.Jar).Do(http.MethodPost, sUrl, d, http.Header{
+		"Content-Type": {"application/json; charset=utf-8"},
 	})
-	resp, err := cli.Post(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	return bs, err
 }
 
 func (r *Ncpssd) getReadUrl(bookId string) (string, error) {
@@ -4,9 +4,9 @@ import (
 	"bookget/config"
 	"bookget/model/iiif"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -17,13 +17,27 @@ import (
 )
 
 type NdlJP struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewNdlJP() *NdlJP {
+func init() {
+	addNormalizer(normalizer{
+		site:      "ndl",
+		canonical: "https://dl.ndl.go.jp/pid/%s",
+		shapes: []urlShape{
+			// /pid/1287288, /ja/pid/1287288/1/5, /info:ndljp/pid/1287288
+			shape(`^dl\.ndl\.go\.jp/(?:(?:ja|en)/)?(?:info:ndljp/)?pid/(\d+)`),
+			shape(`^dl\.ndl\.go\.jp/api/iiif/(\d+)/manifest\.json`),
+		},
+	})
+}
+
+func NewNdlJP(ctx context.Context) *NdlJP {
 	return &NdlJP{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -39,6 +53,7 @@ func (r *NdlJP) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -60,7 +75,7 @@ func (r *NdlJP) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		iiifUrl, _ := r.getManifestUrl(vol)
@@ -73,9 +88,9 @@ func (r *NdlJP) download() (msg string, err error) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return msg, err
@@ -90,7 +105,7 @@ func (r *NdlJP) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -101,11 +116,12 @@ func (r *NdlJP) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -128,7 +144,7 @@ func (r *NdlJP) do(imgUrls []string) (msg string, err error) {
 
 func (r *NdlJP) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/api/meta/search/toc/facet/" + r.dt.BookId
-	bs, err := r.getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
@@ -147,7 +163,7 @@ func (r *NdlJP) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, e
 	}
 	var result = new(ResponseBody)
 	if err = json.Unmarshal(bs, result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if result.Children == nil {
@@ -162,13 +178,13 @@ func (r *NdlJP) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, e
 }
 
 func (r *NdlJP) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -186,28 +202,6 @@ func (r *NdlJP) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 	return canvases, nil
 }
 
-func (r *NdlJP) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
 func (r *NdlJP) getManifestUrl(id string) (iiifUrl string, err error) {
 	type ResponseBody struct {
 		Item struct {
@@ -215,13 +209,13 @@ func (r *NdlJP) getManifestUrl(id string) (iiifUrl string, err error) {
 		} `json:"item"`
 	}
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/api/item/search/info:ndljp/pid/" + id
-	bs, err := r.getBody(apiUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(apiUrl)
 	if err != nil {
 		return "", err
 	}
 	var result ResponseBody
 	if err = json.Unmarshal(bs, &result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	return result.Item.IiifManifestUrl, nil
@@ -5,10 +5,10 @@ import (
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -23,11 +23,11 @@ type Niiac struct {
 	ctx context.Context
 }
 
-func NewNiiac() *Niiac {
+func NewNiiac(ctx context.Context) *Niiac {
 	return &Niiac{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -44,6 +44,7 @@ func (p *Niiac) Run(sUrl string) (msg string, err error) {
 	p.dt.UrlParsed, err = url.Parse(sUrl)
 	p.dt.Url = sUrl
 	p.dt.BookId = p.getBookId(p.dt.Url)
+	setBookId(p.ctx, p.dt.BookId)
 	if p.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -60,7 +61,7 @@ func (p *Niiac) getBookId(sUrl string) (bookId string) {
 }
 
 func (p *Niiac) download() (msg string, err error) {
-	log.Printf("Get %s\n", p.dt.Url)
+	i18n.Logln("get", p.dt.Url)
 
 	respVolume, err := p.getVolumes(p.dt.Url, p.dt.Jar)
 	if err != nil {
@@ -69,14 +70,14 @@ func (p *Niiac) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(p.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			p.dt.SavePath = config.Conf.Directory
+			p.dt.SavePath = bookDir(p.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			p.dt.SavePath = CreateDirectory(vid)
+			p.dt.SavePath = CreateDirectory(p.ctx, vid)
 		}
 
 		canvases, err := p.getCanvases(vol, p.dt.Jar)
@@ -84,7 +85,7 @@ func (p *Niiac) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		p.do(canvases)
 	}
 	return "", nil
@@ -100,7 +101,7 @@ func (p *Niiac) do(imgUrls []string) (msg string, err error) {
 }
 
 func (p *Niiac) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := getBody(sUrl, jar)
+	bs, err := sessionFor(p.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -114,13 +115,13 @@ func (p *Niiac) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, e
 }
 
 func (p *Niiac) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := p.getBody(sUrl, jar)
+	bs, err := sessionFor(p.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
 	var manifest = new(iiif.ManifestResponse)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -145,38 +146,11 @@ func (p *Niiac) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 
 }
 
-func (p *Niiac) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (p *Niiac) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
 func (p *Niiac) doDezoomify(iiifUrls []string) bool {
 	if iiifUrls == nil {
 		return false
 	}
-	referer := url.QueryEscape(p.dt.Url)
+	referer := p.dt.Url
 	args := []string{
 		"-H", "Origin:" + referer,
 		"-H", "Referer:" + referer,
@@ -184,7 +158,7 @@ func (p *Niiac) doDezoomify(iiifUrls []string) bool {
 	size := len(iiifUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(p.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -193,7 +167,8 @@ func (p *Niiac) doDezoomify(iiifUrls []string) bool {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
+		pagePlanned(p.ctx)
 		iiifDownloader.Dezoomify(p.ctx, uri, dest, args)
 
 	}
@@ -209,7 +184,7 @@ func (p *Niiac) doNormal(imgUrls []string) bool {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(p.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -221,11 +196,12 @@ func (p *Niiac) doNormal(imgUrls []string) bool {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(p.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := p.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/model/njuedu"
 	"bookget/pkg/downloader"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
@@ -23,11 +24,11 @@ type Njuedu struct {
 	ctx    context.Context
 }
 
-func NewNjuedu() *Njuedu {
+func NewNjuedu(ctx context.Context) *Njuedu {
 	return &Njuedu{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -43,6 +44,7 @@ func (r *Njuedu) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -58,7 +60,7 @@ func (r *Njuedu) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Njuedu) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	r.typeId, err = r.getDetail(r.dt.BookId, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err)
@@ -70,17 +72,17 @@ func (r *Njuedu) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return msg, err
@@ -90,7 +92,7 @@ func (r *Njuedu) do(dziUrls []string) (msg string, err error) {
 	if dziUrls == nil {
 		return "", err
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -99,7 +101,7 @@ func (r *Njuedu) do(dziUrls []string) (msg string, err error) {
 	size := len(dziUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, val := range dziUrls {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		fileName := fmt.Sprintf("%04d", i+1) + config.Conf.FileExt
@@ -109,6 +111,7 @@ func (r *Njuedu) do(dziUrls []string) (msg string, err error) {
 			continue
 		}
 
+		pagePlanned(r.ctx)
 		if err := iiifDownloader.Dezoomify(r.ctx, inputUri, outfile, args); err == nil {
 			os.Remove(inputUri)
 		}
@@ -119,13 +122,13 @@ func (r *Njuedu) do(dziUrls []string) (msg string, err error) {
 
 func (r *Njuedu) getDetail(bookId string, jar *cookiejar.Jar) (typeId int, err error) {
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/portal/book/getBookById?bookId=" + bookId
-	bs, err := getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return 0, err
 	}
 	var result njuedu.Detail
 	if err = json.Unmarshal(bs, &result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, v := range result.Data {
@@ -139,13 +142,13 @@ func (r *Njuedu) getDetail(bookId string, jar *cookiejar.Jar) (typeId int, err e
 
 func (r *Njuedu) getVolumes(bookId string, jar *cookiejar.Jar) (volumes []string, err error) {
 	apiUrl := fmt.Sprintf("https://%s/portal/book/getMasterSlaveCatalogue?typeId=%d&bookId=%s", r.dt.UrlParsed.Host, r.typeId, bookId)
-	bs, err := getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return nil, err
 	}
 	var result njuedu.Catalog
 	if err = json.Unmarshal(bs, &result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, d := range result.Data {
@@ -157,13 +160,13 @@ func (r *Njuedu) getVolumes(bookId string, jar *cookiejar.Jar) (volumes []string
 }
 
 func (r *Njuedu) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return nil, err
 	}
 	var result njuedu.Response
 	if err = json.Unmarshal(bs, &result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, id := range result.Data.Images {
@@ -189,7 +192,7 @@ func (r *Njuedu) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string
     }
 }
 `
-	bs, err = getBody(jsonUrl, jar)
+	bs, err = sessionFor(r.ctx, jar).Get(jsonUrl)
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/pkg/downloader"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"errors"
@@ -38,8 +39,8 @@ type ChinaNlc struct {
 	vectorBooks []string
 }
 
-func NewChinaNlc() *ChinaNlc {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewChinaNlc(ctx context.Context) *ChinaNlc {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -49,7 +50,7 @@ func NewChinaNlc() *ChinaNlc {
 	return &ChinaNlc{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 		jar:    jar,
@@ -71,8 +72,10 @@ func (r *ChinaNlc) Run() (msg string, err error) {
 	if strings.Contains(r.rawUrl, "OutOpenBook/Open") {
 		r.body, _ = r.getBody(r.rawUrl)
 		r.bookId = r.getBookId(string(r.body))
+		setBookId(r.ctx, r.bookId)
 	} else {
 		r.bookId = r.getBookId(r.rawUrl)
+		setBookId(r.ctx, r.bookId)
 	}
 	if r.bookId == "" {
 		return "requested URL was not found.", err
@@ -105,7 +108,7 @@ func (r *ChinaNlc) download() (msg string, err error) {
 	//单册PDF
 	if strings.Contains(r.rawUrl, "OutOpenBook/OpenObjectBook") {
 		//PDF
-		r.savePath = config.Conf.Directory
+		r.savePath = bookDir(r.ctx)
 		v, _ := r.identifier(r.rawUrl)
 		filename := v.Get("bid") + ".pdf"
 		err = r.doPdfUrl(r.rawUrl, filename)
@@ -113,18 +116,18 @@ func (r *ChinaNlc) download() (msg string, err error) {
 	}
 	//单张图
 	if strings.Contains(r.rawUrl, "OutOpenBook/OpenObjectPic") {
-		r.savePath = config.Conf.Directory
+		r.savePath = bookDir(r.ctx)
 		canvases, err := r.getCanvases()
 		if err != nil || canvases == nil {
 			return "", err
 		}
-		log.Printf("  %d pages \n", len(canvases))
+		log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 		r.do(canvases)
 		return "", err
 	}
 	//对照阅读单册
 	if strings.Contains(r.rawUrl, "OpenTwoObjectBook") {
-		r.savePath = config.Conf.Directory
+		r.savePath = bookDir(r.ctx)
 		v, _ := r.identifier(r.rawUrl)
 		filename := v.Get("bid") + ".pdf"
 		pageUrl := fmt.Sprintf("%s://%s/OutOpenBook/OpenObjectBook?aid=%s&bid=%s", r.parsedUrl.Scheme, r.parsedUrl.Host,
@@ -153,12 +156,12 @@ func (r *ChinaNlc) do(imgUrls []string) (msg string, err error) {
 		return
 	}
 	fmt.Println()
-	referer := url.QueryEscape(r.rawUrl)
+	referer := r.rawUrl
 	size := len(imgUrls)
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -168,7 +171,8 @@ func (r *ChinaNlc) do(imgUrls []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
@@ -201,25 +205,25 @@ func (r *ChinaNlc) downloadForPDFs() error {
 	}
 	size := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
 		//图片
 		if strings.Contains(vol, "OpenObjectPic") {
 			r.dataType = 1
-			r.savePath = CreateDirectory(vid)
+			r.savePath = CreateDirectory(r.ctx, vid)
 			canvases, err := r.getCanvases()
 			if err != nil || canvases == nil {
 				fmt.Println(err)
 				continue
 			}
-			log.Printf(" %d/%d volume, %d pages \n", i+1, size, len(canvases))
+			log.Println(i18n.N("volume.pages", len(canvases), i+1, size, len(canvases)))
 			r.do(canvases)
 		} else {
 			//PDF
-			r.savePath = config.Conf.Directory
-			log.Printf("Get %d/%d volume, URL: %s\n", i+1, size, vol)
+			r.savePath = bookDir(r.ctx)
+			i18n.Logln("get.volume", i+1, size, vol)
 			filename := vid + ".pdf"
 			r.doPdfUrl(vol, filename)
 		}
@@ -232,12 +236,12 @@ func (r *ChinaNlc) downloadForOCR() {
 		return
 	}
 	for i, vol := range r.vectorBooks {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(r.vectorBooks)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.savePath = CreateDirectory("ocr")
-		log.Printf("Get %d/%d volume, URL: %s\n", i+1, len(r.vectorBooks), vol)
+		r.savePath = CreateDirectory(r.ctx, "ocr")
+		i18n.Logln("get.volume", i+1, len(r.vectorBooks), vol)
 		filename := vid + ".pdf"
 		r.doPdfUrl(vol, filename)
 	}
@@ -363,30 +367,13 @@ func (r *ChinaNlc) identifier(sUrl string) (v url.Values, err error) {
 }
 
 func (r *ChinaNlc) getBody(apiUrl string) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	cli := gohttp.NewClient(r.ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
+	return sessionFor(r.ctx, r.jar).Get(apiUrl)
 }
 
 func (r *ChinaNlc) getToken(uri string) (tokenKey, timeKey, timeFlag string) {
 	body, err := r.getBody(uri)
 	if err != nil {
-		log.Printf("Server unavailable: %s", err.Error())
+		i18n.Logln("server_unavailable", err)
 		return
 	}
 	//<iframe id="myframe" name="myframe" src="" width="100%" height="100%" scrolling="no" frameborder="0" tokenKey="4ADAD4B379874C10864990817734A2BA" timeKey="1648363906519" timeFlag="1648363906519" sflag=""></iframe>
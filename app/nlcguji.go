@@ -3,14 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/nlc"
-	"bookget/pkg/chttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/util"
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"net/http/cookiejar"
@@ -40,8 +38,18 @@ type NlcGuji struct {
 	bufBuilder   strings.Builder
 }
 
-func NewNlcGuji() *NlcGuji {
-	ctx, cancel := context.WithCancel(context.Background())
+func init() {
+	addNormalizer(normalizer{
+		site:      "nlcguji",
+		canonical: "https://guji.nlc.cn/guji/pmgj/gjyxxq?metadataId=%s",
+		shapes: []urlShape{
+			shape(`^guji\.nlc\.cn/guji/pmgj/gjyxxq\?(?:.*&)?metadataId=(\d+)`),
+		},
+	})
+}
+
+func NewNlcGuji(ctx context.Context) *NlcGuji {
+	ctx, cancel := context.WithCancel(ctx)
 
 	// 创建自定义 Transport 忽略 SSL 验证
 	tr := NewHttpTransport()
@@ -49,7 +57,7 @@ func NewNlcGuji() *NlcGuji {
 
 	return &NlcGuji{
 		// 初始化字段
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -60,8 +68,9 @@ func (s *NlcGuji) GetRouterInit(sUrl string) (map[string]interface{}, error) {
 	s.parsedUrl, _ = url.Parse(sUrl)
 	s.Run()
 	return map[string]interface{}{
-		"type": "dzicnlib",
-		"url":  sUrl,
+		"type":   "dzicnlib",
+		"url":    sUrl,
+		"bookId": s.bookId,
 	}, nil
 }
 
@@ -92,10 +101,11 @@ func (s *NlcGuji) getBookId() (bookId string) {
 
 func (s *NlcGuji) Run() (msg string, err error) {
 	s.bookId = s.getBookId()
+	setBookId(s.ctx, s.bookId)
 	if s.bookId == "" {
 		return "[err=getBookId]", err
 	}
-	s.savePath = config.Conf.Directory
+	s.savePath = bookDir(s.ctx)
 	s.urlsFile = path.Join(s.savePath, "urls.txt")
 	//先生成书签目录
 	s.buildCatalog(path.Join(s.savePath, "catalog.txt"))
@@ -111,15 +121,13 @@ func (s *NlcGuji) Run() (msg string, err error) {
 		return "getVolumes", err
 	}
 
-	var i = 0
-	for _, item := range groupedVolumes {
-		if !config.VolumeRange(i) {
+	for i, item := range groupedVolumes {
+		if !config.VolumeRange(s.ctx, i, len(groupedVolumes)) {
 			continue
 		}
-		i++
-		vid := fmt.Sprintf("%04d", i)
-		s.savePath = CreateDirectory(vid)
-		log.Printf(" %d/%d volume, %d pages \n", i, len(groupedVolumes), len(item.Items))
+		vid := fmt.Sprintf("%04d", i+1)
+		s.savePath = CreateDirectory(s.ctx, vid)
+		log.Println(i18n.N("volume.pages", len(item.Items), i+1, len(groupedVolumes), len(item.Items)))
 		s.letsGo(item.Items)
 	}
 
@@ -127,7 +135,8 @@ func (s *NlcGuji) Run() (msg string, err error) {
 	if err != nil {
 		return "", err
 	}
-	fmt.Printf("\n已生成图片URLs文件[%s]\n 可复制到 bookget-gui.exe 目录下，或使用其它软件下载。\n", s.urlsFile)
+	fmt.Println()
+	i18n.Println("gui.urls_file", s.urlsFile)
 
 	return "", nil
 }
@@ -140,7 +149,7 @@ func (s *NlcGuji) letsGo(canvases []nlc.DataItem) (msg string, err error) {
 	imgServer := fmt.Sprintf("https://%s/api/common/jpgViewer?ftpId=1&filePathName=", s.parsedUrl.Host)
 
 	markHeader := s.u8Text("###SECURED_IMAGE###")
-	s.bar = progressbar.Default(int64(sizeVol), "downloading")
+	s.bar = progressbar.Default(int64(sizeVol), i18n.T("download.progress"))
 	for i, item := range canvases {
 		i++
 		sortId := fmt.Sprintf("%04d", i)
@@ -234,87 +243,18 @@ func (s *NlcGuji) getVolumes() (volumes []nlc.GroupedVolume, err error) {
 }
 
 func (s *NlcGuji) getBody(sUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", sUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	req.Header.Set("Origin", "https://"+s.parsedUrl.Host)
-	req.Header.Set("Referer", s.rawUrl)
-
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-
-	resp, err := s.client.Do(req.WithContext(s.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(s.ctx, s.client).Do(http.MethodGet, sUrl, nil, http.Header{
+		"Origin":  {"https://" + s.parsedUrl.Host},
+		"Referer": {s.rawUrl},
+	})
 }
 
 func (s *NlcGuji) postBody(sUrl string, postData []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", sUrl, bytes.NewBuffer(postData))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	req.Header.Set("Origin", "https://"+s.parsedUrl.Host)
-	req.Header.Set("Referer", s.rawUrl)
-	req.Header.Set("Content-Type", "application/json")
-
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-
-	resp, err := s.client.Do(req.WithContext(s.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(s.ctx, s.client).Do(http.MethodPost, sUrl, postData, http.Header{
+		"Origin":       {"https://" + s.parsedUrl.Host},
+		"Referer":      {s.rawUrl},
+		"Content-Type": {"application/json"},
+	})
 }
 
 func (s *NlcGuji) buildCatalog(outputPath string) {
@@ -326,13 +266,13 @@ func (s *NlcGuji) buildCatalog(outputPath string) {
 
 	structureData, err := s.postBody(apiUrl, rawData)
 	if err != nil {
-		fmt.Printf("获取目录结构失败: %v\n", err)
+		i18n.Println("nlcguji.structure_failed", err)
 		return
 	}
 
 	var structureResp nlc.StructureResponse
 	if err := json.Unmarshal(structureData, &structureResp); err != nil {
-		fmt.Printf("解析目录结构失败: %v\n", err)
+		i18n.Println("nlcguji.structure_failed", err)
 		return
 	}
 
@@ -341,13 +281,13 @@ func (s *NlcGuji) buildCatalog(outputPath string) {
 	apiUrl = fmt.Sprintf("https://%s/api/anc/ancImageIdListWithPageNum?metadataId=%s", s.parsedUrl.Host, s.bookId)
 	s.responseBody, err = s.postBody(apiUrl, rawData)
 	if err != nil {
-		fmt.Printf("获取页码映射失败: %v\n", err)
+		i18n.Println("nlcguji.pages_failed", err)
 		return
 	}
 
 	var pageResp nlc.PageResponse
 	if err := json.Unmarshal(s.responseBody, &pageResp); err != nil {
-		fmt.Printf("解析页码映射失败: %v\n", err)
+		i18n.Println("nlcguji.pages_failed", err)
 		return
 	}
 
@@ -380,11 +320,11 @@ func (s *NlcGuji) buildCatalog(outputPath string) {
 	// 保存到文件
 	content := strings.Join(catalog, "\n")
 	if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
-		fmt.Printf("保存文件失败: %v\n", err)
+		i18n.Println("save_failed", err)
 		return
 	}
 
-	fmt.Printf("目录已成功保存到 %s\n", outputPath)
+	i18n.Println("nlcguji.catalog_saved", outputPath)
 	//fmt.Printf("共生成 %d 条目录项）\n", len(catalog)-1)
 }
 
@@ -484,7 +424,7 @@ func (s *NlcGuji) removeMarkHeader(e, t []byte) []byte {
 	}
 
 	if !match {
-		log.Println("头部字节不匹配，不移除")
+		i18n.Logln("nlcguji.header_mismatch")
 		return e
 	}
 
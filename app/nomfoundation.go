@@ -3,12 +3,12 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -19,13 +19,15 @@ import (
 )
 
 type Nomfoundation struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewNomfoundation() *Nomfoundation {
+func NewNomfoundation(ctx context.Context) *Nomfoundation {
 	return &Nomfoundation{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -43,6 +45,7 @@ func (r *Nomfoundation) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -62,13 +65,13 @@ func (r *Nomfoundation) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Nomfoundation) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
-	r.dt.SavePath = config.Conf.Directory
+	i18n.Logln("get", r.dt.Url)
+	r.dt.SavePath = bookDir(r.ctx)
 	canvases, err := r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil || canvases == nil {
 		return "requested URL was not found.", err
 	}
-	log.Printf(" %d pages \n", len(canvases))
+	log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 	return r.do(canvases)
 }
 
@@ -82,7 +85,7 @@ func (r *Nomfoundation) do(canvases []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -92,11 +95,12 @@ func (r *Nomfoundation) do(canvases []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -128,7 +132,7 @@ func (r *Nomfoundation) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases [
 	if !strings.Contains(sUrl, "/page/") {
 		sUrl += "/page/1"
 	}
-	bs, err := r.getBody(sUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -154,23 +158,3 @@ func (r *Nomfoundation) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases [
 	}
 	return canvases, nil
 }
-
-func (r *Nomfoundation) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
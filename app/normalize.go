@@ -0,0 +1,137 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Record identifies a book at its site, whichever viewer, catalog, share or
+// manifest URL it was reached by.
+type Record struct {
+	Site string `json:"site"` // Short name of the site, as bookget search --site
+	ID   string `json:"record_id"`
+	URL  string `json:"url"` // Canonical URL, the one the site's adapter downloads
+}
+
+func (r Record) String() string {
+	return r.Site + "/" + r.ID
+}
+
+// urlShape is one URL shape of a site's records. pattern is matched against
+// host/path?query of the cleaned URL; id builds the record ID from its
+// submatches, by default the first one.
+type urlShape struct {
+	pattern *regexp.Regexp
+	id      func(m []string) string
+}
+
+// normalizer canonicalises the URLs of one site
+type normalizer struct {
+	site      string
+	canonical string                 // fmt pattern of the canonical URL, %s is the ID
+	urlOf     func(id string) string // Instead of canonical, when the URL has more than the ID
+	shapes    []urlShape
+}
+
+func shape(pattern string, id ...func(m []string) string) urlShape {
+	s := urlShape{pattern: regexp.MustCompile(pattern)}
+	if len(id) > 0 {
+		s.id = id[0]
+	}
+	return s
+}
+
+// prefixed returns an id func that puts prefix before the first submatch
+func prefixed(prefix string) func(m []string) string {
+	return func(m []string) string { return prefix + m[1] }
+}
+
+func unescaped(m []string) string {
+	if v, err := url.QueryUnescape(m[1]); err == nil {
+		return v
+	}
+	return m[1]
+}
+
+func lower(m []string) string {
+	return strings.ToLower(m[1])
+}
+
+// normalizers know the URL shapes of the sites whose records have them.
+// Each adapter adds its site's in its own file; sites without keep the ID
+// their adapter reads from the URL.
+var normalizers []normalizer
+
+func addNormalizer(n normalizer) {
+	normalizers = append(normalizers, n)
+}
+
+// Normalize maps sUrl to the record it shows. ok is false when no site knows the URL's shape.
+func Normalize(sUrl string) (rec Record, ok bool) {
+	u, err := url.Parse(CleanURL(sUrl))
+	if err != nil || u.Host == "" {
+		return Record{}, false
+	}
+	key := u.Host + u.EscapedPath()
+	if u.RawQuery != "" {
+		key += "?" + u.RawQuery
+	}
+	for _, n := range normalizers {
+		for _, s := range n.shapes {
+			m := s.pattern.FindStringSubmatch(key)
+			if m == nil {
+				continue
+			}
+			id := m[1]
+			if s.id != nil {
+				id = s.id(m)
+			}
+			rec = Record{Site: n.site, ID: id}
+			if n.urlOf != nil {
+				rec.URL = n.urlOf(id)
+			} else {
+				rec.URL = fmt.Sprintf(n.canonical, id)
+			}
+			return rec, true
+		}
+	}
+	return Record{}, false
+}
+
+// trackingParams never change which book a URL shows
+var trackingParams = []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "fbclid", "gclid"}
+
+// CleanURL drops what doesn't change the book a URL shows: viewer fragments
+// such as #page=3 (hash routes such as #/bookDetail?resId= are kept),
+// tracking parameters and default ports. The host is lowercased.
+func CleanURL(sUrl string) string {
+	sUrl = strings.TrimSpace(sUrl)
+	u, err := url.Parse(sUrl)
+	if err != nil || u.Host == "" {
+		return sUrl
+	}
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	if !strings.HasPrefix(u.Fragment, "/") && !strings.HasPrefix(u.Fragment, "!/") {
+		u.Fragment, u.RawFragment = "", ""
+	}
+	if u.RawQuery != "" {
+		q := u.RawQuery
+		for _, p := range trackingParams {
+			if strings.Contains(q, p+"=") {
+				v := u.Query()
+				for _, p := range trackingParams {
+					v.Del(p)
+				}
+				q = v.Encode()
+				break
+			}
+		}
+		u.RawQuery = q
+	}
+	return u.String()
+}
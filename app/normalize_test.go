@@ -0,0 +1,155 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		want Record
+		urls []string
+	}{
+		{Record{"ndl", "1287288", "https://dl.ndl.go.jp/pid/1287288"}, []string{
+			"https://dl.ndl.go.jp/pid/1287288",
+			"https://dl.ndl.go.jp/pid/1287288/1/5",
+			"https://dl.ndl.go.jp/ja/pid/1287288?__lang=ja",
+			"http://dl.ndl.go.jp/info:ndljp/pid/1287288",
+			"https://dl.ndl.go.jp/api/iiif/1287288/manifest.json",
+		}},
+		{Record{"loc", "2014514163", "https://www.loc.gov/item/2014514163/"}, []string{
+			"https://www.loc.gov/item/2014514163/",
+			"https://www.loc.gov/item/2014514163/?sp=3&st=gallery",
+			"https://loc.gov/item/2014514163",
+			"https://lccn.loc.gov/2014514163",
+		}},
+		{Record{"hathitrust", "uc1.b3656924", "https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924"}, []string{
+			"https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924",
+			"https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924&seq=7",
+			"https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924;view=1up;seq=7",
+			"https://babel.hathitrust.org/cgi/pt?view=1up&id=uc1.b3656924",
+			"https://babel.hathitrust.org/cgi/pt/search?q1=shi;id=uc1.b3656924",
+			"https://hdl.handle.net/2027/uc1.b3656924",
+		}},
+		{Record{"hathitrust", "uc2.ark:/13960/t0ns0pf9x", "https://babel.hathitrust.org/cgi/pt?id=uc2.ark:/13960/t0ns0pf9x"}, []string{
+			"https://babel.hathitrust.org/cgi/pt?id=uc2.ark%3A%2F13960%2Ft0ns0pf9x&seq=9",
+			"https://hdl.handle.net/2027/uc2.ark:/13960/t0ns0pf9x",
+		}},
+		{Record{"princeton", "9946093213506421", "https://catalog.princeton.edu/catalog/9946093213506421"}, []string{
+			"https://catalog.princeton.edu/catalog/9946093213506421",
+			"https://catalog.princeton.edu/catalog/9946093213506421#view",
+			"https://catalog.princeton.edu/catalog/9946093213506421/staff_view",
+		}},
+		{Record{"harvard", "drs:53262215", "https://iiif.lib.harvard.edu/manifests/view/drs:53262215"}, []string{
+			"https://iiif.lib.harvard.edu/manifests/view/drs:53262215",
+			"https://iiif.lib.harvard.edu/manifests/view/drs:53262215$5i",
+			"https://iiif.lib.harvard.edu/manifests/drs:53262215",
+			"https://listview.lib.harvard.edu/lists/drs-53262215",
+		}},
+		{Record{"kokusho", "100270332", "https://kokusho.nijl.ac.jp/biblio/100270332"}, []string{
+			"https://kokusho.nijl.ac.jp/biblio/100270332",
+			"https://kokusho.nijl.ac.jp/biblio/100270332/1?ln=ja",
+		}},
+		{Record{"waseda", "ri08_01899", "https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/"}, []string{
+			"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/",
+			"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/index.html",
+			"https://www.wul.waseda.ac.jp/kotenseki/html/ri08/ri08_01899/index.html",
+		}},
+		{Record{"sbb", "PPN3303598630", "https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630"}, []string{
+			"https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630&PHYSID=PHYS_0001",
+			"https://digital.staatsbibliothek-berlin.de/werkansicht/?PPN=3303598630",
+			"https://digital.staatsbibliothek-berlin.de/werkansicht?PHYSID=PHYS_0005&PPN=PPN3303598630&view=overview-toc",
+			"https://content.staatsbibliothek-berlin.de/dc/PPN3303598630/manifest",
+		}},
+		{Record{"bsb", "bsb11129280", "https://www.digitale-sammlungen.de/en/view/bsb11129280"}, []string{
+			"https://www.digitale-sammlungen.de/en/view/bsb11129280?page=1",
+			"https://www.digitale-sammlungen.de/de/view/bsb11129280?page=5,6",
+			"https://www.digitale-sammlungen.de/en/details/bsb11129280",
+			"https://ostasien.digitale-sammlungen.de/view/bsb11129280",
+			"https://api.digitale-sammlungen.de/iiif/presentation/v2/bsb11129280/manifest",
+			"https://mdz-nbn-resolving.de/urn:nbn:de:bvb:12-bsb11129280-3",
+			"https://mdz-nbn-resolving.de/details:bsb11129280",
+		}},
+		// Codex Mendoza, MS. Arch. Selden. A. 1
+		{Record{"bodleian", "2fea788e-2aa2-4f08-b6d9-648c00486220", "https://digital.bodleian.ox.ac.uk/objects/2fea788e-2aa2-4f08-b6d9-648c00486220/"}, []string{
+			"https://digital.bodleian.ox.ac.uk/objects/2fea788e-2aa2-4f08-b6d9-648c00486220/",
+			"https://digital.bodleian.ox.ac.uk/inquire/p/2fea788e-2aa2-4f08-b6d9-648c00486220",
+			"https://iiif.bodleian.ox.ac.uk/iiif/manifest/2fea788e-2aa2-4f08-b6d9-648c00486220.json",
+		}},
+		{Record{"berkeley", "74092", "https://digicoll.lib.berkeley.edu/record/74092"}, []string{
+			"https://digicoll.lib.berkeley.edu/record/74092",
+			"https://digicoll.lib.berkeley.edu/record/74092?ln=en",
+			"https://digicoll.lib.berkeley.edu/record/74092/export/xm",
+		}},
+		{Record{"onb", "DTL_2893716", "https://digital.onb.ac.at/RepViewer/viewer.faces?doc=DTL_2893716"}, []string{
+			"https://digital.onb.ac.at/RepViewer/viewer.faces?doc=DTL_2893716",
+			"https://digital.onb.ac.at/RepViewer/viewer.faces?doc=DTL_2893716&order=1&view=SINGLE",
+			"http://digital.onb.ac.at/RepViewer/viewer.faces?order=5&doc=DTL_2893716",
+		}},
+		{Record{"rsl", "rsl01004088050", "https://viewer.rsl.ru/ru/rsl01004088050"}, []string{
+			"https://viewer.rsl.ru/ru/rsl01004088050",
+			"https://viewer.rsl.ru/ru/rsl01004088050?page=7&rotate=0&theme=white",
+			"https://viewer.rsl.ru/en/rsl01004088050",
+			"https://search.rsl.ru/ru/record/01004088050",
+		}},
+		{Record{"cuhk", "cuhk-412225", "https://repository.lib.cuhk.edu.hk/en/item/cuhk-412225"}, []string{
+			"https://repository.lib.cuhk.edu.hk/sc/item/cuhk-412225",
+			"https://repository.lib.cuhk.edu.hk/tc/item/cuhk-412225#page/1/mode/2up",
+			"https://repository.lib.cuhk.edu.hk/en/islandora/object/cuhk%3A412225",
+		}},
+		{Record{"hku", "q524n4370", "https://digitalrepository.lib.hku.hk/catalog/q524n4370"}, []string{
+			"https://digitalrepository.lib.hku.hk/catalog/q524n4370",
+			"https://digitalrepository.lib.hku.hk/catalog/q524n4370#?c=0&m=0&s=0&cv=3",
+		}},
+		{Record{"nlcguji", "1001165", "https://guji.nlc.cn/guji/pmgj/gjyxxq?metadataId=1001165"}, []string{
+			"https://guji.nlc.cn/guji/pmgj/gjyxxq?metadataId=1001165",
+			"https://guji.nlc.cn/guji/pmgj/gjyxxq?metadataId=1001165&utm_source=wechat",
+		}},
+		{Record{"familysearch", "3:1:3QSQ-G9MC-ZSQ7-3", "https://www.familysearch.org/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3"}, []string{
+			"https://www.familysearch.org/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3",
+			"https://www.familysearch.org/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3?i=5&cat=1234",
+			"https://familysearch.org/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3",
+			"https://www.familysearch.org/zh/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3",
+		}},
+		{Record{"ncl", "NCL-9910010010", "https://taiwanebook.ncl.edu.tw/zh-tw/book/NCL-9910010010/reader"}, []string{
+			"https://taiwanebook.ncl.edu.tw/zh-tw/book/NCL-9910010010/reader",
+			"https://taiwanebook.ncl.edu.tw/en/book/NCL-9910010010",
+			"https://TaiwanEbook.ncl.edu.tw/zh-tw/book/NCL-9910010010/reader?fbclid=abc",
+		}},
+	}
+	for _, tt := range tests {
+		for _, sUrl := range tt.urls {
+			rec, ok := Normalize(sUrl)
+			assert.True(t, ok, sUrl)
+			assert.Equal(t, tt.want, rec, sUrl)
+		}
+		// The canonical URL is its own record
+		rec, _ := Normalize(tt.want.URL)
+		assert.Equal(t, tt.want, rec, tt.want.URL)
+		assert.Equal(t, tt.want.ID, getBookId(tt.urls[len(tt.urls)-1]))
+	}
+
+	for _, sUrl := range []string{
+		"https://www.loc.gov/collections/chinese-rare-books/",
+		"https://babel.hathitrust.org/cgi/ls?q1=shi+ji",
+		"https://guji.sdlib.com/#/bookDetail?resId=JK00001",
+		"https://example.org/iiif/manifest.json",
+		// A volume and a page of a book, not the whole book
+		"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/ri08_01899_0001/",
+		"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/ri08_01899_0001/ri08_01899_0001_p0003.jpg",
+		"not a url",
+	} {
+		_, ok := Normalize(sUrl)
+		assert.False(t, ok, sUrl)
+	}
+}
+
+func TestCleanURL(t *testing.T) {
+	assert.Equal(t, "https://example.org/book?id=1", CleanURL(" https://Example.ORG:443/book?id=1#page=3 "))
+	assert.Equal(t, "https://example.org/book?id=1", CleanURL("https://example.org/book?id=1&utm_source=x&fbclid=y"))
+	// Hash routes are the page of single-page apps
+	assert.Equal(t, "https://guji.sdlib.com/#/bookDetail?resId=JK00001", CleanURL("https://guji.sdlib.com/#/bookDetail?resId=JK00001"))
+	assert.Equal(t, getBookId("https://example.org/book?id=1"), getBookId("https://example.org/book?id=1#page=3"))
+	assert.NotEqual(t, getBookId("https://example.org/book?id=1"), getBookId("https://example.org/book?id=2"))
+}
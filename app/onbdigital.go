@@ -4,9 +4,9 @@ import (
 	"bookget/config"
 	"bookget/model/onbdigital"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -17,13 +17,25 @@ import (
 )
 
 type OnbDigital struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewOnbDigital() *OnbDigital {
+func init() {
+	addNormalizer(normalizer{
+		site:      "onb",
+		canonical: "https://digital.onb.ac.at/RepViewer/viewer.faces?doc=%s",
+		shapes: []urlShape{
+			shape(`^digital\.onb\.ac\.at/RepViewer/viewer\.faces\?(?:.*&)?doc=([A-Za-z]+_[^&]+)`),
+		},
+	})
+}
+
+func NewOnbDigital(ctx context.Context) *OnbDigital {
 	return &OnbDigital{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -39,6 +51,7 @@ func (r *OnbDigital) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -54,15 +67,15 @@ func (r *OnbDigital) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *OnbDigital) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err)
 		return "getVolumes", err
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
@@ -70,7 +83,7 @@ func (r *OnbDigital) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return msg, err
@@ -85,7 +98,7 @@ func (r *OnbDigital) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -96,11 +109,12 @@ func (r *OnbDigital) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -123,7 +137,7 @@ func (r *OnbDigital) do(imgUrls []string) (msg string, err error) {
 
 func (r *OnbDigital) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
 	//刷新cookie
-	_, err = r.getBody(sUrl, jar)
+	_, err = sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -133,13 +147,13 @@ func (r *OnbDigital) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []stri
 
 func (r *OnbDigital) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/OnbViewer/service/viewer/imageData?doc=" + r.dt.BookId + "&from=1&to=3000"
-	bs, err := r.getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
 	var result = new(onbdigital.Response)
 	if err = json.Unmarshal(bs, result); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	serverUrl := "https://" + r.dt.UrlParsed.Host + "/OnbViewer/image?"
@@ -149,28 +163,3 @@ func (r *OnbDigital) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []st
 	}
 	return canvases, err
 }
-
-func (r *OnbDigital) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (r *OnbDigital) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
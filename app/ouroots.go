@@ -3,15 +3,13 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/ouroots"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bookget/pkg/util"
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
@@ -24,14 +22,16 @@ import (
 
 type Ouroots struct {
 	dt      *DownloadTask
+	ctx     context.Context
 	Counter int
 	bar     *progressbar.ProgressBar
 }
 
-func NewOuroots() *Ouroots {
+func NewOuroots(ctx context.Context) *Ouroots {
 	return &Ouroots{
 		// 初始化字段
 		dt:      new(DownloadTask),
+		ctx:     ctx,
 		Counter: 0,
 	}
 }
@@ -48,6 +48,7 @@ func (r *Ouroots) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -64,7 +65,7 @@ func (r *Ouroots) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Ouroots) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.BookId)
 	if err != nil || respVolume.StatusCode != "200" {
@@ -72,18 +73,18 @@ func (r *Ouroots) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	//不按卷下载，所有图片存一个目录
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	macCounter := 0
 	for i, vol := range respVolume.Volume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume.Volume)) {
 			continue
 		}
 		macCounter += vol.Pages
 	}
 	fmt.Println()
-	r.bar = progressbar.Default(int64(macCounter), "downloading")
+	r.bar = progressbar.Default(int64(macCounter), i18n.T("download.progress"))
 	for i, vol := range respVolume.Volume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume.Volume)) {
 			continue
 		}
 		r.do(vol.Pages, vol.VolumeId)
@@ -127,64 +128,25 @@ func (r *Ouroots) do(pageTotal int, volumeId int) (msg string, err error) {
 }
 
 func (r *Ouroots) getVolumes(catalogKey string) (ouroots.ResponseVolume, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-		Query: map[string]interface{}{
-			"catalogKey": catalogKey,
-			"bookid":     "", //目录索引，不重要
-		},
-	})
-	resp, err := cli.Get("http://dsnode.ouroots.nlc.cn/gtService/data/catalogVolume")
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return ouroots.ResponseVolume{}, errors.New(resp.GetReasonPhrase())
+	query := url.Values{
+		"catalogKey": {catalogKey},
+		"bookid":     {""}, //目录索引，不重要
 	}
-
 	var respVolume ouroots.ResponseVolume
-	if err = json.Unmarshal(bs, &respVolume); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
-		return respVolume, errors.New(resp.GetReasonPhrase())
-	}
-	return respVolume, nil
-}
-
-func (r *Ouroots) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (r *Ouroots) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(sUrl)
+	err := sessionFor(r.ctx, r.dt.Jar).GetJSON("http://dsnode.ouroots.nlc.cn/gtService/data/catalogVolume?"+query.Encode(), &respVolume)
 	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
+		i18n.Logln("ouroots.volumes_failed", err)
 	}
-	return bs, nil
+	return respVolume, err
 }
 
-func (r *Ouroots) postBody(sUrl string, d []byte) ([]byte, error) {
+func (r *Ouroots) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	//TODO implement me
 	panic("implement me")
 }
 
 func (r *Ouroots) getToken() (string, error) {
-	bs, err := r.getBody("http://dsNode.ouroots.nlc.cn/loginAnonymousUser", r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get("http://dsNode.ouroots.nlc.cn/loginAnonymousUser")
 	if err != nil {
 		return "", err
 	}
@@ -195,29 +157,13 @@ func (r *Ouroots) getToken() (string, error) {
 	return respLoginAnonymousUser.Token, nil
 }
 func (r *Ouroots) getBase64Image(catalogKey string, volumeId, page int, userKey, token string) (respImage ouroots.ResponseCatalogImage, err error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-		Query: map[string]interface{}{
-			"catalogKey": catalogKey,
-			"volumeId":   strconv.FormatInt(int64(volumeId), 10),
-			"page":       strconv.FormatInt(int64(page), 10),
-			"userKey":    userKey,
-			"token":      token,
-		},
-	})
-	resp, err := cli.Get("http://dsnode.ouroots.nlc.cn/data/catalogImage")
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		err = errors.New(resp.GetReasonPhrase())
-		return
-	}
-	if err = json.Unmarshal(bs, &respImage); err != nil {
-		return
-	}
-	return respImage, nil
+	query := url.Values{
+		"catalogKey": {catalogKey},
+		"volumeId":   {strconv.FormatInt(int64(volumeId), 10)},
+		"page":       {strconv.FormatInt(int64(page), 10)},
+		"userKey":    {userKey},
+		"token":      {token},
+	}
+	err = sessionFor(r.ctx, r.dt.Jar).GetJSON("http://dsnode.ouroots.nlc.cn/data/catalogImage?"+query.Encode(), &respImage)
+	return respImage, err
 }
@@ -4,11 +4,12 @@ import (
 	"bookget/config"
 	"bookget/model/princeton"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"path"
@@ -18,13 +19,25 @@ import (
 )
 
 type Princeton struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewPrinceton() *Princeton {
+func init() {
+	addNormalizer(normalizer{
+		site:      "princeton",
+		canonical: "https://catalog.princeton.edu/catalog/%s",
+		shapes: []urlShape{
+			shape(`^catalog\.princeton\.edu/catalog/(\d+)`),
+		},
+	})
+}
+
+func NewPrinceton(ctx context.Context) *Princeton {
 	return &Princeton{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -40,6 +53,7 @@ func (r *Princeton) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -56,7 +70,7 @@ func (r *Princeton) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Princeton) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -64,17 +78,17 @@ func (r *Princeton) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -90,7 +104,7 @@ func (r *Princeton) do(canvases []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -100,11 +114,12 @@ func (r *Princeton) do(canvases []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -130,7 +145,7 @@ func (r *Princeton) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []strin
 	var manifestUrl = ""
 	//
 	if strings.Contains(sUrl, "dpul.princeton.edu") {
-		bs, err := r.getBody(sUrl, jar)
+		bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 		if err != nil {
 			return nil, err
 		}
@@ -154,7 +169,7 @@ func (r *Princeton) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []strin
 
 		}
 		if err = json.Unmarshal(bs, phql); err != nil {
-			log.Printf("json.Unmarshal failed: %s\n", err)
+			i18n.Logln("json.failed", err)
 			return nil, err
 		}
 		for _, v := range phql.Data.ResourcesByOrangelightIds {
@@ -168,12 +183,12 @@ func (r *Princeton) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []strin
 
 	//查全书分卷URL
 	var manifest = new(princeton.ResponseManifest)
-	body, err := r.getBody(manifestUrl, jar)
+	body, err := sessionFor(r.ctx, jar).Get(manifestUrl)
 	if err != nil {
 		return
 	}
 	if err = json.Unmarshal(body, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 
@@ -190,12 +205,12 @@ func (r *Princeton) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []strin
 
 func (r *Princeton) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	var manifest2 = new(princeton.ResponseManifest2)
-	body, err := r.getBody(sUrl, jar)
+	body, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
 	if err = json.Unmarshal(body, manifest2); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	i := len(manifest2.Sequences[0].Canvases)
@@ -215,45 +230,10 @@ func (r *Princeton) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []str
 	return canvases, nil
 }
 
-func (r *Princeton) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
 func (r *Princeton) postBody(sUrl string, d []byte) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Content-Type": "application/json",
-			"authority":    "figgy.princeton.edu",
-			"referer":      r.dt.Url,
-		},
-		Body: d,
+	return sessionFor(r.ctx, r.dt.Jar).Do(http.MethodPost, sUrl, d, http.Header{
+		"Content-Type": {"application/json"},
+		"Authority":    {"figgy.princeton.edu"},
+		"Referer":      {r.dt.Url},
 	})
-	resp, err := cli.Post(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	return bs, err
 }
@@ -4,12 +4,11 @@ import (
 	"bookget/config"
 	"bookget/model/rslru"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
-	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
@@ -21,13 +20,27 @@ import (
 
 type RslRu struct {
 	dt       *DownloadTask
+	ctx      context.Context
 	response *rslru.Response
 }
 
-func NewRslRu() *RslRu {
+func init() {
+	addNormalizer(normalizer{
+		site:      "rsl",
+		canonical: "https://viewer.rsl.ru/ru/%s",
+		shapes: []urlShape{
+			shape(`^viewer\.rsl\.ru/(?:[a-z]{2}/)?(rsl\d+)`),
+			// The catalog record has the same number
+			shape(`^search\.rsl\.ru/(?:[a-z]{2}/)?record/(\d+)`, prefixed("rsl")),
+		},
+	})
+}
+
+func NewRslRu(ctx context.Context) *RslRu {
 	return &RslRu{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -45,6 +58,7 @@ func (r *RslRu) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -61,19 +75,19 @@ func (r *RslRu) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *RslRu) download() (msg string, err error) {
-	log.Printf("Get  %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	r.response, err = r.getJsonResponse()
 	if err != nil {
 		return "requested URL was not found.", err
 	}
 	vid := regexp.MustCompile(`([\\/:：；\s]+)`).ReplaceAllString(r.response.Description.Title, "")
-	r.dt.SavePath = CreateDirectory(vid)
+	r.dt.SavePath = CreateDirectory(r.ctx, vid)
 	canvases, err := r.getCanvases(r.dt.Url, r.dt.Jar)
 	if err != nil || canvases == nil {
 		return "requested URL was not found.", err
 	}
-	log.Printf(" %d pages \n", len(canvases))
+	log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 	return r.do(canvases)
 }
 
@@ -87,7 +101,7 @@ func (r *RslRu) do(canvases []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -97,11 +111,12 @@ func (r *RslRu) do(canvases []string) (msg string, err error) {
 			continue
 		}
 		imgUrl := uri
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			cli := gohttp.NewClient(ctx, gohttp.Options{
 				CookieFile: config.Conf.CookieFile,
 				CookieJar:  nil,
@@ -141,32 +156,12 @@ func (r *RslRu) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 
 func (r *RslRu) getJsonResponse() (resp *rslru.Response, err error) {
 	apiUrl := fmt.Sprintf("https://viewer.rsl.ru/api/v1/document/%s/info", r.dt.BookId)
-	bs, err := r.getBody(apiUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
 	if err = json.Unmarshal(bs, resp); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 	}
 	return resp, err
 }
-
-func (r *RslRu) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
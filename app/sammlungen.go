@@ -1,21 +1,38 @@
 package app
 
 import (
+	"bookget/pkg/i18n"
+	"context"
 	"fmt"
-	"log"
 	"net/http/cookiejar"
 	"net/url"
 	"regexp"
 )
 
 type Sammlungen struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewSammlungen() *Sammlungen {
+func init() {
+	addNormalizer(normalizer{
+		site:      "bsb",
+		canonical: "https://www.digitale-sammlungen.de/en/view/%s",
+		shapes: []urlShape{
+			// view/bsb11129280?page=5,6 and the details page, in any language
+			shape(`^(?:www|ostasien)\.digitale-sammlungen\.de/(?:[a-z]{2}/)?(?:view|details)/(bsb\d{8})`),
+			shape(`^api\.digitale-sammlungen\.de/iiif/presentation/v2/(bsb\d{8})/`),
+			// URN resolver: urn:nbn:de:bvb:12-bsb11129280-3 or details:bsb11129280
+			shape(`^mdz-nbn-resolving\.de/(?:urn:nbn:de:bvb:12-|details:)(bsb\d{8})`),
+		},
+	})
+}
+
+func NewSammlungen(ctx context.Context) *Sammlungen {
 	return &Sammlungen{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -33,6 +50,7 @@ func (r *Sammlungen) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -49,7 +67,7 @@ func (r *Sammlungen) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Sammlungen) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	manifestUrl := fmt.Sprintf("https://api.digitale-sammlungen.de/iiif/presentation/v2/%s/manifest", r.dt.BookId)
 	var iiif IIIF
 	return iiif.InitWithId(r.dt.Index, manifestUrl, r.dt.BookId)
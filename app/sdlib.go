@@ -3,13 +3,10 @@ package app
 import (
 	"bookget/config"
 	"bookget/model/sdlib"
-	"bookget/pkg/chttp"
 	"bookget/pkg/downloader"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -39,8 +36,8 @@ type Sdlib struct {
 	bookId    string
 }
 
-func NewSdlib() *Sdlib {
-	ctx, cancel := context.WithCancel(context.Background())
+func NewSdlib(ctx context.Context) *Sdlib {
+	ctx, cancel := context.WithCancel(ctx)
 	dm := downloader.NewDownloadManager(ctx, cancel, config.Conf.MaxConcurrent)
 
 	// 创建自定义 Transport 忽略 SSL 验证
@@ -49,7 +46,7 @@ func NewSdlib() *Sdlib {
 	return &Sdlib{
 		// 初始化字段
 		dm:     dm,
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -88,10 +85,11 @@ func (r *Sdlib) getBookId(rawUrl string) (bookId string) {
 
 func (r *Sdlib) Run() (err error) {
 	r.bookId = r.getBookId(r.rawUrl)
+	setBookId(r.ctx, r.bookId)
 	if r.bookId == "" {
 		return err
 	}
-	r.savePath = config.Conf.Directory
+	r.savePath = bookDir(r.ctx)
 	r.urlsFile = path.Join(r.savePath, "urls.txt")
 
 	r.canvases, err = r.getCanvases(r.rawUrl)
@@ -122,7 +120,7 @@ func (r *Sdlib) do(canvases []string) (err error) {
 		sortId := fmt.Sprintf("%04d", i)
 		fileName := sortId + filepath.Ext(imgUrl)
 
-		if imgUrl == "" || !config.PageRange(i, sizeVol) {
+		if imgUrl == "" || !config.PageRange(r.ctx, i, sizeVol) {
 			continue
 		}
 		//跳过存在的文件
@@ -153,7 +151,7 @@ func (r *Sdlib) getVolumes(rawUrl string) (volumes []string, err error) {
 }
 
 func (r *Sdlib) getCanvases(rawUrl string) (canvases []string, err error) {
-	apiUrl := fmt.Sprintf("http://%s/dev-api/ancientbooks/front/getFileContentPage/3/", r.parsedUrl.Host, r.bookId)
+	apiUrl := fmt.Sprintf("http://%s/dev-api/ancientbooks/front/getFileContentPage/3/%s", r.parsedUrl.Host, r.bookId)
 	r.bufBody, err = r.getBody(apiUrl)
 	if err != nil {
 		return nil, err
@@ -172,43 +170,5 @@ func (r *Sdlib) getCanvases(rawUrl string) (canvases []string, err error) {
 }
 
 func (r *Sdlib) getBody(rawUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", rawUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-	resp, err := r.client.Do(req.WithContext(r.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
-}
-
-func (r *Sdlib) postBody(rawUrl string, postData interface{}) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	return clientSession(r.ctx, r.client).Get(rawUrl)
 }
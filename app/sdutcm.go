@@ -5,6 +5,7 @@ import (
 	"bookget/model/sdutcm"
 	"bookget/pkg/crypt"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
@@ -19,14 +20,16 @@ import (
 
 type Sdutcm struct {
 	dt    *DownloadTask
+	ctx   context.Context
 	token string
 	body  []byte
 }
 
-func NewSdutcm() *Sdutcm {
+func NewSdutcm(ctx context.Context) *Sdutcm {
 	return &Sdutcm{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -42,6 +45,7 @@ func (r *Sdutcm) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -59,7 +63,7 @@ func (r *Sdutcm) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Sdutcm) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	r.body, err = r.getPageContent(r.dt.Url)
 	if err != nil {
 		return "requested URL was not found.", err
@@ -71,17 +75,17 @@ func (r *Sdutcm) download() (msg string, err error) {
 	}
 	config.Conf.FileExt = ".pdf"
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -91,9 +95,9 @@ func (r *Sdutcm) do(imgUrls []string) (msg string, err error) {
 	fmt.Println()
 	referer := r.dt.Url
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := r.ctx
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -102,9 +106,9 @@ func (r *Sdutcm) do(imgUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d,  URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 
-		bs, err := getBody(uri, r.dt.Jar)
+		bs, err := sessionFor(r.ctx, r.dt.Jar).Get(uri)
 		var respBody sdutcm.PagePicTxt
 		if err = json.Unmarshal(bs, &respBody); err != nil {
 			break
@@ -124,6 +128,7 @@ func (r *Sdutcm) do(imgUrls []string) (msg string, err error) {
 				"Referer":    referer,
 			},
 		}
+		pagePlanned(r.ctx)
 		for k := 0; k < 10; k++ {
 			resp, err := gohttp.FastGet(ctx, pdfUrl, opts)
 			if err == nil && resp.GetStatusCode() == 200 {
@@ -144,7 +149,7 @@ func (r *Sdutcm) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string,
 		return nil, err
 	}
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/sdutcm/ancient/book/getVolume.jspx?lshh=" + ancientVolume
-	bs, err := getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	var respBody sdutcm.VolumeList
 	if err = json.Unmarshal(bs, &respBody); err != nil {
 		return nil, err
@@ -193,7 +198,7 @@ func (r *Sdutcm) getVolumeId(bs []byte) string {
 }
 
 func (r *Sdutcm) getPageContent(sUrl string) (bs []byte, err error) {
-	r.body, err = getBody(sUrl, r.dt.Jar)
+	r.body, err = sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,321 @@
+package app
+
+import (
+	"bookget/config"
+	"bookget/pkg/gohttp"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// defaultMaxBodySize caps metadata responses (manifests, API answers, HTML pages)
+const defaultMaxBodySize = 64 << 20
+
+// StatusError is returned for responses other than 200, 203 and 206
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ErrCode:%d, %s (%s)", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
+// Session is the HTTP session of one book: its cookie jar (joined with
+// --cookies), User-Agent and header.txt, Referer, retries and response-size
+// limit. It runs on the shared transport, so header profiles, proxies, the
+// metadata cache and --limit-rate apply as well.
+type Session struct {
+	ctx    context.Context
+	client *http.Client
+
+	Header      http.Header // Sent with every request, unless the request sets it
+	Referer     string      // Empty sends the request URL itself, unless headers.ini has a Referer for the host
+	Retries     int         // Extra attempts after network errors, 429 and 5xx
+	RetryPosts  bool        // Retry POSTs as well as GET and HEAD, for APIs whose POSTs only read
+	MaxBodySize int64       // Larger responses fail
+}
+
+// NewSession returns a session with its own cookie jar; jar may be nil
+func NewSession(ctx context.Context, jar *cookiejar.Jar) *Session {
+	if jar == nil {
+		jar, _ = cookiejar.New(nil)
+	}
+	client := gohttp.PooledClient(gohttp.DefaultTransportKey, withCookieFile(jar), config.Conf.Timeout*time.Second)
+	return newClientSession(ctx, client)
+}
+
+// newClientSession wraps the client an adapter already has
+func newClientSession(ctx context.Context, client *http.Client) *Session {
+	s := &Session{
+		ctx:         ctx,
+		client:      client,
+		Header:      make(http.Header),
+		Retries:     config.Conf.Retries,
+		MaxBodySize: defaultMaxBodySize,
+	}
+	for k, v := range BuildRequestHeader() {
+		s.Header.Set(k, v)
+	}
+	return s
+}
+
+// bookSessions are the sessions of one book, by cookie jar or client. They
+// go away with the book's context.
+type bookSessions struct {
+	mu sync.Mutex
+	of map[any]*Session
+}
+
+type sessionsKey struct{}
+
+func withSessions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionsKey{}, &bookSessions{of: make(map[any]*Session)})
+}
+
+// bookSession returns the session of the book of ctx for key, made by
+// newSession on first use. Outside of a book every call gets a new one.
+func bookSession(ctx context.Context, key any, newSession func() *Session) *Session {
+	b, ok := ctx.Value(sessionsKey{}).(*bookSessions)
+	if !ok {
+		return newSession()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s, ok := b.of[key]
+	if !ok {
+		s = newSession()
+		b.of[key] = s
+	}
+	return s
+}
+
+// sessionFor returns the session of the book of ctx owning jar. Requests
+// without a jar share one session of the book.
+func sessionFor(ctx context.Context, jar *cookiejar.Jar) *Session {
+	return bookSession(ctx, jar, func() *Session { return NewSession(ctx, jar) })
+}
+
+// clientSession returns the session of the book of ctx on the client an
+// adapter already has
+func clientSession(ctx context.Context, client *http.Client) *Session {
+	return bookSession(ctx, client, func() *Session { return newClientSession(ctx, client) })
+}
+
+// Get returns the body of rawUrl
+func (s *Session) Get(rawUrl string) ([]byte, error) {
+	return s.Do(http.MethodGet, rawUrl, nil, nil)
+}
+
+// PostForm posts an application/x-www-form-urlencoded body
+func (s *Session) PostForm(rawUrl string, data []byte) ([]byte, error) {
+	return s.Do(http.MethodPost, rawUrl, data, http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+}
+
+// PostJSON posts v as JSON
+func (s *Session) PostJSON(rawUrl string, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
+	}
+	return s.Do(http.MethodPost, rawUrl, data, http.Header{"Content-Type": {"application/json"}})
+}
+
+// Post posts []byte and string as a form and anything else as JSON
+func (s *Session) Post(rawUrl string, postData interface{}) ([]byte, error) {
+	return s.PostWith(rawUrl, postData, nil)
+}
+
+// PostWith is Post with extra headers. isJSON tells extra which body was sent.
+func (s *Session) PostWith(rawUrl string, postData interface{}, extra func(h http.Header, isJSON bool)) ([]byte, error) {
+	var body []byte
+	isJSON := false
+	switch v := postData.(type) {
+	case nil:
+	case []byte:
+		body = v
+	case string:
+		body = []byte(v)
+	default:
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
+		}
+		isJSON = true
+	}
+	h := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	if isJSON {
+		h.Set("Content-Type", "application/json")
+	}
+	if extra != nil {
+		extra(h, isJSON)
+	}
+	return s.Do(http.MethodPost, rawUrl, body, h)
+}
+
+// GetJSON decodes the JSON at rawUrl into v
+func (s *Session) GetJSON(rawUrl string, v interface{}) error {
+	bs, err := s.Get(rawUrl)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(trimToJSON(bs), v)
+}
+
+// GetXML decodes the XML at rawUrl into v
+func (s *Session) GetXML(rawUrl string, v interface{}) error {
+	bs, err := s.Get(rawUrl)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(bs, v)
+}
+
+// GetHTML returns the page at rawUrl converted to UTF-8 from the charset of
+// Content-Type or <meta charset>
+func (s *Session) GetHTML(rawUrl string) (string, error) {
+	var contentType string
+	bs, err := s.do(http.MethodGet, rawUrl, nil, nil, func(resp *http.Response) {
+		contentType = resp.Header.Get("Content-Type")
+	})
+	if err != nil {
+		return "", err
+	}
+	return decodeHTML(bs, contentType), nil
+}
+
+// Do sends a request with the session headers, and extra on top of them
+func (s *Session) Do(method, rawUrl string, body []byte, extra http.Header) ([]byte, error) {
+	return s.do(method, rawUrl, body, extra, nil)
+}
+
+func (s *Session) do(method, rawUrl string, body []byte, extra http.Header, seen func(*http.Response)) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-s.ctx.Done():
+				return nil, s.ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		var bs []byte
+		var retry bool
+		bs, retry, err = s.once(method, rawUrl, body, extra, seen)
+		if err == nil || !retry || !s.retries(method) {
+			return bs, err
+		}
+	}
+	return nil, err
+}
+
+// retries tells whether a failed request of method is sent again. A POST
+// may have done its work before failing.
+func (s *Session) retries(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || s.RetryPosts
+}
+
+// once sends one request. retry tells whether another attempt may succeed.
+func (s *Session) once(method, rawUrl string, body []byte, extra http.Header, seen func(*http.Response)) (bs []byte, retry bool, err error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(s.ctx, method, rawUrl, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	for k, v := range extra {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Referer") == "" {
+		if s.Referer != "" {
+			req.Header.Set("Referer", s.Referer)
+		} else if gohttp.ProfileHeaders(req.URL.Hostname(), gohttp.Book{}).Get("Referer") == "" {
+			req.Header.Set("Referer", rawUrl)
+		}
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, s.ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	if seen != nil {
+		seen(resp)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusPartialContent:
+	default:
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, &StatusError{URL: rawUrl, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	limit := s.MaxBodySize
+	if limit <= 0 {
+		limit = defaultMaxBodySize
+	}
+	bs, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, s.ctx.Err() == nil, err
+	}
+	if int64(len(bs)) > limit {
+		return nil, false, fmt.Errorf("response of %s is larger than %d bytes", rawUrl, limit)
+	}
+	return bs, false, nil
+}
+
+// trimToJSON drops what some servers put before the JSON document, a BOM or
+// stray characters such as '?' (www.dh-jac.net)
+func trimToJSON(bs []byte) []byte {
+	if i := bytes.IndexAny(bs, "{["); i > 0 {
+		return bs[i:]
+	}
+	return bs
+}
+
+var metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset=["']?([\w-]+)`)
+
+// decodeHTML converts a page to UTF-8. Unknown charsets are left as they are.
+func decodeHTML(bs []byte, contentType string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		name = params["charset"]
+	}
+	if name == "" {
+		head := bs
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if m := metaCharset.FindSubmatch(head); m != nil {
+			name = string(m[1])
+		}
+	}
+	if name == "" || strings.EqualFold(name, "utf-8") {
+		return string(bs)
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return string(bs)
+	}
+	out, err := enc.NewDecoder().Bytes(bs)
+	if err != nil {
+		return string(bs)
+	}
+	return string(out)
+}
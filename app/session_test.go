@@ -0,0 +1,124 @@
+package app
+
+import (
+	"bookget/pkg/gohttp"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/busy":
+			if calls.Add(1) == 1 {
+				http.Error(w, "busy", http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		case "/referer":
+			_, _ = w.Write([]byte(r.Header.Get("Referer")))
+		case "/manifest":
+			_, _ = w.Write([]byte("?{\"label\":\"論語\"}"))
+		case "/gbk":
+			w.Header().Set("Content-Type", "text/html; charset=gbk")
+			_, _ = w.Write([]byte{0xc2, 0xdb, 0xd3, 0xef}) // 论语
+		case "/big":
+			_, _ = w.Write([]byte(strings.Repeat("x", 100)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	// The test server is local, not a recorded site
+	saved := gohttp.Fixtures
+	gohttp.Fixtures = gohttp.FixturePolicy{}
+	defer func() { gohttp.Fixtures = saved }()
+
+	s := NewSession(context.Background(), nil)
+	s.Retries = 1
+
+	// 5xx is retried
+	bs, err := s.Get(srv.URL + "/busy")
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(bs))
+	assert.EqualValues(t, 2, calls.Load())
+
+	// POSTs are not, unless the session says so
+	calls.Store(0)
+	_, err = s.PostForm(srv.URL+"/busy", nil)
+	assert.Error(t, err)
+	assert.EqualValues(t, 1, calls.Load())
+	calls.Store(0)
+	s.RetryPosts = true
+	_, err = s.PostForm(srv.URL+"/busy", nil)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// Other statuses fail at once
+	_, err = s.Get(srv.URL + "/missing")
+	var se *StatusError
+	require.True(t, errors.As(err, &se))
+	assert.Equal(t, http.StatusNotFound, se.StatusCode)
+
+	// Referer is the request URL, unless set
+	bs, err = s.Get(srv.URL + "/referer")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/referer", string(bs))
+	s.Referer = srv.URL + "/book"
+	bs, err = s.Get(srv.URL + "/referer")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/book", string(bs))
+
+	var manifest struct {
+		Label string `json:"label"`
+	}
+	require.NoError(t, s.GetJSON(srv.URL+"/manifest", &manifest))
+	assert.Equal(t, "論語", manifest.Label)
+
+	html, err := s.GetHTML(srv.URL + "/gbk")
+	require.NoError(t, err)
+	assert.Equal(t, "论语", html)
+
+	s.MaxBodySize = 10
+	_, err = s.Get(srv.URL + "/big")
+	assert.Error(t, err)
+}
+
+func TestSessionFor(t *testing.T) {
+	jar, _ := cookiejar.New(nil)
+	book := NewBookContext(context.Background(), "https://example.org/book")
+	other := NewBookContext(context.Background(), "https://example.org/other")
+
+	// One session per book and jar, none shared between books
+	s := sessionFor(book, jar)
+	assert.Same(t, s, sessionFor(book, jar))
+	assert.NotSame(t, s, sessionFor(other, jar))
+	assert.Same(t, sessionFor(book, nil), sessionFor(book, nil))
+	assert.NotSame(t, sessionFor(context.Background(), jar), sessionFor(context.Background(), jar))
+
+	// Ctrl-C stops the retries of the book
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	saved := gohttp.Fixtures
+	gohttp.Fixtures = gohttp.FixturePolicy{}
+	defer func() { gohttp.Fixtures = saved }()
+	s = sessionFor(NewBookContext(ctx, srv.URL), jar)
+	s.Retries = 5
+	_, err := s.Get(srv.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -4,10 +4,9 @@ import (
 	"bookget/config"
 	"bookget/model/iiif"
 	"bookget/pkg/downloader"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -25,11 +24,11 @@ type SiEdu struct {
 	ctx context.Context
 }
 
-func NewSiEdu() *SiEdu {
+func NewSiEdu(ctx context.Context) *SiEdu {
 	return &SiEdu{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -48,6 +47,7 @@ func (r *SiEdu) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -77,14 +77,14 @@ func (r *SiEdu) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *SiEdu) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
-	r.dt.SavePath = config.Conf.Directory
+	i18n.Logln("get", r.dt.Url)
+	r.dt.SavePath = bookDir(r.ctx)
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/ids/manifest/" + r.dt.BookId
 	canvases, err := r.getCanvases(apiUrl, r.dt.Jar)
 	if err != nil || canvases == nil {
 		return "requested URL was not found.", err
 	}
-	log.Printf(" %d images \n", len(canvases))
+	log.Println(i18n.N("count.images", len(canvases), len(canvases)))
 	return r.do(canvases)
 }
 
@@ -92,7 +92,7 @@ func (r *SiEdu) do(iiifUrls []string) (msg string, err error) {
 	if iiifUrls == nil {
 		return
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -101,7 +101,7 @@ func (r *SiEdu) do(iiifUrls []string) (msg string, err error) {
 	size := len(iiifUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range iiifUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -118,7 +118,8 @@ func (r *SiEdu) do(iiifUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %s  %s\n", sortId, uri)
+		i18n.Logln("get.named", sortId, uri)
+		pagePlanned(r.ctx)
 		if err := iiifDownloader.Dezoomify(r.ctx, inputUri, dest, args); err == nil {
 			os.Remove(inputUri)
 		}
@@ -133,7 +134,7 @@ func (r *SiEdu) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 		return nil, err
 	}
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -151,24 +152,9 @@ func (r *SiEdu) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 }
 
 func (r *SiEdu) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    r.dt.Url,
-			"authority":  "www.si.edu",
-			"origin":     "https://www.si.edu/",
-		},
+	return sessionFor(r.ctx, jar).Do(http.MethodGet, apiUrl, nil, http.Header{
+		"Referer":   {r.dt.Url},
+		"Authority": {"www.si.edu"},
+		"Origin":    {"https://www.si.edu/"},
 	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != http.StatusOK {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
 }
@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/model/szLib"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
@@ -19,13 +20,15 @@ import (
 )
 
 type SzLib struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewSzLib() *SzLib {
+func NewSzLib(ctx context.Context) *SzLib {
 	return &SzLib{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -41,6 +44,7 @@ func (r *SzLib) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -57,7 +61,7 @@ func (r *SzLib) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *SzLib) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url)
 	if err != nil {
@@ -66,15 +70,15 @@ func (r *SzLib) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume.Volumes)
 	for i, vol := range respVolume.Volumes {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume.Volumes)) {
 			continue
 		}
-		fmt.Printf("\r Test volume %d ... ", i+1)
+		fmt.Print("\r" + i18n.T("szlib.test_volume", i+1) + " ")
 		if sizeVol == 1 {
-			r.dt.SavePath = config.Conf.Directory
+			r.dt.SavePath = bookDir(r.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = CreateDirectory(vid)
+			r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		}
 
 		canvases, err := r.getCanvases(vol)
@@ -83,7 +87,7 @@ func (r *SzLib) download() (msg string, err error) {
 			continue
 		}
 		fmt.Println()
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -98,7 +102,7 @@ func (r *SzLib) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -110,11 +114,12 @@ func (r *SzLib) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -143,7 +148,7 @@ func (r *SzLib) getVolumes(sUrl string) (*szLib.ResultVolumes, error) {
 	}
 	var rstVolumes = new(szLib.ResultVolumes)
 	if err = json.Unmarshal(bs, rstVolumes); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return nil, err
 	}
 	return rstVolumes, err
@@ -163,28 +168,7 @@ func (r *SzLib) getCanvases(vol szLib.Directory) ([]string, error) {
 }
 
 func (r *SzLib) getBody(sUrl string) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  r.dt.Jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (r *SzLib) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
+	return sessionFor(r.ctx, r.dt.Jar).Get(sUrl)
 }
 
 func (r *SzLib) getSinglePage(bookId string, volumeId string, page string) (string, error) {
@@ -2,14 +2,14 @@ package app
 
 import (
 	"bookget/config"
-	"bookget/pkg/gohttp"
 	xhash "bookget/pkg/hash"
+	"bookget/pkg/i18n"
+	"bookget/pkg/postprocess"
 	"bookget/pkg/util"
 	"bytes"
 	"context"
-	"errors"
-	"fmt"
 	"io"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
@@ -51,84 +51,29 @@ func getBookId(sUrl string) (bookId string) {
 	if sUrl == "" {
 		return ""
 	}
+	// 同一本书的各种 URL 用同一个 ID
+	if rec, ok := Normalize(sUrl); ok {
+		return rec.ID
+	}
 	mh := xhash.NewMultiHasher()
-	_, _ = io.Copy(mh, bytes.NewBuffer([]byte(sUrl)))
+	_, _ = io.Copy(mh, bytes.NewBuffer([]byte(CleanURL(sUrl))))
 	bookId, _ = mh.SumString(xhash.QuickXorHash, false)
 	return bookId
 }
 
-func getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func postBody(sUrl string, d []byte, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Content-Type": "application/x-www-form-urlencoded",
-		},
-		Body: d,
-	})
-	resp, err := cli.Post(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	return bs, err
-}
-
-func postJSON(sUrl string, d interface{}, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":   config.Conf.UserAgent,
-			"Content-Type": "application/json",
-		},
-		JSON: d,
-	})
-	resp, err := cli.Post(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	return bs, err
-}
-
 func FileExist(path string) bool {
 	fi, err := os.Stat(path)
 	if err == nil && fi.Size() > 0 {
 		return true
 	}
-	return false
+	// 已被 split-spread 拆分的页面
+	return postprocess.Processed(path)
 }
 
-func CreateDirectory(volumeId string) string {
-	dirPath := config.Conf.Directory
+func CreateDirectory(ctx context.Context, volumeId string) string {
+	dirPath := bookDir(ctx)
 	if volumeId != "" {
-		dirPath = path.Join(config.Conf.Directory, "vol."+volumeId)
+		dirPath = path.Join(dirPath, "vol."+volumeId)
 	}
 	_ = os.MkdirAll(dirPath, os.ModePerm)
 	return dirPath
@@ -140,7 +85,7 @@ func WaitNewCookie() {
 	}
 	var wg sync.WaitGroup
 	wg.Add(1)
-	fmt.Println("请使用 bookget-gui 浏览器，打开图书网址，完成「真人验证 / 登录用户」，然后 「刷新」 网页.")
+	i18n.Println("cookie.wait")
 	go func() {
 		defer wg.Done()
 		for i := 0; i < 3600*8; i++ {
@@ -157,8 +102,7 @@ func WaitNewCookieWithMsg(uri string) {
 	_ = os.Remove(config.Conf.CookieFile)
 	var wg sync.WaitGroup
 	wg.Add(1)
-	fmt.Println("请使用 bookget-gui 浏览器打开下面 URL，完成「真人验证 / 登录用户」，然后 「刷新」 网页.")
-	fmt.Println(uri)
+	i18n.Println("cookie.wait_url", uri)
 	go func() {
 		defer wg.Done()
 		for i := 0; i < 3600*8; i++ {
@@ -172,23 +116,11 @@ func WaitNewCookieWithMsg(uri string) {
 }
 
 func IsChinaIP(jar *cookiejar.Jar) bool {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    "http://ip-api.com/",
-		},
+	bs, err := NewSession(context.Background(), jar).Do(http.MethodGet, "http://ip-api.com/json/?lang=zh-CN", nil, http.Header{
+		"Referer": {"http://ip-api.com/"},
 	})
-	resp, err := cli.Get("http://ip-api.com/json/?lang=zh-CN")
 	if err != nil {
 		return false
 	}
-	bs, _ := resp.GetBody()
-	text := string(bs)
-	if strings.Contains(text, "\"countryCode\":\"CN\"") {
-		return true
-	}
-	return false
+	return strings.Contains(string(bs), "\"countryCode\":\"CN\"")
 }
@@ -2,14 +2,9 @@ package app
 
 import (
 	"bookget/config"
-	"bookget/pkg/chttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/sharedmemory"
-	"bytes"
 	"context"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -50,14 +45,14 @@ type DownloaderImpl struct {
 
 // Implement the NewDownloader method to satisfy the interface
 func (d *DownloaderImpl) NewDownloader() *DownloaderImpl {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(d.ctx)
 
 	// 创建自定义 Transport 忽略 SSL 验证
 	tr := NewHttpTransport()
 	jar, _ := cookiejar.New(nil)
 	return &DownloaderImpl{
 		// 初始化字段
-		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: jar, Transport: tr},
+		client: &http.Client{Timeout: config.Conf.Timeout * time.Second, Jar: withCookieFile(jar), Transport: tr},
 		ctx:    ctx,
 		cancel: cancel,
 	}
@@ -84,7 +79,7 @@ func (d *DownloaderImpl) Run() (err error) {
 func (d *DownloaderImpl) getBodyByGui(rawUrl string) (bs []byte, err error) {
 	err = sharedmemory.WriteURLToSharedMemory(rawUrl)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -100,7 +95,7 @@ func (d *DownloaderImpl) getBodyByGui(rawUrl string) (bs []byte, err error) {
 func (d *DownloaderImpl) imageDownloader(imgUrl, targetFilePath string) (ok bool, err error) {
 	err = sharedmemory.WriteURLImagePathToSharedMemory(imgUrl, targetFilePath)
 	if err != nil {
-		fmt.Println("Failed to write to shared memory:", err)
+		i18n.Println("gui.shm_failed", err)
 		return
 	}
 	for i := 0; i < 300; i++ {
@@ -115,103 +110,12 @@ func (d *DownloaderImpl) imageDownloader(imgUrl, targetFilePath string) (ok bool
 }
 
 func (d *DownloaderImpl) getBody(rawUrl string) ([]byte, error) {
-	req, err := http.NewRequest("GET", rawUrl, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-	resp, err := d.client.Do(req.WithContext(d.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(d.ctx, d.client).Get(rawUrl)
 }
 
 func (d *DownloaderImpl) postBody(rawUrl string, postData interface{}) ([]byte, error) {
-	var bodyData []byte
-	var err error
-
-	var isJSON = false
-	// 根据传入的数据类型处理
-	switch v := postData.(type) {
-	case []byte:
-		bodyData = v // 直接使用 []byte
-	case string:
-		bodyData = []byte(v) // 将 string 转为 []byte
-	default:
-		// 其他类型尝试转为 JSON
-		bodyData, err = json.Marshal(v)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal JSON data: %v", err)
-		}
-		isJSON = true
-	}
-	req, err := http.NewRequest("POST", rawUrl, bytes.NewBuffer(bodyData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-	// 设置请求头
-	req.Header.Set("User-Agent", config.Conf.UserAgent)
-	if isJSON {
-		req.Header.Set("Content-Type", "application/json")
-	} else {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
-	req.Header.Set("Origin", "https://"+d.parsedUrl.Host)
-	req.Header.Set("Referer", d.rawUrl)
-
-	cookies, _ := chttp.ReadCookiesFromFile(config.Conf.CookieFile)
-	if cookies != "" {
-		req.Header.Set("Cookie", cookies)
-	}
-
-	headers, err := chttp.ReadHeadersFromFile(config.Conf.HeaderFile)
-	if err == nil {
-		for key, value := range headers {
-			req.Header.Set(key, value)
-		}
-	}
-	resp, err := d.client.Do(req.WithContext(d.ctx))
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("close body err=%v", err)
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		err = fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
-		return nil, err
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return clientSession(d.ctx, d.client).PostWith(rawUrl, postData, func(h http.Header, isJSON bool) {
+		h.Set("Origin", "https://"+d.parsedUrl.Host)
+		h.Set("Referer", d.rawUrl)
+	})
 }
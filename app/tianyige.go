@@ -5,13 +5,13 @@ import (
 	"bookget/model/tianyige"
 	"bookget/pkg/gohttp"
 	xhash "bookget/pkg/hash"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"bytes"
 	"context"
 	"crypto/aes"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/andreburgaud/crypt2go/ecb"
 	"github.com/andreburgaud/crypt2go/padding"
@@ -20,6 +20,7 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
@@ -37,6 +38,7 @@ const TIANYIGE_KEY = "G3HT5CX8FTG5GWGUUJX8B5SWJTXS1KRC"
 
 type Tianyige struct {
 	dt           *DownloadTask
+	ctx          context.Context
 	index        int
 	localStorage struct {
 		authorization  string
@@ -44,10 +46,11 @@ type Tianyige struct {
 	}
 }
 
-func NewTianyige() *Tianyige {
+func NewTianyige(ctx context.Context) *Tianyige {
 	return &Tianyige{
 		// 初始化字段
 		dt:    new(DownloadTask),
+		ctx:   ctx,
 		index: 0,
 	}
 }
@@ -64,6 +67,7 @@ func (r *Tianyige) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -96,7 +100,7 @@ func (r *Tianyige) download() (msg string, err error) {
 		log.Println(err)
 		return
 	}
-	log.Printf(" %d volumes,  %d pages.\n", len(respVolume), len(canvases))
+	log.Println(i18n.N("count.volumes", len(respVolume), len(respVolume)) + "," + i18n.N("count.pages", len(canvases), len(canvases)))
 	parts := make(tianyige.Parts)
 	for _, record := range canvases {
 		parts[record.FascicleId] = append(parts[record.FascicleId], record)
@@ -104,13 +108,13 @@ func (r *Tianyige) download() (msg string, err error) {
 	var bookmark = config.CatalogVersionInfo + "\r\n"
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		sizePage := len(parts[vol.FascicleId])
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, sizePage)
+		log.Println(i18n.N("volume.pages", sizePage, i+1, sizeVol, sizePage))
 		text, err := r.getCatalogById(vol.CatalogId, vol.FascicleId, r.index)
 		if err == nil {
 			bookmark += text
@@ -118,7 +122,7 @@ func (r *Tianyige) download() (msg string, err error) {
 		r.do(parts[vol.FascicleId])
 	}
 
-	savePath := config.Conf.Directory
+	savePath := bookDir(r.ctx)
 	data, _ := io.ReadAll(transform.NewReader(bytes.NewReader([]byte(bookmark)), simplifiedchinese.GBK.NewEncoder()))
 	_ = os.WriteFile(path.Join(savePath, "catalog.txt"), []byte(bookmark), os.ModePerm)
 	_ = os.WriteFile(path.Join(savePath, "catalog-gbk.txt"), []byte(data), os.ModePerm)
@@ -136,7 +140,7 @@ func (r *Tianyige) do(records []tianyige.ImageRecord) (msg string, err error) {
 	i := 0
 	for _, record := range records {
 		uri, _, err := r.getImageById(record.ImageId)
-		if err != nil || uri == "" || !config.PageRange(i, size) {
+		if err != nil || uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		i++
@@ -147,9 +151,9 @@ func (r *Tianyige) do(records []tianyige.ImageRecord) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d  %s\n", i, size, uri)
+		i18n.Logln("get.page", i, size, uri)
 		//下载时有验证码
-		ctx := context.Background()
+		ctx := r.ctx
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -161,6 +165,7 @@ func (r *Tianyige) do(records []tianyige.ImageRecord) (msg string, err error) {
 				"User-Agent": config.Conf.UserAgent,
 			},
 		}
+		pagePlanned(r.ctx)
 		for k := 0; k < 10; k++ {
 			_, err = gohttp.FastGet(ctx, uri, opts)
 			if err == nil && FileExist(dest) {
@@ -281,62 +286,32 @@ func (r *Tianyige) getCatalogById(catalogId, fascicleId string, indexStart int)
 }
 
 func (r *Tianyige) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	token := r.getToken()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":     config.Conf.UserAgent,
-			"Content-Type":   "application/json;charset=UTF-8",
-			"token":          token,
-			"appId":          TIANYIGE_ID,
-			"authorization":  r.localStorage.authorization,
-			"authorizationu": r.localStorage.authorizationu,
-		},
-	})
-	resp, err := cli.Get(sUrl)
+	bs, err := sessionFor(r.ctx, jar).Do(http.MethodGet, sUrl, nil, r.apiHeader())
 	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil || resp.GetStatusCode() != 200 {
-		msg := fmt.Sprintf("Please try again later.[%d %s]\n", resp.GetStatusCode(), resp.GetReasonPhrase())
-		fmt.Println(msg)
-		return nil, errors.New(msg)
+		i18n.Println("try_later", err)
 	}
 	return bs, err
 }
 
 func (r *Tianyige) postBody(sUrl string, d []byte, jar *cookiejar.Jar) ([]byte, error) {
-	token := r.getToken()
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":     config.Conf.UserAgent,
-			"Content-Type":   "application/json;charset=UTF-8",
-			"token":          token,
-			"appId":          TIANYIGE_ID,
-			"authorization":  r.localStorage.authorization,
-			"authorizationu": r.localStorage.authorizationu,
-		},
-		Body: d,
-	})
-	resp, err := cli.Post(sUrl)
+	bs, err := sessionFor(r.ctx, jar).Do(http.MethodPost, sUrl, d, r.apiHeader())
 	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil || resp.GetStatusCode() != 200 {
-		msg := fmt.Sprintf("Please try again later.[%d %s]\n", resp.GetStatusCode(), resp.GetReasonPhrase())
-		fmt.Println(msg)
-		return nil, errors.New(msg)
+		i18n.Println("try_later", err)
 	}
 	return bs, err
 }
 
+// apiHeader 天一阁接口需要的令牌
+func (r *Tianyige) apiHeader() http.Header {
+	return http.Header{
+		"Content-Type":   {"application/json;charset=UTF-8"},
+		"Token":          {r.getToken()},
+		"Appid":          {TIANYIGE_ID},
+		"Authorization":  {r.localStorage.authorization},
+		"Authorizationu": {r.localStorage.authorizationu},
+	}
+}
+
 func (r *Tianyige) encrypt(pt, key []byte) string {
 	block, err := aes.NewCipher(key)
 	if err != nil {
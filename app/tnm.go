@@ -3,10 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/downloader"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -20,11 +19,11 @@ type Tnm struct {
 	ctx context.Context
 }
 
-func NewTnm() *Tnm {
+func NewTnm(ctx context.Context) *Tnm {
 	return &Tnm{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -42,6 +41,7 @@ func (r *Tnm) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -58,16 +58,16 @@ func (r *Tnm) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Tnm) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	apiUrl := fmt.Sprintf("%s://%s/dlib/pages/%s", r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host, r.dt.BookId)
 	canvases, err := r.getCanvases(apiUrl, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
-	log.Printf(" %d pages \n", len(canvases))
+	log.Println(i18n.N("count.pages", len(canvases), len(canvases)))
 	return r.do(canvases)
 }
 
@@ -75,7 +75,7 @@ func (r *Tnm) do(dziUrls []string) (msg string, err error) {
 	if dziUrls == nil {
 		return "", err
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -84,7 +84,7 @@ func (r *Tnm) do(dziUrls []string) (msg string, err error) {
 	size := len(dziUrls)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range dziUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -93,14 +93,15 @@ func (r *Tnm) do(dziUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %s  %s\n", sortId, uri)
+		i18n.Logln("get.named", sortId, uri)
+		pagePlanned(r.ctx)
 		iiifDownloader.Dezoomify(r.ctx, uri, dest, args)
 	}
 	return "", err
 }
 
 func (r *Tnm) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -122,25 +123,3 @@ func (r *Tnm) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, e
 	}
 	return canvases, nil
 }
-
-func (r *Tnm) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() == 202 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
@@ -4,12 +4,12 @@ import (
 	"bookget/config"
 	"bookget/model/war"
 	"bookget/pkg/downloader"
-	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
@@ -25,11 +25,11 @@ type War1931 struct {
 	ctx             context.Context
 }
 
-func NewWar1931() *War1931 {
+func NewWar1931(ctx context.Context) *War1931 {
 	return &War1931{
 		// 初始化字段
 		dt:  new(DownloadTask),
-		ctx: context.Background(),
+		ctx: ctx,
 	}
 }
 
@@ -46,6 +46,7 @@ func (r *War1931) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 	r.dt.Jar, _ = cookiejar.New(nil)
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -73,13 +74,13 @@ func (r *War1931) mkdirAll(directory, vid string) (dirPath string) {
 		break
 	default:
 	}
-	r.dt.SavePath = config.Conf.Directory + string(os.PathSeparator) + r.dt.VolumeId
+	r.dt.SavePath = bookDir(r.ctx) + string(os.PathSeparator) + r.dt.VolumeId
 	_ = os.MkdirAll(r.dt.SavePath, os.ModePerm)
 	return r.dt.SavePath
 }
 
 func (r *War1931) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	apiUrl := "https://" + r.dt.UrlParsed.Host + "/backend-prod/esBook/findDetailsInfo/" + r.dt.BookId
 	partialVolumes, err := r.getVolumes(apiUrl, r.dt.Jar)
 	if err != nil {
@@ -87,10 +88,10 @@ func (r *War1931) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for k, parts := range partialVolumes {
-		if !config.VolumeRange(k) {
+		if !config.VolumeRange(r.ctx, k, len(partialVolumes)) {
 			continue
 		}
-		log.Printf(" %d/%d, %d volumes \n", k+1, len(partialVolumes), len(parts.Volumes))
+		log.Println(i18n.N("war1931.part_volumes", len(parts.Volumes), k+1, len(partialVolumes), len(parts.Volumes)))
 		for i, vol := range parts.Volumes {
 			vid := fmt.Sprintf("%04d", i+1)
 			r.mkdirAll(parts.Directory, vid)
@@ -99,7 +100,7 @@ func (r *War1931) download() (msg string, err error) {
 				fmt.Println(err)
 				continue
 			}
-			log.Printf(" %d/%d volume, %d pages \n", i+1, len(parts.Volumes), len(canvases))
+			log.Println(i18n.N("volume.pages", len(canvases), i+1, len(parts.Volumes), len(canvases)))
 			r.do(canvases)
 		}
 	}
@@ -110,7 +111,7 @@ func (r *War1931) do(canvases []string) (msg string, err error) {
 	if canvases == nil {
 		return "", nil
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 
 	args := []string{
 		"-H", "Origin:" + referer,
@@ -119,7 +120,7 @@ func (r *War1931) do(canvases []string) (msg string, err error) {
 	size := len(canvases)
 	iiifDownloader := downloader.NewIIIFDownloader(&config.Conf)
 	for i, uri := range canvases {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -128,9 +129,10 @@ func (r *War1931) do(canvases []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %s  %s\n", sortId, uri)
+		i18n.Logln("get.named", sortId, uri)
+		pagePlanned(r.ctx)
 		if err := iiifDownloader.Dezoomify(r.ctx, uri, dest, args); err != nil {
-			log.Printf("Dezoomify %s %s\n", err, uri)
+			i18n.Logln("dezoomify.failed_url", err, uri)
 		}
 	}
 	return "", err
@@ -198,9 +200,9 @@ func (r *War1931) getVolumesForBz(sUrl string, jar *cookiejar.Jar) (volumes []wa
 		}
 		for _, month := range months {
 			if len(month) == 1 {
-				fmt.Printf("Test %s-0%s\r", year, month)
+				fmt.Print(i18n.T("war1931.test_month", year, "0"+month) + "\r")
 			} else {
-				fmt.Printf("Test %s-%s\r", year, month)
+				fmt.Print(i18n.T("war1931.test_month", year, month) + "\r")
 			}
 			apiUrl := "https://" + r.dt.UrlParsed.Host + "/backend-prod/esBook/findDirectoryByMonth?fileCode=" + r.fileCode + "&year=" + year + "&month=" + month
 			bs, err := r.getBody(apiUrl, jar)
@@ -209,7 +211,7 @@ func (r *War1931) getVolumesForBz(sUrl string, jar *cookiejar.Jar) (volumes []wa
 			}
 			var resp = new(war.FindDirectoryByMonth)
 			if err := json.Unmarshal(bs, resp); err != nil {
-				log.Printf("json.Unmarshal failed: %s\n", err)
+				i18n.Logln("json.failed", err)
 				break
 			}
 			for _, item := range resp.Result {
@@ -239,7 +241,7 @@ func (r *War1931) findBzYear(fileCode string) (years []string, err error) {
 	}
 	var resp = new(Response)
 	if err = json.Unmarshal(bs, resp); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	return resp.Result, err
@@ -258,7 +260,7 @@ func (r *War1931) findBzMonth(year string) (years []string, err error) {
 	}
 	var resp = new(Response)
 	if err = json.Unmarshal(bs, resp); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	return resp.Result, err
@@ -272,7 +274,7 @@ func (r *War1931) getVolumesForQk(sUrl string, jar *cookiejar.Jar) (volumes []wa
 	}
 	var resp = new(war.Qk)
 	if err = json.Unmarshal(bs, resp); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, items := range resp.Result {
@@ -300,7 +302,7 @@ func (r *War1931) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []strin
 	}
 	var manifest = new(war.Manifest)
 	if err = json.Unmarshal(bs, manifest); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	if len(manifest.Sequences) == 0 {
@@ -318,23 +320,8 @@ func (r *War1931) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []strin
 }
 
 func (r *War1931) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent":      config.Conf.UserAgent,
-			"Accept-Language": "zh-CN,zh;q=0.8,zh-TW;q=0.7,zh-HK;q=0.5,en-US;q=0.3,en;q=0.2",
-			"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
-		},
+	return sessionFor(r.ctx, jar).Do(http.MethodGet, sUrl, nil, http.Header{
+		"Accept-Language": {"zh-CN,zh;q=0.8,zh-TW;q=0.7,zh-HK;q=0.5,en-US;q=0.3,en;q=0.2"},
+		"Accept":          {"text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"},
 	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if resp.GetStatusCode() != 200 || bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
 }
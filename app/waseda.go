@@ -3,9 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -13,17 +13,37 @@ import (
 	"path"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
 )
 
 type Waseda struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewWaseda() *Waseda {
+func init() {
+	addNormalizer(normalizer{
+		site: "waseda",
+		// ri08_01899 is in the folder ri08
+		urlOf: func(id string) string {
+			shelf, _, _ := strings.Cut(id, "_")
+			return "https://archive.wul.waseda.ac.jp/kosho/" + shelf + "/" + id + "/"
+		},
+		shapes: []urlShape{
+			// The book folder or its Kotenseki catalog page. A volume folder or a
+			// page file is not the whole book, its URL is left as it is.
+			shape(`^archive\.wul\.waseda\.ac\.jp/kosho/[a-z0-9]+/([a-z0-9]+_[a-z0-9]+)/?(?:index\.html)?$`, lower),
+			shape(`^www\.wul\.waseda\.ac\.jp/kotenseki/html/[a-z0-9]+/([a-z0-9]+_[a-z0-9]+)/?(?:index\.html)?$`, lower),
+		},
+	})
+}
+
+func NewWaseda(ctx context.Context) *Waseda {
 	return &Waseda{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -40,6 +60,7 @@ func (r Waseda) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	r.dt.Jar, _ = cookiejar.New(nil)
 	return r.download()
 }
@@ -52,26 +73,26 @@ func (r Waseda) download() (msg string, err error) {
 	}
 	if config.Conf.FileExt == ".pdf" {
 		for i, vol := range respVolume {
-			if !config.VolumeRange(i) {
+			if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 				continue
 			}
 			sortId := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = config.Conf.Directory
-			log.Printf(" %d/%d volume, URL:%s \n", i+1, len(respVolume), vol)
+			r.dt.SavePath = bookDir(r.ctx)
+			i18n.Logln("volume.of", i+1, len(respVolume), vol)
 			filename := sortId + config.Conf.FileExt
 			dest := path.Join(r.dt.SavePath, filename)
 			r.doDownload(vol, dest)
 		}
 	} else {
 		for i, vol := range respVolume {
-			if !config.VolumeRange(i) {
+			if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 				continue
 			}
 			if len(respVolume) == 1 {
-				r.dt.SavePath = config.Conf.Directory
+				r.dt.SavePath = bookDir(r.ctx)
 			} else {
 				vid := fmt.Sprintf("%04d", i+1)
-				r.dt.SavePath = CreateDirectory(vid)
+				r.dt.SavePath = CreateDirectory(r.ctx, vid)
 			}
 			canvases, err := r.getCanvases(vol, r.dt.Jar)
 			if err != nil || canvases == nil {
@@ -79,7 +100,7 @@ func (r Waseda) download() (msg string, err error) {
 				continue
 			}
 
-			log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+			log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 			r.do(canvases)
 		}
 	}
@@ -96,7 +117,7 @@ func (r Waseda) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		sortId := fmt.Sprintf("%04d", i+1)
@@ -105,12 +126,13 @@ func (r Waseda) do(imgUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		imgUrl := uri
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -132,7 +154,7 @@ func (r Waseda) do(imgUrls []string) (msg string, err error) {
 }
 
 func (r Waseda) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -161,7 +183,7 @@ func (r Waseda) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, e
 }
 
 func (r Waseda) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := r.getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -185,33 +207,11 @@ func (r Waseda) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 	return canvases, nil
 }
 
-func (r Waseda) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
 func (r Waseda) doDownload(dUrl, dest string) bool {
 	if FileExist(dest) {
 		return false
 	}
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	opts := gohttp.Options{
 		DestFile:    dest,
 		Overwrite:   false,
@@ -224,7 +224,7 @@ func (r Waseda) doDownload(dUrl, dest string) bool {
 			"Referer":    referer,
 		},
 	}
-	ctx := context.Background()
+	ctx := r.ctx
 	_, err := gohttp.FastGet(ctx, dUrl, opts)
 	if err == nil {
 		fmt.Println()
@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/model/wzlib"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -15,13 +16,15 @@ import (
 )
 
 type Wzlib struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewWzlib() *Wzlib {
+func NewWzlib(ctx context.Context) *Wzlib {
 	return &Wzlib{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -37,6 +40,7 @@ func (p *Wzlib) Run(sUrl string) (msg string, err error) {
 	p.dt.UrlParsed, err = url.Parse(sUrl)
 	p.dt.Url = sUrl
 	p.dt.BookId = p.getBookId(p.dt.Url)
+	setBookId(p.ctx, p.dt.BookId)
 	if p.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -54,8 +58,8 @@ func (p *Wzlib) getBookId(sUrl string) (bookId string) {
 }
 
 func (p *Wzlib) download() (msg string, err error) {
-	log.Printf("Get %s\n", p.dt.Url)
-	p.dt.SavePath = config.Conf.Directory
+	i18n.Logln("get", p.dt.Url)
+	p.dt.SavePath = bookDir(p.ctx)
 
 	//旧版：瓯越记忆
 	if p.dt.UrlParsed.Host == "oyjy.wzlib.cn" {
@@ -79,16 +83,16 @@ func (p *Wzlib) do(dUrls []string) (msg string, err error) {
 	}
 	fmt.Println()
 	size := len(dUrls)
-	log.Printf(" %d PDFs.\n", size)
-	ctx := context.Background()
+	log.Println(i18n.N("count.pdfs", size, size))
+	ctx := p.ctx
 	for i, uri := range dUrls {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(p.ctx, i, size) {
 			continue
 		}
 		if uri == "" {
 			continue
 		}
-		log.Printf("Get %d/%d, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		sortId := fmt.Sprintf("%04d", i+1)
 		filename := sortId + ".pdf"
 		dest := path.Join(p.dt.SavePath, filename)
@@ -103,6 +107,7 @@ func (p *Wzlib) do(dUrls []string) (msg string, err error) {
 			HeaderFile:  config.Conf.HeaderFile,
 			CookieJar:   p.dt.Jar,
 		}
+		pagePlanned(p.ctx)
 		_, err = gohttp.FastGet(ctx, uri, opts)
 		if err != nil {
 			fmt.Println(err)
@@ -121,14 +126,14 @@ func (p *Wzlib) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string, e
 
 func (p *Wzlib) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
 	apiUrl := fmt.Sprintf("https://%s/search/juhe_detail/%s/true?Flag=s", p.dt.UrlParsed.Host, p.dt.BookId)
-	bs, err := getBody(apiUrl, jar)
+	bs, err := sessionFor(p.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
 
 	var resT = new(wzlib.Digital)
 	if err = json.Unmarshal(bs, &resT); err != nil {
-		log.Printf("json.Unmarshal failed: %s\n", err)
+		i18n.Logln("json.failed", err)
 		return
 	}
 	for _, ret := range resT.DigitalResourceData {
@@ -145,7 +150,7 @@ func (p *Wzlib) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 func (p *Wzlib) OyjyGetCanvases(bookId string) (canvases []string, err error) {
 	//一册
 	uri := fmt.Sprintf("https://oyjy.wzlib.cn/api/search/v1/resource/%s", bookId)
-	bs, err := getBody(uri, p.dt.Jar)
+	bs, err := sessionFor(p.ctx, p.dt.Jar).Get(uri)
 	if err == nil {
 		var result wzlib.ResultPdf
 		if err = json.Unmarshal(bs, &result); err == nil {
@@ -160,7 +165,7 @@ func (p *Wzlib) OyjyGetCanvases(bookId string) (canvases []string, err error) {
 
 	//多册
 	relatedUri := fmt.Sprintf("https://oyjy.wzlib.cn/api/search/v1/resource_related/%s", bookId)
-	bs, err = getBody(relatedUri, p.dt.Jar)
+	bs, err = sessionFor(p.ctx, p.dt.Jar).Get(relatedUri)
 	if err != nil {
 		return
 	}
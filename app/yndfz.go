@@ -4,6 +4,7 @@ import (
 	"bookget/config"
 	"bookget/model/yndfz"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"encoding/json"
@@ -16,13 +17,15 @@ import (
 )
 
 type Yndfz struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewYndfz() *Yndfz {
+func NewYndfz(ctx context.Context) *Yndfz {
 	return &Yndfz{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -40,6 +43,7 @@ func (r *Yndfz) Run(sUrl string) (msg string, err error) {
 	r.dt.Url = sUrl
 
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -56,7 +60,7 @@ func (r *Yndfz) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *Yndfz) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 
 	respVolume, err := r.getVolumes(r.dt.Url, r.dt.Jar)
 	if err != nil {
@@ -64,17 +68,17 @@ func (r *Yndfz) download() (msg string, err error) {
 		return "getVolumes", err
 	}
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		vid := fmt.Sprintf("%04d", i+1)
-		r.dt.SavePath = CreateDirectory(vid)
+		r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
 		if err != nil || canvases == nil {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, len(respVolume), len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, len(respVolume), len(canvases)))
 		r.do(canvases)
 	}
 	return "", nil
@@ -85,11 +89,11 @@ func (r *Yndfz) do(imgUrls []string) (msg string, err error) {
 		return
 	}
 	fmt.Println()
-	referer := url.QueryEscape(r.dt.Url)
+	referer := r.dt.Url
 	size := len(imgUrls)
-	ctx := context.Background()
+	ctx := r.ctx
 	for i, uri := range imgUrls {
-		if !config.PageRange(i, size) {
+		if !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		if uri == "" {
@@ -101,7 +105,7 @@ func (r *Yndfz) do(imgUrls []string) (msg string, err error) {
 		if FileExist(dest) {
 			continue
 		}
-		log.Printf("Get %d/%d page, URL: %s\n", i+1, size, uri)
+		i18n.Logln("get.page", i+1, size, uri)
 		opts := gohttp.Options{
 			DestFile:    dest,
 			Overwrite:   false,
@@ -120,6 +124,7 @@ func (r *Yndfz) do(imgUrls []string) (msg string, err error) {
 			fmt.Println(err)
 			break
 		}
+		pagePlanned(r.ctx)
 		_, err = gohttp.FastGet(ctx, imgUrl, opts)
 		if err != nil {
 			fmt.Println(err)
@@ -154,7 +159,7 @@ func (r *Yndfz) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 	}
 	apiUrl := fmt.Sprintf("%s://%s/api/record/pageAndCatalogInfo/getBookInfoByBookId?bookId=%s",
 		r.dt.UrlParsed.Scheme, r.dt.UrlParsed.Host, r.dt.BookId)
-	bs, err := r.getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
@@ -168,28 +173,9 @@ func (r *Yndfz) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string,
 	return canvases, nil
 }
 
-func (r *Yndfz) getBody(apiUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(apiUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(apiUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	return bs, nil
-}
-
 func (r *Yndfz) getDownloadUrl(sUrl string) (string, error) {
 	apiUrl := "http://" + r.dt.UrlParsed.Host + "/api/readRight/path/old040001?key=" + url.QueryEscape(sUrl)
-	bs, err := r.getBody(apiUrl, r.dt.Jar)
+	bs, err := sessionFor(r.ctx, r.dt.Jar).Get(apiUrl)
 	if err != nil {
 		return "", err
 	}
@@ -3,9 +3,9 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
-	"errors"
 	"fmt"
 	"log"
 	"net/http/cookiejar"
@@ -18,13 +18,15 @@ import (
 )
 
 type Yonezawa struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewYonezawa() *Yonezawa {
+func NewYonezawa(ctx context.Context) *Yonezawa {
 	return &Yonezawa{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -40,6 +42,7 @@ func (p *Yonezawa) Run(sUrl string) (msg string, err error) {
 	p.dt.UrlParsed, err = url.Parse(sUrl)
 	p.dt.Url = sUrl
 	p.dt.BookId = p.getBookId(p.dt.Url)
+	setBookId(p.ctx, p.dt.BookId)
 	if p.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -55,7 +58,7 @@ func (p *Yonezawa) getBookId(sUrl string) (bookId string) {
 }
 
 func (p *Yonezawa) download() (msg string, err error) {
-	log.Printf("Get %s\n", p.dt.Url)
+	i18n.Logln("get", p.dt.Url)
 	respVolume, err := p.getVolumes(p.dt.Url, p.dt.Jar)
 	if err != nil {
 		fmt.Println(err)
@@ -63,14 +66,14 @@ func (p *Yonezawa) download() (msg string, err error) {
 	}
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(p.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			p.dt.SavePath = config.Conf.Directory
+			p.dt.SavePath = bookDir(p.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			p.dt.SavePath = CreateDirectory(vid)
+			p.dt.SavePath = CreateDirectory(p.ctx, vid)
 		}
 
 		canvases, err := p.getCanvases(vol, p.dt.Jar)
@@ -78,7 +81,7 @@ func (p *Yonezawa) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		p.do(canvases)
 	}
 	return msg, err
@@ -93,7 +96,7 @@ func (p *Yonezawa) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(p.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -105,11 +108,12 @@ func (p *Yonezawa) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(p.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := p.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -136,7 +140,7 @@ func (p *Yonezawa) getVolumes(sUrl string, jar *cookiejar.Jar) (volumes []string
 }
 
 func (p *Yonezawa) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := p.getBody(sUrl, jar)
+	bs, err := sessionFor(p.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -162,33 +166,6 @@ func (p *Yonezawa) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []stri
 	return canvases, err
 }
 
-func (p *Yonezawa) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	referer := url.QueryEscape(sUrl)
-	ctx := context.Background()
-	cli := gohttp.NewClient(ctx, gohttp.Options{
-		CookieFile: config.Conf.CookieFile,
-		CookieJar:  jar,
-		Headers: map[string]interface{}{
-			"User-Agent": config.Conf.UserAgent,
-			"Referer":    referer,
-		},
-	})
-	resp, err := cli.Get(sUrl)
-	if err != nil {
-		return nil, err
-	}
-	bs, _ := resp.GetBody()
-	if bs == nil {
-		return nil, errors.New(fmt.Sprintf("ErrCode:%d, %s", resp.GetStatusCode(), resp.GetReasonPhrase()))
-	}
-	return bs, nil
-}
-
-func (p *Yonezawa) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
 func (p *Yonezawa) getImageUrls(host, imageDir, val string) (imgUrls []string) {
 	m := strings.Split(val, ",")
 	if m == nil {
@@ -3,6 +3,7 @@ package app
 import (
 	"bookget/config"
 	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/util"
 	"context"
 	"errors"
@@ -17,13 +18,15 @@ import (
 )
 
 type ZhuCheng struct {
-	dt *DownloadTask
+	dt  *DownloadTask
+	ctx context.Context
 }
 
-func NewZhuCheng() *ZhuCheng {
+func NewZhuCheng(ctx context.Context) *ZhuCheng {
 	return &ZhuCheng{
 		// 初始化字段
-		dt: new(DownloadTask),
+		dt:  new(DownloadTask),
+		ctx: ctx,
 	}
 }
 
@@ -39,6 +42,7 @@ func (r *ZhuCheng) Run(sUrl string) (msg string, err error) {
 	r.dt.UrlParsed, err = url.Parse(sUrl)
 	r.dt.Url = sUrl
 	r.dt.BookId = r.getBookId(r.dt.Url)
+	setBookId(r.ctx, r.dt.BookId)
 	if r.dt.BookId == "" {
 		return "requested URL was not found.", err
 	}
@@ -54,23 +58,23 @@ func (r *ZhuCheng) getBookId(sUrl string) (bookId string) {
 }
 
 func (r *ZhuCheng) download() (msg string, err error) {
-	log.Printf("Get %s\n", r.dt.Url)
+	i18n.Logln("get", r.dt.Url)
 	respVolume, err := r.getVolumes(r.dt.BookId, r.dt.Jar)
 	if err != nil {
 		fmt.Println(err)
 		return "getVolumes", err
 	}
-	r.dt.SavePath = config.Conf.Directory
+	r.dt.SavePath = bookDir(r.ctx)
 	sizeVol := len(respVolume)
 	for i, vol := range respVolume {
-		if !config.VolumeRange(i) {
+		if !config.VolumeRange(r.ctx, i, len(respVolume)) {
 			continue
 		}
 		if sizeVol == 1 {
-			r.dt.SavePath = config.Conf.Directory
+			r.dt.SavePath = bookDir(r.ctx)
 		} else {
 			vid := fmt.Sprintf("%04d", i+1)
-			r.dt.SavePath = CreateDirectory(vid)
+			r.dt.SavePath = CreateDirectory(r.ctx, vid)
 		}
 
 		canvases, err := r.getCanvases(vol, r.dt.Jar)
@@ -78,7 +82,7 @@ func (r *ZhuCheng) download() (msg string, err error) {
 			fmt.Println(err)
 			continue
 		}
-		log.Printf(" %d/%d volume, %d pages \n", i+1, sizeVol, len(canvases))
+		log.Println(i18n.N("volume.pages", len(canvases), i+1, sizeVol, len(canvases)))
 		r.do(canvases)
 	}
 	return msg, err
@@ -93,7 +97,7 @@ func (r *ZhuCheng) do(imgUrls []string) (msg string, err error) {
 	var wg sync.WaitGroup
 	q := QueueNew(int(config.Conf.Threads))
 	for i, uri := range imgUrls {
-		if uri == "" || !config.PageRange(i, size) {
+		if uri == "" || !config.PageRange(r.ctx, i, size) {
 			continue
 		}
 		ext := util.FileExt(uri)
@@ -105,11 +109,12 @@ func (r *ZhuCheng) do(imgUrls []string) (msg string, err error) {
 		}
 		imgUrl := uri
 		fmt.Println()
-		log.Printf("Get %d/%d  %s\n", i+1, size, imgUrl)
+		i18n.Logln("get.page", i+1, size, imgUrl)
+		pagePlanned(r.ctx)
 		wg.Add(1)
 		q.Go(func() {
 			defer wg.Done()
-			ctx := context.Background()
+			ctx := r.ctx
 			opts := gohttp.Options{
 				DestFile:    dest,
 				Overwrite:   false,
@@ -133,7 +138,7 @@ func (r *ZhuCheng) do(imgUrls []string) (msg string, err error) {
 func (r *ZhuCheng) getVolumes(bookId string, jar *cookiejar.Jar) (volumes []string, err error) {
 	hostUrl := r.dt.UrlParsed.Scheme + "://" + r.dt.UrlParsed.Host
 	apiUrl := hostUrl + "/index.php?ac=catalog&id=" + bookId
-	bs, err := getBody(apiUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(apiUrl)
 	if err != nil {
 		return
 	}
@@ -156,7 +161,7 @@ func (r *ZhuCheng) getVolumes(bookId string, jar *cookiejar.Jar) (volumes []stri
 }
 
 func (r *ZhuCheng) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []string, err error) {
-	bs, err := getBody(sUrl, jar)
+	bs, err := sessionFor(r.ctx, jar).Get(sUrl)
 	if err != nil {
 		return
 	}
@@ -172,16 +177,6 @@ func (r *ZhuCheng) getCanvases(sUrl string, jar *cookiejar.Jar) (canvases []stri
 	return canvases, err
 }
 
-func (r *ZhuCheng) getBody(sUrl string, jar *cookiejar.Jar) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (r *ZhuCheng) postBody(sUrl string, d []byte) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
 func (r *ZhuCheng) getBid(bs []byte) (string, error) {
 	match := regexp.MustCompile(`var\s+BID\s+=\s+'([A-z0-9]+)'`).FindSubmatch(bs)
 	if match != nil {
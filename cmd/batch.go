@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bookget/config"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchRow is one book of a batch file. Empty options keep the command line's.
+type batchRow struct {
+	Line    int    `json:"-"`
+	URL     string `json:"url"`
+	Pages   string `json:"pages,omitempty"`   // Like --sequence
+	Volumes string `json:"volumes,omitempty"` // Like --volume
+	Dir     string `json:"dir,omitempty"`     // Relative to --dir
+	Ext     string `json:"ext,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Dzi     *bool  `json:"dzi,omitempty"`
+	Cookies string `json:"cookies,omitempty"`
+	Label   string `json:"label,omitempty"`
+}
+
+// batchColumns are the CSV columns
+var batchColumns = []string{"url", "pages", "volumes", "dir", "ext", "format", "dzi", "cookies", "label"}
+
+// hasOptions tells whether the row changes the command line's options
+func (r batchRow) hasOptions() bool {
+	return r.Pages != "" || r.Volumes != "" || r.Dir != "" || r.Ext != "" || r.Format != "" || r.Dzi != nil || r.Cookies != ""
+}
+
+// apply sets the row's options on config.Conf; restore puts the command line's back
+func (r batchRow) apply() (restore func()) {
+	saved := config.Conf
+	if r.Pages != "" || r.Volumes != "" {
+		seq, volume := saved.Seq, saved.Volume
+		if r.Pages != "" {
+			seq = r.Pages
+		}
+		if r.Volumes != "" {
+			volume = r.Volumes
+		}
+		_ = config.SetRanges(seq, volume) // 已由 check 检查
+	}
+	if r.Dir != "" {
+		dir := r.Dir
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(saved.Directory, dir)
+		}
+		_ = os.MkdirAll(dir, os.ModePerm)
+		config.Conf.Directory = dir
+	}
+	if r.Ext != "" {
+		config.Conf.FileExt = "." + strings.TrimPrefix(r.Ext, ".")
+	}
+	if r.Format != "" {
+		config.Conf.Format = r.Format
+	}
+	if r.Dzi != nil {
+		config.Conf.UseDzi = *r.Dzi
+	}
+	if r.Cookies != "" {
+		config.Conf.CookieFile = r.Cookies
+	}
+	return func() { config.Conf = saved }
+}
+
+// loadBatch reads a batch file: CSV with a header row (.csv), JSON lines
+// (.jsonl, .ndjson) or one URL per line. Lines starting with # are comments.
+func loadBatch(filename string) ([]batchRow, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read URL file: %w", err)
+	}
+	rows, err := parseBatch(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no valid URLs found in URL file")
+	}
+	return rows, nil
+}
+
+// parseBatch parses the content of batch file filename
+func parseBatch(filename string, content []byte) (rows []batchRow, err error) {
+	switch batchFormat(filename, content) {
+	case "csv":
+		rows, err = parseBatchCSV(content)
+	case "jsonl":
+		rows, err = parseBatchJSONL(content)
+	default:
+		rows = parseBatchText(content)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return rows, nil
+}
+
+// batchFormat goes by the file extension, then by the first line
+func batchFormat(filename string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			return "jsonl"
+		}
+		if strings.HasPrefix(strings.ToLower(line), "url,") {
+			return "csv"
+		}
+		break
+	}
+	return "text"
+}
+
+func parseBatchText(content []byte) []batchRow {
+	var rows []batchRow
+	for i, line := range strings.Split(string(content), "\n") {
+		sUrl := strings.TrimSpace(strings.Trim(line, "\r"))
+		if isValidURL(sUrl) {
+			rows = append(rows, batchRow{Line: i + 1, URL: sUrl})
+		}
+	}
+	return rows
+}
+
+func parseBatchJSONL(content []byte) ([]batchRow, error) {
+	var rows []batchRow
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(line))
+		dec.DisallowUnknownFields()
+		var row batchRow
+		if err := dec.Decode(&row); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		row.Line = n
+		if err := row.check(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+func parseBatchCSV(content []byte) ([]batchRow, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\uFEFF"))))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, c := range batchColumns {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown column %q, expected %s", name, strings.Join(batchColumns, ","))
+		}
+		index[name] = i
+	}
+	if _, ok := index["url"]; !ok {
+		return nil, fmt.Errorf("no url column")
+	}
+
+	var rows []batchRow
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		get := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := batchRow{
+			Line:    line,
+			URL:     get("url"),
+			Pages:   get("pages"),
+			Volumes: get("volumes"),
+			Dir:     get("dir"),
+			Ext:     get("ext"),
+			Format:  get("format"),
+			Cookies: get("cookies"),
+			Label:   get("label"),
+		}
+		if v := get("dzi"); v != "" {
+			dzi, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: dzi: %w", line, err)
+			}
+			row.Dzi = &dzi
+		}
+		if err = row.check(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func (r batchRow) check() error {
+	if !isValidURL(r.URL) {
+		return fmt.Errorf("invalid URL %q", r.URL)
+	}
+	if _, err := config.ParseSelection(r.Pages, true); err != nil {
+		return fmt.Errorf("pages: %w", err)
+	}
+	if _, err := config.ParseSelection(r.Volumes, false); err != nil {
+		return fmt.Errorf("volumes: %w", err)
+	}
+	return nil
+}
+
+// batchResult is the outcome of one row
+type batchResult struct {
+	Row      batchRow
+	Err      error
+	Msg      string
+	Duration time.Duration
+}
+
+// batchSummary collects the results of executeBatchURLs
+type batchSummary struct {
+	mu      sync.Mutex
+	results []batchResult
+}
+
+func (s *batchSummary) add(r batchResult) {
+	s.mu.Lock()
+	s.results = append(s.results, r)
+	s.mu.Unlock()
+}
+
+// write saves the summary as CSV, in the order of the batch file
+func (s *batchSummary) write(file string) (failed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := append([]batchResult(nil), s.results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Row.Line < results[j].Row.Line })
+
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"line", "label", "url", "dir", "status", "message", "seconds"})
+	for _, r := range results {
+		status, msg := "ok", r.Msg
+		if r.Err != nil {
+			status, msg = "failed", r.Err.Error()
+			failed++
+		}
+		_ = w.Write([]string{
+			strconv.Itoa(r.Row.Line), r.Row.Label, r.Row.URL, r.Row.Dir, status, msg,
+			strconv.FormatFloat(r.Duration.Seconds(), 'f', 1, 64),
+		})
+	}
+	w.Flush()
+	return failed, w.Error()
+}
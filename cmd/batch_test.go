@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bookget/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBatch(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(file, []byte(content), 0644))
+		return file
+	}
+
+	rows, err := loadBatch(write("urls.txt", "# books\nhttps://a.example/1\r\n\nnot a url\nhttps://a.example/2\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, batchRow{Line: 5, URL: "https://a.example/2"}, rows[1])
+
+	rows, err = loadBatch(write("books.csv", "\uFEFFurl,volumes,dir,dzi,label\n"+
+		"https://a.example/1,3:5,a,false,\"Book A, vols 3-5\"\n"+
+		"# skipped\n"+
+		"https://a.example/2,,,,\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "3:5", rows[0].Volumes)
+	assert.Equal(t, "Book A, vols 3-5", rows[0].Label)
+	require.NotNil(t, rows[0].Dzi)
+	assert.False(t, *rows[0].Dzi)
+	assert.Equal(t, 4, rows[1].Line)
+	assert.False(t, rows[1].hasOptions())
+
+	rows, err = loadBatch(write("books.txt", "{\"url\":\"https://a.example/1\",\"pages\":\"10:40\",\"ext\":\"png\"}\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "10:40", rows[0].Pages)
+
+	_, err = loadBatch(write("typo.jsonl", "{\"url\":\"https://a.example/1\",\"page\":\"10:40\"}\n"))
+	assert.Error(t, err)
+	_, err = loadBatch(write("typo.csv", "url,page\nhttps://a.example/1,10:40\n"))
+	assert.Error(t, err)
+}
+
+func TestBatchRowApply(t *testing.T) {
+	saved := config.Conf
+	defer func() { config.Conf = saved }()
+	base := t.TempDir()
+	config.Conf = config.Input{Directory: base, FileExt: ".jpg", Seq: "1:9"}
+	require.NoError(t, config.SetRanges("1:9", ""))
+
+	restore := batchRow{URL: "https://a.example/1", Volumes: "3:5", Dir: "a", Ext: "png"}.apply()
+	assert.Equal(t, filepath.Join(base, "a"), config.Conf.Directory)
+	assert.Equal(t, ".png", config.Conf.FileExt)
+	assert.Equal(t, "3:5", config.Conf.Volumes.String())
+	assert.Equal(t, "1:9", config.Conf.Pages.String())
+	assert.DirExists(t, config.Conf.Directory)
+	restore()
+	assert.Equal(t, ".jpg", config.Conf.FileExt)
+}
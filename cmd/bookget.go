@@ -3,8 +3,10 @@ package main
 import (
 	"bookget/app"
 	"bookget/config"
+	"bookget/pkg/chttp"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/queue"
-	"bookget/pkg/version"
 	"bookget/router"
 	"bufio"
 	"context"
@@ -12,20 +14,33 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 var (
-	wg             sync.WaitGroup
-	versionChecker = version.NewChecker(
-		config.Version,
-		"deweizhu", // GitHub repository owner
-		"bookget",  // GitHub repository name
-	)
+	wg sync.WaitGroup
 )
 
+// subcommands run instead of a download when named as the first argument
+var subcommands = map[string]func(args []string) int{
+	"catalog":     runCatalog,
+	"sites":       runSites,
+	"which":       runWhich,
+	"watch":       runWatch,
+	"search":      runSearch,
+	"self-update": runSelfUpdate,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(run(os.Args[2:]))
+		}
+	}
+
 	ctx := context.Background()
 
 	// Initialize configuration
@@ -33,8 +48,10 @@ func main() {
 		return
 	}
 
-	// Check for updates
-	checkForUpdates()
+	// Check for updates, only when asked to
+	if config.Conf.CheckUpdate || updateCheckEnabled() {
+		checkForUpdates()
+	}
 
 	// Execute based on run mode
 	executeByRunMode(ctx)
@@ -45,23 +62,117 @@ func initializeConfig(ctx context.Context) bool {
 	if !config.Init(ctx) {
 		return false
 	}
+	gohttp.PoolLimits.MaxConnsPerHost = config.Conf.MaxConnsHost
+	if err := initTLS(); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	initProxies()
+	initCache()
+	if err := initRateLimits(); err != nil {
+		fmt.Println(err)
+		return false
+	}
+	gohttp.Headers.Lookup = config.HeaderProfile
+	if config.Conf.PrintHeaders {
+		if err := printHeaders(config.Conf.DUrl); err != nil {
+			fmt.Println(err)
+		}
+		return false
+	}
 	return true
 }
 
+// initCache sets up the metadata cache from --no-cache, --refresh, --cache-ttl and cache_ttl in config.ini
+func initCache() {
+	if config.Conf.NoCache || config.BookgetHomeDir() == "" {
+		return
+	}
+	gohttp.Cache.Dir = config.CacheDir()
+	gohttp.Cache.Refresh = config.Conf.Refresh
+	gohttp.Cache.TTL = func(host string) time.Duration {
+		if v := config.SiteValue(host, "cache_ttl"); v != "" {
+			if ttl, err := time.ParseDuration(v); err == nil {
+				return ttl
+			}
+		}
+		return config.Conf.CacheTTL
+	}
+}
+
+// initRateLimits applies --limit-rate to all hosts and limit_rate in config.ini to each host
+func initRateLimits() error {
+	global, err := gohttp.ParseRate(config.Conf.LimitRate)
+	if err != nil {
+		return fmt.Errorf("--limit-rate: %w", err)
+	}
+	gohttp.RateLimits.Global = global
+	gohttp.RateLimits.Host = func(host string) int64 {
+		rate, err := gohttp.ParseRate(config.SiteValue(host, "limit_rate"))
+		if err != nil {
+			return 0
+		}
+		return rate
+	}
+	return nil
+}
+
+// initProxies routes each host through the proxy setting of config.ini, or --proxy
+func initProxies() {
+	var fallback []string
+	for _, s := range strings.Split(config.Conf.Proxy, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			fallback = append(fallback, s)
+		}
+	}
+	gohttp.Proxies.Lookup = func(host string) []string {
+		if proxies := config.SiteValues(host, "proxy"); len(proxies) > 0 {
+			return proxies
+		}
+		return fallback
+	}
+}
+
+// logProxyStats prints the success rate of each proxy used
+func logProxyStats() {
+	for _, stat := range gohttp.ProxyStats() {
+		i18n.Logln("proxy.stat", stat)
+	}
+}
+
+// initTLS applies --ca-bundle and the insecure_hosts / tls_pin settings of config.ini
+func initTLS() error {
+	if config.Conf.CABundle != "" {
+		roots, err := gohttp.LoadCABundle(config.Conf.CABundle)
+		if err != nil {
+			return err
+		}
+		gohttp.TLS.Roots = roots
+	}
+	gohttp.TLS.InsecureHost = func(host string) bool {
+		return config.HostListed("insecure_hosts", host)
+	}
+	gohttp.TLS.Pins = func(host string) []string {
+		return config.SiteValues(host, "tls_pin")
+	}
+	return nil
+}
+
 // executeByRunMode executes based on the run mode
 func executeByRunMode(ctx context.Context) {
 	switch determineRunMode() {
 	case RunModeSingleURL:
 		executeSingleURL(ctx, config.Conf.DUrl)
 	case RunModeBatchURLs:
-		executeBatchURLs()
+		executeBatchURLs(ctx)
 	case RunModeInteractive:
 		runInteractiveMode(ctx)
 	case RunModeInteractiveImage:
 		runInteractiveModeImage(ctx)
 	}
 
-	log.Println("Download complete.")
+	logProxyStats()
+	i18n.Logln("download.complete")
 }
 
 type RunMode int
@@ -75,7 +186,8 @@ const (
 
 // determineRunMode determines the run mode
 func determineRunMode() RunMode {
-	if config.Conf.DownloaderMode == 1 {
+	// With --tui, -m 1 sends the dashboard's URLs, templates, to the image downloader
+	if (config.Conf.DownloaderMode == 1 && !config.Conf.TUI) || config.Conf.Template != "" {
 		return RunModeInteractiveImage
 	}
 	if config.Conf.DUrl != "" {
@@ -95,31 +207,76 @@ func hasValidURLsFile() bool {
 
 // executeSingleURL handles single URL mode
 func executeSingleURL(ctx context.Context, rawUrl string) {
+	if config.Conf.TUI {
+		if _, err := runDashboard([]batchRow{{Line: 1, URL: rawUrl}}); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	if err := processURL(ctx, rawUrl); err != nil {
 		log.Println(err)
 	}
 }
 
 // executeBatchURLs handles batch URLs mode
-func executeBatchURLs() {
-	allUrls, err := loadAndFilterURLs(config.Conf.UrlsFile)
+func executeBatchURLs(ctx context.Context) {
+	rows, err := loadBatch(config.Conf.UrlsFile)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	q := queue.NewConcurrentQueue(int(config.Conf.Threads))
-	if config.Conf.DownloaderMode == 1 {
-		processURLsDownloaderMode(q, allUrls)
+	summary := new(batchSummary)
+	if config.Conf.TUI {
+		results, err := runDashboard(rows)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		for _, r := range results {
+			summary.add(r)
+		}
 	} else {
-		processURLsManual(q, allUrls)
+		threads := bookWorkers()
+		for _, row := range rows {
+			if row.hasOptions() {
+				// 各行的选项改的是全局 config.Conf，只能一本一本下载
+				threads = 1
+				break
+			}
+		}
+		q := queue.NewConcurrentQueue(threads)
+		if config.Conf.DownloaderMode == 1 {
+			processURLsDownloaderMode(ctx, q, rows, summary)
+		} else {
+			processURLsManual(ctx, q, rows, summary)
+		}
+		wg.Wait()
+	}
+
+	file := filepath.Join(config.Conf.Directory, "batch-summary.csv")
+	failed, err := summary.write(file)
+	if err != nil {
+		i18n.Logln("batch.summary_failed", err)
+		return
 	}
-	wg.Wait()
+	log.Println(i18n.N("batch.done", len(rows), failed, len(rows), file))
+}
+
+// bookWorkers is how many books batch mode and the dashboard download at once
+func bookWorkers() int {
+	return max(1, config.Conf.Threads)
 }
 
 // runInteractiveMode runs interactive mode
 func runInteractiveMode(ctx context.Context) {
 	//cleanupCookieFile()
+	if config.Conf.TUI {
+		if _, err := runDashboard(nil); err != nil {
+			log.Println(err)
+		}
+		return
+	}
 	for {
 		rawUrl, err := readURLFromInput()
 		if err != nil {
@@ -135,30 +292,7 @@ func runInteractiveMode(ctx context.Context) {
 // runInteractiveModeImage runs interactive mode: image download
 func runInteractiveModeImage(ctx context.Context) {
 	//cleanupCookieFile()
-	app.NewImageDownloader().Run("")
-}
-
-// loadAndFilterURLs loads and filters URLs
-func loadAndFilterURLs(filename string) ([]string, error) {
-	content, err := os.ReadFile(filename)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read URL file: %w", err)
-	}
-
-	lines := strings.Split(string(content), "\n")
-	var urls []string
-	for _, line := range lines {
-		sUrl := strings.TrimSpace(strings.Trim(line, "\r"))
-		if isValidURL(sUrl) {
-			urls = append(urls, sUrl)
-		}
-	}
-
-	if len(urls) == 0 {
-		return nil, fmt.Errorf("no valid URLs found in URL file")
-	}
-
-	return urls, nil
+	app.NewImageDownloader(app.NewBookContext(ctx, "")).Run("")
 }
 
 // isValidURL validates if URL is valid
@@ -167,51 +301,66 @@ func isValidURL(url string) bool {
 }
 
 // processURLsDownloaderMode handles URLs in auto-detection mode
-func processURLsDownloaderMode(q *queue.ConcurrentQueue, allUrls []string) {
-	for _, v := range allUrls {
+func processURLsDownloaderMode(ctx context.Context, q *queue.ConcurrentQueue, rows []batchRow, summary *batchSummary) {
+	for _, v := range rows {
 		wg.Add(1)
-		rawURL := v // Create local variable for closure use
+		row := v // Create local variable for closure use
 		q.Go(func() {
 			defer wg.Done()
-			processURLSet("bookget", rawURL)
+			summary.add(processURLSet(ctx, "bookget", row))
 		})
 	}
 }
 
 // processURLsManual handles URLs in manual mode
-func processURLsManual(q *queue.ConcurrentQueue, allUrls []string) {
-	for _, v := range allUrls {
-		u, err := url.Parse(v)
+func processURLsManual(ctx context.Context, q *queue.ConcurrentQueue, rows []batchRow, summary *batchSummary) {
+	for _, v := range rows {
+		u, err := url.Parse(v.URL)
 		if err != nil {
-			log.Printf("URL parsing failed: %s, error: %v\n", v, err)
+			i18n.Logln("url.parse_failed", v.URL, err)
+			summary.add(batchResult{Row: v, Err: err})
 			continue
 		}
 
 		wg.Add(1)
-		rawURL := v // Create local variable for closure use
+		row := v // Create local variable for closure use
 		q.Go(func() {
 			defer wg.Done()
-			processURLSet(u.Host, rawURL)
+			summary.add(processURLSet(ctx, u.Host, row))
 		})
 	}
 }
 
-// processURLSet processes a group of URLs
-func processURLSet(siteID string, rawUrl string) {
-	result, err := router.FactoryRouter(siteID, rawUrl)
+// processURLSet downloads the book of one batch row
+func processURLSet(ctx context.Context, siteID string, row batchRow) batchResult {
+	started := time.Now()
+	if row.hasOptions() {
+		defer row.apply()()
+	}
+	if row.Label != "" {
+		i18n.Logln("batch.line", row.Line, row.Label)
+	}
+	book := app.NewBookContext(ctx, row.URL)
+	if row.Dir == "" {
+		// Books of a batch share --dir, each gets a folder of its own
+		book = app.WithOwnDir(book)
+	}
+	result, err := router.FactoryRouter(book, siteID, row.URL)
 	if err != nil {
 		log.Println(err)
-		return
+		return batchResult{Row: row, Err: err, Duration: time.Since(started)}
 	}
-	// Use result
-	_ = result
+	app.ExportBook(book, row.URL, result)
+	saveCookies()
+	msg, _ := result["msg"].(string)
+	return batchResult{Row: row, Msg: msg, Duration: time.Since(started)}
 }
 
 // readURLFromInput reads URL from user input
 func readURLFromInput() (string, error) {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Println()
-	fmt.Println("Enter an URL:")
+	i18n.Println("prompt.url")
 	fmt.Print("-> ")
 	input, err := reader.ReadString('\n')
 	if err != nil {
@@ -232,38 +381,47 @@ func processURL(ctx context.Context, rawUrl string) error {
 		return fmt.Errorf("URL parsing failed: %w", err)
 	}
 
-	result, err := router.FactoryRouter(u.Host, rawURL)
+	book := app.NewBookContext(ctx, rawURL)
+	result, err := router.FactoryRouter(book, u.Host, rawURL)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	// Use result
-	_ = result
+	app.ExportBook(book, rawURL, result)
+	saveCookies()
 
 	return nil
 }
 
+// saveCookies writes cookies refreshed by the sites back to the cookie file
+func saveCookies() {
+	if err := chttp.SaveJars(); err != nil {
+		i18n.Logln("cookie.save_failed", err)
+	}
+}
+
 // cleanupCookieFile cleans up cookie file
 func cleanupCookieFile() {
 	if err := os.Remove(config.Conf.CookieFile); err != nil && !os.IsNotExist(err) {
-		log.Printf("Failed to clean up cookie file: %v\n", err)
+		i18n.Logln("cookie.cleanup_failed", err)
 	}
 }
 
 // checkForUpdates checks for version updates
 func checkForUpdates() {
-	// Delete old redundant directories (iterate through a few version numbers, this line can be removed)
-	_ = os.RemoveAll(config.BookgetHomeDir())
-	latestVersion, updateAvailable, err := versionChecker.CheckForUpdate()
+	checker := newUpdateChecker("", "")
+	latestVersion, releaseURL, updateAvailable, err := checker.CheckForUpdate()
 	if err != nil {
-		log.Printf("Version check failed: %v\n", err)
+		i18n.Logln("update.check_failed", err)
 		return
 	}
 
 	if updateAvailable {
-		fmt.Printf("\nNew version available: %s (current version: %s)\n", latestVersion, versionChecker.CurrentVersion)
-		fmt.Printf("Please visit https://github.com/deweizhu/bookget/releases/latest to upgrade.\n\n")
+		fmt.Println()
+		i18n.Println("update.available", latestVersion, checker.CurrentVersion)
+		i18n.Println("update.visit", releaseURL)
+		fmt.Println()
 	} else if latestVersion != "" {
-		fmt.Printf("Current version is already the latest: %s\n", versionChecker.CurrentVersion)
+		i18n.Println("update.latest", checker.CurrentVersion)
 	}
 }
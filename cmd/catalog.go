@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bookget/config"
+	"bookget/pkg/catalog"
+	"bookget/pkg/i18n"
+	"bookget/pkg/mets"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+const catalogUsage = `Usage:
+  bookget catalog convert [OPTION]... <input> [output]
+  bookget catalog validate [OPTION]... <input>
+
+Convert catalog.txt bookmarks to and from other formats, and check their page
+numbers against the images downloaded next to them.
+Formats: ` + "txt, json, opml, pdftk (PDF outline), iiif (ranges), freepic2pdf (also PdgCntEditor)"
+
+// runCatalog implements `bookget catalog`
+func runCatalog(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(catalogUsage)
+		return 2
+	}
+	flags := pflag.NewFlagSet("catalog", pflag.ContinueOnError)
+	lang := flags.String("lang", "", config.LangUsage)
+	from := flags.String("from", "", "Input format, guessed from the file name when empty")
+	to := flags.String("to", "", "Output format, guessed from the output file name when empty")
+	dir := flags.String("dir", "", "Book directory to validate page numbers against, defaults to the input's directory")
+	encoding := flags.String("encoding", "auto", "Input encoding [auto|utf-8|gbk]")
+	outEncoding := flags.String("output-encoding", "utf-8", "Output encoding [utf-8|gbk]")
+	manifest := flags.String("manifest", "", "IIIF manifest file or id, for iiif ranges")
+	title := flags.String("title", "", "Book title, for opml and iiif")
+	flags.Usage = func() {
+		fmt.Println(catalogUsage)
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if err := i18n.SetLanguage(*lang); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	action := args[0]
+	if flags.NArg() < 1 || (action != "convert" && action != "validate") {
+		flags.Usage()
+		return 2
+	}
+	input := flags.Arg(0)
+
+	opt := catalog.Options{Title: *title, ManifestId: *manifest}
+	if bs, err := os.ReadFile(*manifest); err == nil {
+		if opt.ManifestId, opt.Canvases, err = catalog.CanvasIds(bs); err != nil {
+			i18n.Println("catalog.manifest_failed", *manifest, err)
+			return 1
+		}
+	}
+
+	inFormat := catalog.NormalizeFormat(*from)
+	if inFormat == "" {
+		inFormat = catalog.DetectFormat(input)
+	}
+	entries, err := readCatalog(input, inFormat, *encoding, opt)
+	if err != nil {
+		i18n.Println("catalog.failed", err)
+		return 1
+	}
+
+	if *dir == "" {
+		*dir = filepath.Dir(input)
+	}
+	failed := reportCatalogIssues(entries, *dir, action == "validate")
+	if action == "validate" {
+		if failed {
+			return 1
+		}
+		return 0
+	}
+
+	output := flags.Arg(1)
+	outFormat := catalog.NormalizeFormat(*to)
+	if outFormat == "" && output != "" {
+		outFormat = catalog.DetectFormat(output)
+	}
+	if outFormat == "" {
+		i18n.Println("catalog.unknown_format", strings.Join(catalog.Formats, ", "))
+		return 2
+	}
+	var buf bytes.Buffer
+	if err = catalog.Write(&buf, entries, outFormat, opt); err != nil {
+		i18n.Println("catalog.failed", err)
+		return 1
+	}
+	data := buf.Bytes()
+	if strings.EqualFold(*outEncoding, "gbk") {
+		if data, err = io.ReadAll(transform.NewReader(&buf, simplifiedchinese.GBK.NewEncoder())); err != nil {
+			i18n.Println("catalog.gbk_failed", err)
+			return 1
+		}
+	}
+	if output == "" || output == "-" {
+		_, _ = os.Stdout.Write(data)
+		return 0
+	}
+	if err = os.WriteFile(output, data, 0644); err != nil {
+		i18n.Println("catalog.failed", err)
+		return 1
+	}
+	fmt.Println(i18n.N("catalog.written", len(entries), len(entries), output, outFormat))
+	return 0
+}
+
+func readCatalog(input, format, encoding string, opt catalog.Options) ([]catalog.Entry, error) {
+	if format == "" {
+		return nil, fmt.Errorf("unknown format of %s, use --from with one of %s", input, strings.Join(catalog.Formats, ", "))
+	}
+	bs, err := os.ReadFile(input)
+	if err != nil {
+		return nil, err
+	}
+	// catalog-gbk.txt and FreePic2Pdf bookmarks are usually GBK
+	if strings.EqualFold(encoding, "gbk") || (strings.EqualFold(encoding, "auto") && !utf8.Valid(bs)) {
+		if bs, err = io.ReadAll(transform.NewReader(bytes.NewReader(bs), simplifiedchinese.GBK.NewDecoder())); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := catalog.Read(bytes.NewReader(bs), format, opt)
+	if err == nil && len(entries) == 0 {
+		err = errors.New("no entries found in " + input)
+	}
+	return entries, err
+}
+
+// reportCatalogIssues validates entries against the pages downloaded in dir.
+// It returns true when an entry points outside the book.
+func reportCatalogIssues(entries []catalog.Entry, dir string, verbose bool) bool {
+	pages, err := mets.CountPages(dir)
+	if err != nil || pages == 0 {
+		if verbose {
+			i18n.Println("catalog.no_pages", dir)
+		}
+		return false
+	}
+	failed := false
+	issues := catalog.Validate(entries, pages)
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+		failed = failed || issue.Error
+	}
+	if verbose && len(issues) == 0 {
+		fmt.Println(i18n.N("catalog.valid", pages, len(entries), pages))
+	}
+	return failed
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bookget/app"
+	"bookget/config"
+	"bookget/pkg/events"
+	"bookget/pkg/queue"
+	"bookget/router"
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// jobState is where a job of the dashboard is
+type jobState int
+
+const (
+	jobQueued jobState = iota
+	jobRunning
+	jobDone
+	jobFailed
+)
+
+func (s jobState) String() string {
+	switch s {
+	case jobRunning:
+		return "running"
+	case jobDone:
+		return "done"
+	case jobFailed:
+		return "failed"
+	}
+	return "queued"
+}
+
+// job is one book of the dashboard's queue
+type job struct {
+	id      int
+	row     batchRow
+	ev      *events.Job // Tags the events of its download and pauses its pages
+	state   jobState
+	held    bool // Paused: a queued job is skipped, a running one starts no more pages
+	again   bool // Run again once the running download ends
+	planned int  // Pages selected so far
+	done    int
+	failed  int
+	bytes   int64
+	result  *batchResult
+}
+
+// pageStat is a page being downloaded
+type pageStat struct {
+	job          *job
+	file, host   string
+	bytes, total int64
+}
+
+// hostStat is the throughput of one host
+type hostStat struct {
+	total int64   // Bytes read
+	last  int64   // total at the last tick
+	rate  float64 // Bytes per second
+}
+
+// failure is a failed page, or a failed book when file is empty
+type failure struct {
+	job  *job
+	file string
+	err  string
+}
+
+const maxLogLines = 200
+
+// dashboard is the state behind the TUI. The engine's events update it, the
+// jobs download on a worker queue like the rows of batch mode.
+type dashboard struct {
+	mu        sync.Mutex
+	jobs      []*job
+	workers   int // Jobs downloading at once
+	running   int
+	exclusive bool // A row with options of its own runs, alone since they change config.Conf
+	pages     map[string]*pageStat
+	pageList  []string // Files of pages, in start order
+	hosts     map[string]*hostStat
+	failures  []failure
+	logs      []string
+	replace   bool // The last log line ended with \r and is overwritten
+	lastTick  time.Time
+	nextLine  int
+	wake      chan struct{}
+
+	// View
+	focusFailures bool
+	jobSel        int
+	failSel       int
+	editing       bool
+	input         []rune
+	notice        string
+	quitting      bool
+}
+
+func newDashboard(rows []batchRow) *dashboard {
+	d := &dashboard{
+		pages:    make(map[string]*pageStat),
+		hosts:    make(map[string]*hostStat),
+		workers:  bookWorkers(),
+		wake:     make(chan struct{}, 1),
+		lastTick: time.Now(),
+		nextLine: 1,
+	}
+	for _, row := range rows {
+		d.add(row)
+	}
+	return d
+}
+
+// add queues a book; rows typed in get line numbers after the batch file's
+func (d *dashboard) add(row batchRow) *job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if row.Line == 0 {
+		row.Line = d.nextLine
+	}
+	if row.Line >= d.nextLine {
+		d.nextLine = row.Line + 1
+	}
+	j := &job{id: len(d.jobs) + 1, row: row}
+	j.ev = &events.Job{ID: j.id}
+	d.jobs = append(d.jobs, j)
+	d.signal()
+	return j
+}
+
+// signal wakes the runner up; d.mu is held
+func (d *dashboard) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// jobOf is the job an event is from, nil if none; d.mu is held
+func (d *dashboard) jobOf(e events.Event) *job {
+	if e.Job < 1 || e.Job > len(d.jobs) {
+		return nil
+	}
+	return d.jobs[e.Job-1]
+}
+
+// handle updates the dashboard from an event of the engine
+func (d *dashboard) handle(e events.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	j := d.jobOf(e)
+	switch e.Kind {
+	case events.Received:
+		h := d.hosts[e.Host]
+		if h == nil {
+			h = new(hostStat)
+			d.hosts[e.Host] = h
+		}
+		h.total += e.Bytes
+		if j != nil {
+			j.bytes += e.Bytes
+		}
+	case events.PagePlanned:
+		if j != nil {
+			j.planned++
+		}
+	case events.PageStarted:
+		if _, ok := d.pages[e.File]; !ok {
+			d.pageList = append(d.pageList, e.File)
+		}
+		d.pages[e.File] = &pageStat{job: j, file: e.File, host: e.Host}
+	case events.PageProgress:
+		if p := d.pages[e.File]; p != nil {
+			p.bytes, p.total = e.Bytes, e.Total
+		}
+	case events.PageDone, events.PageFailed:
+		d.removePage(e.File)
+		if j == nil {
+			return
+		}
+		if e.Kind == events.PageDone {
+			j.done++
+			return
+		}
+		j.failed++
+		msg := "failed"
+		if e.Err != nil {
+			msg = e.Err.Error()
+		}
+		file := e.File
+		if file == "" {
+			file = e.URL
+		}
+		d.failures = append(d.failures, failure{job: j, file: file, err: msg})
+	}
+}
+
+func (d *dashboard) removePage(file string) {
+	delete(d.pages, file)
+	for i, f := range d.pageList {
+		if f == file {
+			d.pageList = append(d.pageList[:i], d.pageList[i+1:]...)
+			break
+		}
+	}
+}
+
+// tick updates the throughput of the hosts
+func (d *dashboard) tick(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	elapsed := now.Sub(d.lastTick).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	d.lastTick = now
+	for _, h := range d.hosts {
+		rate := float64(h.total-h.last) / elapsed
+		// Smooth out the bursts of single pages
+		h.rate = 0.5*h.rate + 0.5*rate
+		h.last = h.total
+	}
+}
+
+// log adds a line of the engine's output; replace overwrites the last line
+func (d *dashboard) log(line string, replace bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.replace && len(d.logs) > 0 {
+		d.logs[len(d.logs)-1] = line
+	} else {
+		d.logs = append(d.logs, line)
+	}
+	d.replace = replace
+	if len(d.logs) > maxLogLines {
+		d.logs = d.logs[len(d.logs)-maxLogLines:]
+	}
+}
+
+// next starts the first queued job that is not paused, nil if there is none
+// or no worker is free
+func (d *dashboard) next() *job {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.running >= d.workers || d.exclusive {
+		return nil
+	}
+	for _, j := range d.jobs {
+		if j.state != jobQueued || j.held {
+			continue
+		}
+		if j.row.hasOptions() {
+			if d.running > 0 {
+				return nil
+			}
+			d.exclusive = true
+		}
+		j.state = jobRunning
+		j.planned, j.done, j.failed = 0, 0, 0
+		d.running++
+		return j
+	}
+	return nil
+}
+
+// finish records the result of the running job
+func (d *dashboard) finish(j *job, result batchResult) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	j.result = &result
+	j.state = jobDone
+	if result.Err != nil {
+		j.state = jobFailed
+		d.failures = append(d.failures, failure{job: j, err: result.Err.Error()})
+	}
+	if j.held {
+		j.ev.Resume()
+	}
+	if j.again {
+		j.again = false
+		j.state = jobQueued
+	}
+	d.running--
+	if j.row.hasOptions() {
+		d.exclusive = false
+	}
+	for file, p := range d.pages {
+		if p.job == j {
+			d.removePage(file)
+		}
+	}
+	d.signal()
+}
+
+// run downloads the queued jobs on the worker queue of batch mode, forever
+func (d *dashboard) run(download func(ctx context.Context, row batchRow) batchResult) {
+	q := queue.NewConcurrentQueue(d.workers)
+	for {
+		j := d.next()
+		if j == nil {
+			<-d.wake
+			continue
+		}
+		q.Go(func() {
+			d.finish(j, download(events.WithJob(context.Background(), j.ev), j.row))
+		})
+	}
+}
+
+// runningJobs counts the jobs downloading and those of them paused; d.mu is held
+func (d *dashboard) runningJobs() (running, paused int) {
+	for _, j := range d.jobs {
+		if j.state == jobRunning {
+			running++
+			if j.held {
+				paused++
+			}
+		}
+	}
+	return running, paused
+}
+
+// togglePause pauses or resumes job j
+func (d *dashboard) togglePause(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch j.state {
+	case jobQueued:
+		j.held = !j.held
+		d.signal()
+	case jobRunning:
+		j.held = !j.held
+		if j.held {
+			j.ev.Pause()
+		} else {
+			j.ev.Resume()
+		}
+	}
+}
+
+// retry queues the book of failure i again. Pages already saved are kept,
+// so only the missing ones are downloaded.
+func (d *dashboard) retry(i int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i < 0 || i >= len(d.failures) {
+		return
+	}
+	d.requeue(d.failures[i].job)
+}
+
+// retryJob queues j again if it has finished
+func (d *dashboard) retryJob(j *job) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if j.state == jobDone || j.state == jobFailed {
+		d.requeue(j)
+	}
+}
+
+// requeue queues j again and drops its failures; d.mu is held
+func (d *dashboard) requeue(j *job) {
+	kept := d.failures[:0]
+	for _, f := range d.failures {
+		if f.job != j {
+			kept = append(kept, f)
+		}
+	}
+	d.failures = kept
+	if j.state == jobRunning {
+		j.again = true
+		return
+	}
+	j.state = jobQueued
+	d.signal()
+}
+
+// skip drops failure i from the list
+func (d *dashboard) skip(i int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if i < 0 || i >= len(d.failures) {
+		return
+	}
+	d.failures = append(d.failures[:i], d.failures[i+1:]...)
+}
+
+// results are the last results of the jobs that ran, for the batch summary
+func (d *dashboard) results() []batchResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var results []batchResult
+	for _, j := range d.jobs {
+		if j.result != nil {
+			results = append(results, *j.result)
+		}
+	}
+	return results
+}
+
+// downloadRow downloads the book of a dashboard job
+func downloadRow(ctx context.Context, row batchRow) batchResult {
+	u, err := url.Parse(row.URL)
+	if err != nil {
+		return batchResult{Row: row, Err: err}
+	}
+	siteID := u.Host
+	if config.Conf.DownloaderMode == 1 {
+		siteID = "bookget"
+	}
+	if router.SiteOf(siteID, row.URL) == "bookget" && !app.IsTemplate(row.URL) {
+		// Without a template in the URL it would ask for one on the terminal
+		return batchResult{Row: row, Err: errors.New("the image downloader needs a URL template such as https://host/[VOL]/[PAGE].jpg here")}
+	}
+	return processURLSet(ctx, siteID, row)
+}
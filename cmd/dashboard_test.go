@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bookget/pkg/events"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardEvents(t *testing.T) {
+	d := newDashboard([]batchRow{{Line: 3, URL: "https://a.example/1"}, {Line: 4, URL: "https://a.example/2"}})
+	j := d.add(batchRow{URL: "https://a.example/3"})
+	assert.Equal(t, 5, j.row.Line)
+
+	// The second job is paused and skipped
+	d.togglePause(d.jobs[1])
+	first := d.next()
+	require.Equal(t, 1, first.id)
+
+	for _, e := range []events.Event{
+		{Kind: events.PagePlanned, Job: 1}, {Kind: events.PagePlanned, Job: 1},
+		{Kind: events.PageStarted, Job: 1, Host: "a.example", File: "0001.jpg"},
+		{Kind: events.PageProgress, Job: 1, File: "0001.jpg", Bytes: 50, Total: 100},
+		{Kind: events.Received, Job: 1, Host: "a.example", Bytes: 4000},
+		{Kind: events.PageStarted, Job: 1, Host: "a.example", File: "0002.jpg"},
+	} {
+		d.handle(e)
+	}
+	assert.Equal(t, int64(50), d.pages["0001.jpg"].bytes)
+	d.handle(events.Event{Kind: events.PageDone, Job: 1, File: "0001.jpg"})
+	d.handle(events.Event{Kind: events.PageFailed, Job: 1, File: "0002.jpg", Err: errors.New("HTTP 404 Not Found")})
+	assert.Equal(t, []int{2, 1, 1}, []int{first.planned, first.done, first.failed})
+	assert.Equal(t, int64(4000), first.bytes)
+	assert.Empty(t, d.pages)
+	require.Len(t, d.failures, 1)
+
+	d.tick(d.lastTick.Add(time.Second))
+	assert.Equal(t, 2000.0, d.hosts["a.example"].rate)
+
+	lines := strings.Join(d.render(100, 30), "\n")
+	assert.Contains(t, lines, "1/2 pages, 1 failed")
+	assert.Contains(t, lines, "#1 0002.jpg  HTTP 404 Not Found")
+	assert.Contains(t, lines, "paused")
+
+	d.finish(first, batchResult{Row: first.row})
+	assert.Equal(t, jobDone, first.state)
+
+	// Retrying the failed page queues its book again, skip just drops it
+	d.retry(0)
+	assert.Empty(t, d.failures)
+	assert.Equal(t, first, d.next())
+	d.finish(first, batchResult{Row: first.row, Err: errors.New("no pages")})
+	require.Len(t, d.failures, 1)
+	d.skip(0)
+	assert.Empty(t, d.failures)
+	assert.Equal(t, 3, d.next().id)
+	assert.Len(t, d.results(), 1)
+}
+
+func TestDashboardWorkers(t *testing.T) {
+	d := newDashboard([]batchRow{{URL: "https://a.example/1"}, {URL: "https://a.example/2"}, {URL: "https://a.example/3", Pages: "1-5"}, {URL: "https://a.example/4"}})
+	d.workers = 2
+	first, second := d.next(), d.next()
+	require.NotNil(t, second)
+	assert.Nil(t, d.next(), "both workers are busy")
+
+	// Pausing a job holds its pages only
+	d.togglePause(first)
+	assert.True(t, first.ev.Paused())
+	assert.False(t, second.ev.Paused())
+	d.togglePause(first)
+	assert.False(t, first.ev.Paused())
+
+	// A row with options of its own runs alone
+	d.finish(first, batchResult{Row: first.row})
+	assert.Nil(t, d.next())
+	d.finish(second, batchResult{Row: second.row})
+	third := d.next()
+	require.Equal(t, 3, third.id)
+	assert.Nil(t, d.next())
+	d.finish(third, batchResult{Row: third.row})
+	assert.Equal(t, 4, d.next().id)
+}
+
+func TestDashboardKeys(t *testing.T) {
+	keys := parseKeys([]byte("a\x1b[Bhé\r\x7f\x1b[1;5C\x1b"))
+	assert.Equal(t, []key{{r: 'a'}, {name: "down"}, {r: 'h'}, {r: 'é'}, {name: "enter"}, {name: "backspace"}, {name: "esc"}}, keys)
+
+	d := newDashboard(nil)
+	for _, k := range parseKeys([]byte("ahttps://a.example/1\r")) {
+		assert.False(t, d.key(k))
+	}
+	require.Len(t, d.jobs, 1)
+	assert.Equal(t, "https://a.example/1", d.jobs[0].row.URL)
+
+	for _, k := range parseKeys([]byte("anot a url\r")) {
+		d.key(k)
+	}
+	assert.Len(t, d.jobs, 1)
+	assert.Contains(t, d.notice, "Invalid URL")
+	assert.True(t, d.key(key{r: 'q'}))
+}
+
+func TestScanLines(t *testing.T) {
+	var got []string
+	data := []byte("10%\r20%\rdone\nnext")
+	for len(data) > 0 {
+		n, token, _ := scanLines(data, true)
+		got = append(got, string(token))
+		data = data[n:]
+	}
+	assert.Equal(t, []string{"10%\r", "20%\r", "done", "next"}, got)
+
+	d := newDashboard(nil)
+	for _, s := range got {
+		d.log(strings.TrimSpace(s), strings.HasSuffix(s, "\r"))
+	}
+	assert.Equal(t, []string{"done", "next"}, d.logs)
+}
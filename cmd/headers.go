@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bookget/app"
+	"bookget/config"
+	"bookget/pkg/chttp"
+	"bookget/pkg/gohttp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// printHeaders shows the headers bookget sends to rawUrl: User-Agent and
+// header.txt, then the headers.ini profile of the host, then cookies.
+// {book_id} is left as is, the id is only known once the site is parsed.
+func printHeaders(rawUrl string) error {
+	u, err := url.Parse(strings.TrimSpace(rawUrl))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid URL: %s", rawUrl)
+	}
+
+	h := make(http.Header)
+	source := make(map[string]string)
+	for k, v := range app.BuildRequestHeader() {
+		h.Set(k, v)
+		source[http.CanonicalHeaderKey(k)] = "header.txt"
+	}
+	source["User-Agent"] = "--user-agent"
+	for k, v := range gohttp.ProfileHeaders(u.Hostname(), gohttp.Book{PageURL: u.String(), ID: "{book_id}"}) {
+		if h.Get(k) == "" {
+			h[k] = v
+			source[k] = config.HeaderProfilesFile
+		}
+	}
+	if jar := chttp.FileJar(config.Conf.CookieFile); jar != nil {
+		var pairs []string
+		for _, c := range jar.Cookies(u) {
+			pairs = append(pairs, c.Name+"="+maskValue(c.Value))
+		}
+		if len(pairs) > 0 && h.Get("Cookie") == "" {
+			h.Set("Cookie", strings.Join(pairs, "; "))
+			source["Cookie"] = config.Conf.CookieFile
+		}
+	}
+
+	fmt.Printf("%s\n", u.String())
+	for _, k := range gohttp.SortedHeaderKeys(h) {
+		fmt.Printf("%s: %s\t(%s)\n", k, h.Get(k), source[k])
+	}
+	return nil
+}
+
+// maskValue keeps the first characters of a cookie value, enough to tell sessions apart
+func maskValue(v string) string {
+	if len(v) <= 4 {
+		return strings.Repeat("*", len(v))
+	}
+	return v[:4] + strings.Repeat("*", min(len(v)-4, 8))
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"bookget/pkg/search"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/spf13/pflag"
+)
+
+const searchUsage = `Usage:
+  bookget search [OPTION]... <query>
+  bookget search --get 1,3-5 [OPTION]... [query]
+
+Search the institutions with search APIs and list the books found, numbered.
+--get downloads hits by number, from the last search when no query is given.
+--output urls, csv and jsonl print batch files for bookget -I.
+Sites: ndl, loc, hathitrust, princeton; iiif searches the text of the
+manifest given with --within. All download options apply, see bookget --help.`
+
+// defaultSearchSites are searched without --site
+const defaultSearchSites = "ndl,loc,hathitrust,princeton"
+
+// runSearch implements `bookget search`
+func runSearch(args []string) int {
+	sites := pflag.String("site", "", "search: sites to search, comma separated [ndl|loc|hathitrust|princeton|iiif], default "+defaultSearchSites)
+	limit := pflag.Int("limit", 10, "search: hits per site")
+	within := pflag.String("within", "", "search: IIIF manifest whose text --site iiif searches")
+	output := pflag.String("output", "table", "search: print hits as [table|urls|csv|jsonl|json]")
+	get := pflag.String("get", "", "search: download hits by number, e.g. 1,3-5 or all")
+	os.Args = append(os.Args[:1], args...)
+	if !initializeConfig(context.Background()) {
+		return 2
+	}
+	query := strings.TrimSpace(strings.Join(pflag.Args(), " "))
+	lastFile := lastSearchFile()
+
+	var hits []search.Hit
+	if query == "" {
+		if *get == "" {
+			fmt.Println(searchUsage)
+			return 2
+		}
+		var err error
+		if hits, err = loadLastSearch(lastFile); err != nil {
+			i18n.Println("search.no_last")
+			return 2
+		}
+	} else {
+		if *sites == "" {
+			*sites = defaultSearchSites
+			if *within != "" {
+				*sites = "iiif"
+			}
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		results, err := search.Run(ctx, splitList(*sites), search.Query{Text: query, Limit: *limit, Within: *within})
+		stop()
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				i18n.Logln("search.failed", r.Site, r.Err)
+			}
+			hits = append(hits, r.Hits...)
+		}
+		if err := saveLastSearch(lastFile, hits); err != nil {
+			log.Println(err)
+		}
+		if err := printHits(os.Stdout, hits, *output); err != nil {
+			fmt.Println(err)
+			return 2
+		}
+	}
+	if *get == "" {
+		return 0
+	}
+	return getHits(hits, *get)
+}
+
+// getHits downloads the hits numbered in sel
+func getHits(hits []search.Hit, sel string) int {
+	if sel == "all" {
+		sel = ""
+	}
+	numbers, err := config.ParseSelection(sel, false)
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	code, picked := 0, 0
+	for i, hit := range hits {
+		n := i + 1
+		if !numbers.Contains(n, len(hits), 0) {
+			continue
+		}
+		picked++
+		i18n.Logln("search.get", n, hit.Title, hit.URL)
+		if r := downloadRow(context.Background(), hitRow(n, hit)); r.Err != nil {
+			i18n.Logln("search.get_failed", n, r.Err)
+			code = 1
+		}
+	}
+	if picked == 0 {
+		i18n.Println("search.none_selected", sel, len(hits))
+		return 2
+	}
+	return code
+}
+
+// hitRow is the batch row that downloads hit; content search hits get their page
+func hitRow(n int, hit search.Hit) batchRow {
+	row := batchRow{Line: n, URL: hit.URL}
+	if hit.Page > 0 {
+		row.Pages = strconv.Itoa(hit.Page)
+	}
+	return row
+}
+
+// hitLabel is the label of hit in batch files
+func hitLabel(hit search.Hit) string {
+	label := hit.Title
+	if hit.Date != "" {
+		label += " (" + hit.Date + ")"
+	}
+	return label
+}
+
+func printHits(w io.Writer, hits []search.Hit, output string) error {
+	switch output {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "#\tSITE\tDATE\tPAGES\tTITLE\tURL")
+		for i, hit := range hits {
+			pages, title := "", hit.Title
+			if hit.Pages > 0 {
+				pages = strconv.Itoa(hit.Pages)
+			}
+			if hit.Volumes > 1 {
+				pages = strings.TrimSpace(pages + " " + i18n.N("count.volumes", hit.Volumes, hit.Volumes))
+			}
+			if hit.Page > 0 {
+				pages = fmt.Sprintf("%d/%s", hit.Page, pages)
+				title = hit.Snippet
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, hit.Site, orDash(hit.Date), orDash(pages), truncate(title, 60), hit.URL)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, i18n.N("search.count", len(hits), len(hits)))
+	case "urls":
+		for _, hit := range hits {
+			fmt.Fprintln(w, hit.URL)
+		}
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"url", "pages", "label"})
+		for i, hit := range hits {
+			row := hitRow(i+1, hit)
+			_ = cw.Write([]string{row.URL, row.Pages, hitLabel(hit)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for i, hit := range hits {
+			row := hitRow(i+1, hit)
+			row.Label = hitLabel(hit)
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hits)
+	default:
+		return fmt.Errorf("unknown output %q, expected table, urls, csv, jsonl or json", output)
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// lastSearchFile keeps the hits of the last search for --get
+func lastSearchFile() string {
+	home := config.BookgetHomeDir()
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, "last_search.json")
+}
+
+func saveLastSearch(file string, hits []search.Hit) error {
+	if file == "" {
+		return nil
+	}
+	data, err := json.Marshal(hits)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func loadLastSearch(file string) ([]search.Hit, error) {
+	if file == "" {
+		return nil, errors.New("no home directory")
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var hits []search.Hit
+	return hits, json.Unmarshal(data, &hits)
+}
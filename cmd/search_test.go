@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bookget/pkg/search"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchOutputIsABatchFile(t *testing.T) {
+	hits := []search.Hit{
+		{Site: "loc", Title: "Qing dynasty atlas, \"first\" edition", Date: "1850", URL: "https://www.loc.gov/item/2014514163/", Pages: 100},
+		{Site: "iiif", Title: "Lunyu", URL: "https://example.org/manifest.json", Pages: 300, Page: 12, Snippet: "學而時習之"},
+	}
+	want := []batchRow{
+		{Line: 2, URL: "https://www.loc.gov/item/2014514163/", Label: "Qing dynasty atlas, \"first\" edition (1850)"},
+		{Line: 3, URL: "https://example.org/manifest.json", Pages: "12", Label: "Lunyu"},
+	}
+	for _, output := range []string{"csv", "jsonl", "urls"} {
+		t.Run(output, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, printHits(&out, hits, output))
+			rows, err := parseBatch("hits."+output, out.Bytes())
+			require.NoError(t, err)
+			require.Len(t, rows, 2)
+			if output == "urls" {
+				assert.Equal(t, want[1].URL, rows[1].URL)
+				return
+			}
+			if output == "jsonl" {
+				want[0].Line, want[1].Line = 1, 2
+			}
+			assert.Equal(t, want, rows)
+		})
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, printHits(&out, hits, "table"))
+	assert.Contains(t, out.String(), "12/300")
+	assert.Error(t, printHits(&out, hits, "xml"))
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"bookget/pkg/version"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+const selfUpdateUsage = `Usage:
+  bookget self-update [OPTION]...
+
+Download the latest release for this platform, check its SHA-256 against the
+release's SHA256SUMS (and SHA256SUMS.sig when update_pubkey is set in
+config.ini) and replace the running binary. The releases come from
+update_repo at update_endpoint, storytracer/bookget on GitHub by default.`
+
+// runSelfUpdate implements `bookget self-update`
+func runSelfUpdate(args []string) int {
+	dir, _ := os.Getwd()
+	flags := pflag.NewFlagSet("self-update", pflag.ContinueOnError)
+	lang := flags.String("lang", "", config.LangUsage)
+	configFile := flags.String("config", filepath.Join(dir, "config.ini"), "Config file")
+	repo := flags.String("repo", "", "Releases of this GitHub owner/name instead of update_repo")
+	endpoint := flags.String("endpoint", "", "GitHub compatible API instead of update_endpoint, e.g. https://git.example.org/api/v1")
+	pubkey := flags.String("pubkey", "", "Ed25519 public key that must have signed SHA256SUMS instead of update_pubkey")
+	check := flags.Bool("check", false, "Only report whether a newer release exists")
+	force := flags.Bool("force", false, "Install the latest release even when it isn't newer")
+	flags.Usage = func() {
+		fmt.Println(selfUpdateUsage)
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := i18n.SetLanguage(*lang); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if err := config.LoadConfigFile(*configFile); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if *pubkey == "" {
+		*pubkey = config.Value("update_pubkey")
+	}
+	updater := version.NewUpdater(nil)
+	if *pubkey != "" {
+		key, err := version.ParsePublicKey(*pubkey)
+		if err != nil {
+			fmt.Println(err)
+			return 2
+		}
+		updater.PublicKey = key
+	}
+
+	checker := newUpdateChecker(*repo, *endpoint)
+	release, err := checker.Latest()
+	if err != nil {
+		i18n.Println("update.check_failed", err)
+		return 1
+	}
+	newer := version.Newer(release.Version(), checker.CurrentVersion)
+	if !newer && !*force {
+		i18n.Println("update.latest", checker.CurrentVersion)
+		return 0
+	}
+	if *check {
+		i18n.Println("update.available", release.Version(), checker.CurrentVersion)
+		i18n.Println("update.visit", checker.ReleaseURL(release))
+		return 0
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	i18n.Println("update.installing", release.Version(), updater.Asset, checker.Repo)
+	if err := updater.Apply(release, exe); err != nil {
+		i18n.Println("update.failed", err)
+		return 1
+	}
+	i18n.Println("update.installed", release.Version(), exe)
+	return 0
+}
+
+// newUpdateChecker checks repo at endpoint, defaulting to update_repo and update_endpoint of config.ini
+func newUpdateChecker(repo, endpoint string) *version.Checker {
+	if repo == "" {
+		repo = config.Value("update_repo")
+	}
+	if endpoint == "" {
+		endpoint = config.Value("update_endpoint")
+	}
+	return version.NewChecker(config.Version, repo, endpoint)
+}
+
+// updateCheckEnabled reports whether config.ini turns the check on every start on
+func updateCheckEnabled() bool {
+	on, _ := strconv.ParseBool(config.Value("update_check"))
+	return on
+}
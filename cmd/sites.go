@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bookget/app"
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"bookget/pkg/util"
+	"bookget/router"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+)
+
+const sitesUsage = `Usage:
+  bookget sites [OPTION]... [filter]
+
+List the supported sites. filter matches names, countries and hosts.`
+
+const whichUsage = `Usage:
+  bookget which [OPTION]... <url>
+
+Show which site adapter would download url, the record and the book ID it
+reads from url, and why the URL matched or not.`
+
+// runSites implements `bookget sites`
+func runSites(args []string) int {
+	flags := pflag.NewFlagSet("sites", pflag.ContinueOnError)
+	lang := flags.String("lang", "", config.LangUsage)
+	country := flags.String("country", "", "Only sites of this country")
+	asJSON := flags.Bool("json", false, "Print JSON instead of a table")
+	flags.Usage = func() {
+		fmt.Println(sitesUsage)
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := i18n.SetLanguage(*lang); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	filter := strings.ToLower(strings.Join(flags.Args(), " "))
+
+	var sites []router.Site
+	for _, s := range router.Sites {
+		if *country != "" && !strings.EqualFold(s.Country, *country) {
+			continue
+		}
+		if filter != "" && !strings.Contains(strings.ToLower(s.Name+" "+s.Country+" "+strings.Join(s.Hosts, " ")), filter) {
+			continue
+		}
+		sites = append(sites, s)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(sites); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COUNTRY\tNAME\tHOSTS\tAUTH")
+	for _, s := range sites {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", orDash(s.Country), s.Name, strings.Join(s.Hosts, ", "), describeAuth(s))
+	}
+	_ = w.Flush()
+	fmt.Println()
+	fmt.Println(i18n.N("sites.count", len(sites), len(sites)))
+	return 0
+}
+
+// runWhich implements `bookget which`
+func runWhich(args []string) int {
+	flags := pflag.NewFlagSet("which", pflag.ContinueOnError)
+	lang := flags.String("lang", "", config.LangUsage)
+	probe := flags.Bool("probe", false, "Ask the server for the Content-Type of URLs of unlisted hosts")
+	flags.Usage = func() {
+		fmt.Println(whichUsage)
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if err := i18n.SetLanguage(*lang); err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	rawUrl := strings.TrimSpace(flags.Arg(0))
+	u, err := url.Parse(rawUrl)
+	if err != nil || u.Host == "" || !isValidURL(rawUrl) {
+		i18n.Println("url.invalid", rawUrl)
+		return 1
+	}
+
+	route := router.Resolve(u.Host, rawUrl)
+	if route.Site == nil {
+		i18n.Println("which.no_site", route.Reason)
+		if hosts := router.SimilarHosts(u.Host); len(hosts) > 0 {
+			i18n.Println("which.similar_hosts", strings.Join(hosts, ", "))
+		}
+		if !*probe {
+			i18n.Println("which.would_probe")
+			return 1
+		}
+		switch util.GetHeaderContentType(rawUrl) {
+		case "json":
+			route = router.Resolve("iiif.io", rawUrl)
+		case "bookget":
+			route = router.Resolve("bookget", rawUrl)
+		default:
+			i18n.Println("which.unsupported")
+			return 1
+		}
+		route.Reason = i18n.T("which.by_content_type")
+	}
+
+	s := route.Site
+	i18n.Println("which.site", s.Name)
+	if s.Country != "" {
+		i18n.Println("which.country", s.Country)
+	}
+	i18n.Println("which.adapter", route.SiteID)
+	i18n.Println("which.matched", route.Reason)
+	if auth := describeAuth(*s); auth != "" {
+		i18n.Println("which.needs", auth)
+	}
+
+	if rec, ok := app.Normalize(rawUrl); ok {
+		i18n.Println("which.record", rec, rec.URL)
+	}
+	bookId, ok := app.BookIdOf(router.Router[route.SiteID], route.URL)
+	switch {
+	case !ok:
+		i18n.Println("which.id_later")
+	case bookId != "":
+		i18n.Println("which.id", bookId)
+	default:
+		i18n.Println("which.id_none")
+		for _, example := range s.Examples {
+			i18n.Println("which.example", example)
+		}
+		return 1
+	}
+	return 0
+}
+
+// describeAuth tells what a site needs besides the URL
+func describeAuth(s router.Site) string {
+	var needs []string
+	switch s.Auth {
+	case router.AuthCookie:
+		needs = append(needs, "cookie.txt")
+	case router.AuthLogin:
+		needs = append(needs, "login")
+	}
+	if s.GUI {
+		needs = append(needs, "bookget-gui")
+	}
+	return strings.Join(needs, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bookget/config"
+	"bookget/pkg/events"
+	"bookget/pkg/gohttp"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/term"
+)
+
+// runDashboard shows the full-screen dashboard until q is pressed, downloading
+// rows and the URLs added meanwhile. It returns the results of the jobs that ran.
+func runDashboard(rows []batchRow) ([]batchResult, error) {
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return nil, errors.New("--tui needs a terminal")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(in, state)
+
+	tty := os.Stdout
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(tty, "\x1b[?25h\x1b[?1049l")
+
+	d := newDashboard(rows)
+	// The sites' own progress bars and log lines go to the log pane
+	restore, err := captureOutput(d.log)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	cancel := events.Subscribe(d.handle)
+	defer cancel()
+	go d.run(downloadRow)
+
+	keys := make(chan key, 16)
+	go readKeys(os.Stdin, keys)
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		width, height, err := term.GetSize(out)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		fmt.Fprint(tty, "\x1b[H"+strings.Join(d.render(width, height), "\x1b[K\r\n")+"\x1b[K\x1b[J")
+		select {
+		case k, ok := <-keys:
+			if !ok || d.key(k) {
+				return d.results(), nil
+			}
+		case now := <-ticker.C:
+			d.tick(now)
+		}
+	}
+}
+
+// captureOutput sends what is printed to stdout, stderr and the log to logf,
+// line by line; text ending with \r is overwritten by the next line.
+func captureOutput(logf func(line string, replace bool)) (restore func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	log.SetOutput(w)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 4096), 1<<20)
+		scanner.Split(scanLines)
+		for scanner.Scan() {
+			text := scanner.Text()
+			replace := strings.HasSuffix(text, "\r")
+			line := strings.TrimSpace(ansiEscape.ReplaceAllString(text, ""))
+			if line != "" {
+				logf(line, replace)
+			}
+		}
+	}()
+	return func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		log.SetOutput(stderr)
+		_ = w.Close()
+		<-done
+		_ = r.Close()
+	}, nil
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// scanLines splits at \n and \r, keeping a trailing \r
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' {
+			return i + 1, data[:i+1], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// key is a key press, name is empty for printable runes
+type key struct {
+	name string
+	r    rune
+}
+
+// parseKeys splits the bytes read from a raw terminal into keys
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch {
+		case bytes.HasPrefix(b, []byte("\x1b[A")) || bytes.HasPrefix(b, []byte("\x1bOA")):
+			keys, b = append(keys, key{name: "up"}), b[3:]
+			continue
+		case bytes.HasPrefix(b, []byte("\x1b[B")) || bytes.HasPrefix(b, []byte("\x1bOB")):
+			keys, b = append(keys, key{name: "down"}), b[3:]
+			continue
+		case b[0] == 0x1b && len(b) > 2 && b[1] == '[':
+			// Other sequences: skip to the final byte
+			i := 2
+			for i < len(b) && (b[i] < 0x40 || b[i] > 0x7e) {
+				i++
+			}
+			b = b[min(i+1, len(b)):]
+			continue
+		}
+		name := ""
+		switch b[0] {
+		case 0x1b:
+			name = "esc"
+		case '\r', '\n':
+			name = "enter"
+		case 0x7f, 0x08:
+			name = "backspace"
+		case '\t':
+			name = "tab"
+		case 0x03:
+			name = "ctrl-c"
+		}
+		if name != "" {
+			keys, b = append(keys, key{name: name}), b[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(b)
+		if unicode.IsPrint(r) {
+			keys = append(keys, key{r: r})
+		}
+		b = b[size:]
+	}
+	return keys
+}
+
+func readKeys(r io.Reader, keys chan<- key) {
+	defer close(keys)
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Read(buf)
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// key handles a key press; quit tells the dashboard to close
+func (d *dashboard) key(k key) (quit bool) {
+	if d.editing {
+		d.editKey(k)
+		return false
+	}
+	d.mu.Lock()
+	d.notice = ""
+	quitting := d.quitting
+	d.quitting = false
+	jobs, failures := len(d.jobs), len(d.failures)
+	var selected *job
+	if d.jobSel < jobs {
+		selected = d.jobs[d.jobSel]
+	}
+	running, _ := d.runningJobs()
+	focusFailures, failSel := d.focusFailures, d.failSel
+	d.mu.Unlock()
+
+	switch {
+	case k.name == "up" || k.r == 'k':
+		d.move(-1)
+	case k.name == "down" || k.r == 'j':
+		d.move(1)
+	case k.name == "tab":
+		d.mu.Lock()
+		d.focusFailures = !d.focusFailures && failures > 0
+		d.mu.Unlock()
+	case k.r == 'a' || k.r == 'i':
+		d.mu.Lock()
+		d.editing, d.input = true, nil
+		d.mu.Unlock()
+	case k.r == 'p' || k.r == ' ':
+		if selected != nil && !focusFailures {
+			d.togglePause(selected)
+		}
+	case k.r == 'r':
+		if focusFailures {
+			d.retry(failSel)
+		} else if selected != nil {
+			d.retryJob(selected)
+		}
+	case k.r == 's':
+		if focusFailures {
+			d.skip(failSel)
+		}
+	case k.r == 'q' || k.name == "ctrl-c":
+		if running == 0 || quitting {
+			return true
+		}
+		d.mu.Lock()
+		d.notice, d.quitting = "A book is still downloading, press q again to quit", true
+		d.mu.Unlock()
+	}
+	d.mu.Lock()
+	d.clampSelection()
+	d.mu.Unlock()
+	return false
+}
+
+// editKey handles a key of the URL input line
+func (d *dashboard) editKey(k key) {
+	d.mu.Lock()
+	switch k.name {
+	case "enter":
+		sUrl := strings.TrimSpace(string(d.input))
+		d.editing, d.input = false, nil
+		if !isValidURL(sUrl) {
+			d.notice = fmt.Sprintf("Invalid URL: %q", sUrl)
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+		d.add(batchRow{URL: sUrl})
+		return
+	case "esc", "ctrl-c":
+		d.editing, d.input = false, nil
+	case "backspace":
+		if len(d.input) > 0 {
+			d.input = d.input[:len(d.input)-1]
+		}
+	case "":
+		d.input = append(d.input, k.r)
+	}
+	d.mu.Unlock()
+}
+
+func (d *dashboard) move(delta int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.focusFailures {
+		d.failSel += delta
+	} else {
+		d.jobSel += delta
+	}
+	d.clampSelection()
+}
+
+// clampSelection keeps the selections in their lists; d.mu is held
+func (d *dashboard) clampSelection() {
+	d.jobSel = max(0, min(d.jobSel, len(d.jobs)-1))
+	d.failSel = max(0, min(d.failSel, len(d.failures)-1))
+	if len(d.failures) == 0 {
+		d.focusFailures = false
+	}
+}
+
+// render lays the dashboard out in height lines of width cells
+func (d *dashboard) render(width, height int) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	counts := make(map[jobState]int)
+	for _, j := range d.jobs {
+		counts[j.state]++
+	}
+	var rate float64
+	for _, h := range d.hosts {
+		rate += h.rate
+	}
+	status := fmt.Sprintf("bookget v%s  jobs: %d running, %d queued, %d done, %d failed  %s/s",
+		config.Version, counts[jobRunning], counts[jobQueued], counts[jobDone], counts[jobFailed], gohttp.ByteUnitString(int64(rate)))
+	if _, paused := d.runningJobs(); paused > 0 {
+		status += fmt.Sprintf("  [%d paused]", paused)
+	}
+
+	// Bottom lines: notice and the help or the input line
+	footer := []string{d.notice}
+	if d.editing {
+		footer = append(footer, "URL: "+string(d.input)+"_")
+	} else {
+		footer = append(footer, "a add URL  ↑↓ select  tab jobs/failures  p pause/resume  r retry  s skip  q quit")
+	}
+
+	lines := []string{status}
+	room := height - len(lines) - len(footer)
+	section := func(title string, rows []string, limit, selected int) {
+		if room < 2 {
+			return
+		}
+		n := min(len(rows), limit, room-1)
+		lines = append(lines, rule(title, width))
+		start := 0
+		if n > 0 && selected >= n {
+			start = selected - n + 1
+		}
+		lines = append(lines, rows[start:start+n]...)
+		room -= n + 1
+	}
+
+	var jobRows []string
+	for i, j := range d.jobs {
+		jobRows = append(jobRows, marker(!d.focusFailures && i == d.jobSel)+j.describe())
+	}
+	section(fmt.Sprintf("Jobs (%d)", len(d.jobs)), jobRows, 8, d.jobSel)
+
+	var pageRows []string
+	for _, file := range d.pageList {
+		p := d.pages[file]
+		pageRows = append(pageRows, "  "+p.describe(width))
+	}
+	if len(pageRows) > 0 {
+		section(fmt.Sprintf("Pages (%d downloading)", len(pageRows)), pageRows, 6, 0)
+	}
+
+	if len(d.hosts) > 0 {
+		hosts := make([]string, 0, len(d.hosts))
+		for host := range d.hosts {
+			hosts = append(hosts, host)
+		}
+		sort.Slice(hosts, func(i, k int) bool { return d.hosts[hosts[i]].total > d.hosts[hosts[k]].total })
+		var hostRows []string
+		for _, host := range hosts {
+			h := d.hosts[host]
+			hostRows = append(hostRows, fmt.Sprintf("  %-32s %10s/s %10s", host, gohttp.ByteUnitString(int64(h.rate)), gohttp.ByteUnitString(h.total)))
+		}
+		section("Hosts", hostRows, 4, 0)
+	}
+
+	if len(d.failures) > 0 {
+		var failRows []string
+		for i, f := range d.failures {
+			what := f.file
+			if what == "" {
+				what = "book"
+			}
+			failRows = append(failRows, fmt.Sprintf("%s#%d %s  %s", marker(d.focusFailures && i == d.failSel), f.job.id, what, f.err))
+		}
+		section(fmt.Sprintf("Failures (%d)", len(d.failures)), failRows, 6, d.failSel)
+	}
+
+	if room >= 2 && len(d.logs) > 0 {
+		n := min(len(d.logs), room-1)
+		lines = append(lines, rule("Log", width))
+		for _, l := range d.logs[len(d.logs)-n:] {
+			lines = append(lines, "  "+l)
+		}
+		room -= n + 1
+	}
+	for ; room > 0; room-- {
+		lines = append(lines, "")
+	}
+	lines = append(lines, footer...)
+	for i := range lines {
+		lines[i] = fit(lines[i], width)
+	}
+	return lines
+}
+
+func (j *job) describe() string {
+	state := j.state.String()
+	if j.held && (j.state == jobQueued || j.state == jobRunning) {
+		state = "paused"
+	}
+	s := fmt.Sprintf("#%-3d %-8s", j.id, state)
+	if j.state != jobQueued || j.result != nil {
+		s += fmt.Sprintf(" %d/%d pages", j.done, j.planned)
+		if j.failed > 0 {
+			s += fmt.Sprintf(", %d failed", j.failed)
+		}
+		s += "  " + gohttp.ByteUnitString(j.bytes)
+	}
+	name := j.row.URL
+	if j.row.Label != "" {
+		name = j.row.Label
+	}
+	return s + "  " + name
+}
+
+func (p *pageStat) describe(width int) string {
+	name := p.file
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if p.total <= 0 {
+		return fmt.Sprintf("%-24s %10s  %s", name, gohttp.ByteUnitString(p.bytes), p.host)
+	}
+	return fmt.Sprintf("%-24s %s %10s/%-10s  %s", name, bar(p.bytes, p.total, min(30, width/4)),
+		gohttp.ByteUnitString(p.bytes), gohttp.ByteUnitString(p.total), p.host)
+}
+
+// bar draws done of total in width cells
+func bar(done, total int64, width int) string {
+	if width < 3 {
+		width = 3
+	}
+	filled := int(done * int64(width-2) / max(total, 1))
+	filled = max(0, min(filled, width-2))
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-2-filled) + "]"
+}
+
+func marker(selected bool) string {
+	if selected {
+		return "> "
+	}
+	return "  "
+}
+
+// rule is a section title across the width
+func rule(title string, width int) string {
+	s := "── " + title + " "
+	if n := width - uniseg.StringWidth(s); n > 0 {
+		s += strings.Repeat("─", n)
+	}
+	return s
+}
+
+// fit cuts s to width cells
+func fit(s string, width int) string {
+	if uniseg.StringWidth(s) <= width {
+		return s
+	}
+	var b strings.Builder
+	w := 0
+	g := uniseg.NewGraphemes(s)
+	for g.Next() {
+		if w+g.Width() > width {
+			break
+		}
+		w += g.Width()
+		b.WriteString(g.Str())
+	}
+	return b.String()
+}
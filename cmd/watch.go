@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bookget/app"
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+const watchUsage = `Usage:
+  bookget watch [OPTION]... --input-file <urls.txt|folder>
+
+Download the books of a batch file as lines are added to it, or of the .url
+and .txt files dropped into a folder. Books already downloaded are skipped.
+The status of every book is appended to urls.status.csv next to the file, or
+to status.csv in the folder, where dropped files go to done/ or failed/.
+All download options apply, see bookget --help.`
+
+// watchExts are the files picked up from a drop folder
+var watchExts = map[string]bool{".url": true, ".txt": true}
+
+// runWatch implements `bookget watch`
+func runWatch(args []string) int {
+	interval := pflag.Duration("interval", 5*time.Second, "watch: how often to look for new URLs")
+	once := pflag.Bool("once", false, "watch: download the URLs there are now, then exit")
+	os.Args = append(os.Args[:1], args...)
+	if !initializeConfig(context.Background()) {
+		return 2
+	}
+	if config.Conf.UrlsFile == "" {
+		fmt.Println(watchUsage)
+		return 2
+	}
+
+	w, err := newWatcher(config.Conf.UrlsFile, config.Conf.Threads, func(row batchRow) batchResult {
+		return downloadRow(context.Background(), row)
+	})
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	w.settle = *once
+	i18n.Logln("watch.start", w.input, w.journal)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for !*once {
+		w.poll()
+		select {
+		case <-ctx.Done():
+			// A second Ctrl-C quits at once
+			stop()
+			i18n.Logln("watch.stopping")
+			w.wait()
+			return 0
+		case <-time.After(*interval):
+		}
+	}
+	w.poll()
+	w.wait()
+	return 0
+}
+
+// watcher downloads the books of a batch file or drop folder as they show up
+type watcher struct {
+	input    string
+	folder   bool
+	journal  string // Status of the books, CSV
+	settle   bool   // Take files as they are, without waiting for them to stop changing
+	download func(row batchRow) batchResult
+
+	mu      sync.Mutex
+	done    map[string]bool  // Books downloaded, from the journal, by bookKey
+	seen    map[string]bool  // Books of the batch file queued in this run, by bookKey
+	sizes   map[string]int64 // Size of each file at the last poll
+	busy    map[string]bool  // Dropped files being downloaded
+	lastErr string
+
+	sem     chan struct{}
+	options sync.RWMutex // Rows with options change config.Conf and run alone
+	wg      sync.WaitGroup
+}
+
+func newWatcher(input string, threads int, download func(row batchRow) batchResult) (*watcher, error) {
+	fi, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if threads < 1 {
+		threads = 1
+	}
+	w := &watcher{
+		input:    input,
+		folder:   fi.IsDir(),
+		download: download,
+		seen:     make(map[string]bool),
+		sizes:    make(map[string]int64),
+		busy:     make(map[string]bool),
+		sem:      make(chan struct{}, threads),
+	}
+	if w.folder {
+		w.journal = filepath.Join(input, "status.csv")
+	} else {
+		w.journal = strings.TrimSuffix(input, filepath.Ext(input)) + ".status.csv"
+	}
+	if w.done, err = loadJournal(w.journal); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// poll queues the books that are new since the last poll
+func (w *watcher) poll() {
+	if w.folder {
+		w.pollFolder()
+	} else {
+		w.pollFile()
+	}
+}
+
+// pollFile queues the new lines of the batch file. A last line without a
+// newline waits until the file stops changing, it may still be typed.
+func (w *watcher) pollFile() {
+	content, err := os.ReadFile(w.input)
+	if err != nil {
+		w.logError(w.input, err)
+		return
+	}
+	size := int64(len(content))
+	if !w.settle && !w.stable(w.input, size) && !strings.HasSuffix(string(content), "\n") {
+		content = content[:strings.LastIndex(string(content), "\n")+1]
+	}
+	rows, err := parseBatch(w.input, content)
+	if err != nil {
+		w.logError(w.input, err)
+		return
+	}
+	w.lastErr = ""
+	for _, row := range rows {
+		w.mu.Lock()
+		key := bookKey(row.URL)
+		skip := w.done[key] || w.seen[key]
+		w.seen[key] = true
+		w.mu.Unlock()
+		if !skip {
+			w.start(row, filepath.Base(w.input), nil)
+		}
+	}
+}
+
+// pollFolder downloads the books of each dropped file once it stops changing,
+// then moves the file to done/ or, if a book failed, to failed/
+func (w *watcher) pollFolder() {
+	entries, err := os.ReadDir(w.input)
+	if err != nil {
+		w.logError(w.input, err)
+		return
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !watchExts[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+		file := filepath.Join(w.input, name)
+		fi, err := e.Info()
+		w.mu.Lock()
+		busy := w.busy[file]
+		w.mu.Unlock()
+		if err != nil || busy || (!w.settle && !w.stable(file, fi.Size())) {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var rows []batchRow
+		if strings.EqualFold(filepath.Ext(name), ".url") {
+			rows = parseShortcut(content)
+		} else if rows, err = parseBatch(file, content); err != nil {
+			i18n.Logln("watch.error", name, err)
+			w.move(file, "failed")
+			continue
+		}
+
+		w.mu.Lock()
+		w.busy[file] = true
+		w.mu.Unlock()
+		summary := new(batchSummary)
+		var fileWG sync.WaitGroup
+		for _, row := range rows {
+			w.mu.Lock()
+			skip := w.done[bookKey(row.URL)]
+			w.mu.Unlock()
+			if skip {
+				continue
+			}
+			fileWG.Add(1)
+			w.start(row, name, func(r batchResult) {
+				summary.add(r)
+				fileWG.Done()
+			})
+		}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			fileWG.Wait()
+			failed := false
+			for _, r := range summary.results {
+				failed = failed || r.Err != nil
+			}
+			if !failed {
+				w.move(file, "done")
+				return
+			}
+			dest := w.move(file, "failed")
+			if dest != "" {
+				_, _ = summary.write(strings.TrimSuffix(dest, filepath.Ext(dest)) + "-summary.csv")
+			}
+		}()
+	}
+}
+
+// stable tells whether file still has the size of the last poll
+func (w *watcher) stable(file string, size int64) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last, ok := w.sizes[file]
+	w.sizes[file] = size
+	return ok && last == size
+}
+
+// start downloads the book of row with the concurrency of --threads
+func (w *watcher) start(row batchRow, source string, finished func(batchResult)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.sem <- struct{}{}
+		defer func() { <-w.sem }()
+		if row.hasOptions() {
+			w.options.Lock()
+			defer w.options.Unlock()
+		} else {
+			w.options.RLock()
+			defer w.options.RUnlock()
+		}
+		result := w.download(row)
+		w.record(result, source)
+		if finished != nil {
+			finished(result)
+		}
+	}()
+}
+
+// record appends the status of a book to the journal
+func (w *watcher) record(r batchResult, source string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status, msg := "ok", r.Msg
+	if r.Err != nil {
+		status, msg = "failed", r.Err.Error()
+		i18n.Logln("watch.failed", r.Row.URL, r.Err)
+	} else {
+		w.done[bookKey(r.Row.URL)] = true
+		i18n.Logln("watch.ok", r.Row.URL)
+	}
+
+	_, err := os.Stat(w.journal)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(w.journal, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		i18n.Logln("watch.error", w.journal, err)
+		return
+	}
+	defer f.Close()
+	cw := csv.NewWriter(f)
+	if isNew {
+		_ = cw.Write(journalColumns)
+	}
+	_ = cw.Write([]string{
+		time.Now().Format(time.RFC3339), r.Row.URL, r.Row.Label, status, msg,
+		strconv.FormatFloat(r.Duration.Seconds(), 'f', 1, 64), source,
+	})
+	cw.Flush()
+}
+
+// move puts a dropped file into the sub-folder dir, returns where it went
+func (w *watcher) move(file, dir string) string {
+	folder := filepath.Join(w.input, dir)
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		i18n.Logln("watch.error", file, err)
+		return ""
+	}
+	name := filepath.Base(file)
+	ext := filepath.Ext(name)
+	dest := filepath.Join(folder, name)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = filepath.Join(folder, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), n, ext))
+	}
+	if err := os.Rename(file, dest); err != nil {
+		i18n.Logln("watch.error", file, err)
+		return ""
+	}
+	w.mu.Lock()
+	delete(w.busy, file)
+	delete(w.sizes, file)
+	w.mu.Unlock()
+	i18n.Logln("watch.moved", name, filepath.Join(dir, filepath.Base(dest)))
+	return dest
+}
+
+// logError logs err once, not at every poll
+func (w *watcher) logError(file string, err error) {
+	if err.Error() != w.lastErr {
+		w.lastErr = err.Error()
+		i18n.Logln("watch.error", file, err)
+	}
+}
+
+// wait waits for the downloads started so far
+func (w *watcher) wait() {
+	w.wg.Wait()
+}
+
+var journalColumns = []string{"time", "url", "label", "status", "message", "seconds", "source"}
+
+// loadJournal reads the URLs downloaded from the journal file
+func loadJournal(file string) (map[string]bool, error) {
+	done := make(map[string]bool)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if len(record) > 3 && record[3] == "ok" {
+			done[bookKey(record[1])] = true
+		}
+	}
+	return done, nil
+}
+
+// bookKey is the record of sUrl, so that every URL of a book is downloaded once
+func bookKey(sUrl string) string {
+	if rec, ok := app.Normalize(sUrl); ok {
+		return rec.String()
+	}
+	return sUrl
+}
+
+// parseShortcut reads the URL of an Internet shortcut (.url), or URLs one per line
+func parseShortcut(content []byte) []batchRow {
+	for i, line := range strings.Split(string(content), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "URL="); ok && isValidURL(v) {
+			return []batchRow{{Line: i + 1, URL: v}}
+		}
+	}
+	return parseBatchText(content)
+}
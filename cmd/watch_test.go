@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDownloads records the URLs downloaded; URLs with "bad" fail
+type fakeDownloads struct {
+	mu   sync.Mutex
+	urls []string
+}
+
+func (f *fakeDownloads) download(row batchRow) batchResult {
+	f.mu.Lock()
+	f.urls = append(f.urls, row.URL)
+	f.mu.Unlock()
+	if strings.Contains(row.URL, "bad") {
+		return batchResult{Row: row, Err: errors.New("HTTP 404")}
+	}
+	return batchResult{Row: row}
+}
+
+func (f *fakeDownloads) take() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	urls := f.urls
+	f.urls = nil
+	sort.Strings(urls)
+	return urls
+}
+
+func TestWatchFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "urls.txt")
+	require.NoError(t, os.WriteFile(file, []byte("https://a.example/1\nhttps://a.example/bad\nhttps://a.example/2"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "urls.status.csv"),
+		[]byte("time,url,label,status,message,seconds,source\n2026-10-18T10:00:00Z,https://a.example/1,,ok,,1.0,urls.txt\n"), 0644))
+
+	fake := new(fakeDownloads)
+	w, err := newWatcher(file, 2, fake.download)
+	require.NoError(t, err)
+
+	// The last line may still be typed
+	w.poll()
+	w.wait()
+	assert.Equal(t, []string{"https://a.example/bad"}, fake.take())
+	w.poll()
+	w.wait()
+	assert.Equal(t, []string{"https://a.example/2"}, fake.take())
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, _ = f.WriteString("\nhttps://a.example/2\nhttps://a.example/3\n")
+	require.NoError(t, f.Close())
+	w.poll()
+	w.wait()
+	assert.Equal(t, []string{"https://a.example/3"}, fake.take())
+
+	// A restart skips what is done and retries the failure
+	w, err = newWatcher(file, 2, fake.download)
+	require.NoError(t, err)
+	w.poll()
+	w.wait()
+	assert.Equal(t, []string{"https://a.example/bad"}, fake.take())
+
+	done, err := loadJournal(w.journal)
+	require.NoError(t, err)
+	assert.Len(t, done, 3)
+}
+
+func TestWatchFolder(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("a.url", "[InternetShortcut]\r\nURL=https://a.example/1\r\n")
+	write("b.txt", "https://a.example/2\nhttps://a.example/bad\n")
+	write("notes.md", "https://a.example/3\n")
+
+	fake := new(fakeDownloads)
+	w, err := newWatcher(dir, 1, fake.download)
+	require.NoError(t, err)
+
+	// Files are taken once they stop changing
+	w.poll()
+	w.wait()
+	assert.Empty(t, fake.take())
+	w.poll()
+	w.wait()
+	assert.Equal(t, []string{"https://a.example/1", "https://a.example/2", "https://a.example/bad"}, fake.take())
+
+	assert.FileExists(t, filepath.Join(dir, "done", "a.url"))
+	assert.FileExists(t, filepath.Join(dir, "failed", "b.txt"))
+	assert.FileExists(t, filepath.Join(dir, "failed", "b-summary.csv"))
+	assert.FileExists(t, filepath.Join(dir, "notes.md"))
+
+	// A URL dropped again is not downloaded again
+	write("c.url", "[InternetShortcut]\nURL=https://a.example/1\n")
+	w.settle = true
+	w.poll()
+	w.wait()
+	assert.Empty(t, fake.take())
+	assert.FileExists(t, filepath.Join(dir, "done", "c.url"))
+}
+
+func TestBookKey(t *testing.T) {
+	assert.Equal(t, bookKey("https://lccn.loc.gov/2014514163"), bookKey("https://www.loc.gov/item/2014514163/?sp=3"))
+	assert.Equal(t, "https://example.org/a", bookKey("https://example.org/a"))
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/ini.v1"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// HeaderProfilesFile is read from the directory of config.ini. Each section is
+// a host glob, each key a header name:
+//
+//	[*.nlc.cn]
+//	Referer = {page_url}
+//	Origin = {origin}
+//
+// Values may use {page_url} (the URL given to bookget), {origin} (its
+// scheme://host) and {book_id}.
+const HeaderProfilesFile = "headers.ini"
+
+var headerProfiles = ini.Empty()
+
+// HeaderProfilesPath is headers.ini next to the config file
+func HeaderProfilesPath() string {
+	return filepath.Join(filepath.Dir(Conf.ConfigFile), HeaderProfilesFile)
+}
+
+// loadHeaderProfiles reads headers.ini. A missing file means no profiles.
+func loadHeaderProfiles(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	f, err := ini.LoadSources(ini.LoadOptions{IgnoreInlineComment: true}, path)
+	if err != nil {
+		return fmt.Errorf("failed to load header profiles %s: %w", path, err)
+	}
+	headerProfiles = f
+	return nil
+}
+
+// HeaderProfile returns the header templates of every section matching host.
+// Earlier sections win on equal header names.
+func HeaderProfile(host string) map[string]string {
+	var profile map[string]string
+	for _, section := range headerProfiles.Sections() {
+		if section.Name() == ini.DefaultSection || !MatchHost(section.Name(), host) {
+			continue
+		}
+		for _, key := range section.Keys() {
+			if profile == nil {
+				profile = make(map[string]string)
+			}
+			name := http.CanonicalHeaderKey(strings.TrimSpace(key.Name()))
+			if _, ok := profile[name]; !ok {
+				profile[name] = key.String()
+			}
+		}
+	}
+	return profile
+}
@@ -1,89 +1,59 @@
 package config
 
 import (
-	"strconv"
-	"strings"
+	"context"
+	"sync/atomic"
 )
 
 var Conf Input
 
-// initSeq    false = minimum value <= current page number <= maximum value
-func initSeqRange() {
-	if Conf.Seq == "" || !strings.Contains(Conf.Seq, ":") {
-		return
+// initRanges parses --sequence and --volume
+func initRanges() (err error) {
+	if Conf.Pages, err = ParseSelection(Conf.Seq, true); err != nil {
+		return err
 	}
-	m := strings.Split(Conf.Seq, ":")
-	if len(m) == 1 {
-		Conf.SeqStart, _ = strconv.Atoi(m[0])
-		Conf.SeqEnd = Conf.SeqStart
-	} else {
-		Conf.SeqStart, _ = strconv.Atoi(m[0])
-		Conf.SeqEnd, _ = strconv.Atoi(m[1])
-	}
-	return
+	Conf.Volumes, err = ParseSelection(Conf.Volume, false)
+	return err
 }
 
-// initVolumeRange    false = minimum value <= current page number <= maximum value
-func initVolumeRange() {
-	m := strings.Split(Conf.Volume, ":")
-	if len(m) == 1 {
-		Conf.VolStart, _ = strconv.Atoi(m[0])
-		Conf.VolEnd = Conf.VolStart
-	} else {
-		Conf.VolStart, _ = strconv.Atoi(m[0])
-		Conf.VolEnd, _ = strconv.Atoi(m[1])
-	}
-	return
+// SetRanges replaces --sequence and --volume, e.g. for one row of a batch file
+func SetRanges(seq, vol string) error {
+	Conf.Seq, Conf.Volume = seq, vol
+	return initRanges()
 }
 
-// PageRange    return true (minimum value <= current page number <= maximum value)
-func PageRange(index, size int) bool {
-	//not set
-	if Conf.SeqStart <= 0 {
-		return true
-	}
-	//negative end page
-	if Conf.SeqEnd < 0 && (index-size >= Conf.SeqEnd) {
-		return false
-	}
-	//end page
-	if Conf.SeqEnd > 0 {
-		//finished
-		if index >= Conf.SeqEnd {
-			return false
-		}
-		//start page
-		if index+1 >= Conf.SeqStart {
-			return true
-		}
-	} else if index+1 >= Conf.SeqStart { //after start page
-		return true
-	}
-	return false
+type volumeKey struct{}
+
+// WithVolume gives the book of ctx its own volume being downloaded, for
+// volume:pages items of --sequence. Books downloaded side by side don't
+// see each other's volume.
+func WithVolume(ctx context.Context) context.Context {
+	return context.WithValue(ctx, volumeKey{}, new(atomic.Int32))
 }
 
-// VolumeRange    return true (minimum value <= current page number <= maximum value)
-func VolumeRange(index int) bool {
-	//not set
-	if Conf.VolStart <= 0 {
-		return true
-	}
-	//negative end page
-	if Conf.VolEnd < 0 && index > Conf.VolStart {
+func volumeOf(ctx context.Context) *atomic.Int32 {
+	v, _ := ctx.Value(volumeKey{}).(*atomic.Int32)
+	return v
+}
+
+// PageRange tells whether page index (from 0) of size pages is selected by
+// --sequence, in the volume of ctx last passed by VolumeRange
+func PageRange(ctx context.Context, index, size int) bool {
+	vol := 1
+	if v := volumeOf(ctx); v != nil && v.Load() > 0 {
+		vol = int(v.Load())
+	}
+	return Conf.Pages.Contains(index+1, size, vol)
+}
+
+// VolumeRange tells whether volume index (from 0) of size volumes is selected
+// by --volume, and has pages selected by --sequence
+func VolumeRange(ctx context.Context, index, size int) bool {
+	if !Conf.Volumes.Contains(index+1, size, 0) || !Conf.Pages.HasVolume(index+1) {
 		return false
 	}
-	//end page
-	if Conf.VolEnd > 0 {
-		//finished
-		if index >= Conf.VolEnd {
-			return false
-		}
-		//start page
-		if index+1 >= Conf.VolStart {
-			return true
-		}
-	} else if index+1 >= Conf.VolStart { //after start page
-		return true
+	if v := volumeOf(ctx); v != nil {
+		v.Store(int32(index + 1))
 	}
-	return false
+	return true
 }
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Selection is a parsed --sequence or --volume, a comma separated list of
+//
+//	9         page 9
+//	1-5       pages 1 to 5
+//	20-       page 20 to the end
+//	1-100:2   every other page of 1 to 100
+//	-10:      the last 10 pages
+//	4:434     pages 4 to 434, as before; 4: is 4 to the end, 4:-2 drops the last 2
+//	2:10-30   pages 10 to 30 of volume 2 (--sequence only)
+//
+// Numbers start at 1. The zero Selection selects everything.
+type Selection struct {
+	src   string
+	spans []span
+}
+
+// span is one item of a Selection
+type span struct {
+	vol   int // Volume the span belongs to, 0 = every volume
+	first int // Negative counts from the end: -1 is the last
+	last  int // 0 = to the end; negative counts from the end
+	step  int
+}
+
+// ParseSelection parses s; perVolume allows volume:pages items.
+func ParseSelection(s string, perVolume bool) (Selection, error) {
+	sel := Selection{src: strings.TrimSpace(s)}
+	if sel.src == "" {
+		return sel, nil
+	}
+	for _, item := range strings.Split(sel.src, ",") {
+		item = strings.TrimSpace(item)
+		sp, err := parseSpan(item, perVolume)
+		if err != nil {
+			return Selection{}, fmt.Errorf("invalid selection %q: %w", s, err)
+		}
+		sel.spans = append(sel.spans, sp)
+	}
+	return sel, nil
+}
+
+func parseSpan(item string, perVolume bool) (span, error) {
+	if item == "" {
+		return span{}, fmt.Errorf("empty item")
+	}
+	left, right, hasColon := strings.Cut(item, ":")
+	if !hasColon {
+		return parseRange(item)
+	}
+
+	switch {
+	case isNumber(left) && left[0] == '-' && right == "":
+		// -10: the last 10
+		n, _ := strconv.Atoi(left)
+		if n == 0 {
+			return span{}, fmt.Errorf("%q: counting from the end starts at -1", item)
+		}
+		return span{first: n, step: 1}, nil
+	case isNumber(left) && left[0] != '-' && (right == "" || isNumber(right)):
+		// 4:434, 4:, 4:-2 as before
+		first, _ := strconv.Atoi(left)
+		last := 0
+		if right != "" {
+			last, _ = strconv.Atoi(right)
+		}
+		if last < 0 {
+			last-- // 4:-2 drops the last 2, the last kept is -3
+		}
+		if first < 1 || (last > 0 && last < first) {
+			return span{}, fmt.Errorf("%q: bad range", item)
+		}
+		return span{first: first, last: last, step: 1}, nil
+	case isNumber(left) && left[0] != '-':
+		// 2:10-30 pages of volume 2
+		if !perVolume {
+			return span{}, fmt.Errorf("%q: volume:pages only works in --sequence", item)
+		}
+		vol, _ := strconv.Atoi(left)
+		if vol < 1 {
+			return span{}, fmt.Errorf("%q: volumes start at 1", item)
+		}
+		sp, err := parseSpan(right, false)
+		if err != nil {
+			return span{}, err
+		}
+		sp.vol = vol
+		return sp, nil
+	}
+
+	// 1-100:2 every other page
+	sp, err := parseRange(left)
+	if err != nil {
+		return span{}, err
+	}
+	step, err := strconv.Atoi(right)
+	if err != nil || step < 1 {
+		return span{}, fmt.Errorf("%q: bad step", item)
+	}
+	sp.step = step
+	return sp, nil
+}
+
+// parseRange parses 9, 1-5 and 20-
+func parseRange(item string) (span, error) {
+	a, b, isRange := strings.Cut(item, "-")
+	if a == "" {
+		return span{}, fmt.Errorf("%q: write 1-N for the first pages, -N: for the last", item)
+	}
+	first, err := strconv.Atoi(a)
+	if err != nil || first < 1 {
+		return span{}, fmt.Errorf("%q: bad number", item)
+	}
+	if !isRange {
+		return span{first: first, last: first, step: 1}, nil
+	}
+	if b == "" {
+		return span{first: first, step: 1}, nil
+	}
+	last, err := strconv.Atoi(b)
+	if err != nil || last < first {
+		return span{}, fmt.Errorf("%q: bad range", item)
+	}
+	return span{first: first, last: last, step: 1}, nil
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// IsAll tells whether the selection selects everything
+func (s Selection) IsAll() bool {
+	return len(s.spans) == 0
+}
+
+func (s Selection) String() string {
+	return s.src
+}
+
+// Contains tells whether item n (from 1) of total is selected in volume vol.
+// total <= 0 means unknown, then items counted from the end are not selected.
+func (s Selection) Contains(n, total, vol int) bool {
+	if len(s.spans) == 0 {
+		return true
+	}
+	for _, sp := range s.spans {
+		if sp.vol != 0 && sp.vol != vol {
+			continue
+		}
+		if sp.contains(n, total) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasVolume tells whether any item applies to volume vol
+func (s Selection) HasVolume(vol int) bool {
+	if len(s.spans) == 0 {
+		return true
+	}
+	for _, sp := range s.spans {
+		if sp.vol == 0 || sp.vol == vol {
+			return true
+		}
+	}
+	return false
+}
+
+func (sp span) contains(n, total int) bool {
+	first, last := sp.first, sp.last
+	if first < 0 || last < 0 {
+		if total <= 0 {
+			return false
+		}
+		if first < 0 {
+			first += total + 1
+		}
+		if last < 0 {
+			if last += total + 1; last < 1 {
+				return false
+			}
+		}
+	}
+	if n < first || (last != 0 && n > last) {
+		return false
+	}
+	if first < 1 {
+		first = 1
+	}
+	return (n-first)%sp.step == 0
+}
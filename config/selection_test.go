@@ -0,0 +1,89 @@
+package config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selected lists the items of 1..total that s selects in volume vol
+func selected(s Selection, total, vol int) []int {
+	var got []int
+	for n := 1; n <= total; n++ {
+		if s.Contains(n, total, vol) {
+			got = append(got, n)
+		}
+	}
+	return got
+}
+
+func TestSelection(t *testing.T) {
+	tests := []struct {
+		in    string
+		total int
+		vol   int
+		want  []int
+	}{
+		{"", 3, 1, []int{1, 2, 3}},
+		{"2", 5, 1, []int{2}},
+		{"1-3,5", 6, 1, []int{1, 2, 3, 5}},
+		{"4-", 6, 1, []int{4, 5, 6}},
+		{"1-10:3", 12, 1, []int{1, 4, 7, 10}},
+		{"5-:2", 10, 1, []int{5, 7, 9}},
+		{"-3:", 10, 1, []int{8, 9, 10}},
+		{"-30:", 3, 1, []int{1, 2, 3}},
+		{"2:4", 6, 1, []int{2, 3, 4}}, // start:end as before
+		{"3:", 5, 1, []int{3, 4, 5}},
+		{"2:-2", 6, 1, []int{2, 3, 4}}, // drops the last 2
+		{"2:-9", 6, 1, nil},
+		{"2:3-4", 6, 2, []int{3, 4}},
+		{"2:3-4", 6, 1, nil},
+		{"2:3-4,6", 6, 1, []int{6}},
+		{"2:3-4,6", 6, 2, []int{3, 4, 6}},
+		{"1:-2:", 6, 1, []int{5, 6}},
+		{" 1 , 3 ", 4, 1, []int{1, 3}},
+	}
+	for _, tt := range tests {
+		s, err := ParseSelection(tt.in, true)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, selected(s, tt.total, tt.vol), "%q of %d in volume %d", tt.in, tt.total, tt.vol)
+	}
+}
+
+func TestSelectionErrors(t *testing.T) {
+	for _, in := range []string{"x", "0", "5-3", "-3", "1,,2", "1-5:0", "1-5:x", "-0:", "6:2", "2:x"} {
+		_, err := ParseSelection(in, true)
+		assert.Error(t, err, in)
+	}
+	_, err := ParseSelection("2:3-4", false)
+	assert.Error(t, err)
+}
+
+func TestSelectionVolumes(t *testing.T) {
+	s, err := ParseSelection("2:10-30", true)
+	require.NoError(t, err)
+	assert.False(t, s.HasVolume(1))
+	assert.True(t, s.HasVolume(2))
+
+	// Items counted from the end need the total
+	s, err = ParseSelection("-2:", true)
+	require.NoError(t, err)
+	assert.False(t, s.Contains(9, 0, 1))
+}
+
+func TestPageAndVolumeRange(t *testing.T) {
+	saved := Conf
+	defer func() { Conf = saved }()
+	require.NoError(t, SetRanges("2:2-3,1", "2-"))
+
+	book, other := WithVolume(context.Background()), WithVolume(context.Background())
+	assert.False(t, VolumeRange(book, 0, 3))
+	assert.True(t, VolumeRange(book, 1, 3))
+	assert.Equal(t, []bool{true, true, true, false}, []bool{PageRange(book, 0, 4), PageRange(book, 1, 4), PageRange(book, 2, 4), PageRange(book, 3, 4)})
+
+	// A book without volumes is volume 1, whatever the volume of the book next to it
+	assert.Equal(t, []bool{true, false}, []bool{PageRange(other, 0, 4), PageRange(other, 1, 4)})
+	assert.Equal(t, []bool{true, false}, []bool{PageRange(context.Background(), 0, 4), PageRange(context.Background(), 1, 4)})
+}
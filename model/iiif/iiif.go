@@ -1,5 +1,7 @@
 package iiif
 
+import "encoding/json"
+
 // ManifestResponse by view-source:https://iiif.lib.harvard.edu/manifests/drs:53262215
 type ManifestResponse struct {
 	Sequences []struct {
@@ -82,3 +84,13 @@ type ManifestPresentation struct {
 	Context string `json:"@context"`
 	Id      string `json:"id"`
 }
+
+// Descriptive holds the label and metadata of a v2 or v3 manifest.
+// Values may be a string, a list, a language map or @value objects, see https://iiif.io/api/presentation/3.0/#44-language-of-property-values
+type Descriptive struct {
+	Label    json.RawMessage `json:"label"`
+	Metadata []struct {
+		Label json.RawMessage `json:"label"`
+		Value json.RawMessage `json:"value"`
+	} `json:"metadata"`
+}
@@ -0,0 +1,99 @@
+// Package catalog reads the catalog.txt bookmark files written by adapters.
+//
+// A catalog is one entry per line, "title ………… page" or "title......page",
+// indented with tabs for nested levels. The page is the 1-based position of the
+// image in the whole book; "未知" marks an unknown page.
+package catalog
+
+import (
+	"bookget/config"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Entry is one bookmark.
+type Entry struct {
+	Title string
+	Level int // 0 = top level
+	Page  int // 0 = unknown
+}
+
+var lineRe = regexp.MustCompile(`^(.*?)\s*(?:…{2,}|\.{2,})\s*(\S*)$`)
+
+// Parse reads catalog entries from r.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r ")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, config.CatalogVersionInfo) {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "\t"))
+		line = strings.TrimSpace(line)
+
+		e := Entry{Title: line, Level: level}
+		if m := lineRe.FindStringSubmatch(line); m != nil {
+			e.Title = m[1]
+			e.Page, _ = strconv.Atoi(m[2])
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// ReadFile reads the catalog at path. A missing file is not an error.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Issue is a problem found by Validate.
+type Issue struct {
+	Index   int // Entry index, 0-based
+	Entry   Entry
+	Message string
+	Error   bool // false for warnings
+}
+
+func (i Issue) String() string {
+	kind := "warning"
+	if i.Error {
+		kind = "error"
+	}
+	return fmt.Sprintf("%s: entry %d %q: %s", kind, i.Index+1, i.Entry.Title, i.Message)
+}
+
+// Validate checks the page numbers of entries against the number of pages downloaded.
+func Validate(entries []Entry, pages int) []Issue {
+	var issues []Issue
+	last := 0
+	for k, e := range entries {
+		switch {
+		case e.Page == 0:
+			issues = append(issues, Issue{Index: k, Entry: e, Message: "unknown page"})
+		case e.Page < 0 || e.Page > pages:
+			issues = append(issues, Issue{Index: k, Entry: e, Error: true,
+				Message: fmt.Sprintf("page %d is out of range, %d pages downloaded", e.Page, pages)})
+		case e.Page < last:
+			issues = append(issues, Issue{Index: k, Entry: e,
+				Message: fmt.Sprintf("page %d comes before the previous entry's page %d", e.Page, last)})
+		}
+		if e.Page > 0 {
+			last = e.Page
+		}
+	}
+	return issues
+}
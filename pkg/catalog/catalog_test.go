@@ -0,0 +1,53 @@
+package catalog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	text := "#版本=1.0\n卷一 ………… 1\n\t序 ………… 2\n\t正文 ………… 未知\n卷二......5\r\n"
+	entries, err := Parse(strings.NewReader(text))
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Title: "卷一", Level: 0, Page: 1},
+		{Title: "序", Level: 1, Page: 2},
+		{Title: "正文", Level: 1, Page: 0},
+		{Title: "卷二", Level: 0, Page: 5},
+	}, entries)
+}
+
+func TestRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{Title: "卷一", Level: 0, Page: 1},
+		{Title: "序 & 凡例", Level: 1, Page: 2},
+		{Title: "第一", Level: 2, Page: 3},
+		{Title: "卷二", Level: 0, Page: 7},
+	}
+	opt := Options{Title: "書", ManifestId: "https://example.org/iiif/b1/manifest.json"}
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, entries, format, opt), format)
+		got, err := Read(&buf, format, opt)
+		require.NoError(t, err, format)
+		assert.Equal(t, entries, got, format)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	issues := Validate([]Entry{
+		{Title: "a", Page: 3},
+		{Title: "b", Page: 2},
+		{Title: "c", Page: 0},
+		{Title: "d", Page: 11},
+	}, 10)
+	require.Len(t, issues, 3)
+	assert.False(t, issues[0].Error)
+	assert.Equal(t, 1, issues[0].Index)
+	assert.False(t, issues[1].Error)
+	assert.True(t, issues[2].Error)
+}
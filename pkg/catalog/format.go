@@ -0,0 +1,287 @@
+package catalog
+
+import (
+	"bookget/config"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats understood by Read and Write
+const (
+	FormatTxt         = "txt"         // catalog.txt written by the adapters
+	FormatJSON        = "json"        // Nested [{"title","page","children"}]
+	FormatOPML        = "opml"        // OPML 2.0 outline with a page attribute
+	FormatPdftk       = "pdftk"       // PDF outline for `pdftk in.pdf update_info_utf8 outline.txt output out.pdf`
+	FormatIIIF        = "iiif"        // IIIF Presentation 3 ranges ("structures")
+	FormatFreePic2Pdf = "freepic2pdf" // FreePic2Pdf_bkmk.txt / PdgCntEditor: tab-indented "title<TAB>page"
+)
+
+// Formats lists every format name
+var Formats = []string{FormatTxt, FormatJSON, FormatOPML, FormatPdftk, FormatIIIF, FormatFreePic2Pdf}
+
+// Options describe the book for formats that need more than titles and pages.
+type Options struct {
+	Title      string   // Book title, for OPML and IIIF labels
+	ManifestId string   // IIIF manifest id, used as base for range and canvas ids
+	Canvases   []string // IIIF canvas ids in page order; page n is Canvases[n-1]
+}
+
+// Node is an entry with its nested entries.
+type Node struct {
+	Title    string  `json:"title"`
+	Page     int     `json:"page,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+// NormalizeFormat resolves aliases; it returns "" for an unknown format.
+func NormalizeFormat(format string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "txt", "catalog", "bookget":
+		return FormatTxt
+	case "json":
+		return FormatJSON
+	case "opml":
+		return FormatOPML
+	case "pdftk", "pdf", "pdf-outline", "outline":
+		return FormatPdftk
+	case "iiif", "iiif-ranges", "ranges":
+		return FormatIIIF
+	case "freepic2pdf", "fp2p", "pdgcnt", "pdgcnteditor":
+		return FormatFreePic2Pdf
+	}
+	return ""
+}
+
+// DetectFormat guesses the format from a file name.
+func DetectFormat(name string) string {
+	base := strings.ToLower(filepath.Base(name))
+	switch {
+	case strings.HasPrefix(base, "freepic2pdf") || strings.HasSuffix(base, "_bkmk.txt"):
+		return FormatFreePic2Pdf
+	case strings.Contains(base, "manifest") && strings.HasSuffix(base, ".json"):
+		return FormatIIIF
+	}
+	switch filepath.Ext(base) {
+	case ".json":
+		return FormatJSON
+	case ".opml", ".xml":
+		return FormatOPML
+	case ".txt":
+		return FormatTxt
+	}
+	return ""
+}
+
+// Tree nests entries by level. A level deeper than one below its parent is attached to the parent.
+func Tree(entries []Entry) []*Node {
+	var roots []*Node
+	var stack []*Node
+	for _, e := range entries {
+		n := &Node{Title: e.Title, Page: e.Page}
+		level := min(e.Level, len(stack))
+		stack = stack[:level]
+		if level == 0 {
+			roots = append(roots, n)
+		} else {
+			parent := stack[level-1]
+			parent.Children = append(parent.Children, n)
+		}
+		stack = append(stack, n)
+	}
+	return roots
+}
+
+// Flatten turns a tree back into entries.
+func Flatten(nodes []*Node) []Entry {
+	var entries []Entry
+	var walk func(nodes []*Node, level int)
+	walk = func(nodes []*Node, level int) {
+		for _, n := range nodes {
+			entries = append(entries, Entry{Title: n.Title, Level: level, Page: n.Page})
+			walk(n.Children, level+1)
+		}
+	}
+	walk(nodes, 0)
+	return entries
+}
+
+// Read parses entries in the given format.
+func Read(r io.Reader, format string, opt Options) ([]Entry, error) {
+	switch NormalizeFormat(format) {
+	case FormatTxt:
+		return Parse(r)
+	case FormatJSON:
+		var nodes []*Node
+		if err := json.NewDecoder(r).Decode(&nodes); err != nil {
+			return nil, err
+		}
+		return Flatten(nodes), nil
+	case FormatOPML:
+		return readOPML(r)
+	case FormatPdftk:
+		return readPdftk(r)
+	case FormatIIIF:
+		return readIIIF(r, opt)
+	case FormatFreePic2Pdf:
+		return readTabbed(r)
+	}
+	return nil, fmt.Errorf("unknown catalog format %q", format)
+}
+
+// Write writes entries in the given format.
+func Write(w io.Writer, entries []Entry, format string, opt Options) error {
+	switch NormalizeFormat(format) {
+	case FormatTxt:
+		return writeTxt(w, entries)
+	case FormatJSON:
+		nodes := Tree(entries)
+		if nodes == nil {
+			nodes = []*Node{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nodes)
+	case FormatOPML:
+		return writeOPML(w, entries, opt)
+	case FormatPdftk:
+		return writePdftk(w, entries)
+	case FormatIIIF:
+		return writeIIIF(w, entries, opt)
+	case FormatFreePic2Pdf:
+		return writeTabbed(w, entries)
+	}
+	return fmt.Errorf("unknown catalog format %q", format)
+}
+
+// writeTxt writes the format of NlcGuji.buildCatalog
+func writeTxt(w io.Writer, entries []Entry) error {
+	lines := []string{config.CatalogVersionInfo}
+	for _, e := range entries {
+		page := "未知"
+		if e.Page > 0 {
+			page = strconv.Itoa(e.Page)
+		}
+		lines = append(lines, fmt.Sprintf("%s%s ………… %s", strings.Repeat("\t", e.Level), e.Title, page))
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func readTabbed(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(strings.TrimPrefix(scanner.Text(), "\ufeff"), "\r ")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "\t"))
+		line = strings.TrimLeft(line, "\t")
+		e := Entry{Title: strings.TrimSpace(line), Level: level}
+		if i := strings.LastIndex(line, "\t"); i > 0 {
+			if page, err := strconv.Atoi(strings.TrimSpace(line[i+1:])); err == nil {
+				e.Title, e.Page = strings.TrimSpace(line[:i]), page
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+func writeTabbed(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		// FreePic2Pdf needs a page; unknown pages point at the first one
+		fmt.Fprintf(bw, "%s%s\t%d\r\n", strings.Repeat("\t", e.Level), e.Title, max(e.Page, 1))
+	}
+	return bw.Flush()
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Page     int           `xml:"page,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+func readOPML(r io.Reader) ([]Entry, error) {
+	var doc opmlDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var convert func(outlines []opmlOutline) []*Node
+	convert = func(outlines []opmlOutline) []*Node {
+		var nodes []*Node
+		for _, o := range outlines {
+			nodes = append(nodes, &Node{Title: o.Text, Page: o.Page, Children: convert(o.Outlines)})
+		}
+		return nodes
+	}
+	return Flatten(convert(doc.Body)), nil
+}
+
+func writeOPML(w io.Writer, entries []Entry, opt Options) error {
+	var convert func(nodes []*Node) []opmlOutline
+	convert = func(nodes []*Node) []opmlOutline {
+		var outlines []opmlOutline
+		for _, n := range nodes {
+			outlines = append(outlines, opmlOutline{Text: n.Title, Page: n.Page, Outlines: convert(n.Children)})
+		}
+		return outlines
+	}
+	bs, err := xml.MarshalIndent(opmlDocument{Version: "2.0", Title: opt.Title, Body: convert(Tree(entries))}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header+string(bs)+"\n")
+	return err
+}
+
+func readPdftk(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var cur *Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok && key == "BookmarkBegin" {
+			entries = append(entries, Entry{})
+			cur = &entries[len(entries)-1]
+			continue
+		}
+		if cur == nil || !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "BookmarkTitle":
+			cur.Title = value
+		case "BookmarkLevel":
+			level, _ := strconv.Atoi(value)
+			cur.Level = max(level-1, 0)
+		case "BookmarkPageNumber":
+			cur.Page, _ = strconv.Atoi(value)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func writePdftk(w io.Writer, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(bw, "BookmarkBegin\nBookmarkTitle: %s\nBookmarkLevel: %d\nBookmarkPageNumber: %d\n", e.Title, e.Level+1, max(e.Page, 1))
+	}
+	return bw.Flush()
+}
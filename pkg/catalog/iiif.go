@@ -0,0 +1,190 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type iiifRef struct {
+	Id    string              `json:"id"`
+	Type  string              `json:"type"`
+	Label map[string][]string `json:"label,omitempty"`
+	Items []iiifRef           `json:"items,omitempty"`
+}
+
+type iiifStructures struct {
+	Structures []iiifRef `json:"structures"`
+}
+
+// CanvasIds returns the canvas ids of a IIIF manifest in page order (Presentation 2 or 3)
+func CanvasIds(manifest []byte) (id string, canvases []string, err error) {
+	var m struct {
+		Id        string `json:"id"`
+		IdV2      string `json:"@id"`
+		Sequences []struct {
+			Canvases []struct {
+				Id string `json:"@id"`
+			} `json:"canvases"`
+		} `json:"sequences"`
+		Items []struct {
+			Id string `json:"id"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(manifest, &m); err != nil {
+		return "", nil, err
+	}
+	id = m.Id
+	if id == "" {
+		id = m.IdV2
+	}
+	for _, c := range m.Items {
+		canvases = append(canvases, c.Id)
+	}
+	if len(m.Sequences) > 0 {
+		for _, c := range m.Sequences[0].Canvases {
+			canvases = append(canvases, c.Id)
+		}
+	}
+	return id, canvases, nil
+}
+
+var (
+	manifestSuffix = regexp.MustCompile(`/(manifest(\.json)?|iiif-manifest)?$`)
+	// ownCanvasId matches the canvas ids made up by canvasId
+	ownCanvasId = regexp.MustCompile(`/canvas/p(\d+)$`)
+)
+
+func (opt Options) canvasId(page int) string {
+	if page >= 1 && page <= len(opt.Canvases) {
+		return opt.Canvases[page-1]
+	}
+	return fmt.Sprintf("%s/canvas/p%d", manifestSuffix.ReplaceAllString(opt.ManifestId, ""), page)
+}
+
+// writeIIIF writes {"structures": [...]} ready to merge into a Presentation 3 manifest
+func writeIIIF(w io.Writer, entries []Entry, opt Options) error {
+	if opt.ManifestId == "" && len(opt.Canvases) == 0 {
+		return errors.New("iiif ranges need the manifest id or the manifest itself")
+	}
+	base := manifestSuffix.ReplaceAllString(opt.ManifestId, "")
+	n := 0
+	var convert func(nodes []*Node) []iiifRef
+	convert = func(nodes []*Node) []iiifRef {
+		var ranges []iiifRef
+		for _, node := range nodes {
+			n++
+			r := iiifRef{
+				Id:    fmt.Sprintf("%s/range/r%d", base, n),
+				Type:  "Range",
+				Label: map[string][]string{"none": {node.Title}},
+			}
+			if node.Page > 0 {
+				r.Items = append(r.Items, iiifRef{Id: opt.canvasId(node.Page), Type: "Canvas"})
+			}
+			r.Items = append(r.Items, convert(node.Children)...)
+			ranges = append(ranges, r)
+		}
+		return ranges
+	}
+	top := iiifRef{
+		Id:    base + "/range/toc",
+		Type:  "Range",
+		Label: map[string][]string{"none": {strings.TrimSpace(opt.Title + " 目录")}},
+		Items: convert(Tree(entries)),
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(iiifStructures{Structures: []iiifRef{top}})
+}
+
+// readIIIF reads the ranges of a Presentation 3 manifest, or of a {"structures"} document
+// when the canvases are passed in opt.
+func readIIIF(r io.Reader, opt Options) ([]Entry, error) {
+	bs, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc iiifStructures
+	if err = json.Unmarshal(bs, &doc); err != nil {
+		return nil, err
+	}
+	pages := map[string]int{}
+	canvases := opt.Canvases
+	if _, ids, err := CanvasIds(bs); err == nil && len(ids) > 0 {
+		canvases = ids
+	}
+	for k, id := range canvases {
+		pages[id] = k + 1
+	}
+
+	ranges := doc.Structures
+	// A single top-level "table of contents" range is a wrapper, not an entry
+	if len(ranges) == 1 && ranges[0].onlyRanges() {
+		ranges = ranges[0].Items
+	}
+	var convert func(refs []iiifRef) []*Node
+	convert = func(refs []iiifRef) []*Node {
+		var nodes []*Node
+		for _, ref := range refs {
+			if ref.Type != "Range" {
+				continue
+			}
+			canvas := ref.firstCanvas()
+			page, ok := pages[canvas]
+			if m := ownCanvasId.FindStringSubmatch(canvas); !ok && m != nil {
+				page, _ = strconv.Atoi(m[1])
+			}
+			nodes = append(nodes, &Node{
+				Title:    ref.title(),
+				Page:     page,
+				Children: convert(ref.Items),
+			})
+		}
+		return nodes
+	}
+	return Flatten(convert(ranges)), nil
+}
+
+func (r iiifRef) title() string {
+	for _, lang := range []string{"none", "zh", "ja", "en"} {
+		if v := r.Label[lang]; len(v) > 0 {
+			return v[0]
+		}
+	}
+	for _, v := range r.Label {
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+func (r iiifRef) onlyRanges() bool {
+	for _, item := range r.Items {
+		if item.Type != "Range" {
+			return false
+		}
+	}
+	return len(r.Items) > 0
+}
+
+// firstCanvas returns the id of the first canvas in the range, searching nested ranges
+func (r iiifRef) firstCanvas() string {
+	for _, item := range r.Items {
+		switch item.Type {
+		case "Canvas":
+			return strings.SplitN(item.Id, "#", 2)[0]
+		case "Range":
+			if id := item.firstCanvas(); id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
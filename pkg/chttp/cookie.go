@@ -1,106 +1,337 @@
 package chttp
 
 import (
+	"bufio"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
-	"regexp"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-var cookieNameSanitizer = strings.NewReplacer("\n", "-", "\r", "-")
+// httpOnlyPrefix marks HttpOnly cookies in the domain column of curl/browser exports
+const httpOnlyPrefix = "#HttpOnly_"
 
-func sanitizeCookieName(n string) string {
-	return cookieNameSanitizer.Replace(n)
+// ParseCookieFile reads a Netscape cookie file (cookie.txt). Domain cookies keep
+// their leading dot in Domain, host-only cookies have none; Expires is zero for
+// session cookies.
+func ParseCookieFile(r io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		if strings.HasPrefix(line, httpOnlyPrefix) {
+			httpOnly = true
+			line = line[len(httpOnlyPrefix):]
+		} else if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		line = strings.ReplaceAll(line, `\"`, `"`)
+		row := strings.Split(line, "\t")
+		if len(row) < 7 {
+			continue
+		}
+		domain := strings.ToLower(strings.TrimSpace(row[0]))
+		if strings.EqualFold(row[1], "TRUE") && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+		c := &http.Cookie{
+			Domain:   domain,
+			Path:     row[2],
+			Secure:   strings.EqualFold(row[3], "TRUE"),
+			HttpOnly: httpOnly,
+			Name:     strings.ReplaceAll(row[5], `"`, ""),
+			Value:    strings.ReplaceAll(row[6], `"`, ""),
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if sec, err := strconv.ParseInt(row[4], 10, 64); err == nil && sec > 0 {
+			c.Expires = time.Unix(sec, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, scanner.Err()
 }
-func sanitizeCookieValue(v string, quoted bool) string {
-	v = sanitizeOrWarn("Cookie.Value", validCookieValueByte, v)
-	if len(v) == 0 {
-		return v
+
+// WriteCookieFile writes cookies in the format read by ParseCookieFile
+func WriteCookieFile(w io.Writer, cookies []*http.Cookie) error {
+	bw := bufio.NewWriter(w)
+	_, _ = bw.WriteString("# Netscape HTTP Cookie File\n# Written by bookget, edits are kept.\n\n")
+	for _, c := range cookies {
+		domain := c.Domain
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		_, _ = fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, upperBool(strings.HasPrefix(c.Domain, ".")), c.Path, upperBool(c.Secure), expires, c.Name, c.Value)
 	}
-	if strings.ContainsAny(v, " ,") || quoted {
-		return `"` + v + `"`
+	return bw.Flush()
+}
+
+func upperBool(b bool) string {
+	if b {
+		return "TRUE"
 	}
-	return v
+	return "FALSE"
 }
 
-func validCookieValueByte(b byte) bool {
-	return 0x20 <= b && b < 0x7f && b != '"' && b != ';' && b != '\\'
+// Jar is a cookiejar.Jar loaded from a cookie file. It remembers every cookie
+// with its attributes so that refreshed cookies can be written back, and
+// reloads the file when another program (the bookget-gui browser) rewrites it.
+type Jar struct {
+	file    string
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]*http.Cookie // by domain, path and name
+	stamp   fileStamp
+	dirty   bool
 }
 
-func sanitizeOrWarn(fieldName string, valid func(byte) bool, v string) string {
-	ok := true
-	for i := 0; i < len(v); i++ {
-		if valid(v[i]) {
-			continue
-		}
-		log.Printf("net/http: invalid byte %q in %s; dropping invalid bytes", v[i], fieldName)
-		ok = false
-		break
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func stampOf(file string) fileStamp {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fileStamp{}
 	}
-	if ok {
-		return v
+	return fileStamp{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+var (
+	jarsMu sync.Mutex
+	jars   = make(map[string]*Jar)
+)
+
+// FileJar returns the process-wide jar of a cookie file, nil for an empty name.
+// A missing file gives an empty jar, which Save creates once a site sets cookies.
+func FileJar(file string) *Jar {
+	if file == "" {
+		return nil
 	}
-	buf := make([]byte, 0, len(v))
-	for i := 0; i < len(v); i++ {
-		if b := v[i]; valid(b) {
-			buf = append(buf, b)
-		}
+	file = filepath.Clean(file)
+	jarsMu.Lock()
+	defer jarsMu.Unlock()
+	if j, ok := jars[file]; ok {
+		return j
 	}
-	return string(buf)
+	j := &Jar{file: file}
+	j.load()
+	jars[file] = j
+	return j
 }
 
-func ReadHttpCookiesFromFile(cookieFile string) ([]http.Cookie, error) {
-	fp, err := os.Open(cookieFile)
-	if err != nil {
-		return nil, err
+// SaveJars writes back every cookie file whose cookies changed
+func SaveJars() error {
+	jarsMu.Lock()
+	defer jarsMu.Unlock()
+	var first error
+	for _, j := range jars {
+		if err := j.Save(); err != nil && first == nil {
+			first = err
+		}
 	}
-	defer fp.Close()
+	return first
+}
 
-	bsHeader, err := io.ReadAll(fp)
+// load replaces the cookies with the file content. Callers hold mu, or own j.
+func (j *Jar) load() {
+	j.jar, _ = cookiejar.New(nil)
+	j.cookies = make(map[string]*http.Cookie)
+	j.stamp = stampOf(j.file)
+	j.dirty = false
+	fp, err := os.Open(j.file)
 	if err != nil {
-		return nil, err
+		return
 	}
-	mHeader := strings.Split(string(bsHeader), "\n")
-	cookies := make([]http.Cookie, 0, len(mHeader)+1)
-	for _, line := range mHeader {
-		if strings.HasPrefix(line, "#") {
+	defer fp.Close()
+	cookies, _ := ParseCookieFile(fp)
+	now := time.Now()
+	for _, c := range cookies {
+		if !c.Expires.IsZero() && c.Expires.Before(now) {
 			continue
 		}
-		text := regexp.MustCompile(`\\"`).ReplaceAllString(line, "\"")
-		row := strings.Split(text, "\t")
-		if len(row) < 8 {
+		host := strings.TrimPrefix(c.Domain, ".")
+		if host == "" {
 			continue
 		}
-		name := strings.ReplaceAll(row[5], "\"", "")
-		value := strings.ReplaceAll(row[6], "\"", "")
-		//expires := strings.ReplaceAll(row[4], "#HttpOnly_", "")
-		cookies = append(cookies, http.Cookie{Name: name, Value: value})
+		set := *c
+		if !strings.HasPrefix(c.Domain, ".") {
+			set.Domain = "" // host-only
+		}
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		j.jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: c.Path}, []*http.Cookie{&set})
+		j.cookies[cookieKey(c)] = c
 	}
-	return cookies, nil
 }
 
-func ReadCookiesFromFile(cfile string) (cookies string, err error) {
-	bs, err := os.ReadFile(cfile)
-	if err != nil {
-		return "", err
+// refresh reloads the file if it changed on disk since it was read or saved
+func (j *Jar) refresh() {
+	if stampOf(j.file) != j.stamp {
+		j.load()
 	}
-	mCookie := strings.Split(string(bs), "\n")
+}
+
+func cookieKey(c *http.Cookie) string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+// SetCookies implements http.CookieJar
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.refresh()
+	j.jar.SetCookies(u, cookies)
 
-	for _, line := range mCookie {
-		if strings.HasPrefix(line, "#") {
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+	for _, c := range cookies {
+		stored := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly, Expires: c.Expires}
+		if c.Domain == "" {
+			stored.Domain = host
+		} else {
+			domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue // rejected by the jar as well
+			}
+			stored.Domain = "." + domain
+		}
+		if stored.Path == "" || stored.Path[0] != '/' {
+			stored.Path = defaultPath(u.Path)
+		}
+		switch {
+		case c.MaxAge < 0:
+			stored.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			stored.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		key := cookieKey(stored)
+		if !stored.Expires.IsZero() && stored.Expires.Before(now) {
+			if _, ok := j.cookies[key]; ok {
+				delete(j.cookies, key)
+				j.dirty = true
+			}
 			continue
 		}
-		text := regexp.MustCompile(`\\"`).ReplaceAllString(line, "\"")
-		row := strings.Split(text, "\t")
-		if len(row) < 8 {
+		if old, ok := j.cookies[key]; ok && old.Value == stored.Value && old.Expires.Equal(stored.Expires) {
 			continue
 		}
-		name := strings.ReplaceAll(row[5], "\"", "")
-		value := strings.ReplaceAll(row[6], "\"", "")
-		s := name + "=" + value + "; "
-		cookies += s
+		j.cookies[key] = stored
+		j.dirty = true
+	}
+}
+
+// defaultPath is the cookie path for a response without a Path attribute, RFC 6265 5.1.4
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	if dir := path.Dir(p); dir != "." {
+		return dir
+	}
+	return "/"
+}
+
+// Cookies implements http.CookieJar
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.refresh()
+	return j.jar.Cookies(u)
+}
+
+// Value returns the value of the cookie name sent to u, "" if there is none
+func (j *Jar) Value(u *url.URL, name string) string {
+	for _, c := range j.Cookies(u) {
+		if c.Name == name {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// Save writes the cookies back to the file if any changed
+func (j *Jar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.dirty {
+		return nil
+	}
+	cookies := make([]*http.Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		cookies = append(cookies, c)
+	}
+	sort.Slice(cookies, func(a, b int) bool { return cookieKey(cookies[a]) < cookieKey(cookies[b]) })
+
+	tmp := j.file + ".tmp"
+	fp, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	err = WriteCookieFile(fp, cookies)
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, j.file)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	j.stamp = stampOf(j.file)
+	j.dirty = false
+	return nil
+}
+
+// JoinJars sends the cookies of every jar (the first one wins on equal names)
+// and hands Set-Cookie to all of them. Pass only non-nil jars.
+func JoinJars(jars ...http.CookieJar) http.CookieJar {
+	if len(jars) == 1 {
+		return jars[0]
+	}
+	return joinedJar(jars)
+}
+
+type joinedJar []http.CookieJar
+
+func (jj joinedJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	for _, j := range jj {
+		j.SetCookies(u, cookies)
+	}
+}
+
+func (jj joinedJar) Cookies(u *url.URL) []*http.Cookie {
+	var cookies []*http.Cookie
+	seen := make(map[string]bool)
+	for _, j := range jj {
+		for _, c := range j.Cookies(u) {
+			if !seen[c.Name] {
+				seen[c.Name] = true
+				cookies = append(cookies, c)
+			}
+		}
 	}
-	return cookies, nil
+	return cookies
 }
@@ -0,0 +1,85 @@
+package chttp
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const cookieTxt = `# Netscape HTTP Cookie File
+.nlc.cn	TRUE	/	FALSE	0	session	s1
+#HttpOnly_guji.nlc.cn	FALSE	/api	TRUE	4102444800	token	"t1"
+www.example.org	FALSE	/	FALSE	946684800	expired	x
+`
+
+func names(cookies []*http.Cookie) string {
+	var s []string
+	for _, c := range cookies {
+		s = append(s, c.Name+"="+c.Value)
+	}
+	return strings.Join(s, "; ")
+}
+
+func TestParseCookieFile(t *testing.T) {
+	cookies, err := ParseCookieFile(strings.NewReader(cookieTxt))
+	require.NoError(t, err)
+	require.Len(t, cookies, 3)
+	assert.Equal(t, ".nlc.cn", cookies[0].Domain)
+	assert.True(t, cookies[0].Expires.IsZero())
+	assert.Equal(t, "guji.nlc.cn", cookies[1].Domain)
+	assert.True(t, cookies[1].HttpOnly)
+	assert.True(t, cookies[1].Secure)
+	assert.Equal(t, "/api", cookies[1].Path)
+	assert.Equal(t, "t1", cookies[1].Value)
+}
+
+func TestFileJar(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cookie.txt")
+	require.NoError(t, os.WriteFile(file, []byte(cookieTxt), 0644))
+	jar := FileJar(file)
+	require.Same(t, jar, FileJar(file))
+
+	u := func(s string) *url.URL {
+		p, err := url.Parse(s)
+		require.NoError(t, err)
+		return p
+	}
+	// Domain, path, secure and expiry are honoured
+	assert.Equal(t, "token=t1; session=s1", names(jar.Cookies(u("https://guji.nlc.cn/api/book"))))
+	assert.Equal(t, "session=s1", names(jar.Cookies(u("http://guji.nlc.cn/api/book"))))
+	assert.Equal(t, "session=s1", names(jar.Cookies(u("https://read.nlc.cn/"))))
+	assert.Empty(t, jar.Cookies(u("https://ip-api.com/json")))
+	assert.Empty(t, jar.Cookies(u("https://www.example.org/")))
+
+	// Nothing changed, nothing written
+	require.NoError(t, SaveJars())
+	bs, _ := os.ReadFile(file)
+	assert.Equal(t, cookieTxt, string(bs))
+
+	// A refreshed cookie is written back and read again
+	jar.SetCookies(u("https://guji.nlc.cn/api/login"), []*http.Cookie{
+		{Name: "token", Value: "t2", Path: "/api", Secure: true, HttpOnly: true, MaxAge: 3600},
+	})
+	require.NoError(t, SaveJars())
+	saved, err := os.Open(file)
+	require.NoError(t, err)
+	cookies, err := ParseCookieFile(saved)
+	_ = saved.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "session=s1; token=t2", names(cookies))
+	assert.True(t, cookies[1].HttpOnly)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), cookies[1].Expires, time.Minute)
+
+	// The browser rewriting the file replaces the cookies
+	later := time.Now().Add(2 * time.Second)
+	require.NoError(t, os.WriteFile(file, []byte(".nlc.cn\tTRUE\t/\tFALSE\t0\tsession\ts2\n"), 0644))
+	require.NoError(t, os.Chtimes(file, later, later))
+	assert.Equal(t, "session=s2", names(jar.Cookies(u("https://guji.nlc.cn/api/book"))))
+}
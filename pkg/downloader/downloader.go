@@ -1,12 +1,16 @@
 package downloader
 
 import (
+	"bookget/pkg/events"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
+	"bookget/pkg/phash"
+	"bookget/pkg/postprocess"
 	"bookget/pkg/progressbar"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -61,6 +65,21 @@ type DownloadManager struct {
 	totalSize  int64                    // 总文件大小
 	downloaded int64                    // 已下载字节数
 	UseSizeBar bool                     //使用totalSize显示进度条
+
+	checker  *phash.Checker        // 占位图/空白页/重复页检测
+	pipeline *postprocess.Pipeline // 页面后处理
+}
+
+// httpClient 共享连接池的客户端 (keep-alive, HTTP/2)
+func httpClient() *http.Client {
+	return gohttp.PooledClient(gohttp.DefaultTransportKey, nil, 0)
+}
+
+// describeRate 限速时在进度条上显示实际速度
+func describeRate(bar *progressbar.ProgressBar, desc string) {
+	if s := gohttp.RateStatus(); s != "" {
+		bar.Describe(desc + " " + s)
+	}
 }
 
 // NewDownloadManager 创建下载管理器
@@ -101,6 +120,7 @@ func (dm *DownloadManager) AddTask(url, method string, headers map[string]string
 	}
 
 	dm.tasks = append(dm.tasks, task)
+	events.Emit(dm.ctx, events.Event{Kind: events.PagePlanned, URL: url, File: filepath.Join(saveDir, filename)})
 }
 
 // SetBar 设置进度条
@@ -108,7 +128,7 @@ func (dm *DownloadManager) SetBar(maxTasks int) {
 	dm.mu.Lock()
 	defer dm.mu.Unlock()
 
-	dm.bar = progressbar.Default(int64(maxTasks), "downloading")
+	dm.bar = progressbar.Default(int64(maxTasks), i18n.T("download.progress"))
 }
 
 // Start 开始下载
@@ -117,42 +137,64 @@ func (dm *DownloadManager) Start() {
 	dm.startTime = time.Now()
 	// 初始化进度条
 	if dm.bar == nil {
-		dm.bar = progressbar.Default(int64(len(dm.tasks)), "downloading")
+		dm.bar = progressbar.Default(int64(len(dm.tasks)), i18n.T("download.progress"))
+	}
+	if dm.checker == nil && len(dm.tasks) > 0 {
+		if u, err := url.Parse(dm.tasks[0].URL); err == nil {
+			dm.checker = phash.NewChecker(u.Host)
+			dm.pipeline = postprocess.New(u.Host)
+		}
 	}
 	if dm.showPrompt {
-		fmt.Printf("\n开始下载任务 (最大并发数: %d)...\n", dm.maxConcurrent)
-		fmt.Printf("总任务数: %d\n", len(dm.tasks))
+		fmt.Println()
+		i18n.Println("download.start", dm.maxConcurrent)
+		fmt.Println(i18n.N("download.tasks", len(dm.tasks), len(dm.tasks)))
 		dm.showPrompt = false
 	}
 
 	dm.mu.Unlock()
 
-	for _, task := range dm.tasks {
+	for index, task := range dm.tasks {
 		dm.wg.Add(1)
-		go func(t *DownloadTask) {
+		go func(index int, t *DownloadTask) {
 			dm.sem <- struct{}{}
 			defer func() {
 				<-dm.sem
 				dm.wg.Done()
 			}()
 
+			done := gohttp.PageStarted(dm.ctx, t.URL, filepath.Join(t.SaveDir, t.FileName))
 			err := t.Download(dm.ctx, dm) // 传入dm以更新总进度
+			if err == nil {
+				dest := filepath.Join(t.SaveDir, t.FileName)
+				ok := dm.checker.Handle(index, dest, func() error {
+					t.buffer.Reset()
+					return t.Download(dm.ctx, dm)
+				})
+				if ok {
+					if _, perr := dm.pipeline.Apply(dest); perr != nil {
+						i18n.Logln("postprocess.failed", dest, perr)
+					}
+				}
+			}
+			done(err)
 
 			dm.mu.Lock()
 			if err != nil {
 				atomic.AddInt32(&dm.failCount, 1)
 				t.Success = false
 				t.ErrorMessage = err.Error()
-				fmt.Printf("下载失败: %s (%s)\n", t.FileName, err)
+				i18n.Println("download.failed", t.FileName, err)
 			} else {
 				atomic.AddInt32(&dm.successCount, 1)
 				t.Success = true
 				if !dm.UseSizeBar {
 					_ = dm.bar.Add(1) // 每个任务完成时进度条+1
+					describeRate(dm.bar, i18n.T("download.progress"))
 				}
 			}
 			dm.mu.Unlock()
-		}(task)
+		}(index, task)
 	}
 
 	dm.wg.Wait()
@@ -162,8 +204,8 @@ func (dm *DownloadManager) Start() {
 	if dm.bar != nil {
 		_ = dm.bar.Finish()
 	}
-	fmt.Printf("\n下载完成! 成功: %d, 失败: %d, 耗时: %v\n",
-		dm.successCount, dm.failCount, elapsed.Round(time.Millisecond))
+	fmt.Println()
+	i18n.Println("download.done", dm.successCount, dm.failCount, elapsed.Round(time.Millisecond))
 	dm.allDone = true
 	dm.mu.Unlock()
 }
@@ -206,7 +248,7 @@ func (dm *DownloadManager) Stop() {
 func (task *DownloadTask) Download(ctx context.Context, dm *DownloadManager) error {
 	// 1. 获取文件信息
 	if err := task.getFileInfo(ctx); err != nil {
-		log.Printf("警告: %v", err)
+		i18n.Logln("download.warning", err)
 		if task.FileName == "" {
 			task.FileName = getFileNameFromURL(task.URL)
 		}
@@ -217,7 +259,7 @@ func (task *DownloadTask) Download(ctx context.Context, dm *DownloadManager) err
 		dm.mu.Lock()
 		defer dm.mu.Unlock()
 		// 更新进度条
-		dm.bar = progressbar.Default(dm.totalSize, "downloading")
+		dm.bar = progressbar.Default(dm.totalSize, i18n.T("download.progress"))
 	}
 
 	// 2. 自动获取文件名
@@ -304,11 +346,7 @@ func (task *DownloadTask) multiThreadDownload(ctx context.Context, dm *DownloadM
 			// 设置Range头
 			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 
-			client := &http.Client{
-				Transport: &http.Transport{
-					Proxy: http.ProxyFromEnvironment,
-				},
-			}
+			client := httpClient()
 			resp, err := client.Do(req.WithContext(ctx))
 			if err != nil {
 				errOnce.Do(func() { firstErr = err })
@@ -372,11 +410,7 @@ func (task *DownloadTask) singleThreadDownload(ctx context.Context, dm *Download
 		req.Header.Set("User-Agent", userAgent)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+	client := httpClient()
 	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
@@ -447,11 +481,7 @@ func (task *DownloadTask) getFileInfo(ctx context.Context) error {
 		req.Header.Set("User-Agent", userAgent)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+	client := httpClient()
 	resp, err := client.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
@@ -530,11 +560,7 @@ func (task *DownloadTask) detectSupportedMethods(ctx context.Context) error {
 		headReq.Header.Set("User-Agent", userAgent)
 	}
 
-	client := &http.Client{
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+	client := httpClient()
 	resp, err := client.Do(headReq.WithContext(ctx))
 
 	if err == nil && resp.StatusCode == http.StatusOK {
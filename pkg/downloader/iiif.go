@@ -3,10 +3,11 @@ package downloader
 import (
 	"bookget/config"
 	"bookget/pkg/chttp"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
 	"bookget/pkg/progressbar"
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
@@ -132,29 +133,27 @@ type IIIFDownloader struct {
 	maxConcurrent int
 	quiet         bool // Quiet mode, don't show progress bars
 
-	cookies []http.Cookie
 	headers http.Header
 }
 
 func NewIIIFDownloader(c *config.Input) *IIIFDownloader {
-	// Create custom Transport to ignore SSL verification
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
+	// Shared keep-alive transport
+	tr := gohttp.DefaultTransport()
 	jar, _ := cookiejar.New(nil)
 
-	cookies, _ := chttp.ReadHttpCookiesFromFile(config.Conf.CookieFile)
 	headers, _ := chttp.ReadHttpHeadersFromFile(config.Conf.HeaderFile)
+	// Cookies of cookie.txt go only to their own hosts, not to every image server
+	var cookies http.CookieJar = jar
+	if fileJar := chttp.FileJar(config.Conf.CookieFile); fileJar != nil {
+		cookies = chttp.JoinJars(jar, fileJar)
+	}
 
 	dl := &IIIFDownloader{
-		client:        &http.Client{Jar: jar, Transport: tr},
+		client:        &http.Client{Jar: cookies, Transport: tr},
 		userAgent:     c.UserAgent,
 		maxRetries:    c.Retries,
 		jpgQuality:    c.Quality,
 		maxConcurrent: c.MaxConcurrent,
-		cookies:       cookies,
 		headers:       headers,
 	}
 	// Set v2 template (supports shorthand sizes and legacy field names)
@@ -177,12 +176,8 @@ func NewIIIFDownloader(c *config.Input) *IIIFDownloader {
 }
 
 func NewIIIFDownloaderDefault() *IIIFDownloader {
-	// Create custom Transport to ignore SSL verification
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	}
+	// Shared keep-alive transport
+	tr := gohttp.DefaultTransport()
 	jar, _ := cookiejar.New(nil)
 
 	dl := &IIIFDownloader{
@@ -243,7 +238,16 @@ func (d *IIIFDownloader) SetQuiet(quiet bool) {
 	d.quiet = quiet
 }
 
-func (d *IIIFDownloader) Dezoomify(ctx context.Context, infoURL string, outputPath string, args []string) error {
+func (d *IIIFDownloader) Dezoomify(ctx context.Context, infoURL string, outputPath string, args []string) (err error) {
+	done := gohttp.PageStarted(ctx, infoURL, outputPath)
+	defer func() {
+		done(err)
+		if err == nil {
+			gohttp.FinishPage(ctx, outputPath, func(ctx context.Context) error {
+				return d.Dezoomify(ctx, infoURL, outputPath, args)
+			})
+		}
+	}()
 	headers, err := d.argsToHeaders(args)
 	if err != nil {
 		return fmt.Errorf("failed to convert headers: %v", err)
@@ -266,13 +270,23 @@ func (d *IIIFDownloader) Dezoomify(ctx context.Context, infoURL string, outputPa
 	}
 
 	if !d.quiet {
-		fmt.Printf("\nImage merge completed, saved to %s\n", outputPath)
+		fmt.Println()
+		i18n.Println("dezoomify.merged", outputPath)
 	}
 	return nil
 }
 
 // DezoomifyWithContent directly uses XML or JSON content for downloading
-func (d *IIIFDownloader) DezoomifyWithContent(ctx context.Context, content string, outputPath string, args []string) error {
+func (d *IIIFDownloader) DezoomifyWithContent(ctx context.Context, content string, outputPath string, args []string) (err error) {
+	done := gohttp.PageStarted(ctx, "", outputPath)
+	defer func() {
+		done(err)
+		if err == nil {
+			gohttp.FinishPage(ctx, outputPath, func(ctx context.Context) error {
+				return d.DezoomifyWithContent(ctx, content, outputPath, args)
+			})
+		}
+	}()
 	headers, err := d.argsToHeaders(args)
 	if err != nil {
 		return fmt.Errorf("failed to convert headers: %v", err)
@@ -335,7 +349,7 @@ func (d *IIIFDownloader) downloadIIIFv2Tiles(ctx context.Context, info *IIIFInfo
 	finalImg := image.NewRGBA(image.Rect(0, 0, info.Width, info.Height))
 	var progressBar *progressbar.ProgressBar
 	if !d.quiet {
-		progressBar = progressbar.Default(int64(cols*rows), "downloading tiles")
+		progressBar = progressbar.Default(int64(cols*rows), i18n.T("download.tiles"))
 	}
 
 	sem := make(chan struct{}, d.maxConcurrent)
@@ -424,6 +438,7 @@ func (d *IIIFDownloader) downloadIIIFv2Tiles(ctx context.Context, info *IIIFInfo
 
 				if progressBar != nil {
 					progressBar.Add(1)
+					describeRate(progressBar, i18n.T("download.tiles"))
 				}
 			}(x, y)
 		}
@@ -462,7 +477,7 @@ func (d *IIIFDownloader) downloadIIIFv3Tiles(ctx context.Context, info *IIIFInfo
 	finalImg := image.NewRGBA(image.Rect(0, 0, info.Width, info.Height))
 	var progressBar *progressbar.ProgressBar
 	if !d.quiet {
-		progressBar = progressbar.Default(int64(cols*rows), "downloading tiles")
+		progressBar = progressbar.Default(int64(cols*rows), i18n.T("download.tiles"))
 	}
 
 	sem := make(chan struct{}, d.maxConcurrent)
@@ -537,6 +552,7 @@ func (d *IIIFDownloader) downloadIIIFv3Tiles(ctx context.Context, info *IIIFInfo
 
 				if progressBar != nil {
 					progressBar.Add(1)
+					describeRate(progressBar, i18n.T("download.tiles"))
 				}
 			}(x, y)
 		}
@@ -568,7 +584,7 @@ func (d *IIIFDownloader) downloadAndMergeXMLTiles(ctx context.Context, info *III
 	finalImg := image.NewRGBA(image.Rect(0, 0, info.Size.Width, info.Size.Height))
 	var progressBar *progressbar.ProgressBar
 	if !d.quiet {
-		progressBar = progressbar.Default(int64(cols*rows), "downloading tiles")
+		progressBar = progressbar.Default(int64(cols*rows), i18n.T("download.tiles"))
 	}
 
 	sem := make(chan struct{}, d.maxConcurrent)
@@ -647,6 +663,7 @@ func (d *IIIFDownloader) downloadAndMergeXMLTiles(ctx context.Context, info *III
 
 				if progressBar != nil {
 					progressBar.Add(1)
+					describeRate(progressBar, i18n.T("download.tiles"))
 				}
 			}(x, y)
 		}
@@ -681,13 +698,6 @@ func (d *IIIFDownloader) getIIIFInfo(ctx context.Context, url string, headers ht
 		req.Header.Set("User-Agent", d.userAgent)
 	}
 
-	// 添加 Cookie
-	if d.cookies != nil {
-		for _, cookie := range d.cookies {
-			req.AddCookie(&cookie)
-		}
-	}
-
 	// 处理额外头（d.headers）
 	if d.headers != nil {
 		for key, values := range d.headers {
@@ -738,13 +748,6 @@ func (d *IIIFDownloader) getIIIFXMLInfo(ctx context.Context, url string, headers
 		req.Header.Set("User-Agent", d.userAgent)
 	}
 
-	// 添加 Cookie
-	if d.cookies != nil {
-		for _, cookie := range d.cookies {
-			req.AddCookie(&cookie)
-		}
-	}
-
 	// 处理额外头（d.headers）
 	if d.headers != nil {
 		for key, values := range d.headers {
@@ -797,13 +800,6 @@ func (d *IIIFDownloader) downloadImage(ctx context.Context, url string, headers
 		req.Header.Set("User-Agent", d.userAgent)
 	}
 
-	// 添加 Cookie
-	if d.cookies != nil {
-		for _, cookie := range d.cookies {
-			req.AddCookie(&cookie)
-		}
-	}
-
 	// 处理额外头（d.headers）
 	if d.headers != nil {
 		for key, values := range d.headers {
@@ -0,0 +1,130 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bookget/pkg/mets"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// comicInfo is the ComicInfo.xml read by comic readers, see https://anansi-project.github.io/docs/comicinfo/schemas/v2.0
+type comicInfo struct {
+	XMLName   xml.Name    `xml:"ComicInfo"`
+	Title     string      `xml:"Title,omitempty"`
+	Series    string      `xml:"Series,omitempty"`
+	Number    string      `xml:"Number,omitempty"`
+	Count     int         `xml:"Count,omitempty"`
+	Notes     string      `xml:"Notes,omitempty"`
+	Web       string      `xml:"Web,omitempty"`
+	PageCount int         `xml:"PageCount"`
+	Manga     string      `xml:"Manga"`
+	Pages     []comicPage `xml:"Pages>Page,omitempty"`
+}
+
+type comicPage struct {
+	Image    int    `xml:"Image,attr"`
+	Type     string `xml:"Type,attr,omitempty"`
+	Bookmark string `xml:"Bookmark,attr,omitempty"`
+}
+
+// WriteCBZ packages every volume of b as <volume>.cbz in b.Dir and returns the files written.
+func WriteCBZ(b *mets.Book, opt Options) ([]string, error) {
+	var outputs []string
+	for k := range b.Volumes {
+		dest := filepath.Join(b.Dir, volumeName(b, k)+".cbz")
+		if err := writeCBZ(b, k, opt, dest); err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, dest)
+	}
+	return outputs, nil
+}
+
+func writeCBZ(b *mets.Book, k int, opt Options, dest string) error {
+	vol := b.Volumes[k]
+	info := comicInfo{
+		Title:     volumeTitle(b, k),
+		Series:    b.Title,
+		Notes:     "bookget " + b.ID,
+		Web:       b.URL,
+		PageCount: len(vol.Files),
+		Manga:     "No",
+	}
+	if len(b.Volumes) > 1 {
+		info.Number = fmt.Sprint(k + 1)
+		info.Count = len(b.Volumes)
+	}
+	if opt.RightToLeft {
+		info.Manga = "YesAndRightToLeft"
+	}
+	bookmarks := map[int]string{}
+	for _, e := range volumeCatalog(b, k) {
+		if _, ok := bookmarks[e.Page]; !ok {
+			bookmarks[e.Page] = e.Title
+		}
+	}
+	for i := range vol.Files {
+		p := comicPage{Image: i, Bookmark: bookmarks[i+1]}
+		if i == 0 {
+			p.Type = "FrontCover"
+		}
+		if p.Type != "" || p.Bookmark != "" {
+			info.Pages = append(info.Pages, p)
+		}
+	}
+
+	f, err := os.Create(dest + ".part")
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	err = func() error {
+		for i, page := range vol.Files {
+			// Re-number so that every reader sorts the pages the same way
+			name := fmt.Sprintf("%04d%s", i+1, strings.ToLower(filepath.Ext(page.Path)))
+			if err := copyToZip(zw, name, pagePath(b, page)); err != nil {
+				return err
+			}
+		}
+		w, err := zw.Create("ComicInfo.xml")
+		if err != nil {
+			return err
+		}
+		bs, err := xml.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append([]byte(xml.Header), bs...))
+		return err
+	}()
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dest + ".part")
+		return err
+	}
+	return os.Rename(dest+".part", dest)
+}
+
+// copyToZip stores a page without compression; images are already compressed.
+func copyToZip(zw *zip.Writer, name, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
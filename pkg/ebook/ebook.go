@@ -0,0 +1,68 @@
+// Package ebook packages the volumes of a downloaded book for reading on
+// tablets and e-readers: CBZ with ComicInfo.xml, and fixed-layout EPUB 3.
+package ebook
+
+import (
+	"bookget/pkg/catalog"
+	"bookget/pkg/mets"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Options control packaging.
+type Options struct {
+	RightToLeft bool // Page progression of vertical Chinese/Japanese books
+}
+
+// volumeName returns the package file name, without extension, for volume k of b.
+func volumeName(b *mets.Book, k int) string {
+	name := b.Volumes[k].Label
+	if name == "" {
+		name = b.ID
+	}
+	if name == "" {
+		name = "book"
+	}
+	return regexp.MustCompile(`[\\/:*?"<>|\s]+`).ReplaceAllString(name, "_")
+}
+
+// volumeCatalog returns the catalog entries that point into volume k, with
+// pages renumbered from 1 within the volume. Entries without a page are dropped.
+func volumeCatalog(b *mets.Book, k int) []catalog.Entry {
+	offset := 0
+	for _, v := range b.Volumes[:k] {
+		offset += len(v.Files)
+	}
+	size := len(b.Volumes[k].Files)
+	var entries []catalog.Entry
+	for _, e := range b.Catalog {
+		if e.Page > offset && e.Page <= offset+size {
+			e.Page -= offset
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// volumeTitle is the title shown for volume k.
+func volumeTitle(b *mets.Book, k int) string {
+	title := b.Title
+	if title == "" {
+		title = b.ID
+	}
+	if len(b.Volumes) > 1 && b.Volumes[k].Label != "" {
+		title += " " + b.Volumes[k].Label
+	}
+	return strings.TrimSpace(title)
+}
+
+func pagePath(b *mets.Book, f mets.File) string {
+	return filepath.Join(b.Dir, filepath.FromSlash(f.Path))
+}
+
+var escaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;", `"`, "&quot;")
+
+func esc(s string) string {
+	return escaper.Replace(s)
+}
@@ -0,0 +1,101 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bookget/pkg/mets"
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBook(t *testing.T) *mets.Book {
+	dir := t.TempDir()
+	for _, name := range []string{"vol.0001/0001.jpg", "vol.0001/0002.jpg", "vol.0002/0001.jpg"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		f, err := os.Create(filepath.Join(dir, name))
+		require.NoError(t, err)
+		require.NoError(t, jpeg.Encode(f, image.NewGray(image.Rect(0, 0, 60, 90)), nil))
+		require.NoError(t, f.Close())
+	}
+	catalogTxt := "#版本=1.0\n卷一 ………… 1\n\t序 ………… 2\n卷二 ………… 3\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "catalog.txt"), []byte(catalogTxt), 0644))
+	b := &mets.Book{ID: "b1", Title: "書", Dir: dir}
+	require.NoError(t, b.Scan())
+	return b
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+	files := map[string]string{}
+	for k, f := range zr.File {
+		if k == 0 {
+			files["#first"] = f.Name
+		}
+		r, err := f.Open()
+		require.NoError(t, err)
+		bs, _ := io.ReadAll(r)
+		_ = r.Close()
+		files[f.Name] = string(bs)
+	}
+	return files
+}
+
+func wellFormed(t *testing.T, doc string) {
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestWriteCBZ(t *testing.T) {
+	b := newBook(t)
+	outputs, err := WriteCBZ(b, Options{RightToLeft: true})
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+	assert.Equal(t, "vol.0001.cbz", filepath.Base(outputs[0]))
+
+	files := readZip(t, outputs[0])
+	assert.Contains(t, files, "0001.jpg")
+	assert.Contains(t, files, "0002.jpg")
+	info := files["ComicInfo.xml"]
+	wellFormed(t, info)
+	assert.Contains(t, info, "<Manga>YesAndRightToLeft</Manga>")
+	assert.Contains(t, info, `<Page Image="1" Bookmark="序"></Page>`)
+}
+
+func TestWriteEPUB(t *testing.T) {
+	b := newBook(t)
+	outputs, err := WriteEPUB(b, Options{RightToLeft: true})
+	require.NoError(t, err)
+	require.Len(t, outputs, 2)
+
+	files := readZip(t, outputs[0])
+	assert.Equal(t, "mimetype", files["#first"])
+	assert.Equal(t, "application/epub+zip", files["mimetype"])
+	for _, name := range []string{"META-INF/container.xml", "OEBPS/content.opf", "OEBPS/nav.xhtml", "OEBPS/p0002.xhtml"} {
+		wellFormed(t, files[name])
+	}
+	assert.Contains(t, files["OEBPS/content.opf"], `page-progression-direction="rtl"`)
+	assert.Contains(t, files["OEBPS/p0001.xhtml"], `content="width=60, height=90"`)
+	nav := files["OEBPS/nav.xhtml"]
+	assert.Contains(t, nav, `<a href="p0002.xhtml">序</a>`)
+	assert.NotContains(t, nav, "卷二")
+
+	// The second volume only gets the entries that point into it, renumbered.
+	files = readZip(t, outputs[1])
+	assert.Contains(t, files["OEBPS/nav.xhtml"], `<a href="p0001.xhtml">卷二</a>`)
+}
@@ -0,0 +1,245 @@
+package ebook
+
+import (
+	"archive/zip"
+	"bookget/pkg/catalog"
+	"bookget/pkg/i18n"
+	"bookget/pkg/mets"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// epubMediaTypes are the EPUB 3 core media types for images decodable by the standard library.
+var epubMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type epubPage struct {
+	id     string // p0001
+	image  string // images/p0001.jpg
+	src    string
+	mime   string
+	width  int
+	height int
+}
+
+// WriteEPUB packages every volume of b as a fixed-layout <volume>.epub in b.Dir and returns the files written.
+func WriteEPUB(b *mets.Book, opt Options) ([]string, error) {
+	var outputs []string
+	for k := range b.Volumes {
+		dest := filepath.Join(b.Dir, volumeName(b, k)+".epub")
+		if err := writeEPUB(b, k, opt, dest); err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, dest)
+	}
+	return outputs, nil
+}
+
+func writeEPUB(b *mets.Book, k int, opt Options, dest string) error {
+	var pages []epubPage
+	for _, f := range b.Volumes[k].Files {
+		if !epubMediaTypes[f.MimeType] {
+			i18n.Logln("epub.skip_type", f.Path, f.MimeType)
+			continue
+		}
+		src := pagePath(b, f)
+		w, h, err := imageSize(src)
+		if err != nil {
+			i18n.Logln("epub.skip", f.Path, err)
+			continue
+		}
+		id := fmt.Sprintf("p%04d", len(pages)+1)
+		pages = append(pages, epubPage{
+			id:     id,
+			image:  "images/" + id + strings.ToLower(filepath.Ext(f.Path)),
+			src:    src,
+			mime:   f.MimeType,
+			width:  w,
+			height: h,
+		})
+	}
+	if len(pages) == 0 {
+		return fmt.Errorf("epub: no pages in %s", volumeName(b, k))
+	}
+	title := volumeTitle(b, k)
+
+	f, err := os.Create(dest + ".part")
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	err = func() error {
+		// mimetype must come first and uncompressed
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write([]byte("application/epub+zip")); err != nil {
+			return err
+		}
+		files := []struct{ name, content string }{
+			{"META-INF/container.xml", containerXml},
+			{"OEBPS/content.opf", packageDocument(b, k, title, pages, opt)},
+			{"OEBPS/nav.xhtml", navDocument(title, volumeCatalog(b, k), pages)},
+		}
+		for _, p := range pages {
+			files = append(files, struct{ name, content string }{"OEBPS/" + p.id + ".xhtml", pageDocument(p)})
+		}
+		for _, file := range files {
+			w, err = zw.Create(file.name)
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write([]byte(file.content)); err != nil {
+				return err
+			}
+		}
+		for _, p := range pages {
+			if err = copyToZip(zw, "OEBPS/"+p.image, p.src); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dest + ".part")
+		return err
+	}
+	return os.Rename(dest+".part", dest)
+}
+
+func imageSize(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg.Width, cfg.Height, err
+}
+
+const containerXml = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+func packageDocument(b *mets.Book, k int, title string, pages []epubPage, opt Options) string {
+	id := b.ID
+	if len(b.Volumes) > 1 {
+		id += "-" + fmt.Sprint(k+1)
+	}
+	direction := "ltr"
+	if opt.RightToLeft {
+		direction = "rtl"
+	}
+	var s strings.Builder
+	s.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="bookid">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&s, "    <dc:identifier id=\"bookid\">urn:bookget:%s</dc:identifier>\n", esc(id))
+	fmt.Fprintf(&s, "    <dc:title>%s</dc:title>\n", esc(title))
+	s.WriteString("    <dc:language>und</dc:language>\n")
+	if b.URL != "" {
+		fmt.Fprintf(&s, "    <dc:source>%s</dc:source>\n", esc(b.URL))
+	}
+	fmt.Fprintf(&s, "    <meta property=\"dcterms:modified\">%s</meta>\n", time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	s.WriteString(`    <meta property="rendition:layout">pre-paginated</meta>
+    <meta property="rendition:orientation">auto</meta>
+    <meta property="rendition:spread">landscape</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+`)
+	for i, p := range pages {
+		props := ""
+		if i == 0 {
+			props = ` properties="cover-image"`
+		}
+		fmt.Fprintf(&s, "    <item id=\"%s-img\" href=\"%s\" media-type=\"%s\"%s/>\n", p.id, p.image, p.mime, props)
+		fmt.Fprintf(&s, "    <item id=\"%s\" href=\"%s.xhtml\" media-type=\"application/xhtml+xml\"/>\n", p.id, p.id)
+	}
+	fmt.Fprintf(&s, "  </manifest>\n  <spine page-progression-direction=\"%s\">\n", direction)
+	for _, p := range pages {
+		fmt.Fprintf(&s, "    <itemref idref=\"%s\"/>\n", p.id)
+	}
+	s.WriteString("  </spine>\n</package>\n")
+	return s.String()
+}
+
+func pageDocument(p epubPage) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml">
+<head>
+<meta charset="UTF-8"/>
+<meta name="viewport" content="width=%d, height=%d"/>
+<title>%s</title>
+<style>html, body { margin: 0; padding: 0; } img { display: block; width: %dpx; height: %dpx; }</style>
+</head>
+<body><img src="%s" alt=""/></body>
+</html>
+`, p.width, p.height, p.id, p.width, p.height, p.image)
+}
+
+// navDocument builds the table of contents from catalog.txt, nesting by indent level.
+func navDocument(title string, entries []catalog.Entry, pages []epubPage) string {
+	var s strings.Builder
+	fmt.Fprintf(&s, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops">
+<head><meta charset="UTF-8"/><title>%s</title></head>
+<body>
+<nav epub:type="toc" id="toc">
+<h1>%s</h1>
+`, esc(title), esc(title))
+
+	var toc []catalog.Entry
+	for _, e := range entries {
+		if e.Page <= len(pages) {
+			toc = append(toc, e)
+		}
+	}
+	if len(toc) == 0 {
+		toc = []catalog.Entry{{Title: title, Page: 1}}
+	}
+	// depth is the number of open <ol>; each of them has an open <li> once written to.
+	depth := 0
+	for _, e := range toc {
+		level := min(e.Level, depth)
+		if level == depth {
+			s.WriteString(strings.Repeat("  ", depth) + "<ol>\n")
+			depth++
+		} else {
+			for ; depth > level+1; depth-- {
+				s.WriteString(strings.Repeat("  ", depth-1) + "</li></ol>\n")
+			}
+			s.WriteString(strings.Repeat("  ", depth-1) + "</li>\n")
+		}
+		fmt.Fprintf(&s, "%s<li><a href=\"%s.xhtml\">%s</a>\n", strings.Repeat("  ", depth), pages[e.Page-1].id, esc(e.Title))
+	}
+	for ; depth > 0; depth-- {
+		s.WriteString(strings.Repeat("  ", depth-1) + "</li></ol>\n")
+	}
+	s.WriteString("</nav>\n</body>\n</html>\n")
+	return s.String()
+}
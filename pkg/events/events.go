@@ -0,0 +1,168 @@
+// Package events reports what the download engine does, pages started,
+// written, done or failed and bytes read per host, so that a dashboard can
+// follow downloads without parsing their output. It also holds the gate that
+// pauses the page downloads of a job.
+package events
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// Kind is the type of an Event.
+type Kind int
+
+const (
+	PagePlanned  Kind = iota // A page of the book was queued for download
+	PageStarted              // Download of URL to File started
+	PageProgress             // Bytes of Total written to File
+	PageDone
+	PageFailed
+	Received // Bytes read from Host, metadata included
+)
+
+func (k Kind) String() string {
+	switch k {
+	case PagePlanned:
+		return "planned"
+	case PageStarted:
+		return "started"
+	case PageProgress:
+		return "progress"
+	case PageDone:
+		return "done"
+	case PageFailed:
+		return "failed"
+	case Received:
+		return "received"
+	}
+	return "unknown"
+}
+
+// Event is one thing the engine did. Fields not used by Kind are empty.
+type Event struct {
+	Kind  Kind
+	Job   int // ID of the Job of the download, 0 if it has none
+	Host  string
+	URL   string
+	File  string
+	Bytes int64 // Written so far for PageProgress, read for Received
+	Total int64 // Size of File, 0 if unknown
+	Err   error
+}
+
+var (
+	mu     sync.RWMutex
+	subs   = make(map[int]func(Event))
+	nextID int
+	active atomic.Bool
+)
+
+// Subscribe calls fn for every event until cancel is called. fn runs on the
+// engine's goroutines and must not block.
+func Subscribe(fn func(Event)) (cancel func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	id := nextID
+	nextID++
+	subs[id] = fn
+	active.Store(true)
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(subs, id)
+		active.Store(len(subs) > 0)
+	}
+}
+
+// Enabled tells whether anyone listens, to skip building events nobody reads
+func Enabled() bool {
+	return active.Load()
+}
+
+// Emit passes e, from a download of ctx, to the subscribers
+func Emit(ctx context.Context, e Event) {
+	if !active.Load() {
+		return
+	}
+	if j := JobOf(ctx); j != nil {
+		e.Job = j.ID
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, fn := range subs {
+		fn(e)
+	}
+}
+
+// Job is a download the dashboard follows on its own: the events of its
+// context carry its ID, and its page downloads can be paused.
+type Job struct {
+	ID     int
+	mu     sync.Mutex
+	resume chan struct{} // nil while running, closed by Resume
+}
+
+type jobKey struct{}
+
+// WithJob makes the downloads of ctx part of j
+func WithJob(ctx context.Context, j *Job) context.Context {
+	return context.WithValue(ctx, jobKey{}, j)
+}
+
+// JobOf is the Job of ctx, nil if it has none
+func JobOf(ctx context.Context) *Job {
+	if ctx == nil {
+		return nil
+	}
+	j, _ := ctx.Value(jobKey{}).(*Job)
+	return j
+}
+
+// Pause holds the page downloads of j that have not started yet; running ones finish.
+func (j *Job) Pause() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.resume == nil {
+		j.resume = make(chan struct{})
+	}
+}
+
+// Resume lets the held page downloads of j go on
+func (j *Job) Resume() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.resume != nil {
+		close(j.resume)
+		j.resume = nil
+	}
+}
+
+// Paused tells whether the page downloads of j are held
+func (j *Job) Paused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.resume != nil
+}
+
+// Wait returns once the Job of ctx is not paused, or ctx is done.
+// The engine calls it before each page.
+func Wait(ctx context.Context) error {
+	j := JobOf(ctx)
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	resume := j.resume
+	j.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
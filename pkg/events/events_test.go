@@ -0,0 +1,42 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribe(t *testing.T) {
+	var got []Event
+	cancel := Subscribe(func(e Event) { got = append(got, e) })
+	assert.True(t, Enabled())
+	Emit(context.Background(), Event{Kind: PageStarted})
+	Emit(WithJob(context.Background(), &Job{ID: 3}), Event{Kind: PageDone})
+	cancel()
+	Emit(context.Background(), Event{Kind: PageFailed})
+	assert.Equal(t, []Event{{Kind: PageStarted}, {Kind: PageDone, Job: 3}}, got)
+	assert.False(t, Enabled())
+}
+
+func TestPause(t *testing.T) {
+	j, other := &Job{ID: 1}, &Job{ID: 2}
+	ctx := WithJob(context.Background(), j)
+	assert.NoError(t, Wait(ctx))
+
+	j.Pause()
+	assert.True(t, j.Paused())
+	// Other jobs and downloads outside a job go on
+	assert.NoError(t, Wait(WithJob(context.Background(), other)))
+	assert.NoError(t, Wait(context.Background()))
+	timeout, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.Error(t, Wait(timeout))
+
+	done := make(chan error)
+	go func() { done <- Wait(ctx) }()
+	j.Resume()
+	assert.NoError(t, <-done)
+	assert.False(t, j.Paused())
+}
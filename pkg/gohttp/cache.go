@@ -0,0 +1,226 @@
+package gohttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CachePolicy controls the metadata cache. Successful GET responses that are
+// JSON, XML or HTML are stored with their ETag/Last-Modified and revalidated
+// with conditional requests; images and other media are never stored.
+type CachePolicy struct {
+	Dir     string                          // Cache directory; "" turns the cache off
+	TTL     func(host string) time.Duration // Serve without revalidating while younger than this; nil = always revalidate
+	Refresh bool                            // Ignore stored responses, fetch and store again
+}
+
+// Cache is the metadata cache of every pooled transport. Set it before the first request.
+var Cache CachePolicy
+
+// maxCachedBody keeps large responses, e.g. full-text dumps, out of the cache
+const maxCachedBody = 32 << 20
+
+// cacheEntry is the .json sidecar of a stored body
+type cacheEntry struct {
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Stored time.Time   `json:"stored"`
+}
+
+// cacheTransport answers metadata requests from Cache.Dir when it can
+type cacheTransport struct {
+	next http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.next.RoundTrip(req)
+	}
+	key := cacheKey(req)
+	entry, body := loadCache(key)
+	if entry != nil && !Cache.Refresh {
+		if time.Since(entry.Stored) < cacheTTL(req.URL.Hostname()) {
+			return entry.response(req, body), nil
+		}
+		if etag, modified := entry.Header.Get("ETag"), entry.Header.Get("Last-Modified"); etag != "" || modified != "" {
+			req = req.Clone(req.Context())
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			if modified != "" {
+				req.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		for _, k := range []string{"ETag", "Last-Modified", "Cache-Control", "Expires"} {
+			if v := resp.Header.Get(k); v != "" {
+				entry.Header.Set(k, v)
+			}
+		}
+		entry.Stored = time.Now()
+		_ = saveCache(key, entry, nil)
+		return entry.response(req, body), nil
+	}
+	if resp.StatusCode != http.StatusOK || !metadataType(resp.Header.Get("Content-Type")) ||
+		strings.Contains(resp.Header.Get("Cache-Control"), "no-store") ||
+		resp.ContentLength > maxCachedBody {
+		return resp, nil
+	}
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" && cacheTTL(req.URL.Hostname()) <= 0 {
+		// Nothing to revalidate with and no TTL to serve it by
+		return resp, nil
+	}
+
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if len(buf) > maxCachedBody {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(buf))
+	_ = saveCache(key, &cacheEntry{URL: req.URL.String(), Header: resp.Header.Clone(), Stored: time.Now()}, buf)
+	return resp, nil
+}
+
+func cacheTTL(host string) time.Duration {
+	if Cache.TTL == nil {
+		return 0
+	}
+	return Cache.TTL(host)
+}
+
+// cacheable leaves out everything but plain GETs; conditional and range
+// requests belong to the caller.
+func cacheable(req *http.Request) bool {
+	if Cache.Dir == "" || req.Method != http.MethodGet {
+		return false
+	}
+	for _, k := range []string{"Range", "If-None-Match", "If-Modified-Since"} {
+		if req.Header.Get(k) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataType reports whether a Content-Type is JSON, XML or HTML
+func metadataType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return true
+	case strings.HasSuffix(mediaType, "/json"), strings.HasSuffix(mediaType, "+json"):
+		return true
+	case strings.HasSuffix(mediaType, "/xml"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
+
+// cacheKey is the hash of the URL and the Accept header, which picks between
+// representations such as IIIF v2 and v3 manifests. Cookie and Authorization
+// are in it too: what a logged-in session got is never answered to another.
+func cacheKey(req *http.Request) string {
+	key := req.URL.String() + "\n" + req.Header.Get("Accept") + "\n" + req.Header.Get("Cookie") + "\n" + req.Header.Get("Authorization")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func cachePath(key string) string {
+	return filepath.Join(Cache.Dir, key[:2], key)
+}
+
+func loadCache(key string) (*cacheEntry, []byte) {
+	p := cachePath(key)
+	bs, err := os.ReadFile(p + ".json")
+	if err != nil {
+		return nil, nil
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(bs, &entry); err != nil {
+		return nil, nil
+	}
+	body, err := os.ReadFile(p + ".body")
+	if err != nil {
+		return nil, nil
+	}
+	return &entry, body
+}
+
+// saveCache writes the body (unless nil) and then the entry, each through a rename
+func saveCache(key string, entry *cacheEntry, body []byte) error {
+	p := cachePath(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := writeFileAtomic(p+".body", body); err != nil {
+			return err
+		}
+	}
+	// Cookies belong to the session that got them, not to the later runs the cache answers
+	entry.Header.Del("Set-Cookie")
+	entry.Header.Del("Set-Cookie2")
+	bs, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p+".json", bs)
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+func (e *cacheEntry) response(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
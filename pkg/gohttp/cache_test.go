@@ -0,0 +1,98 @@
+package gohttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	var hits, notModified int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.URL.Path == "/page.jpg" {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("ETag", `"img"`)
+			_, _ = w.Write([]byte("jpeg"))
+			return
+		}
+		if r.URL.Path == "/account" {
+			c, _ := r.Cookie("session")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"user":"` + c.Value + `"}`))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ld+json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"id":"manifest"}`))
+	}))
+	defer srv.Close()
+
+	defer func() { Cache = CachePolicy{} }()
+	Cache = CachePolicy{Dir: t.TempDir()}
+
+	var cookie string
+	get := func(path string) string {
+		resp, err := PooledClient(DefaultTransportKey, nil, 0).Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		cookie = resp.Header.Get("Set-Cookie")
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// First fetch stores, the second revalidates and is answered from the cache
+	assert.Equal(t, `{"id":"manifest"}`, get("/manifest"))
+	assert.Equal(t, `{"id":"manifest"}`, get("/manifest"))
+	assert.Equal(t, 2, hits)
+	assert.Equal(t, 1, notModified)
+	assert.Empty(t, cookie, "cookies are not stored")
+
+	// Within the TTL the server isn't asked at all
+	Cache.TTL = func(host string) time.Duration { return time.Hour }
+	assert.Equal(t, `{"id":"manifest"}`, get("/manifest"))
+	assert.Equal(t, 2, hits)
+
+	// --refresh fetches unconditionally
+	Cache.Refresh = true
+	assert.Equal(t, `{"id":"manifest"}`, get("/manifest"))
+	assert.Equal(t, 3, hits)
+	assert.Equal(t, 1, notModified)
+
+	// Images are never stored
+	Cache.Refresh = false
+	assert.Equal(t, "jpeg", get("/page.jpg"))
+	assert.Equal(t, "jpeg", get("/page.jpg"))
+	assert.Equal(t, 5, hits)
+
+	// Requests with other credentials don't share stored answers
+	Cache.TTL = func(host string) time.Duration { return time.Hour }
+	getAs := func(cookie string) string {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/account", nil)
+		require.NoError(t, err)
+		req.Header.Set("Cookie", cookie)
+		resp, err := PooledClient(DefaultTransportKey, nil, 0).Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+	assert.Equal(t, `{"user":"a"}`, getAs("session=a"))
+	assert.Equal(t, `{"user":"b"}`, getAs("session=b"))
+	assert.Equal(t, `{"user":"a"}`, getAs("session=a"))
+	assert.Equal(t, 7, hits)
+}
@@ -4,6 +4,7 @@ import (
 	"io"
 	"path/filepath"
 	"runtime"
+	"sync/atomic"
 )
 
 type OffsetWriter struct {
@@ -17,9 +18,16 @@ func (dst *OffsetWriter) Write(b []byte) (n int, err error) {
 	return
 }
 
-// Chunk represents the partial content range
+// Chunk represents the partial content range. Done counts the bytes already
+// written from Start.
 type Chunk struct {
-	Start, End uint64
+	Start uint64 `json:"start"`
+	End   uint64 `json:"end"`
+	Done  uint64 `json:"done"`
+}
+
+func (c *Chunk) complete() bool {
+	return atomic.LoadUint64(&c.Done) >= c.End-c.Start+1
 }
 
 // Return constant path which will not change once the download starts
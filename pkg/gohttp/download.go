@@ -1,6 +1,7 @@
 package gohttp
 
 import (
+	"bookget/pkg/events"
 	"context"
 	"fmt"
 	"io"
@@ -31,8 +32,10 @@ func ByteUnitString(n int64) string {
 
 // Info holds downloadable file info.
 type Info struct {
-	Size      uint64
-	Rangeable bool
+	Size         uint64
+	Rangeable    bool
+	ETag         string
+	LastModified string
 }
 
 // Download holds downloadable file config and infos.
@@ -45,11 +48,12 @@ type Download struct {
 
 	Cookie                                          []http.Cookie
 	Concurrency                                     int
-	StopProgress                                    bool
 	URL, Dir, Dest, path, unsafeName                string
 	Interval, ChunkSize, MinChunkSize, MaxChunkSize uint64
 	opts                                            Options
 	mutex                                           *sync.RWMutex
+	controlMu                                       sync.Mutex
+	stopProgress                                    atomic.Bool
 }
 
 // TotalSize returns file total size (0 if unknown).
@@ -85,7 +89,10 @@ func (d *Download) TotalCost() time.Duration {
 // Write updates progress size.
 func (d *Download) Write(b []byte) (int, error) {
 	n := len(b)
-	atomic.AddUint64(&d.size, uint64(n))
+	size := atomic.AddUint64(&d.size, uint64(n))
+	if events.Enabled() {
+		events.Emit(d.ctx, events.Event{Kind: events.PageProgress, URL: d.URL, File: d.Dest, Bytes: int64(size), Total: int64(d.TotalSize())})
+	}
 	return n, nil
 }
 
@@ -96,21 +103,30 @@ func (d *Download) IsRangeable() bool {
 
 func (r *Response) dlFile(d *Download) (size int64, err error) {
 	defer func(d *Download) {
-		d.StopProgress = true
+		d.stopProgress.Store(true)
 	}(d)
 	//if r.resp.StatusCode != 200 || r.resp.ContentLength == -1 {
 	//	return 0, errors.New(r.resp.Status)
 	//}
 	var destTemp = fmt.Sprintf("%s.downloading", d.Dest)
 	file, err := os.Create(destTemp)
-	defer os.Rename(destTemp, d.Dest)
-	defer file.Close()
 	if err != nil {
 		return
 	}
-	// Allocate the file completely so that we can write concurrently
-	file.Truncate(r.resp.ContentLength)
 	size, err = io.Copy(file, io.TeeReader(r.resp.Body, d))
+	if err == nil && r.resp.ContentLength >= 0 && size != r.resp.ContentLength {
+		err = fmt.Errorf("incomplete download: %d of %d bytes", size, r.resp.ContentLength)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	//只有完整的文件才改名
+	if err == nil {
+		err = os.Rename(destTemp, d.Dest)
+	}
+	if err != nil {
+		_ = os.Remove(destTemp)
+	}
 	return
 }
 func dlProgressBar(wg *sync.WaitGroup, d *Download) {
@@ -136,14 +152,18 @@ func dlProgressBar(wg *sync.WaitGroup, d *Download) {
 		for k := 0; k < after; k++ {
 			Sleep += " "
 		}
-		fmt.Fprintf(os.Stdout, "\r%d%%[%s]  %s/%s  %s/s    in %s", pd, Sleep, ByteUnitString(int64(d.Size())),
-			ByteUnitString(int64(d.TotalSize())), ByteUnitString(int64(d.AvgSleep())), d.TotalCost())
+		limit := ""
+		if RateLimits.Global > 0 {
+			limit = " (limit " + ByteUnitString(RateLimits.Global) + "/s)"
+		}
+		fmt.Fprintf(os.Stdout, "\r%d%%[%s]  %s/%s  %s/s%s    in %s", pd, Sleep, ByteUnitString(int64(d.Size())),
+			ByteUnitString(int64(d.TotalSize())), ByteUnitString(int64(d.AvgSleep())), limit, d.TotalCost())
 
 		// Update last size
 		atomic.StoreUint64(&d.lastSize, atomic.LoadUint64(&d.size))
 		//stop
-		if pd == 100 || d.StopProgress {
-			d.StopProgress = true
+		if pd == 100 || d.stopProgress.Load() {
+			d.stopProgress.Store(true)
 			break
 		}
 		// Context check.
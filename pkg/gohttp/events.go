@@ -0,0 +1,88 @@
+package gohttp
+
+import (
+	"bookget/pkg/events"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// PageFinisher checks and post-processes the page just saved at dest by a
+// download of ctx. redo downloads the page again to dest.
+type PageFinisher func(ctx context.Context, dest string, redo func() error)
+
+type pageFinisherKey struct{}
+
+// WithPageFinisher runs finish on every page image downloaded with ctx
+func WithPageFinisher(ctx context.Context, finish PageFinisher) context.Context {
+	return context.WithValue(ctx, pageFinisherKey{}, finish)
+}
+
+type redoKey struct{}
+
+// pageImages are the files FinishPage runs on; PDFs and other files are left alone
+var pageImages = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true,
+	".tif": true, ".tiff": true, ".jp2": true, ".webp": true,
+}
+
+// FinishPage runs the PageFinisher of ctx on the page image saved at dest,
+// unless the page is being downloaded again by its redo
+func FinishPage(ctx context.Context, dest string, redo func(ctx context.Context) error) {
+	finish, _ := ctx.Value(pageFinisherKey{}).(PageFinisher)
+	if finish == nil || ctx.Value(redoKey{}) != nil || !pageImages[strings.ToLower(filepath.Ext(dest))] {
+		return
+	}
+	finish(ctx, dest, func() error { return redo(context.WithValue(ctx, redoKey{}, true)) })
+}
+
+// PageStarted waits while the job of ctx is paused, then reports the download
+// of uri to dest; done reports how it ended.
+func PageStarted(ctx context.Context, uri, dest string) (done func(err error)) {
+	_ = events.Wait(ctx)
+	host := ""
+	if u, err := url.Parse(uri); err == nil {
+		host = u.Hostname()
+	}
+	events.Emit(ctx, events.Event{Kind: events.PageStarted, Host: host, URL: uri, File: dest})
+	return func(err error) {
+		kind := events.PageDone
+		if err != nil {
+			kind = events.PageFailed
+		}
+		events.Emit(ctx, events.Event{Kind: kind, Host: host, URL: uri, File: dest, Err: err})
+	}
+}
+
+// downloadErr is why a download to a file failed, nil if it did not
+func downloadErr(resp *Response, err error) error {
+	switch {
+	case err != nil:
+		return err
+	case resp == nil || resp.resp == nil:
+		return errors.New("no response")
+	case resp.resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("HTTP %s", resp.resp.Status)
+	}
+	return resp.err
+}
+
+// countingReader reports the bytes read from host
+type countingReader struct {
+	ctx  context.Context
+	host string
+	r    io.Reader
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if n > 0 {
+		events.Emit(c.ctx, events.Event{Kind: events.Received, Host: c.host, Bytes: int64(n)})
+	}
+	return n, err
+}
@@ -0,0 +1,44 @@
+package gohttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinishPage(t *testing.T) {
+	var served int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		_, _ = w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	var checked []string
+	ctx := WithPageFinisher(context.Background(), func(ctx context.Context, dest string, redo func() error) {
+		checked = append(checked, filepath.Base(dest))
+		require.NoError(t, redo())
+	})
+
+	// Every page saved by FastGet is checked once, also when it is downloaded again
+	dir := t.TempDir()
+	_, err := FastGet(ctx, srv.URL+"/0001.jpg", Options{DestFile: filepath.Join(dir, "0001.jpg"), Concurrency: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001.jpg"}, checked)
+	assert.Equal(t, 2, served)
+
+	// A PDF is not a page image
+	_, err = FastGet(ctx, srv.URL+"/book.pdf", Options{DestFile: filepath.Join(dir, "book.pdf"), Concurrency: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001.jpg"}, checked)
+
+	// Without a finisher in ctx nothing is checked
+	_, err = FastGet(context.Background(), srv.URL+"/0002.jpg", Options{DestFile: filepath.Join(dir, "0002.jpg"), Concurrency: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0001.jpg"}, checked)
+}
@@ -0,0 +1,189 @@
+package gohttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// FixtureMode switches the pooled transports between the network and recorded fixtures
+type FixtureMode int
+
+const (
+	FixtureOff    FixtureMode = iota
+	FixtureRecord             // Send requests and save each exchange under Dir
+	FixtureReplay             // Answer requests from Dir only, never touching the network
+)
+
+// FixturePolicy records and replays request/response pairs as
+// <Dir>/<host>/<name>.json, so adapter tests run without network access.
+// Cookies, auth headers and ScrubParams are never written to disk.
+type FixturePolicy struct {
+	Mode   FixtureMode
+	Dir    string
+	Served func(req *http.Request) // Called for every replayed request, for tests to inspect
+}
+
+// Fixtures is the fixture policy of every pooled transport. Set it before the first request.
+var Fixtures FixturePolicy
+
+// ScrubParams are query parameters dropped from fixture keys and files: credentials and cache busters
+var ScrubParams = []string{"token", "access_token", "key", "apikey", "sig", "signature", "auth", "_", "t", "timestamp"}
+
+// scrubHeaders are response headers left out of fixtures
+var scrubHeaders = []string{"Set-Cookie", "Authorization", "Proxy-Authorization", "Cookie", "Date", "Age"}
+
+// FixtureModeFromEnv reads BOOKGET_FIXTURES: "record" records, anything else replays.
+func FixtureModeFromEnv() FixtureMode {
+	if strings.EqualFold(os.Getenv("BOOKGET_FIXTURES"), "record") {
+		return FixtureRecord
+	}
+	return FixtureReplay
+}
+
+// fixture is one recorded exchange
+type fixture struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+type fixtureTransport struct {
+	next http.RoundTripper
+}
+
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if Fixtures.Mode == FixtureOff {
+		return t.next.RoundTrip(req)
+	}
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	name := fixturePath(req, reqBody)
+
+	if Fixtures.Mode == FixtureReplay {
+		bs, err := os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("no fixture for %s %s (record with BOOKGET_FIXTURES=record): %w", req.Method, scrubURL(req.URL), err)
+		}
+		var f fixture
+		if err = json.Unmarshal(bs, &f); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", name, err)
+		}
+		if Fixtures.Served != nil {
+			Fixtures.Served(req)
+		}
+		return f.response(req)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	f := fixture{Method: req.Method, URL: scrubURL(req.URL), Status: resp.StatusCode, Header: resp.Header.Clone()}
+	for _, k := range scrubHeaders {
+		f.Header.Del(k)
+	}
+	if utf8.Valid(body) {
+		f.Body = string(body)
+	} else {
+		f.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+	bs, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(name, append(bs, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (f *fixture) response(req *http.Request) (*http.Response, error) {
+	body := []byte(f.Body)
+	if f.BodyBase64 != "" {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(f.BodyBase64); err != nil {
+			return nil, err
+		}
+	}
+	header := f.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// scrubURL drops credentials and ScrubParams from u
+func scrubURL(u *url.URL) string {
+	c := *u
+	c.User = nil
+	c.Fragment = ""
+	q := c.Query()
+	for _, k := range ScrubParams {
+		q.Del(k)
+	}
+	c.RawQuery = q.Encode()
+	return c.String()
+}
+
+var fixtureSlugRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixturePath is <Dir>/<host>/<method>-<last path segment>-<hash>.json; the
+// hash covers the scrubbed URL and the request body.
+func fixturePath(req *http.Request, body []byte) string {
+	sum := sha256.Sum256(append([]byte(req.Method+" "+scrubURL(req.URL)+"\n"), body...))
+	slug := fixtureSlugRe.ReplaceAllString(path.Base(req.URL.Path), "_")
+	if len(slug) > 40 {
+		slug = slug[:40]
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", strings.ToLower(req.Method), strings.Trim(slug, "_."), hex.EncodeToString(sum[:])[:12])
+	return filepath.Join(FixtureHostDir(req.URL.Host), name)
+}
+
+// FixtureHostDir is the directory the exchanges with host are recorded in
+func FixtureHostDir(host string) string {
+	return filepath.Join(Fixtures.Dir, fixtureSlugRe.ReplaceAllString(host, "_"))
+}
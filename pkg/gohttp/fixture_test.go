@@ -0,0 +1,71 @@
+package gohttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set("Content-Type", "application/json")
+		body, _ := io.ReadAll(r.Body)
+		_, _ = io.WriteString(w, `{"path":"`+r.URL.Path+`","body":"`+string(body)+`"}`)
+	}))
+	defer func() { Fixtures = FixturePolicy{} }()
+	dir := t.TempDir()
+
+	do := func(method, path, body string) (string, error) {
+		req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := PooledClient(DefaultTransportKey, nil, 0).Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		bs, err := io.ReadAll(resp.Body)
+		return string(bs), err
+	}
+
+	Fixtures = FixturePolicy{Mode: FixtureRecord, Dir: dir}
+	got, err := do(http.MethodGet, "/book/1?token=abc", "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/book/1","body":""}`, got)
+	_, err = do(http.MethodPost, "/pages", "page=1")
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	for _, f := range files {
+		bs, err := os.ReadFile(f)
+		require.NoError(t, err)
+		assert.NotContains(t, string(bs), "secret")
+		assert.NotContains(t, string(bs), "abc")
+	}
+
+	// Replay works with the server gone, whatever the token
+	srv.Close()
+	var served []string
+	Fixtures = FixturePolicy{Mode: FixtureReplay, Dir: dir, Served: func(req *http.Request) {
+		served = append(served, req.Method+" "+req.URL.Path)
+	}}
+	got, err = do(http.MethodGet, "/book/1?token=xyz", "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/book/1","body":""}`, got)
+	got, err = do(http.MethodPost, "/pages", "page=1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"path":"/pages","body":"page=1"}`, got)
+	assert.Equal(t, []string{"GET /book/1", "POST /pages"}, served)
+
+	_, err = do(http.MethodPost, "/pages", "page=2")
+	assert.ErrorContains(t, err, "no fixture for POST")
+}
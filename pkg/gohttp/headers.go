@@ -0,0 +1,113 @@
+package gohttp
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HeaderPolicy fills in request headers from per-host profiles. Headers set by
+// the caller always win. Profile values may use {page_url}, {origin} and
+// {book_id}; a header whose template expands to nothing is left out.
+type HeaderPolicy struct {
+	Lookup func(host string) map[string]string // Header templates for a host
+}
+
+// Headers is the header policy of every pooled transport. Set it before the first request.
+var Headers HeaderPolicy
+
+// Book is the book being downloaded, as seen by header templates
+type Book struct {
+	PageURL string // The URL given to bookget
+	ID      string
+}
+
+// bookRef is the book of a context. Its ID is filled in once the adapter has read it.
+type bookRef struct {
+	mu   sync.Mutex
+	book Book
+}
+
+type bookContextKey struct{}
+
+// WithBook attaches b to ctx. Requests made with ctx, or a context derived
+// from it, fill in the header profiles for b.
+func WithBook(ctx context.Context, b Book) context.Context {
+	return context.WithValue(ctx, bookContextKey{}, &bookRef{book: b})
+}
+
+// SetBookID sets the ID of the book of ctx, keeping its PageURL
+func SetBookID(ctx context.Context, id string) {
+	if ref, ok := ctx.Value(bookContextKey{}).(*bookRef); ok {
+		ref.mu.Lock()
+		ref.book.ID = id
+		ref.mu.Unlock()
+	}
+}
+
+// BookOf returns the book of ctx, empty if it carries none
+func BookOf(ctx context.Context) Book {
+	if ref, ok := ctx.Value(bookContextKey{}).(*bookRef); ok {
+		ref.mu.Lock()
+		defer ref.mu.Unlock()
+		return ref.book
+	}
+	return Book{}
+}
+
+// ProfileHeaders returns the profile headers of host expanded for b
+func ProfileHeaders(host string, b Book) http.Header {
+	if Headers.Lookup == nil {
+		return nil
+	}
+	templates := Headers.Lookup(host)
+	if len(templates) == 0 {
+		return nil
+	}
+	origin := ""
+	if u, err := url.Parse(b.PageURL); err == nil && u.Host != "" {
+		origin = u.Scheme + "://" + u.Host
+	}
+	r := strings.NewReplacer("{page_url}", b.PageURL, "{origin}", origin, "{book_id}", b.ID)
+	h := make(http.Header, len(templates))
+	for k, v := range templates {
+		if v = strings.TrimSpace(r.Replace(v)); v != "" {
+			h.Set(k, v)
+		}
+	}
+	return h
+}
+
+// SortedHeaderKeys returns the keys of h in order, for printing
+func SortedHeaderKeys(h http.Header) []string {
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// headerTransport adds the profile headers the caller didn't set
+type headerTransport struct {
+	next http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	profile := ProfileHeaders(req.URL.Hostname(), BookOf(req.Context()))
+	cloned := false
+	for k, v := range profile {
+		if req.Header.Get(k) != "" {
+			continue
+		}
+		if !cloned {
+			req = req.Clone(req.Context())
+			cloned = true
+		}
+		req.Header[k] = v
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,65 @@
+package gohttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderTransport(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	saved := Headers
+	defer func() { Headers = saved }()
+	Headers.Lookup = func(host string) map[string]string {
+		return map[string]string{
+			"Referer":  "{page_url}",
+			"Origin":   "{origin}",
+			"X-Book":   "{book_id}",
+			"X-Token":  "fixed",
+			"X-Absent": "{book_id}",
+		}
+	}
+
+	client := &http.Client{Transport: &headerTransport{next: http.DefaultTransport}}
+	ctx := WithBook(context.Background(), Book{PageURL: "https://guji.nlc.cn/detail?id=1&v=2", ID: "b1"})
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Token", "caller")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// Not percent-encoded, caller headers win
+	assert.Equal(t, "https://guji.nlc.cn/detail?id=1&v=2", got.Get("Referer"))
+	assert.Equal(t, "https://guji.nlc.cn", got.Get("Origin"))
+	assert.Equal(t, "b1", got.Get("X-Book"))
+	assert.Equal(t, "caller", got.Get("X-Token"))
+	assert.Equal(t, "caller", req.Header.Get("X-Token"))
+	assert.Empty(t, req.Header.Get("Referer"), "the caller's request is not modified")
+
+	// An empty expansion leaves the header out
+	h := ProfileHeaders("guji.nlc.cn", Book{PageURL: "https://guji.nlc.cn/"})
+	assert.Empty(t, h.Get("X-Absent"))
+	assert.Equal(t, []string{"Origin", "Referer", "X-Token"}, SortedHeaderKeys(h))
+}
+
+func TestBookOf(t *testing.T) {
+	a := WithBook(context.Background(), Book{PageURL: "https://a.example/1"})
+	b := WithBook(context.Background(), Book{PageURL: "https://b.example/2"})
+	SetBookID(a, "a1")
+
+	// Each download sees its own book only
+	assert.Equal(t, Book{PageURL: "https://a.example/1", ID: "a1"}, BookOf(a))
+	assert.Equal(t, Book{PageURL: "https://b.example/2"}, BookOf(b))
+	assert.Equal(t, Book{}, BookOf(context.Background()))
+	SetBookID(context.Background(), "ignored")
+}
@@ -1,9 +1,11 @@
 package gohttp
 
 import (
+	"bookget/pkg/postprocess"
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
@@ -12,14 +14,21 @@ import (
 	"time"
 )
 
+// pageExists tells whether the page at dest was saved by a previous run,
+// as it is or already split by post-processing
+func pageExists(dest string) bool {
+	fi, err := os.Stat(dest)
+	if err == nil && fi.Size() > 0 {
+		return true
+	}
+	return postprocess.Processed(dest)
+}
+
 func (r *Request) FastGet(uri string, opts ...Options) (resp *Response, err error) {
 	if len(opts) > 0 {
 		r.opts = opts[0]
-		if !opts[0].Overwrite {
-			fi, err := os.Stat(opts[0].DestFile)
-			if err == nil && fi.Size() > 0 {
-				return nil, nil
-			}
+		if !opts[0].Overwrite && pageExists(opts[0].DestFile) {
+			return nil, nil
 		}
 		if opts[0].Concurrency == 1 {
 			return Get(r.ctx, uri, opts...)
@@ -33,6 +42,18 @@ func (r *Request) FastGet(uri string, opts ...Options) (resp *Response, err erro
 		Concurrency: r.opts.Concurrency,
 	}
 	d.mutex = new(sync.RWMutex)
+	done := PageStarted(r.ctx, uri, d.Dest)
+	defer func() {
+		done(err)
+		if err == nil {
+			FinishPage(r.ctx, d.Dest, func(ctx context.Context) error {
+				opts := r.opts
+				opts.Overwrite = true
+				_, err := FastGet(ctx, uri, opts)
+				return err
+			})
+		}
+	}()
 	//多线程下载
 	if err = d.ChunkInit(); err != nil {
 		return nil, err
@@ -68,10 +89,15 @@ func (d *Download) ChunkInit() (err error) {
 	}
 
 	// Set concurrency default.
-	if d.Concurrency == 0 {
+	if d.Concurrency <= 0 {
 		d.Concurrency = getDefaultConcurrency()
 	}
 
+	// 断点续传：同一文件（大小、ETag、Last-Modified 不变）只下载缺少的部分
+	if d.chunks = d.loadControl(); d.chunks != nil {
+		return nil
+	}
+
 	// Set default chunk size
 	if d.ChunkSize == 0 {
 		d.ChunkSize = getDefaultChunkSize(d.info.Size, d.MinChunkSize, d.MaxChunkSize, uint64(d.Concurrency))
@@ -107,11 +133,19 @@ func (d *Download) GetInfoOrDownload() (*Info, error) {
 	}
 	d.opts.Headers["Range"] = "bytes=0-0"
 	r := NewClient(d.ctx)
-	r.Request("GET", d.URL, d.opts)
+	if _, err := r.Request("GET", d.URL, d.opts); err != nil {
+		return nil, err
+	}
 	_resp, err := r.cli.Do(r.req)
+	if err != nil {
+		return nil, err
+	}
 	defer _resp.Body.Close()
 
-	info := &Info{}
+	info := &Info{
+		ETag:         _resp.Header.Get("ETag"),
+		LastModified: _resp.Header.Get("Last-Modified"),
+	}
 	if _resp.ContentLength > 0 {
 		atomic.StoreUint64(&info.Size, uint64(_resp.ContentLength))
 	}
@@ -122,17 +156,6 @@ func (d *Download) GetInfoOrDownload() (*Info, error) {
 
 	// Set content disposition non trusted name
 	d.unsafeName = _resp.Header.Get("content-disposition")
-	//只使用临时文件
-	var destTemp = fmt.Sprintf("%s.downloading", d.Path())
-	dest, err := os.Create(destTemp)
-	if err != nil {
-		return info, err
-	}
-	defer dest.Close()
-
-	if _, err = io.Copy(dest, io.TeeReader(_resp.Body, d)); err != nil {
-		return info, err
-	}
 
 	// Get content length from content-range response header,
 	// if content-range exists, that means partial content is supported.
@@ -140,18 +163,35 @@ func (d *Download) GetInfoOrDownload() (*Info, error) {
 		l := strings.Split(cr, "/")
 		if len(l) == 2 {
 			if length, err := strconv.ParseUint(l[1], 10, 64); err == nil {
-
-				return &Info{
-					Size:      length,
-					Rangeable: true,
-				}, nil
+				info.Size = length
+				info.Rangeable = true
+				return info, nil
 			}
 		}
 		// Make sure the caller knows about the problem and we don't just silently fail
 		return info, fmt.Errorf("Response includes content-range header which is invalid: %s", cr)
 	}
 
-	return info, nil
+	//不支持 Range，整个文件已在响应中，下载完整后再改名
+	destTemp := d.tempPath()
+	dest, err := os.Create(destTemp)
+	if err != nil {
+		return info, err
+	}
+	n, err := io.Copy(dest, io.TeeReader(_resp.Body, d))
+	if err == nil && _resp.ContentLength >= 0 && n != _resp.ContentLength {
+		err = fmt.Errorf("incomplete download: %d of %d bytes", n, _resp.ContentLength)
+	}
+	if cerr := dest.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(destTemp, d.Path())
+	}
+	if err != nil {
+		_ = os.Remove(destTemp)
+	}
+	return info, err
 }
 
 // Start downloads the file chunks, and merges them.
@@ -168,31 +208,46 @@ func (d *Download) ChunkStart() (err error) {
 	}
 
 	// Otherwise there are always at least 2 chunks
-	var destTemp = fmt.Sprintf("%s.downloading", d.Path())
-	file, err := os.Create(destTemp)
+	destTemp := d.tempPath()
+	file, err := os.OpenFile(destTemp, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		err = file.Close()
-		if err == nil {
-			os.Rename(destTemp, d.Path())
-		}
-	}()
-	size := d.TotalSize()
 	// Allocate the file completely so that we can write concurrently
-	file.Truncate(int64(size))
+	if err = file.Truncate(int64(d.TotalSize())); err != nil {
+		_ = file.Close()
+		return err
+	}
+	for _, chunk := range d.chunks {
+		atomic.AddUint64(&d.size, atomic.LoadUint64(&chunk.Done))
+	}
+	if err = d.saveControl(); err != nil {
+		_ = file.Close()
+		return err
+	}
 
 	// Download chunks.
 	errs := make(chan error, 1)
 	go d.dl(file, errs)
-
-	select {
-	case err = <-errs:
-	case <-d.ctx.Done():
-		err = d.ctx.Err()
+	// Wait for every chunk to stop writing, also when ctx is cancelled
+	err = <-errs
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Keep the offsets for the next run
+		_ = d.saveControl()
+		return err
+	}
+	//全部分块完成后才改名
+	if err = os.Rename(destTemp, d.Path()); err != nil {
+		return err
 	}
-	return
+	_ = os.Remove(d.controlPath())
+	return nil
 }
 
 // Download chunks
@@ -204,51 +259,85 @@ func (d *Download) dl(dest io.WriterAt, errC chan error) {
 
 		// Concurrency limit.
 		max = make(chan int, d.Concurrency)
+
+		// The first chunk error
+		firstErr error
+		errOnce  sync.Once
 	)
 	if d.opts.Headers == nil {
 		d.opts.Headers = make(map[string]interface{})
 	}
+	if v := d.info.ifRange(); v != "" {
+		d.opts.Headers["If-Range"] = v
+	}
 
-	wg.Add(1)
-	go dlProgressBar(&wg, d)
+	var progress sync.WaitGroup
+	progress.Add(1)
+	go dlProgressBar(&progress, d)
+
+	// Save the offsets now and then, a killed process loses at most a few seconds
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(2 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = d.saveControl()
+			}
+		}
+	}()
 
 	for i := 0; i < len(d.chunks); i++ {
+		if d.chunks[i].complete() {
+			continue
+		}
 
 		max <- 1
 		wg.Add(1)
 
 		go func(i int) {
 			defer wg.Done()
+			defer func() { <-max }()
 
 			// Concurrently download and write chunk
-			if err := d.DownloadChunk(d.chunks[i], &OffsetWriter{dest, int64(d.chunks[i].Start)}); err != nil {
-				errC <- err
-				return
+			if err := d.DownloadChunk(d.chunks[i], &chunkWriter{dest, d.chunks[i]}); err != nil {
+				errOnce.Do(func() { firstErr = err })
 			}
-
-			<-max
 		}(i)
 	}
 
 	wg.Wait()
-	errC <- nil
+	close(stop)
+	d.stopProgress.Store(true)
+	progress.Wait()
+	errC <- firstErr
 }
 
-// DownloadChunk downloads a file chunk.
+// DownloadChunk downloads the missing part of a file chunk.
 func (d *Download) DownloadChunk(c *Chunk, dest io.Writer) error {
-	contentRange := fmt.Sprintf("bytes=%d-%d", c.Start, c.End)
+	start := c.Start + atomic.LoadUint64(&c.Done)
+	contentRange := fmt.Sprintf("bytes=%d-%d", start, c.End)
 	d.mutex.Lock()
 	d.opts.Headers["Range"] = contentRange
 	r := NewClient(d.ctx)
-	r.Request("GET", d.URL, d.opts)
+	_, err := r.Request("GET", d.URL, d.opts)
 	d.mutex.Unlock()
+	if err != nil {
+		return err
+	}
 	resp, err := r.cli.Do(r.req)
-	defer resp.Body.Close()
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("Range request %s returned status %d, the file may have changed", contentRange, resp.StatusCode)
+	}
 	// Verify the length
-	if resp.ContentLength != int64(c.End-c.Start+1) {
+	if resp.ContentLength != int64(c.End-start+1) {
 		return fmt.Errorf(
 			"Range request returned invalid Content-Length: %d however the range was: %s",
 			resp.ContentLength, contentRange,
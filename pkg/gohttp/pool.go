@@ -0,0 +1,131 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TransportKey selects a pooled transport. Requests with the same key share
+// keep-alive connections and HTTP/2 sessions.
+type TransportKey struct {
+	Proxy    string // Proxy URL for every host; empty follows Proxies, then HTTP_PROXY/HTTPS_PROXY
+	Insecure bool   // Skip TLS certificate verification for every host, see TLSPolicy for per-host exceptions
+}
+
+// DefaultTransportKey is used by requests that don't ask for anything special
+var DefaultTransportKey = TransportKey{}
+
+// PoolLimits apply to every pooled transport. Change them before the first request.
+var PoolLimits = struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int // 0 = unlimited
+	IdleConnTimeout     time.Duration
+}{
+	MaxIdleConns:        256,
+	MaxIdleConnsPerHost: 32,
+	MaxConnsPerHost:     32,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+var (
+	poolMu sync.Mutex
+	pool   = make(map[TransportKey]*http.Transport)
+)
+
+// Transport returns the process-wide transport for key, creating it on first use.
+func Transport(key TransportKey) *http.Transport {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	if tr, ok := pool[key]; ok {
+		return tr
+	}
+	tr := newTransport(key)
+	pool[key] = tr
+	return tr
+}
+
+// RoundTripper returns the transport for key. It adds the profile Headers,
+// answers metadata requests from Cache, chooses per-host Proxies, applies
+// RateLimits to response bodies and, in tests, records or replays Fixtures.
+func RoundTripper(key TransportKey) http.RoundTripper {
+	var rt http.RoundTripper = &proxyTransport{tr: Transport(key), explicit: key.Proxy != ""}
+	rt = &rateTransport{next: rt}
+	rt = &fixtureTransport{next: rt}
+	rt = &cacheTransport{next: rt}
+	return &headerTransport{next: rt}
+}
+
+// DefaultTransport returns the round tripper for DefaultTransportKey.
+func DefaultTransport() http.RoundTripper {
+	return RoundTripper(DefaultTransportKey)
+}
+
+// PooledClient returns a client on the shared transport for key. Clients are
+// cheap; what is expensive, the connections, lives in the transport.
+func PooledClient(key TransportKey, jar http.CookieJar, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: RoundTripper(key),
+		Jar:       jar,
+		Timeout:   timeout,
+	}
+}
+
+// CloseIdleConnections closes idle connections of every pooled transport.
+func CloseIdleConnections() {
+	poolMu.Lock()
+	defer poolMu.Unlock()
+	for _, tr := range pool {
+		tr.CloseIdleConnections()
+	}
+}
+
+func newTransport(key TransportKey) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	tr := &http.Transport{
+		Proxy:           proxyFunc(http.ProxyFromEnvironment),
+		DialContext:     dialer.DialContext,
+		TLSClientConfig: tlsConfig(key.Insecure, ""),
+		// A custom TLS config turns HTTP/2 off unless asked for explicitly
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          PoolLimits.MaxIdleConns,
+		MaxIdleConnsPerHost:   PoolLimits.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       PoolLimits.MaxConnsPerHost,
+		IdleConnTimeout:       PoolLimits.IdleConnTimeout,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	// Connections to the host or to an https:// proxy do their own handshake so that the dialed host is
+	// known even when it is an IP address and no SNI is sent.
+	tr.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		cfg := tlsConfig(key.Insecure, host)
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+		tc := tls.Client(conn, cfg)
+		if err = tc.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return tc, nil
+	}
+	if key.Proxy != "" {
+		if proxy, err := ParseProxy(key.Proxy); err == nil {
+			tr.Proxy = http.ProxyURL(proxy.URL)
+		}
+	}
+	return tr
+}
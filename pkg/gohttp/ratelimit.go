@@ -0,0 +1,238 @@
+package gohttp
+
+import (
+	"bookget/pkg/events"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitPolicy caps the bytes per second read from response bodies, over
+// all hosts and per host. Every pooled transport applies it, so image pages,
+// IIIF tiles and chunked downloads share the same budget.
+type RateLimitPolicy struct {
+	Global int64                   // Bytes per second for all hosts together, 0 = unlimited
+	Host   func(host string) int64 // Bytes per second for one host, 0 = unlimited
+}
+
+// RateLimits is the rate limit of every pooled transport
+var RateLimits RateLimitPolicy
+
+// ParseRate parses a rate like curl's --limit-rate: 800 (bytes/s), 500K, 2M, 1.5G.
+// K, M and G are powers of 1024; a trailing B or /s is allowed.
+func ParseRate(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	v = strings.TrimSuffix(v, "/S")
+	v = strings.TrimSuffix(v, "B")
+	if v == "" {
+		return 0, nil
+	}
+	mult := 1.0
+	switch v[len(v)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid rate %q, expected e.g. 500K or 2M", s)
+	}
+	return int64(n * mult), nil
+}
+
+// tokenBucket lets rate bytes per second through, with bursts of up to burst bytes.
+// Readers take tokens after reading and sleep off the debt, so many readers
+// together stay at rate.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate int64) *tokenBucket {
+	burst := float64(rate) / 4
+	if burst < 4096 {
+		burst = 4096
+	}
+	return &tokenBucket{rate: float64(rate), burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context, n int) error {
+	b.mu.Lock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	var d time.Duration
+	if b.tokens < 0 {
+		d = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	bucketsMu sync.Mutex
+	buckets   = make(map[string]*tokenBucket) // "" is the global bucket
+)
+
+// bucket returns the shared bucket of host for rate, nil for no limit
+func bucket(host string, rate int64) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	bucketsMu.Lock()
+	defer bucketsMu.Unlock()
+	b, ok := buckets[host]
+	if !ok || b.rate != float64(rate) {
+		b = newTokenBucket(rate)
+		buckets[host] = b
+	}
+	return b
+}
+
+// hostRate is the per-host limit of host
+func hostRate(host string) int64 {
+	if RateLimits.Host == nil {
+		return 0
+	}
+	return RateLimits.Host(host)
+}
+
+// LimitReader throttles r to the global and the host limit. Bodies of pooled
+// transports already are; use it for other readers.
+func LimitReader(ctx context.Context, host string, r io.Reader) io.Reader {
+	var bs []*tokenBucket
+	if b := bucket("", RateLimits.Global); b != nil {
+		bs = append(bs, b)
+	}
+	if host != "" {
+		if b := bucket(host, hostRate(host)); b != nil {
+			bs = append(bs, b)
+		}
+	}
+	if len(bs) == 0 {
+		return r
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &limitedReader{ctx: ctx, r: r, buckets: bs}
+}
+
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	buckets []*tokenBucket
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth
+	if max := int(l.buckets[0].burst); len(p) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		meter.add(n)
+		for _, b := range l.buckets {
+			if werr := b.wait(l.ctx, n); werr != nil && err == nil {
+				err = werr
+			}
+		}
+	}
+	return n, err
+}
+
+// limitedBody keeps the Close of a response body
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// rateTransport passes response bodies through LimitReader
+type rateTransport struct {
+	next http.RoundTripper
+}
+
+func (t *rateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.Body == nil || resp.Body == http.NoBody {
+		return resp, err
+	}
+	var r io.Reader = resp.Body
+	if events.Enabled() {
+		r = &countingReader{ctx: req.Context(), host: req.URL.Hostname(), r: r}
+	}
+	if r = LimitReader(req.Context(), req.URL.Hostname(), r); r != io.Reader(resp.Body) {
+		resp.Body = &limitedBody{Reader: r, Closer: resp.Body}
+	}
+	return resp, nil
+}
+
+// rateMeter measures the throttled throughput for progress bars
+type rateMeter struct {
+	total     atomic.Int64
+	mu        sync.Mutex
+	lastAt    time.Time
+	lastTotal int64
+	rate      float64
+}
+
+var meter rateMeter
+
+func (m *rateMeter) add(n int) {
+	m.total.Add(int64(n))
+}
+
+// current is the rate over the last second or more
+func (m *rateMeter) current() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if m.lastAt.IsZero() {
+		m.lastAt, m.lastTotal = now, m.total.Load()
+		return 0
+	}
+	if elapsed := now.Sub(m.lastAt); elapsed >= time.Second {
+		total := m.total.Load()
+		m.rate = float64(total-m.lastTotal) / elapsed.Seconds()
+		m.lastAt, m.lastTotal = now, total
+	}
+	return m.rate
+}
+
+// RateStatus describes the throttled rate for progress bars, e.g.
+// "1.9 MB/s of 2 MB/s"; empty without a global limit.
+func RateStatus() string {
+	if RateLimits.Global <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/s of %s/s", ByteUnitString(int64(meter.current())), ByteUnitString(RateLimits.Global))
+}
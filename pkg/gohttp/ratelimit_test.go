@@ -0,0 +1,70 @@
+package gohttp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]int64{
+		"":       0,
+		"800":    800,
+		"500K":   500 << 10,
+		"2M":     2 << 20,
+		"1.5m":   3 << 19,
+		"2MB/s":  2 << 20,
+		"1G":     1 << 30,
+		" 64k  ": 64 << 10,
+	} {
+		got, err := ParseRate(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"fast", "-1M", "2X"} {
+		_, err := ParseRate(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	body := bytes.Repeat([]byte("x"), 100<<10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	saved := RateLimits
+	defer func() { RateLimits = saved }()
+
+	get := func() time.Duration {
+		client := &http.Client{Transport: &rateTransport{next: http.DefaultTransport}}
+		started := time.Now()
+		resp, err := client.Get(srv.URL)
+		require.NoError(t, err)
+		got, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+		require.Len(t, got, len(body))
+		return time.Since(started)
+	}
+
+	// 100K at 200K/s: a 50K burst, then a quarter of a second
+	RateLimits = RateLimitPolicy{Global: 200 << 10}
+	assert.GreaterOrEqual(t, get(), 200*time.Millisecond)
+	assert.NotEmpty(t, RateStatus())
+
+	// The host limit applies on its own
+	RateLimits = RateLimitPolicy{Host: func(host string) int64 { return 200 << 10 }}
+	assert.GreaterOrEqual(t, get(), 200*time.Millisecond)
+	assert.Empty(t, RateStatus())
+
+	RateLimits = RateLimitPolicy{}
+	assert.Less(t, get(), 200*time.Millisecond)
+}
@@ -4,7 +4,6 @@ import (
 	"bookget/pkg/chttp"
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,8 +12,6 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -80,7 +77,9 @@ func (r *Request) Request(method, uri string, opts ...Options) (*Request, error)
 		if err != nil {
 			return nil, err
 		}
-		req.WithContext(r.ctx)
+		if r.ctx != nil {
+			req = req.WithContext(r.ctx)
+		}
 		r.req = req
 	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodOptions:
 		// parse body
@@ -90,7 +89,9 @@ func (r *Request) Request(method, uri string, opts ...Options) (*Request, error)
 		if err != nil {
 			return nil, err
 		}
-		req.WithContext(r.ctx)
+		if r.ctx != nil {
+			req = req.WithContext(r.ctx)
+		}
 		r.req = req
 	default:
 		return nil, errors.New("invalid request method")
@@ -109,7 +110,6 @@ func (r *Request) Request(method, uri string, opts ...Options) (*Request, error)
 	r.parseHeaders()
 
 	// parse cookies
-	r.parseCookieFile()
 	r.parseCookies()
 
 	if r.opts.Debug {
@@ -123,6 +123,26 @@ func (r *Request) Request(method, uri string, opts ...Options) (*Request, error)
 }
 
 func (r *Request) do() (*Response, error) {
+	if r.opts.DestFile == "" {
+		return r.send()
+	}
+	uri := r.req.URL.String()
+	done := PageStarted(r.ctx, uri, r.opts.DestFile)
+	resp, err := r.send()
+	failed := downloadErr(resp, err)
+	done(failed)
+	if failed == nil {
+		FinishPage(r.ctx, r.opts.DestFile, func(ctx context.Context) error {
+			opts := r.opts
+			opts.Overwrite = true
+			resp, err := Get(ctx, uri, opts)
+			return downloadErr(resp, err)
+		})
+	}
+	return resp, err
+}
+
+func (r *Request) send() (*Response, error) {
 	var _resp = new(http.Response)
 	var err error
 	for i := 0; i < r.opts.Retry; i++ {
@@ -189,27 +209,19 @@ func (r *Request) parseOptions() {
 }
 
 func (r *Request) parseClient() {
-	tr := &http.Transport{
-		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-		DisableKeepAlives: true,
-	}
-
-	// Check for explicit proxy setting first
-	if r.opts.Proxy != "" {
-		proxy, err := url.Parse(r.opts.Proxy)
-		if err == nil {
-			tr.Proxy = http.ProxyURL(proxy)
-		}
-	} else {
-		// If no explicit proxy, check environment variables
-		tr.Proxy = http.ProxyFromEnvironment
+	key := DefaultTransportKey
+	key.Proxy = r.opts.Proxy
+	r.cli = PooledClient(key, nil, r.opts.timeout)
+	// Cookies of the cookie file only go to the hosts they belong to
+	var jars []http.CookieJar
+	if r.opts.CookieJar != nil {
+		jars = append(jars, r.opts.CookieJar)
 	}
-	r.cli = &http.Client{
-		Timeout:   r.opts.timeout,
-		Transport: tr,
+	if fileJar := chttp.FileJar(r.opts.CookieFile); fileJar != nil {
+		jars = append(jars, fileJar)
 	}
-	if r.opts.CookieJar != nil {
-		r.cli.Jar = r.opts.CookieJar
+	if len(jars) > 0 {
+		r.cli.Jar = chttp.JoinJars(jars...)
 	}
 }
 
@@ -262,40 +274,6 @@ func (r *Request) parseCookies() {
 	}
 }
 
-func (r *Request) parseCookieFile() {
-	if r.opts.CookieFile == "" {
-		return
-	}
-	fp, err := os.Open(r.opts.CookieFile)
-	if err != nil {
-		return
-	}
-	defer fp.Close()
-
-	bsHeader, err := io.ReadAll(fp)
-	if err != nil {
-		return
-	}
-	mHeader := strings.Split(string(bsHeader), "\n")
-	for _, line := range mHeader {
-		if strings.HasPrefix(line, "#") {
-			continue
-		}
-		text := regexp.MustCompile(`\\"`).ReplaceAllString(line, "\"")
-		row := strings.Split(text, "\t")
-		if len(row) < 8 {
-			continue
-		}
-		k := strings.ReplaceAll(row[5], "\"", "")
-		v := strings.ReplaceAll(row[6], "\"", "")
-		//expires := strings.ReplaceAll(row[4], "#HttpOnly_", "")
-		r.req.AddCookie(&http.Cookie{
-			Name:  k,
-			Value: v,
-		})
-	}
-}
-
 func (r *Request) parseHeaders() {
 	if r.opts.Headers == nil {
 		r.opts.Headers = make(map[string]interface{})
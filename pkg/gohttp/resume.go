@@ -0,0 +1,101 @@
+package gohttp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// controlSuffix names the sidecar next to the .downloading file. It records the
+// chunk offsets of an interrupted download, so the next run fetches only what
+// is missing.
+const controlSuffix = ".downloading.json"
+
+// control is the content of the sidecar file
+type control struct {
+	URL          string   `json:"url"`
+	Size         uint64   `json:"size"`
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Chunks       []*Chunk `json:"chunks"`
+}
+
+func (d *Download) tempPath() string {
+	return d.Path() + ".downloading"
+}
+
+func (d *Download) controlPath() string {
+	return d.Path() + controlSuffix
+}
+
+// loadControl returns the chunks of an earlier run of the same file, nil if
+// there is none or the file changed on the server since.
+func (d *Download) loadControl() []*Chunk {
+	bs, err := os.ReadFile(d.controlPath())
+	if err != nil {
+		return nil
+	}
+	var c control
+	if err = json.Unmarshal(bs, &c); err != nil || len(c.Chunks) == 0 {
+		return nil
+	}
+	if c.Size != d.info.Size || c.ETag != d.info.ETag || c.LastModified != d.info.LastModified {
+		return nil
+	}
+	// Without the partial file the offsets mean nothing
+	if fi, err := os.Stat(d.tempPath()); err != nil || uint64(fi.Size()) != c.Size {
+		return nil
+	}
+	for _, chunk := range c.Chunks {
+		if chunk.End < chunk.Start || chunk.End >= c.Size || chunk.Done > chunk.End-chunk.Start+1 {
+			return nil
+		}
+	}
+	return c.Chunks
+}
+
+// saveControl records the offsets reached so far
+func (d *Download) saveControl() error {
+	c := control{
+		URL:          d.URL,
+		Size:         d.info.Size,
+		ETag:         d.info.ETag,
+		LastModified: d.info.LastModified,
+		Chunks:       make([]*Chunk, len(d.chunks)),
+	}
+	for i, chunk := range d.chunks {
+		c.Chunks[i] = &Chunk{Start: chunk.Start, End: chunk.End, Done: atomic.LoadUint64(&chunk.Done)}
+	}
+	bs, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	d.controlMu.Lock()
+	defer d.controlMu.Unlock()
+	return writeFileAtomic(d.controlPath(), bs)
+}
+
+// ifRange is the If-Range value of chunk requests, so that a file replaced on
+// the server mid-download answers 200 instead of mixing two versions.
+// Weak ETags are not allowed in If-Range.
+func (info *Info) ifRange() string {
+	if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		return info.ETag
+	}
+	return info.LastModified
+}
+
+// chunkWriter writes a chunk into the file and counts the bytes written
+type chunkWriter struct {
+	dest  io.WriterAt
+	chunk *Chunk
+}
+
+func (w *chunkWriter) Write(b []byte) (n int, err error) {
+	offset := w.chunk.Start + atomic.LoadUint64(&w.chunk.Done)
+	n, err = w.dest.WriteAt(b, int64(offset))
+	atomic.AddUint64(&w.chunk.Done, uint64(n))
+	return
+}
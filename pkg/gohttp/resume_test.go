@@ -0,0 +1,123 @@
+package gohttp
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResumableDownload(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1000) // 16000 bytes
+	etag := `"v1"`
+	var (
+		mu     sync.Mutex
+		ranges []string
+		fail   = true
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rng := r.Header.Get("Range")
+		mu.Lock()
+		ranges = append(ranges, rng)
+		broken := fail && strings.HasPrefix(rng, "bytes=8000-")
+		tag := etag
+		mu.Unlock()
+		if broken {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		http.ServeContent(w, r, "book.pdf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "book.pdf")
+	download := func() error {
+		d := &Download{
+			ctx:         context.Background(),
+			URL:         srv.URL + "/book.pdf",
+			Dest:        dest,
+			Concurrency: 2,
+			ChunkSize:   3999,
+			mutex:       new(sync.RWMutex),
+		}
+		if err := d.ChunkInit(); err != nil {
+			return err
+		}
+		return d.ChunkStart()
+	}
+
+	// One chunk fails: nothing is renamed, the offsets are kept
+	require.Error(t, download())
+	assert.NoFileExists(t, dest)
+	assert.FileExists(t, dest+".downloading")
+	assert.FileExists(t, dest+controlSuffix)
+
+	// The next run asks only for the missing chunk
+	mu.Lock()
+	fail, ranges = false, nil
+	mu.Unlock()
+	require.NoError(t, download())
+	assert.Equal(t, []string{"bytes=0-0", "bytes=8000-11999"}, ranges)
+	got, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+	assert.NoFileExists(t, dest+".downloading")
+	assert.NoFileExists(t, dest+controlSuffix)
+
+	// A file changed on the server starts over
+	require.NoError(t, os.Remove(dest))
+	mu.Lock()
+	fail, ranges = true, nil
+	mu.Unlock()
+	require.Error(t, download())
+	mu.Lock()
+	fail, ranges, etag = false, nil, `"v2"`
+	mu.Unlock()
+	require.NoError(t, download())
+	assert.Len(t, ranges, 5)
+}
+
+func TestDownloadNotRangeable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		_, _ = w.Write([]byte("short"))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "page.jpg")
+	_, err := FastGet(context.Background(), srv.URL, Options{DestFile: dest, Concurrency: 2})
+	require.Error(t, err)
+	assert.NoFileExists(t, dest)
+	assert.NoFileExists(t, dest+".downloading")
+}
+
+func TestFastGetSkipsSplitPage(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_, _ = w.Write([]byte("jpeg"))
+	}))
+	defer srv.Close()
+
+	// A previous run saved the page and split it into 0001_1.jpg and 0001_2.jpg
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0001_1.jpg"), []byte("left"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0001_2.jpg"), []byte("right"), 0644))
+	for _, concurrency := range []int{1, 2} {
+		_, err := FastGet(context.Background(), srv.URL+"/1.jpg", Options{DestFile: filepath.Join(dir, "0001.jpg"), Concurrency: concurrency})
+		assert.NoError(t, err)
+	}
+	assert.Zero(t, hits)
+	_, err := os.Stat(filepath.Join(dir, "0001.jpg"))
+	assert.True(t, os.IsNotExist(err))
+}
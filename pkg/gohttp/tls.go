@@ -0,0 +1,148 @@
+package gohttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TLSPolicy decides how server certificates are checked. Certificates are
+// verified against Roots unless InsecureHost allows the host; pins are checked
+// in every case.
+type TLSPolicy struct {
+	Roots        *x509.CertPool             // nil = system roots
+	InsecureHost func(host string) bool     // Hosts with broken certificates that are accepted anyway
+	Pins         func(host string) []string // "sha256/<base64 of the SubjectPublicKeyInfo hash>"
+}
+
+// TLS is the policy of every pooled transport. Set it before the first request.
+var TLS TLSPolicy
+
+// TLSError reports a certificate that failed verification or pinning.
+type TLSError struct {
+	Host   string
+	Reason string
+	Err    error
+}
+
+func (e *TLSError) Error() string {
+	msg := fmt.Sprintf("TLS error: %s: %s", e.Host, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *TLSError) Unwrap() error { return e.Err }
+
+// IsTLSError reports whether err was caused by certificate verification.
+func IsTLSError(err error) bool {
+	var tlsErr *TLSError
+	return errors.As(err, &tlsErr)
+}
+
+// LoadCABundle returns the system roots plus the PEM certificates in path.
+func LoadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ca bundle: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("ca bundle: no PEM certificates found in %s", path)
+	}
+	return roots, nil
+}
+
+// PinOf returns the pin of a certificate in the form used by the pins setting.
+func PinOf(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// tlsConfig verifies in VerifyConnection instead of the standard check, so that
+// the per-host exceptions and pins apply to a single shared transport.
+// host is the dialed host; when empty the SNI name is used.
+func tlsConfig(insecure bool, host string) *tls.Config {
+	return &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if host != "" {
+				cs.ServerName = host
+			}
+			return verifyConnection(cs, insecure)
+		},
+	}
+}
+
+// tlsReported holds the hosts whose TLS error was already logged
+var tlsReported sync.Map
+
+// verifyConnection logs the first failure per host, since many adapters drop request errors.
+func verifyConnection(cs tls.ConnectionState, insecure bool) error {
+	err := checkConnection(cs, insecure)
+	if err != nil {
+		if _, seen := tlsReported.LoadOrStore(cs.ServerName, true); !seen {
+			log.Println(err)
+		}
+		return err
+	}
+	return nil
+}
+
+func checkConnection(cs tls.ConnectionState, insecure bool) error {
+	host := cs.ServerName
+	if host == "" {
+		return &TLSError{Reason: "no host name to verify the certificate against"}
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return &TLSError{Host: host, Reason: "server sent no certificate"}
+	}
+	if !insecure && (TLS.InsecureHost == nil || !TLS.InsecureHost(host)) {
+		opts := x509.VerifyOptions{
+			DNSName:       host,
+			Roots:         TLS.Roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+			return &TLSError{
+				Host:   host,
+				Reason: "certificate verification failed (use --ca-bundle, or add the host to insecure_hosts in config.ini)",
+				Err:    err,
+			}
+		}
+	}
+	if TLS.Pins == nil {
+		return nil
+	}
+	pins := TLS.Pins(host)
+	if len(pins) == 0 {
+		return nil
+	}
+	for _, cert := range cs.PeerCertificates {
+		pin := PinOf(cert)
+		for _, want := range pins {
+			if strings.EqualFold(strings.TrimSpace(want), pin) {
+				return nil
+			}
+		}
+	}
+	return &TLSError{
+		Host:   host,
+		Reason: fmt.Sprintf("certificate pin mismatch, server presented %s", PinOf(cs.PeerCertificates[0])),
+	}
+}
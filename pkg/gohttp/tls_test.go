@@ -0,0 +1,50 @@
+package gohttp
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	defer func() { TLS = TLSPolicy{} }()
+
+	get := func() error {
+		CloseIdleConnections()
+		resp, err := PooledClient(DefaultTransportKey, nil, 0).Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		return err
+	}
+
+	// Verification is on by default; the test server's certificate is self-signed.
+	err := get()
+	require.Error(t, err)
+	assert.True(t, IsTLSError(err))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	TLS.Roots = roots
+	assert.NoError(t, get())
+
+	TLS.Roots = nil
+	TLS.InsecureHost = func(host string) bool { return host == "127.0.0.1" }
+	assert.NoError(t, get())
+
+	TLS.Pins = func(host string) []string { return []string{"sha256/AAAA"} }
+	err = get()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), PinOf(srv.Certificate()))
+
+	TLS.Pins = func(host string) []string { return []string{PinOf(srv.Certificate())} }
+	assert.NoError(t, get())
+}
@@ -0,0 +1,193 @@
+// Package i18n translates the messages bookget prints. Messages are looked up
+// by ID in the catalogs/<lang>.json files; --lang, LC_ALL, LC_MESSAGES or LANG
+// pick the language, English is the fallback.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Languages of the shipped catalogs
+const (
+	English            = "en"
+	SimplifiedChinese  = "zh-Hans"
+	TraditionalChinese = "zh-Hant"
+	Japanese           = "ja"
+)
+
+//go:embed catalogs/*.json
+var files embed.FS
+
+// Message is the text of a message ID. A plural message has a form for
+// one and another for other counts; languages without plurals use Other only.
+type Message struct {
+	One   string `json:"one,omitempty"`
+	Other string `json:"other"`
+}
+
+// UnmarshalJSON reads "text" or {"one": "...", "other": "..."}
+func (m *Message) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &m.Other)
+	}
+	type plain Message
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs map[string]map[string]Message
+	lang     string
+)
+
+func init() {
+	catalogs = make(map[string]map[string]Message)
+	entries, err := files.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range entries {
+		data, err := files.ReadFile("catalogs/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		msgs := make(map[string]Message)
+		if err = json.Unmarshal(data, &msgs); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = msgs
+	}
+	lang = Detect()
+}
+
+// Languages lists the languages that have a catalog
+func Languages() []string {
+	return []string{English, SimplifiedChinese, TraditionalChinese, Japanese}
+}
+
+// SetLanguage switches to tag, e.g. zh-TW or ja_JP.UTF-8; empty detects it
+// from the environment again
+func SetLanguage(tag string) error {
+	l := Detect()
+	if tag != "" {
+		var ok bool
+		if l, ok = Normalize(tag); !ok {
+			return fmt.Errorf("--lang %s: unknown language, use one of %s", tag, strings.Join(Languages(), ", "))
+		}
+	}
+	mu.Lock()
+	lang = l
+	mu.Unlock()
+	return nil
+}
+
+// Language is the language in use
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return lang
+}
+
+// Detect reads the language from LC_ALL, LC_MESSAGES and LANG, then from the
+// system where it has its own setting, e.g. Windows
+func Detect() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(name)
+		if v == "" || v == "C" || v == "POSIX" {
+			continue
+		}
+		if l, ok := Normalize(v); ok {
+			return l
+		}
+		// LANG=fr_FR: English rather than a later variable
+		return English
+	}
+	if l, ok := Normalize(systemLocale()); ok {
+		return l
+	}
+	return English
+}
+
+// Normalize maps a locale or language tag to the language of a catalog.
+// zh_TW, zh_HK, zh_MO and zh-Hant are Traditional Chinese, other zh Simplified.
+func Normalize(tag string) (string, bool) {
+	tag, _, _ = strings.Cut(tag, ".") // zh_CN.UTF-8
+	tag, _, _ = strings.Cut(tag, "@")
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if tag == "" {
+		return "", false
+	}
+	parts := strings.Split(tag, "-")
+	switch parts[0] {
+	case "en":
+		return English, true
+	case "ja":
+		return Japanese, true
+	case "zh":
+		for _, p := range parts[1:] {
+			switch p {
+			case "hant", "tw", "hk", "mo":
+				return TraditionalChinese, true
+			}
+		}
+		return SimplifiedChinese, true
+	}
+	return "", false
+}
+
+// lookup finds id in the language in use, then in English
+func lookup(id string) (Message, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if m, ok := catalogs[lang][id]; ok {
+		return m, true
+	}
+	m, ok := catalogs[English][id]
+	return m, ok
+}
+
+// T is the message id formatted with args; an unknown id is returned as is
+func T(id string, args ...interface{}) string {
+	m, ok := lookup(id)
+	if !ok {
+		return id
+	}
+	return sprintf(m.Other, args)
+}
+
+// N is the plural message id for a count of n, formatted with args
+func N(id string, n int, args ...interface{}) string {
+	m, ok := lookup(id)
+	if !ok {
+		return id
+	}
+	text := m.Other
+	if n == 1 && m.One != "" {
+		text = m.One
+	}
+	return sprintf(text, args)
+}
+
+func sprintf(format string, args []interface{}) string {
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Println prints the message id on a line of its own
+func Println(id string, args ...interface{}) {
+	fmt.Println(T(id, args...))
+}
+
+// Logln writes the message id to the log
+func Logln(id string, args ...interface{}) {
+	log.Println(T(id, args...))
+}
@@ -0,0 +1,95 @@
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0]*[0-9]*(\.[0-9]+)?[a-zA-Z%]`)
+
+func TestCatalogs(t *testing.T) {
+	en := catalogs[English]
+	require.NotEmpty(t, en)
+	for _, l := range Languages() {
+		msgs, ok := catalogs[l]
+		require.True(t, ok, l)
+		for id, m := range en {
+			tr, ok := msgs[id]
+			if !assert.True(t, ok, "%s: %s is missing", l, id) {
+				continue
+			}
+			want := verbRe.FindAllString(m.Other, -1)
+			assert.Equal(t, want, verbRe.FindAllString(tr.Other, -1), "%s: %s", l, id)
+			if tr.One != "" {
+				assert.Equal(t, want, verbRe.FindAllString(tr.One, -1), "%s: %s", l, id)
+			}
+		}
+		for id := range msgs {
+			assert.Contains(t, en, id, "%s: %s is not in en", l, id)
+		}
+	}
+}
+
+// Every message ID in the code has to be in the catalogs
+func TestMessageIds(t *testing.T) {
+	idRe := regexp.MustCompile(`i18n\.(?:T|N|Println|Logln)\("([^"]+)"`)
+	err := filepath.Walk("../..", func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".go") {
+			return err
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range idRe.FindAllStringSubmatch(string(bs), -1) {
+			if strings.HasSuffix(m[1], ".") {
+				continue // Built from a prefix, e.g. "pagecheck."+what
+			}
+			assert.Contains(t, catalogs[English], m[1], path)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	for tag, want := range map[string]string{
+		"zh_CN.UTF-8": SimplifiedChinese,
+		"zh-Hans":     SimplifiedChinese,
+		"zh_TW.UTF-8": TraditionalChinese,
+		"zh-HK":       TraditionalChinese,
+		"zh-Hant-CN":  TraditionalChinese,
+		"ja_JP.eucJP": Japanese,
+		"en_US":       English,
+	} {
+		got, ok := Normalize(tag)
+		assert.True(t, ok, tag)
+		assert.Equal(t, want, got, tag)
+	}
+	_, ok := Normalize("fr_FR")
+	assert.False(t, ok)
+}
+
+func TestTranslate(t *testing.T) {
+	defer func() { _ = SetLanguage("") }()
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "zh_TW.UTF-8")
+	require.NoError(t, SetLanguage(""))
+	assert.Equal(t, TraditionalChinese, Language())
+	assert.Equal(t, "第 0003 冊共 12 頁", N("image.volume_pages", 12, 3, 12))
+
+	require.NoError(t, SetLanguage("en"))
+	assert.Equal(t, "Volume 0003 has 1 page", N("image.volume_pages", 1, 3, 1))
+	assert.Equal(t, "Volume 0003 has 12 pages", N("image.volume_pages", 12, 3, 12))
+	assert.Equal(t, "no.such.id", T("no.such.id"))
+
+	assert.Error(t, SetLanguage("xx"))
+}
@@ -0,0 +1,8 @@
+//go:build !windows
+
+package i18n
+
+// systemLocale is empty, the environment already has the locale
+func systemLocale() string {
+	return ""
+}
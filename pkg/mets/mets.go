@@ -0,0 +1,336 @@
+// Package mets writes a METS document with MODS descriptive metadata for a downloaded book.
+//
+// The physical structMap lists volumes and pages in file order, the logical
+// structMap is built from catalog.txt, and every file carries a SHA-256 checksum.
+package mets
+
+import (
+	"bookget/config"
+	"bookget/pkg/catalog"
+	xhash "bookget/pkg/hash"
+	"bookget/pkg/postprocess"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the METS document written into the book directory
+const FileName = "mets.xml"
+
+// Field is one descriptive label/value pair, e.g. from IIIF manifest metadata.
+type Field struct {
+	Label string
+	Value string
+}
+
+// Book describes a downloaded book.
+type Book struct {
+	ID       string
+	Title    string
+	URL      string
+	Dir      string // Book directory; volumes are its vol.* sub-directories
+	Metadata []Field
+
+	Volumes []Volume
+	Catalog []catalog.Entry
+}
+
+// Volume is a list of page files in reading order.
+type Volume struct {
+	Label string
+	Files []File
+}
+
+// File is one saved page.
+type File struct {
+	Path     string // Relative to Book.Dir, slash separated
+	MimeType string
+	Size     int64
+	Checksum string // SHA-256, hex
+}
+
+var mimeTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".jp2":  "image/jp2",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+}
+
+// skipDirs are sub-directories that never hold pages of the book.
+var skipDirs = map[string]bool{
+	postprocess.OriginalsDir: true,
+	"ocr":                    true,
+}
+
+// Scan fills Volumes and Catalog from the files in b.Dir.
+func (b *Book) Scan() error {
+	return b.scan(true)
+}
+
+// ScanPages is Scan without the checksums, for exports that don't record them.
+func (b *Book) ScanPages() error {
+	return b.scan(false)
+}
+
+// CountPages returns the number of pages saved in a book directory.
+func CountPages(dir string) (int, error) {
+	b := &Book{Dir: dir}
+	if err := b.scan(false); err != nil {
+		return 0, err
+	}
+	return b.Pages(), nil
+}
+
+func (b *Book) scan(checksums bool) error {
+	b.Volumes = nil
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return err
+	}
+	root, err := scanFiles(b.Dir, "", checksums)
+	if err != nil {
+		return err
+	}
+	if len(root) > 0 {
+		b.Volumes = append(b.Volumes, Volume{Files: root})
+	}
+	for _, e := range entries {
+		if !e.IsDir() || skipDirs[e.Name()] || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		files, err := scanFiles(filepath.Join(b.Dir, e.Name()), e.Name(), checksums)
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			b.Volumes = append(b.Volumes, Volume{Label: e.Name(), Files: files})
+		}
+	}
+	b.Catalog, err = catalog.ReadFile(filepath.Join(b.Dir, "catalog.txt"))
+	return err
+}
+
+func scanFiles(dir, rel string, checksums bool) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for _, e := range entries {
+		mimeType, ok := mimeTypes[strings.ToLower(filepath.Ext(e.Name()))]
+		if e.IsDir() || !ok {
+			continue
+		}
+		file := File{
+			Path:     strings.TrimPrefix(rel+"/"+e.Name(), "/"),
+			MimeType: mimeType,
+		}
+		if checksums {
+			f, err := os.Open(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			sums, err := xhash.StreamTypes(f, xhash.NewHashSet(xhash.SHA256))
+			fi, _ := f.Stat()
+			_ = f.Close()
+			if err != nil {
+				return nil, err
+			}
+			file.Size, file.Checksum = fi.Size(), sums[xhash.SHA256]
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Pages returns the number of pages over all volumes.
+func (b *Book) Pages() int {
+	n := 0
+	for _, v := range b.Volumes {
+		n += len(v.Files)
+	}
+	return n
+}
+
+// Write saves the METS document as mets.xml in b.Dir, scanning the directory first
+// unless Scan has already been called.
+func Write(b *Book) (string, error) {
+	if b.Volumes == nil {
+		if err := b.Scan(); err != nil {
+			return "", err
+		}
+	}
+	if b.Pages() == 0 {
+		return "", fmt.Errorf("mets: no pages found in %s", b.Dir)
+	}
+	dest := filepath.Join(b.Dir, FileName)
+	return dest, os.WriteFile(dest, []byte(b.Encode(time.Now())), 0644)
+}
+
+// Encode returns the METS document.
+func (b *Book) Encode(created time.Time) string {
+	w := &writer{}
+	w.line(0, `<?xml version="1.0" encoding="UTF-8"?>`)
+	w.line(0, `<mets:mets xmlns:mets="http://www.loc.gov/METS/" xmlns:mods="http://www.loc.gov/mods/v3" `+
+		`xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" `+
+		`xsi:schemaLocation="http://www.loc.gov/METS/ http://www.loc.gov/standards/mets/mets.xsd `+
+		`http://www.loc.gov/mods/v3 http://www.loc.gov/standards/mods/v3/mods-3-8.xsd"`+
+		attr("OBJID", b.ID)+attr("LABEL", b.Title)+`>`)
+
+	w.line(1, `<mets:metsHdr`+attr("CREATEDATE", created.UTC().Format(time.RFC3339))+`>`)
+	w.line(2, `<mets:agent ROLE="CREATOR" TYPE="OTHER" OTHERTYPE="SOFTWARE">`)
+	w.line(3, `<mets:name>bookget `+esc(config.Version)+`</mets:name>`)
+	w.line(2, `</mets:agent>`)
+	w.line(1, `</mets:metsHdr>`)
+
+	b.writeMods(w)
+	b.writeFileSec(w)
+	b.writePhysical(w)
+	if links := b.writeLogical(w); len(links) > 0 {
+		w.line(1, `<mets:structLink>`)
+		for _, l := range links {
+			w.line(2, `<mets:smLink`+attr("xlink:from", l[0])+attr("xlink:to", l[1])+`/>`)
+		}
+		w.line(1, `</mets:structLink>`)
+	}
+	w.line(0, `</mets:mets>`)
+	return w.String()
+}
+
+func (b *Book) writeMods(w *writer) {
+	w.line(1, `<mets:dmdSec ID="DMD_0001">`)
+	w.line(2, `<mets:mdWrap MDTYPE="MODS">`)
+	w.line(3, `<mets:xmlData>`)
+	w.line(4, `<mods:mods version="3.8">`)
+	if b.Title != "" {
+		w.line(5, `<mods:titleInfo><mods:title>`+esc(b.Title)+`</mods:title></mods:titleInfo>`)
+	}
+	if b.ID != "" {
+		w.line(5, `<mods:identifier type="local">`+esc(b.ID)+`</mods:identifier>`)
+	}
+	if b.URL != "" {
+		w.line(5, `<mods:location><mods:url access="object in context">`+esc(b.URL)+`</mods:url></mods:location>`)
+	}
+	w.line(5, `<mods:physicalDescription><mods:extent unit="pages">`+fmt.Sprint(b.Pages())+`</mods:extent>`+
+		`<mods:digitalOrigin>reformatted digital</mods:digitalOrigin></mods:physicalDescription>`)
+	for _, f := range b.Metadata {
+		if f.Value == "" {
+			continue
+		}
+		w.line(5, `<mods:note`+attr("displayLabel", f.Label)+`>`+esc(f.Value)+`</mods:note>`)
+	}
+	w.line(4, `</mods:mods>`)
+	w.line(3, `</mets:xmlData>`)
+	w.line(2, `</mets:mdWrap>`)
+	w.line(1, `</mets:dmdSec>`)
+}
+
+func (b *Book) writeFileSec(w *writer) {
+	w.line(1, `<mets:fileSec>`)
+	w.line(2, `<mets:fileGrp USE="MASTER">`)
+	n := 0
+	for _, v := range b.Volumes {
+		for _, f := range v.Files {
+			n++
+			w.line(3, `<mets:file`+attr("ID", fileId(n))+attr("MIMETYPE", f.MimeType)+
+				attr("SIZE", fmt.Sprint(f.Size))+attr("CHECKSUM", f.Checksum)+` CHECKSUMTYPE="SHA-256">`)
+			w.line(4, `<mets:FLocat LOCTYPE="URL"`+attr("xlink:href", f.Path)+`/>`)
+			w.line(3, `</mets:file>`)
+		}
+	}
+	w.line(2, `</mets:fileGrp>`)
+	w.line(1, `</mets:fileSec>`)
+}
+
+func (b *Book) writePhysical(w *writer) {
+	w.line(1, `<mets:structMap TYPE="PHYSICAL">`)
+	w.line(2, `<mets:div ID="PHYS_0000" TYPE="physSequence" DMDID="DMD_0001"`+attr("LABEL", b.Title)+`>`)
+	n := 0
+	for k, v := range b.Volumes {
+		depth := 3
+		if len(b.Volumes) > 1 {
+			w.line(3, `<mets:div TYPE="volume"`+attr("ORDER", fmt.Sprint(k+1))+attr("LABEL", v.Label)+`>`)
+			depth = 4
+		}
+		for range v.Files {
+			n++
+			w.line(depth, `<mets:div`+attr("ID", physId(n))+` TYPE="page"`+attr("ORDER", fmt.Sprint(n))+`>`+
+				`<mets:fptr`+attr("FILEID", fileId(n))+`/></mets:div>`)
+		}
+		if len(b.Volumes) > 1 {
+			w.line(3, `</mets:div>`)
+		}
+	}
+	w.line(2, `</mets:div>`)
+	w.line(1, `</mets:structMap>`)
+}
+
+// writeLogical writes catalog.txt as the logical structMap and returns the
+// [logical, physical] pairs for structLink.
+func (b *Book) writeLogical(w *writer) (links [][2]string) {
+	if len(b.Catalog) == 0 {
+		return nil
+	}
+	pages := b.Pages()
+	w.line(1, `<mets:structMap TYPE="LOGICAL">`)
+	w.line(2, `<mets:div ID="LOG_0000" TYPE="monograph" DMDID="DMD_0001"`+attr("LABEL", b.Title)+`>`)
+	depth := 0
+	for k, e := range b.Catalog {
+		level := min(e.Level, depth)
+		for ; depth > level; depth-- {
+			w.line(3+depth-1, `</mets:div>`)
+		}
+		id := fmt.Sprintf("LOG_%04d", k+1)
+		w.line(3+depth, `<mets:div`+attr("ID", id)+` TYPE="section"`+attr("LABEL", e.Title)+`>`)
+		depth++
+		if e.Page > 0 && e.Page <= pages {
+			links = append(links, [2]string{id, physId(e.Page)})
+		}
+		// A leaf closes right away unless the next entry is nested below it.
+		if k+1 == len(b.Catalog) || b.Catalog[k+1].Level < depth {
+			depth--
+			w.line(3+depth, `</mets:div>`)
+		}
+	}
+	for ; depth > 0; depth-- {
+		w.line(3+depth-1, `</mets:div>`)
+	}
+	w.line(2, `</mets:div>`)
+	w.line(1, `</mets:structMap>`)
+	return links
+}
+
+func fileId(n int) string { return fmt.Sprintf("FILE_%04d", n) }
+func physId(n int) string { return fmt.Sprintf("PHYS_%04d", n) }
+
+type writer struct {
+	strings.Builder
+}
+
+func (w *writer) line(depth int, s string) {
+	w.WriteString(strings.Repeat("  ", depth))
+	w.WriteString(s)
+	w.WriteByte('\n')
+}
+
+func attr(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	return " " + name + `="` + esc(value) + `"`
+}
+
+var escaper = strings.NewReplacer(`&`, "&amp;", `<`, "&lt;", `>`, "&gt;", `"`, "&quot;", "\r", "&#xD;", "\n", "&#xA;", "\t", "&#x9;")
+
+func esc(s string) string {
+	return escaper.Replace(s)
+}
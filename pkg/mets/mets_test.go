@@ -0,0 +1,55 @@
+package mets
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"vol.0001/0001.jpg", "vol.0001/0002.jpg", "vol.0002/0001.jpg", "original/0001.jpg"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	}
+	catalogTxt := "#版本=1.0\n卷一 ………… 1\n\t序 ………… 2\n卷二 ………… 3\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "catalog.txt"), []byte(catalogTxt), 0644))
+
+	book := &Book{ID: "b1", Title: "Tom & Jerry", Dir: dir, Metadata: []Field{{Label: "Date", Value: "1850"}}}
+	dest, err := Write(book)
+	require.NoError(t, err)
+	assert.Equal(t, 3, book.Pages())
+	assert.Len(t, book.Volumes[0].Files[0].Checksum, 64)
+
+	// cbz and epub don't need the checksums
+	pages := &Book{Dir: dir}
+	require.NoError(t, pages.ScanPages())
+	assert.Equal(t, 3, pages.Pages())
+	assert.Empty(t, pages.Volumes[0].Files[0].Checksum)
+
+	bs, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	doc := string(bs)
+	assert.Contains(t, doc, `LABEL="Tom &amp; Jerry"`)
+	assert.Contains(t, doc, `xlink:href="vol.0002/0001.jpg"`)
+	assert.NotContains(t, doc, "original/")
+	assert.Contains(t, doc, `<mets:smLink xlink:from="LOG_0002" xlink:to="PHYS_0002"/>`)
+	assert.Contains(t, doc, `<mods:note displayLabel="Date">1850</mods:note>`)
+
+	// Well-formed, with the nested logical divs closed in the right places.
+	dec := xml.NewDecoder(strings.NewReader(book.Encode(time.Time{})))
+	for {
+		_, err = dec.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+}
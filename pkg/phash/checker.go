@@ -0,0 +1,210 @@
+package phash
+
+import (
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultDistance    = 5   // Maximum Hamming distance for "near-identical"
+	defaultBlankStdDev = 2.0 // Luminance deviation below which a page counts as blank
+)
+
+// Verdict is the outcome of a page check.
+type Verdict int
+
+const (
+	OK Verdict = iota
+	Placeholder
+	Blank
+	Duplicate
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Placeholder:
+		return "placeholder"
+	case Blank:
+		return "blank"
+	case Duplicate:
+		return "duplicate"
+	}
+	return "ok"
+}
+
+// Action tells the checker what to do with flagged pages.
+type Action string
+
+const (
+	ActionOff    Action = "off"
+	ActionFlag   Action = "flag"
+	ActionRetry  Action = "retry"
+	ActionDelete Action = "delete"
+)
+
+// Result describes a checked page.
+type Result struct {
+	Verdict     Verdict
+	Fingerprint Fingerprint
+	Neighbour   int // Index of the duplicated page
+}
+
+type placeholder struct {
+	fp   Fingerprint
+	hasA bool
+}
+
+// Checker flags pages of one book that are near-identical to a known placeholder,
+// blank, or a copy of the page before or after them.
+type Checker struct {
+	action       Action
+	placeholders []placeholder
+	distance     int
+	blankStdDev  float64
+	retries      int
+
+	mu    sync.Mutex
+	pages map[int]Fingerprint
+}
+
+// ErrUnsupported is returned for image formats that cannot be decoded.
+var ErrUnsupported = errors.New("unsupported image format")
+
+// NewChecker builds a checker from --page-check and the config.ini settings of host.
+func NewChecker(host string) *Checker {
+	c := &Checker{
+		action:      Action(config.Conf.PageCheck),
+		distance:    defaultDistance,
+		blankStdDev: defaultBlankStdDev,
+		retries:     config.Conf.Retries,
+		pages:       make(map[int]Fingerprint),
+	}
+	if v := config.SiteValue(host, "page_check"); v != "" {
+		c.action = Action(v)
+	}
+	if c.action == "" {
+		c.action = ActionOff
+	}
+	if v, err := strconv.Atoi(config.SiteValue(host, "placeholder_distance")); err == nil {
+		c.distance = v
+	}
+	if v, err := strconv.ParseFloat(config.SiteValue(host, "blank_stddev"), 64); err == nil {
+		c.blankStdDev = v
+	}
+	for _, s := range config.SiteValues(host, "placeholder") {
+		fp, hasA, err := ParseFingerprint(s)
+		if err != nil {
+			i18n.Logln("pagecheck.failed", err)
+			continue
+		}
+		c.placeholders = append(c.placeholders, placeholder{fp: fp, hasA: hasA})
+	}
+	return c
+}
+
+// Enabled reports whether pages should be checked at all.
+func (c *Checker) Enabled() bool {
+	return c != nil && c.action != ActionOff
+}
+
+// CheckImage classifies the page at index.
+func (c *Checker) CheckImage(index int, img image.Image) Result {
+	fp := Hash(img)
+	r := Result{Verdict: OK, Fingerprint: fp, Neighbour: -1}
+
+	for _, p := range c.placeholders {
+		if Distance(fp.D, p.fp.D) <= c.distance && (!p.hasA || Distance(fp.A, p.fp.A) <= c.distance) {
+			r.Verdict = Placeholder
+			return r
+		}
+	}
+	if StdDev(img) < c.blankStdDev {
+		r.Verdict = Blank
+		return r
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pages[index] = fp
+	for _, n := range []int{index - 1, index + 1} {
+		prev, ok := c.pages[n]
+		if ok && Distance(fp.A, prev.A) <= c.distance && Distance(fp.D, prev.D) <= c.distance {
+			r.Verdict = Duplicate
+			r.Neighbour = n
+			break
+		}
+	}
+	return r
+}
+
+// CheckFile decodes and classifies a saved page.
+func (c *Checker) CheckFile(index int, path string) (Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Result{}, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		if errors.Is(err, image.ErrFormat) {
+			return Result{}, ErrUnsupported
+		}
+		return Result{}, err
+	}
+	return c.CheckImage(index, img), nil
+}
+
+// Handle checks the page saved at path and applies the configured action.
+// redo downloads the page again to the same path; it is only used by the retry action.
+// Handle returns false when the page was deleted.
+func (c *Checker) Handle(index int, path string, redo func() error) bool {
+	if !c.Enabled() {
+		return true
+	}
+	r, err := c.CheckFile(index, path)
+	if err != nil {
+		return true
+	}
+	if c.action == ActionRetry && redo != nil && r.Verdict != Duplicate {
+		for i := 0; i < c.retries && r.Verdict != OK; i++ {
+			c.report(path, r, "retrying")
+			_ = os.Remove(path)
+			if err = redo(); err != nil {
+				break
+			}
+			if r, err = c.CheckFile(index, path); err != nil {
+				return true
+			}
+		}
+	}
+	if r.Verdict == OK {
+		return true
+	}
+	if c.action == ActionDelete {
+		c.report(path, r, "deleted")
+		_ = os.Remove(path)
+		// Pages after it are compared with the pages kept
+		c.mu.Lock()
+		delete(c.pages, index)
+		c.mu.Unlock()
+		return false
+	}
+	c.report(path, r, "flagged")
+	return true
+}
+
+func (c *Checker) report(path string, r Result, what string) {
+	detail := i18n.T("pagecheck." + r.Verdict.String())
+	if r.Verdict == Duplicate {
+		detail = i18n.T("pagecheck.duplicate_of", r.Neighbour+1)
+	}
+	i18n.Logln("pagecheck.report", path, i18n.T("pagecheck."+what), detail, r.Fingerprint)
+}
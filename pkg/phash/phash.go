@@ -0,0 +1,154 @@
+// Package phash computes perceptual hashes of page images, so that placeholder,
+// blank and duplicated pages can be recognised after download.
+package phash
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Fingerprint holds the average hash and difference hash of an image.
+type Fingerprint struct {
+	A uint64 // aHash: 8x8 luminance compared with the mean
+	D uint64 // dHash: 9x8 luminance, each pixel compared with its right neighbour
+}
+
+// String returns the fingerprint as 32 hex digits, aHash first.
+func (f Fingerprint) String() string {
+	return fmt.Sprintf("%016x%016x", f.A, f.D)
+}
+
+// ParseFingerprint parses the output of Fingerprint.String.
+// 16 hex digits are read as a dHash alone.
+func ParseFingerprint(s string) (f Fingerprint, hasA bool, err error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	switch len(s) {
+	case 32:
+		if f.A, err = strconv.ParseUint(s[:16], 16, 64); err != nil {
+			return f, false, err
+		}
+		f.D, err = strconv.ParseUint(s[16:], 16, 64)
+		return f, true, err
+	case 16:
+		f.D, err = strconv.ParseUint(s, 16, 64)
+		return f, false, err
+	default:
+		return f, false, fmt.Errorf("invalid fingerprint %q", s)
+	}
+}
+
+// Distance returns the Hamming distance between two hashes.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Hash computes the fingerprint of img.
+func Hash(img image.Image) Fingerprint {
+	return Fingerprint{A: AHash(img), D: DHash(img)}
+}
+
+// AHash returns the average hash of img.
+func AHash(img image.Image) uint64 {
+	px := thumbnail(img, 8, 8)
+	var sum float64
+	for _, v := range px {
+		sum += v
+	}
+	mean := sum / float64(len(px))
+
+	var h uint64
+	for i, v := range px {
+		if v > mean {
+			h |= 1 << uint(63-i)
+		}
+	}
+	return h
+}
+
+// DHash returns the difference hash of img.
+func DHash(img image.Image) uint64 {
+	px := thumbnail(img, 9, 8)
+	var h uint64
+	i := 0
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if px[y*9+x] < px[y*9+x+1] {
+				h |= 1 << uint(63-i)
+			}
+			i++
+		}
+	}
+	return h
+}
+
+// StdDev returns the standard deviation of the luminance of img on a 32x32 grid.
+// Uniform tiles and empty pages score close to 0.
+func StdDev(img image.Image) float64 {
+	px := thumbnail(img, 32, 32)
+	var sum float64
+	for _, v := range px {
+		sum += v
+	}
+	mean := sum / float64(len(px))
+	var variance float64
+	for _, v := range px {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(px)))
+}
+
+// thumbnail shrinks img to w*h luminance values (0-255) by averaging sampled pixels.
+func thumbnail(img image.Image, w, h int) []float64 {
+	b := img.Bounds()
+	out := make([]float64, w*h)
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return out
+	}
+
+	// Sample at most ~16x16 points per cell, enough for a stable average on large scans.
+	const samples = 16
+	for cy := 0; cy < h; cy++ {
+		y0 := b.Min.Y + cy*b.Dy()/h
+		y1 := b.Min.Y + (cy+1)*b.Dy()/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		stepY := max((y1-y0)/samples, 1)
+		for cx := 0; cx < w; cx++ {
+			x0 := b.Min.X + cx*b.Dx()/w
+			x1 := b.Min.X + (cx+1)*b.Dx()/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			stepX := max((x1-x0)/samples, 1)
+
+			var sum float64
+			var n int
+			for y := y0; y < y1 && y < b.Max.Y; y += stepY {
+				for x := x0; x < x1 && x < b.Max.X; x += stepX {
+					sum += luminance(img, x, y)
+					n++
+				}
+			}
+			if n > 0 {
+				out[cy*w+cx] = sum / float64(n)
+			}
+		}
+	}
+	return out
+}
+
+func luminance(img image.Image, x, y int) float64 {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return float64(m.Y[m.YOffset(x, y)])
+	case *image.Gray:
+		return float64(m.GrayAt(x, y).Y)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 257
+}
@@ -0,0 +1,96 @@
+package phash
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func uniform(w, h int, c uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = c
+	}
+	return img
+}
+
+func gradient(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestFingerprintRoundTrip(t *testing.T) {
+	fp := Hash(gradient(200, 300))
+	parsed, hasA, err := ParseFingerprint(fp.String())
+	require.NoError(t, err)
+	assert.True(t, hasA)
+	assert.Equal(t, fp, parsed)
+
+	parsed, hasA, err = ParseFingerprint("0x00000000000000ff")
+	require.NoError(t, err)
+	assert.False(t, hasA)
+	assert.Equal(t, uint64(0xff), parsed.D)
+
+	_, _, err = ParseFingerprint("xyz")
+	assert.Error(t, err)
+}
+
+func TestDHashGradient(t *testing.T) {
+	// Luminance increases left to right, so every comparison sets a bit.
+	assert.Equal(t, uint64(0xffffffffffffffff), DHash(gradient(900, 800)))
+	assert.Equal(t, uint64(0), DHash(uniform(900, 800, 128)))
+}
+
+func TestScaledCopyIsNearIdentical(t *testing.T) {
+	a := Hash(gradient(1200, 1600))
+	b := Hash(gradient(300, 400))
+	assert.LessOrEqual(t, Distance(a.A, b.A), defaultDistance)
+	assert.LessOrEqual(t, Distance(a.D, b.D), defaultDistance)
+}
+
+func TestCheckerVerdicts(t *testing.T) {
+	c := &Checker{action: ActionFlag, distance: defaultDistance, blankStdDev: defaultBlankStdDev, pages: map[int]Fingerprint{}}
+
+	assert.Equal(t, Blank, c.CheckImage(0, uniform(100, 100, 200)).Verdict)
+	assert.Equal(t, OK, c.CheckImage(1, gradient(100, 100)).Verdict)
+
+	r := c.CheckImage(2, gradient(100, 100))
+	assert.Equal(t, Duplicate, r.Verdict)
+	assert.Equal(t, 1, r.Neighbour)
+
+	c.placeholders = []placeholder{{fp: r.Fingerprint, hasA: true}}
+	assert.Equal(t, Placeholder, c.CheckImage(5, gradient(400, 400)).Verdict)
+}
+
+func TestHandleDeleteForgetsPage(t *testing.T) {
+	c := &Checker{action: ActionDelete, distance: defaultDistance, blankStdDev: defaultBlankStdDev, pages: map[int]Fingerprint{}}
+	dir := t.TempDir()
+	save := func(name string) string {
+		p := filepath.Join(dir, name)
+		f, err := os.Create(p)
+		require.NoError(t, err)
+		require.NoError(t, png.Encode(f, gradient(100, 100)))
+		require.NoError(t, f.Close())
+		return p
+	}
+
+	assert.True(t, c.Handle(1, save("0001.png"), nil))
+	// A copy of page 1 is deleted
+	p := save("0002.png")
+	assert.False(t, c.Handle(2, p, nil))
+	assert.NoFileExists(t, p)
+	// Page 3 is not compared with the deleted page 2
+	assert.True(t, c.Handle(3, save("0003.png"), nil))
+}
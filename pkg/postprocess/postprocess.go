@@ -0,0 +1,226 @@
+// Package postprocess runs a configurable pipeline of image steps on every page
+// after it has been saved: split-spread, autocrop, rotate, grayscale and resize-max.
+package postprocess
+
+import (
+	"bookget/config"
+	"bookget/pkg/i18n"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OriginalsDir is the sub-directory that keeps unprocessed pages when --keep-originals is set
+const OriginalsDir = "original"
+
+// Step transforms one page into one or more pages, in reading order.
+type Step interface {
+	Apply(img image.Image) []image.Image
+}
+
+// Pipeline is an ordered list of steps.
+type Pipeline struct {
+	Spec          string
+	Steps         []Step
+	KeepOriginals bool
+	quality       int
+}
+
+// New builds the pipeline from --post-process, or from the post_process key of host in config.ini.
+func New(host string) *Pipeline {
+	spec := config.Conf.PostProcess
+	if v := config.SiteValue(host, "post_process"); v != "" {
+		spec = v
+	}
+	p, err := Parse(spec)
+	if err != nil {
+		i18n.Logln("postprocess.bad_spec", err)
+		return &Pipeline{}
+	}
+	p.KeepOriginals = config.Conf.KeepOriginals
+	p.quality = config.Conf.Quality
+	return p
+}
+
+// Parse reads a comma separated list of steps, e.g. "split-spread:rtl,autocrop,resize-max:3000".
+func Parse(spec string) (*Pipeline, error) {
+	p := &Pipeline{Spec: spec}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(item, ":")
+		var step Step
+		switch strings.ToLower(name) {
+		case "split-spread":
+			switch strings.ToLower(arg) {
+			case "", "rtl":
+				step = SplitSpread{RightToLeft: true}
+			case "ltr":
+				step = SplitSpread{RightToLeft: false}
+			default:
+				return nil, fmt.Errorf("split-spread: unknown order %q, want rtl or ltr", arg)
+			}
+		case "autocrop":
+			c := AutoCrop{Tolerance: defaultCropTolerance}
+			if arg != "" {
+				v, err := strconv.Atoi(arg)
+				if err != nil {
+					return nil, fmt.Errorf("autocrop: invalid tolerance %q", arg)
+				}
+				c.Tolerance = v
+			}
+			step = c
+		case "rotate":
+			v, err := strconv.Atoi(arg)
+			if err != nil || v%90 != 0 {
+				return nil, fmt.Errorf("rotate: angle must be a multiple of 90, got %q", arg)
+			}
+			step = Rotate{Degrees: ((v % 360) + 360) % 360}
+		case "grayscale", "greyscale":
+			step = Grayscale{}
+		case "resize-max":
+			v, err := strconv.Atoi(arg)
+			if err != nil || v <= 0 {
+				return nil, fmt.Errorf("resize-max: invalid size %q", arg)
+			}
+			step = ResizeMax{Size: v}
+		default:
+			return nil, fmt.Errorf("unknown step %q", name)
+		}
+		p.Steps = append(p.Steps, step)
+	}
+	return p, nil
+}
+
+// Enabled reports whether the pipeline has any steps.
+func (p *Pipeline) Enabled() bool {
+	return p != nil && len(p.Steps) > 0
+}
+
+// Apply processes the page saved at path. A page that becomes several pages is
+// written as name_1.ext, name_2.ext … in reading order, replacing the original.
+func (p *Pipeline) Apply(path string) ([]string, error) {
+	if !p.Enabled() {
+		return []string{path}, nil
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return []string{path}, nil
+	}
+
+	img, err := decode(path)
+	if err != nil {
+		return nil, err
+	}
+	pages := []image.Image{img}
+	for _, step := range p.Steps {
+		var next []image.Image
+		for _, page := range pages {
+			next = append(next, step.Apply(page)...)
+		}
+		pages = next
+	}
+
+	if p.KeepOriginals {
+		if err = p.keepOriginal(path); err != nil {
+			return nil, err
+		}
+	}
+	if len(pages) == 1 {
+		return []string{path}, p.encode(pages[0], path)
+	}
+	outputs := SplitNames(path, len(pages))
+	for k, page := range pages {
+		if err = p.encode(page, outputs[k]); err != nil {
+			return nil, err
+		}
+	}
+	// The spread is in original/ already when kept
+	_ = os.Remove(path)
+	return outputs, nil
+}
+
+// SplitNames returns the file names a page at path is split into.
+func SplitNames(path string, n int) []string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	names := make([]string, n)
+	for k := range names {
+		names[k] = fmt.Sprintf("%s_%d%s", base, k+1, ext)
+	}
+	return names
+}
+
+// Processed reports whether the page at path was already split by a previous run.
+func Processed(path string) bool {
+	fi, err := os.Stat(SplitNames(path, 1)[0])
+	return err == nil && fi.Size() > 0
+}
+
+func (p *Pipeline) keepOriginal(path string) error {
+	dir := filepath.Join(filepath.Dir(path), OriginalsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, filepath.Base(path)), bs, 0644)
+}
+
+func decode(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+func (p *Pipeline) encode(img image.Image, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if strings.ToLower(filepath.Ext(path)) == ".png" {
+		return png.Encode(f, img)
+	}
+	quality := p.quality
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+	return jpeg.Encode(f, img, &jpeg.Options{Quality: quality})
+}
+
+// toEditable returns img as *image.Gray or *image.RGBA, copying only when needed.
+func toEditable(img image.Image) image.Image {
+	switch img.(type) {
+	case *image.Gray, *image.RGBA:
+		return img
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+	return dst
+}
+
+func crop(img image.Image, r image.Rectangle) image.Image {
+	img = toEditable(img)
+	if s, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r.Add(img.Bounds().Min))
+	}
+	return img
+}
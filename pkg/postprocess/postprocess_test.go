@@ -0,0 +1,91 @@
+package postprocess
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse("split-spread:ltr, autocrop:30,rotate:-90,grayscale,resize-max:3000")
+	require.NoError(t, err)
+	assert.Equal(t, []Step{
+		SplitSpread{RightToLeft: false},
+		AutoCrop{Tolerance: 30},
+		Rotate{Degrees: 270},
+		Grayscale{},
+		ResizeMax{Size: 3000},
+	}, p.Steps)
+
+	p, err = Parse("")
+	require.NoError(t, err)
+	assert.False(t, p.Enabled())
+
+	for _, spec := range []string{"rotate:45", "resize-max:0", "split-spread:up", "sharpen"} {
+		_, err = Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestSplitSpreadReadingOrder(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 100))
+	for y := 0; y < 100; y++ {
+		for x := 100; x < 200; x++ {
+			img.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	pages := SplitSpread{RightToLeft: true}.Apply(img)
+	require.Len(t, pages, 2)
+	assert.Equal(t, 100, pages[0].Bounds().Dx())
+	r, _, _, _ := pages[0].At(pages[0].Bounds().Min.X, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r, "right half first")
+
+	portrait := image.NewGray(image.Rect(0, 0, 100, 200))
+	assert.Len(t, SplitSpread{RightToLeft: true}.Apply(portrait), 1)
+	assert.Equal(t, []string{"a/0005_1.jpg", "a/0005_2.jpg"}, SplitNames("a/0005.jpg", 2))
+}
+
+func TestAutoCropAndRotate(t *testing.T) {
+	// Black scanner border around a white page with a dark block of text.
+	img := image.NewGray(image.Rect(0, 0, 300, 400))
+	for y := 40; y < 360; y++ {
+		for x := 30; x < 270; x++ {
+			img.SetGray(x, y, color.Gray{Y: 250})
+		}
+	}
+	cropped := AutoCrop{Tolerance: defaultCropTolerance}.Apply(img)[0]
+	assert.Equal(t, image.Rect(30, 40, 270, 360), cropped.Bounds())
+
+	rotated := Rotate{Degrees: 90}.Apply(cropped)[0]
+	assert.Equal(t, 320, rotated.Bounds().Dx())
+	assert.Equal(t, 240, rotated.Bounds().Dy())
+
+	resized := ResizeMax{Size: 160}.Apply(rotated)[0]
+	assert.Equal(t, image.Rect(0, 0, 160, 120), resized.Bounds())
+}
+
+func TestApplySplitKeepsOriginal(t *testing.T) {
+	dir := t.TempDir()
+	spread := filepath.Join(dir, "0005.png")
+	f, err := os.Create(spread)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 200, 100))))
+	require.NoError(t, f.Close())
+
+	p, err := Parse("split-spread:ltr")
+	require.NoError(t, err)
+	p.KeepOriginals = true
+	outputs, err := p.Apply(spread)
+	require.NoError(t, err)
+	assert.Equal(t, SplitNames(spread, 2), outputs)
+
+	// Only the halves are pages of the book, the spread is in original/
+	assert.NoFileExists(t, spread)
+	assert.FileExists(t, filepath.Join(dir, OriginalsDir, "0005.png"))
+}
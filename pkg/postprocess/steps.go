@@ -0,0 +1,213 @@
+package postprocess
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+const (
+	defaultCropTolerance = 48   // Luminance difference from the border colour that counts as content
+	minContentRatio      = 0.02 // Share of content pixels for a row/column to be kept
+	spreadRatio          = 1.1  // Pages wider than this ratio are treated as two-page spreads
+)
+
+// SplitSpread cuts a landscape two-page spread into two pages.
+// East Asian books read right to left, so the right half comes first.
+type SplitSpread struct {
+	RightToLeft bool
+}
+
+func (s SplitSpread) Apply(img image.Image) []image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if float64(w) < float64(h)*spreadRatio {
+		return []image.Image{img}
+	}
+	left := crop(img, image.Rect(0, 0, w/2, h))
+	right := crop(img, image.Rect(w/2, 0, w, h))
+	if s.RightToLeft {
+		return []image.Image{right, left}
+	}
+	return []image.Image{left, right}
+}
+
+// AutoCrop trims scanner borders: rows and columns at the edges that hardly differ
+// from the colour found in the corners.
+type AutoCrop struct {
+	Tolerance int
+}
+
+func (c AutoCrop) Apply(img image.Image) []image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w < 16 || h < 16 {
+		return []image.Image{img}
+	}
+	bg := (lum(img, b.Min.X, b.Min.Y) + lum(img, b.Max.X-1, b.Min.Y) +
+		lum(img, b.Min.X, b.Max.Y-1) + lum(img, b.Max.X-1, b.Max.Y-1)) / 4
+
+	isContent := func(x, y int) bool {
+		d := lum(img, b.Min.X+x, b.Min.Y+y) - bg
+		return d > c.Tolerance || d < -c.Tolerance
+	}
+	stepX, stepY := max(w/400, 1), max(h/400, 1)
+	rowHasContent := func(y int) bool {
+		n, total := 0, 0
+		for x := 0; x < w; x += stepX {
+			if isContent(x, y) {
+				n++
+			}
+			total++
+		}
+		return float64(n) > float64(total)*minContentRatio
+	}
+	colHasContent := func(x int) bool {
+		n, total := 0, 0
+		for y := 0; y < h; y += stepY {
+			if isContent(x, y) {
+				n++
+			}
+			total++
+		}
+		return float64(n) > float64(total)*minContentRatio
+	}
+
+	top, bottom, left, right := 0, h-1, 0, w-1
+	for top < bottom && !rowHasContent(top) {
+		top++
+	}
+	for bottom > top && !rowHasContent(bottom) {
+		bottom--
+	}
+	for left < right && !colHasContent(left) {
+		left++
+	}
+	for right > left && !colHasContent(right) {
+		right--
+	}
+	// Nothing found, or almost everything would be cut: leave the page alone.
+	if right-left < w/4 || bottom-top < h/4 {
+		return []image.Image{img}
+	}
+	return []image.Image{crop(img, image.Rect(left, top, right+1, bottom+1))}
+}
+
+// Rotate turns the page clockwise by a multiple of 90 degrees.
+type Rotate struct {
+	Degrees int
+}
+
+func (r Rotate) Apply(img image.Image) []image.Image {
+	if r.Degrees == 0 {
+		return []image.Image{img}
+	}
+	src := toEditable(img)
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := h, w
+	if r.Degrees == 180 {
+		dw, dh = w, h
+	}
+	target := func(x, y int) (int, int) {
+		switch r.Degrees {
+		case 90:
+			return h - 1 - y, x
+		case 180:
+			return w - 1 - x, h - 1 - y
+		default: // 270
+			return y, w - 1 - x
+		}
+	}
+
+	switch s := src.(type) {
+	case *image.Gray:
+		dst := image.NewGray(image.Rect(0, 0, dw, dh))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				tx, ty := target(x, y)
+				dst.Pix[ty*dst.Stride+tx] = s.Pix[s.PixOffset(b.Min.X+x, b.Min.Y+y)]
+			}
+		}
+		return []image.Image{dst}
+	case *image.RGBA:
+		dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				tx, ty := target(x, y)
+				i := s.PixOffset(b.Min.X+x, b.Min.Y+y)
+				copy(dst.Pix[ty*dst.Stride+tx*4:ty*dst.Stride+tx*4+4], s.Pix[i:i+4])
+			}
+		}
+		return []image.Image{dst}
+	}
+	return []image.Image{img}
+}
+
+// Grayscale converts the page to 8-bit gray.
+type Grayscale struct{}
+
+func (Grayscale) Apply(img image.Image) []image.Image {
+	if _, ok := img.(*image.Gray); ok {
+		return []image.Image{img}
+	}
+	b := img.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return []image.Image{dst}
+}
+
+// ResizeMax shrinks the page so that its longest side is at most Size pixels.
+type ResizeMax struct {
+	Size int
+}
+
+func (r ResizeMax) Apply(img image.Image) []image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= r.Size && h <= r.Size {
+		return []image.Image{img}
+	}
+	dw, dh := r.Size, h*r.Size/w
+	if h > w {
+		dw, dh = w*r.Size/h, r.Size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	// Box filter: every target pixel is the average of the source pixels it covers.
+	_, gray := img.(*image.Gray)
+	var dst draw.Image
+	if gray {
+		dst = image.NewGray(image.Rect(0, 0, dw, dh))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, dw, dh))
+	}
+	for ty := 0; ty < dh; ty++ {
+		y0, y1 := ty*h/dh, max((ty+1)*h/dh, ty*h/dh+1)
+		for tx := 0; tx < dw; tx++ {
+			x0, x1 := tx*w/dw, max((tx+1)*w/dw, tx*w/dw+1)
+			var sr, sg, sb, sa, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+					sr, sg, sb, sa = sr+uint64(cr), sg+uint64(cg), sb+uint64(cb), sa+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(tx, ty, color.RGBA64{R: uint16(sr / n), G: uint16(sg / n), B: uint16(sb / n), A: uint16(sa / n)})
+		}
+	}
+	return []image.Image{dst}
+}
+
+// lum returns the 0-255 luminance of a pixel.
+func lum(img image.Image, x, y int) int {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return int(m.Y[m.YOffset(x, y)])
+	case *image.Gray:
+		return int(m.GrayAt(x, y).Y)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	return int((299*r + 587*g + 114*b) / 1000 >> 8)
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// HathiTrust searches the full-view volumes of HathiTrust. The full-text
+// search only answers HTML, so its volume IDs are looked up in the
+// Bibliographic API for titles and dates.
+type HathiTrust struct {
+	Endpoint    string // Full-text search
+	BibEndpoint string // Bibliographic API, brief records
+}
+
+func NewHathiTrust() *HathiTrust {
+	return &HathiTrust{
+		Endpoint:    "https://babel.hathitrust.org/cgi/ls",
+		BibEndpoint: "https://catalog.hathitrust.org/api/volumes/brief/json/",
+	}
+}
+
+func (s *HathiTrust) Name() string {
+	return "HathiTrust"
+}
+
+// hathiID is the volume of a pt?id= link, e.g. uc1.b3656924 or mdp.39015012345678
+var hathiID = regexp.MustCompile(`pt\?id=([a-z0-9]+\.[^"'&;#\s<>]+)`)
+
+type hathiBrief map[string]struct {
+	Records map[string]struct {
+		Titles       []string `json:"titles"`
+		PublishDates []string `json:"publishDates"`
+	} `json:"records"`
+	Items []struct {
+		Orig       string `json:"orig"`
+		HtID       string `json:"htid"`
+		FromRecord string `json:"fromRecord"`
+		EnumCron   any    `json:"enumcron"` // false or a string such as v.2
+	} `json:"items"`
+}
+
+func (s *HathiTrust) Search(ctx context.Context, q Query) ([]Hit, error) {
+	v := url.Values{}
+	v.Set("q1", q.Text)
+	v.Set("anyall1", "all")
+	v.Set("lmt", "ft") // Full view only
+	v.Set("a", "srchls")
+	v.Set("pn", "1")
+	v.Set("sz", fmt.Sprint(limit(q)))
+	body, err := get(ctx, s.Endpoint+"?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	seen := map[string]bool{}
+	for _, m := range hathiID.FindAllStringSubmatch(string(body), -1) {
+		id, _ := url.QueryUnescape(m[1])
+		if !seen[id] && len(ids) < limit(q) {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var hits []Hit
+	// The Bibliographic API takes 20 volumes at a time
+	for start := 0; start < len(ids); start += 20 {
+		batch := ids[start:min(start+20, len(ids))]
+		keys := make([]string, len(batch))
+		for i, id := range batch {
+			keys[i] = "htid:" + id
+		}
+		var brief hathiBrief
+		if err := getJSON(ctx, s.BibEndpoint+strings.Join(keys, "|"), &brief); err != nil {
+			return hits, err
+		}
+		for _, id := range batch {
+			hits = append(hits, brief.hit(id))
+		}
+	}
+	return hits, nil
+}
+
+func (b hathiBrief) hit(id string) Hit {
+	hit := Hit{Title: id, URL: "https://babel.hathitrust.org/cgi/pt?id=" + id}
+	entry, ok := b["htid:"+id]
+	if !ok {
+		return hit
+	}
+	for _, item := range entry.Items {
+		if item.HtID != id {
+			continue
+		}
+		if rec, ok := entry.Records[item.FromRecord]; ok {
+			if len(rec.Titles) > 0 {
+				hit.Title = rec.Titles[0]
+			}
+			if len(rec.PublishDates) > 0 {
+				hit.Date = rec.PublishDates[0]
+			}
+		}
+		if enum, ok := item.EnumCron.(string); ok && enum != "" {
+			hit.Title += " " + enum
+		}
+		if item.Orig != "" {
+			hit.Institution = "HathiTrust (" + item.Orig + ")"
+		}
+	}
+	return hit
+}
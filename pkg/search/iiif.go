@@ -0,0 +1,178 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// IIIF looks for text inside one book with the IIIF Content Search service
+// (API 0.9, 1 or 2) of its manifest. Each hit is a page of that book.
+type IIIF struct{}
+
+func NewIIIF() *IIIF {
+	return &IIIF{}
+}
+
+func (s *IIIF) Name() string {
+	return "IIIF Content Search"
+}
+
+// iiifManifest reads IIIF Presentation 2 and 3 manifests
+type iiifManifest struct {
+	Label             json.RawMessage `json:"label"`
+	Attribution       json.RawMessage `json:"attribution"`
+	RequiredStatement struct {
+		Value json.RawMessage `json:"value"`
+	} `json:"requiredStatement"`
+	Provider []struct {
+		Label json.RawMessage `json:"label"`
+	} `json:"provider"`
+	NavDate   string          `json:"navDate"`
+	Service   json.RawMessage `json:"service"`
+	Sequences []struct {
+		Canvases []struct {
+			Id string `json:"@id"`
+		} `json:"canvases"`
+	} `json:"sequences"`
+	Items []struct {
+		Id string `json:"id"`
+	} `json:"items"`
+}
+
+type iiifService struct {
+	Id      string `json:"id"`
+	OldId   string `json:"@id"`
+	Type    string `json:"type"`
+	Context any    `json:"@context"`
+	Profile any    `json:"profile"`
+}
+
+// iiifAnnotations is a search answer, an AnnotationList (0.9, 1) or AnnotationPage (2)
+type iiifAnnotations struct {
+	Resources []struct {
+		On       json.RawMessage `json:"on"`
+		Resource struct {
+			Chars string `json:"chars"`
+		} `json:"resource"`
+	} `json:"resources"`
+	Items []struct {
+		Target json.RawMessage `json:"target"`
+		Body   json.RawMessage `json:"body"`
+	} `json:"items"`
+}
+
+func (s *IIIF) Search(ctx context.Context, q Query) ([]Hit, error) {
+	if q.Within == "" {
+		return nil, errors.New("iiif: the manifest to search in is required")
+	}
+	var manifest iiifManifest
+	if err := getJSON(ctx, q.Within, &manifest); err != nil {
+		return nil, err
+	}
+	service := searchService(manifest.Service)
+	if service == "" {
+		return nil, errors.New("iiif: the manifest has no content search service")
+	}
+
+	var canvases []string
+	for _, seq := range manifest.Sequences {
+		for _, c := range seq.Canvases {
+			canvases = append(canvases, c.Id)
+		}
+	}
+	for _, c := range manifest.Items {
+		canvases = append(canvases, c.Id)
+	}
+	pageOf := make(map[string]int, len(canvases))
+	for i, c := range canvases {
+		pageOf[c] = i + 1
+	}
+
+	base := Hit{
+		Title:       text(manifest.Label),
+		Date:        manifest.NavDate,
+		Institution: text(manifest.Attribution),
+		URL:         q.Within,
+		Pages:       len(canvases),
+	}
+	if base.Institution == "" {
+		base.Institution = text(manifest.RequiredStatement.Value)
+	}
+	if base.Institution == "" && len(manifest.Provider) > 0 {
+		base.Institution = text(manifest.Provider[0].Label)
+	}
+
+	sep := "?"
+	if strings.Contains(service, "?") {
+		sep = "&"
+	}
+	var answer iiifAnnotations
+	if err := getJSON(ctx, service+sep+"q="+url.QueryEscape(q.Text), &answer); err != nil {
+		return nil, err
+	}
+	var hits []Hit
+	add := func(target json.RawMessage, snippet string) {
+		if len(hits) >= limit(q) {
+			return
+		}
+		hit := base
+		hit.Page = pageOf[canvasOf(target)]
+		hit.Snippet = collapseSpace(snippet)
+		hits = append(hits, hit)
+	}
+	for _, r := range answer.Resources {
+		add(r.On, r.Resource.Chars)
+	}
+	for _, item := range answer.Items {
+		add(item.Target, text(item.Body))
+	}
+	return hits, nil
+}
+
+// searchService finds the Content Search service among the services of a manifest
+func searchService(raw json.RawMessage) string {
+	var services []iiifService
+	if json.Unmarshal(raw, &services) != nil {
+		var one iiifService
+		if json.Unmarshal(raw, &one) != nil {
+			return ""
+		}
+		services = []iiifService{one}
+	}
+	for _, sv := range services {
+		hints := sv.Type + " " + fmtAny(sv.Context) + " " + fmtAny(sv.Profile)
+		if strings.Contains(hints, "SearchService") || strings.Contains(hints, "iiif.io/api/search/") {
+			if sv.Id != "" {
+				return sv.Id
+			}
+			return sv.OldId
+		}
+	}
+	return ""
+}
+
+func fmtAny(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// canvasOf is the canvas an annotation target points at, without its #xywh fragment
+func canvasOf(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		canvas, _, _ := strings.Cut(s, "#")
+		return canvas
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) == nil {
+		for _, key := range []string{"full", "source", "@id", "id"} {
+			if v, ok := obj[key]; ok {
+				return canvasOf(v)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,74 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// LoC searches loc.gov with its JSON API (fo=json), digitised items only
+type LoC struct {
+	Endpoint string
+}
+
+func NewLoC() *LoC {
+	return &LoC{Endpoint: "https://www.loc.gov/search/"}
+}
+
+func (s *LoC) Name() string {
+	return "Library of Congress"
+}
+
+type locResponse struct {
+	Results []struct {
+		Title     json.RawMessage `json:"title"`
+		Date      json.RawMessage `json:"date"`
+		URL       string          `json:"url"`
+		ID        string          `json:"id"`
+		Resources []struct {
+			Files json.RawMessage `json:"files"`
+		} `json:"resources"`
+	} `json:"results"`
+}
+
+func (s *LoC) Search(ctx context.Context, q Query) ([]Hit, error) {
+	v := url.Values{}
+	v.Set("q", q.Text)
+	v.Set("fo", "json")
+	v.Set("at", "results")
+	v.Set("c", fmt.Sprint(limit(q)))
+	v.Set("fa", "online-format:image")
+	var resp locResponse
+	if err := getJSON(ctx, s.Endpoint+"?"+v.Encode(), &resp); err != nil {
+		return nil, err
+	}
+	var hits []Hit
+	for _, r := range resp.Results {
+		viewer := r.URL
+		if viewer == "" {
+			viewer = r.ID
+		}
+		// The adapter downloads www.loc.gov/item/<id>/
+		if !strings.Contains(viewer, "/item/") {
+			continue
+		}
+		hit := Hit{
+			Title: text(r.Title),
+			Date:  text(r.Date),
+			URL:   strings.Replace(viewer, "http://", "https://", 1),
+		}
+		for _, res := range r.Resources {
+			var files int
+			if json.Unmarshal(res.Files, &files) == nil {
+				hit.Pages += files
+			}
+		}
+		if len(r.Resources) > 1 {
+			hit.Volumes = len(r.Resources)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
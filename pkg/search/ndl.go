@@ -0,0 +1,81 @@
+package search
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// NDL searches the digital collections of the National Diet Library through
+// the NDL Search OpenSearch API. Only items with a dl.ndl.go.jp PID are hits.
+type NDL struct {
+	Endpoint string
+}
+
+func NewNDL() *NDL {
+	return &NDL{Endpoint: "https://ndlsearch.ndl.go.jp/api/opensearch"}
+}
+
+func (s *NDL) Name() string {
+	return "National Diet Library"
+}
+
+type ndlRSS struct {
+	Items []struct {
+		Title  string   `xml:"title"`
+		Dates  []string `xml:"date"`
+		Issued []string `xml:"issued"`
+		Extent []string `xml:"extent"`
+		Inner  string   `xml:",innerxml"`
+	} `xml:"channel>item"`
+}
+
+var (
+	// https://dl.ndl.go.jp/pid/1287288, https://dl.ndl.go.jp/info:ndljp/pid/1287288 or info:ndljp/pid/1287288
+	ndlPid = regexp.MustCompile(`(?:dl\.ndl\.go\.jp/(?:info:ndljp/)?pid/|info:ndljp/pid/)(\d+)`)
+	// 128p, 64丁, 210頁
+	ndlPages = regexp.MustCompile(`(\d+)\s*(?:p\b|ページ|頁|丁|コマ)`)
+)
+
+func (s *NDL) Search(ctx context.Context, q Query) ([]Hit, error) {
+	v := url.Values{}
+	v.Set("any", q.Text)
+	v.Set("dpgroupid", "digitalcontents")
+	v.Set("cnt", fmt.Sprint(limit(q)))
+	body, err := get(ctx, s.Endpoint+"?"+v.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var rss ndlRSS
+	if err := xml.Unmarshal(body, &rss); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Endpoint, err)
+	}
+	var hits []Hit
+	for _, item := range rss.Items {
+		m := ndlPid.FindStringSubmatch(item.Inner)
+		if m == nil {
+			continue
+		}
+		hit := Hit{
+			Title: item.Title,
+			URL:   "https://dl.ndl.go.jp/pid/" + m[1],
+		}
+		for _, d := range append(item.Dates, item.Issued...) {
+			if d != "" {
+				hit.Date = d
+				break
+			}
+		}
+		for _, e := range item.Extent {
+			if p := ndlPages.FindStringSubmatch(e); p != nil {
+				hit.Pages, _ = strconv.Atoi(p[1])
+				break
+			}
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
@@ -0,0 +1,147 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Princeton searches the Princeton University Library catalog (Blacklight
+// JSON) and keeps the records that figgy, asked through the GraphQL endpoint
+// the adapter uses, has digitised.
+type Princeton struct {
+	Endpoint        string // Catalog search
+	GraphQLEndpoint string
+}
+
+func NewPrinceton() *Princeton {
+	return &Princeton{
+		Endpoint:        "https://catalog.princeton.edu/catalog.json",
+		GraphQLEndpoint: "https://figgy.princeton.edu/graphql",
+	}
+}
+
+func (s *Princeton) Name() string {
+	return "Princeton University Library"
+}
+
+type princetonCatalog struct {
+	Data []struct {
+		ID         string                     `json:"id"`
+		Attributes map[string]json.RawMessage `json:"attributes"`
+	} `json:"data"`
+}
+
+type princetonResources struct {
+	Data struct {
+		ResourcesByOrangelightIds []struct {
+			ManifestUrl   string `json:"manifestUrl"`
+			OrangelightId string `json:"orangelightId"`
+			Members       []struct {
+				Typename string `json:"__typename"`
+			} `json:"members"`
+		} `json:"resourcesByOrangelightIds"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+const princetonQuery = `query GetResourcesByOrangelightIds($ids: [String!]!) {
+  resourcesByOrangelightIds(ids: $ids) {
+    members { __typename }
+    ... on ScannedResource { manifestUrl orangelightId }
+    ... on ScannedMap { manifestUrl orangelightId }
+    ... on Coin { manifestUrl orangelightId }
+  }
+}`
+
+func (s *Princeton) Search(ctx context.Context, q Query) ([]Hit, error) {
+	v := url.Values{}
+	v.Set("q", q.Text)
+	v.Set("f[access_facet][]", "Online")
+	v.Set("per_page", fmt.Sprint(limit(q)))
+	var catalog princetonCatalog
+	if err := getJSON(ctx, s.Endpoint+"?"+v.Encode(), &catalog); err != nil {
+		return nil, err
+	}
+	if len(catalog.Data) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(catalog.Data))
+	for i, d := range catalog.Data {
+		ids[i] = d.ID
+	}
+	payload, _ := json.Marshal(map[string]any{
+		"operationName": "GetResourcesByOrangelightIds",
+		"query":         princetonQuery,
+		"variables":     map[string]any{"ids": ids},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.GraphQLEndpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	var resources princetonResources
+	if err := json.Unmarshal(body, &resources); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.GraphQLEndpoint, err)
+	}
+	if len(resources.Errors) > 0 {
+		return nil, fmt.Errorf("%s: %s", s.GraphQLEndpoint, resources.Errors[0].Message)
+	}
+
+	// Members of a book are its pages (FileSet) or its volumes
+	type digitised struct{ pages, volumes int }
+	found := map[string]digitised{}
+	for _, r := range resources.Data.ResourcesByOrangelightIds {
+		if r.ManifestUrl == "" || r.OrangelightId == "" {
+			continue
+		}
+		var d digitised
+		for _, m := range r.Members {
+			if m.Typename == "FileSet" {
+				d.pages++
+			} else {
+				d.volumes++
+			}
+		}
+		found[r.OrangelightId] = d
+	}
+
+	var hits []Hit
+	for _, doc := range catalog.Data {
+		d, ok := found[doc.ID]
+		if !ok {
+			continue
+		}
+		hit := Hit{
+			Title: attribute(doc.Attributes, "title_display", "title"),
+			Date:  attribute(doc.Attributes, "pub_created_display", "pub_date_display", "pub_date_start_sort"),
+			URL:   "https://catalog.princeton.edu/catalog/" + doc.ID,
+			Pages: d.pages,
+		}
+		if d.volumes > 1 {
+			hit.Volumes = d.volumes
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// attribute is the first of keys that the catalog has a value for
+func attribute(attrs map[string]json.RawMessage, keys ...string) string {
+	for _, k := range keys {
+		if t := text(attrs[k]); t != "" {
+			return t
+		}
+	}
+	return ""
+}
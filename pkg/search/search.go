@@ -0,0 +1,215 @@
+// Package search finds books in the search APIs of the supported institutions.
+package search
+
+import (
+	"bookget/pkg/gohttp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Query is what to look for
+type Query struct {
+	Text  string
+	Limit int // Hits per site
+	// Within is the IIIF manifest whose text the iiif searcher looks in
+	Within string
+}
+
+// Hit is a book found, normalised across institutions
+type Hit struct {
+	Site        string `json:"site"`
+	Title       string `json:"title"`
+	Date        string `json:"date,omitempty"`
+	Institution string `json:"institution"`
+	URL         string `json:"url"` // Viewer URL that bookget downloads
+	Pages       int    `json:"pages,omitempty"`
+	Volumes     int    `json:"volumes,omitempty"`
+	Page        int    `json:"page,omitempty"` // Page of the match, from IIIF Content Search
+	Snippet     string `json:"snippet,omitempty"`
+}
+
+// Searcher searches one institution
+type Searcher interface {
+	// Name is the institution, as Hit.Institution
+	Name() string
+	Search(ctx context.Context, q Query) ([]Hit, error)
+}
+
+var (
+	mu        sync.RWMutex
+	searchers = map[string]func() Searcher{}
+)
+
+// Register adds a searcher under id, the name --site takes
+func Register(id string, newSearcher func() Searcher) {
+	mu.Lock()
+	defer mu.Unlock()
+	searchers[id] = newSearcher
+}
+
+// New returns the searcher registered as id
+func New(id string) (Searcher, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	newSearcher, ok := searchers[id]
+	if !ok {
+		return nil, false
+	}
+	return newSearcher(), true
+}
+
+// IDs are the registered searchers, sorted
+func IDs() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	ids := make([]string, 0, len(searchers))
+	for id := range searchers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func init() {
+	Register("ndl", func() Searcher { return NewNDL() })
+	Register("loc", func() Searcher { return NewLoC() })
+	Register("hathitrust", func() Searcher { return NewHathiTrust() })
+	Register("princeton", func() Searcher { return NewPrinceton() })
+	Register("iiif", func() Searcher { return NewIIIF() })
+}
+
+// Result is the answer of one site
+type Result struct {
+	Site string
+	Hits []Hit
+	Err  error
+}
+
+// Run asks the searchers of sites at once. Results keep the order of sites.
+func Run(ctx context.Context, sites []string, q Query) ([]Result, error) {
+	results := make([]Result, len(sites))
+	var wg sync.WaitGroup
+	for i, id := range sites {
+		s, ok := New(id)
+		if !ok {
+			return nil, fmt.Errorf("unknown site %q, expected %s", id, strings.Join(IDs(), ","))
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hits, err := s.Search(ctx, q)
+			for j := range hits {
+				hits[j].Site = id
+				if hits[j].Institution == "" {
+					hits[j].Institution = s.Name()
+				}
+				hits[j].Title = collapseSpace(hits[j].Title)
+			}
+			results[i] = Result{Site: id, Hits: hits, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// client is shared by the searchers. It is built on first use, after the
+// command line has set gohttp.PoolLimits.
+var client = sync.OnceValue(func() *http.Client {
+	return gohttp.PooledClient(gohttp.DefaultTransportKey, nil, 60*time.Second)
+})
+
+// get fetches url, which must answer 200
+func get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return do(req)
+}
+
+func do(req *http.Request) ([]byte, error) {
+	resp, err := client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: status %d", req.URL, resp.StatusCode)
+	}
+	return body, nil
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	body, err := get(ctx, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	return nil
+}
+
+func limit(q Query) int {
+	if q.Limit <= 0 {
+		return 20
+	}
+	return q.Limit
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// text reads a JSON value that may be a string, a list of strings or a
+// IIIF language map ({"en": ["..."]}), joining several with "; "
+func text(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var list []json.RawMessage
+	if json.Unmarshal(raw, &list) == nil {
+		var parts []string
+		for _, v := range list {
+			if t := text(v); t != "" {
+				parts = append(parts, t)
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(raw, &obj) == nil {
+		// IIIF v2 {"@value": "..."}, Blacklight {"attributes": {"value": ...}}
+		for _, key := range []string{"@value", "value", "attributes"} {
+			if v, ok := obj[key]; ok {
+				return text(v)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if t := text(obj[k]); t != "" {
+				return t
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve answers each path with its body and records the queries
+func serve(t *testing.T, bodies map[string]string) (*httptest.Server, map[string]string) {
+	queries := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		queries[r.URL.Path] = r.URL.RawQuery
+		if r.Method == http.MethodPost {
+			b, _ := io.ReadAll(r.Body)
+			queries[r.URL.Path] = string(b)
+		}
+		_, _ = io.WriteString(w, strings.ReplaceAll(body, "{{server}}", "http://"+r.Host))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, queries
+}
+
+func TestLoC(t *testing.T) {
+	srv, queries := serve(t, map[string]string{"/search/": `{"results": [
+		{"title": "Qing dynasty atlas", "date": "1850", "url": "https://www.loc.gov/item/2014514163/", "resources": [{"files": 48}, {"files": 52}]},
+		{"title": "A collection", "date": "1900", "url": "https://www.loc.gov/collections/chinese/"},
+		{"title": ["Map of Peking"], "id": "http://www.loc.gov/item/gm71005018/", "resources": [{"files": 1}]}
+	]}`})
+	s := NewLoC()
+	s.Endpoint = srv.URL + "/search/"
+
+	hits, err := s.Search(context.Background(), Query{Text: "peking", Limit: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{
+		{Title: "Qing dynasty atlas", Date: "1850", URL: "https://www.loc.gov/item/2014514163/", Pages: 100, Volumes: 2},
+		{Title: "Map of Peking", URL: "https://www.loc.gov/item/gm71005018/", Pages: 1},
+	}, hits)
+	assert.Contains(t, queries["/search/"], "fo=json")
+	assert.Contains(t, queries["/search/"], "c=5")
+}
+
+func TestNDL(t *testing.T) {
+	srv, queries := serve(t, map[string]string{"/api/opensearch": `<?xml version="1.0" encoding="UTF-8"?>
+<rss xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:rdfs="http://www.w3.org/2000/01/rdf-schema#" xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dcterms="http://purl.org/dc/terms/" version="2.0">
+<channel>
+<item>
+  <title>四庫全書總目</title>
+  <link>https://ndlsearch.ndl.go.jp/books/R100000039-I000001</link>
+  <dc:date>1931</dc:date>
+  <dc:extent>210p ; 23cm</dc:extent>
+  <rdfs:seeAlso rdf:resource="https://dl.ndl.go.jp/info:ndljp/pid/1287288"/>
+</item>
+<item>
+  <title>冊子体のみ</title>
+  <link>https://ndlsearch.ndl.go.jp/books/R100000002-I000002</link>
+</item>
+<item>
+  <title>永楽大典</title>
+  <dcterms:issued>1960</dcterms:issued>
+  <dc:identifier>info:ndljp/pid/2541234</dc:identifier>
+</item>
+</channel>
+</rss>`})
+	s := NewNDL()
+	s.Endpoint = srv.URL + "/api/opensearch"
+
+	hits, err := s.Search(context.Background(), Query{Text: "四庫"})
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{
+		{Title: "四庫全書總目", Date: "1931", URL: "https://dl.ndl.go.jp/pid/1287288", Pages: 210},
+		{Title: "永楽大典", Date: "1960", URL: "https://dl.ndl.go.jp/pid/2541234"},
+	}, hits)
+	assert.Contains(t, queries["/api/opensearch"], "cnt=20")
+}
+
+func TestHathiTrust(t *testing.T) {
+	srv, queries := serve(t, map[string]string{
+		"/cgi/ls": `<a href="https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924;view=1up">Full view</a>
+			<a href="/cgi/pt?id=mdp.39015012345678&amp;seq=7">Full view</a>
+			<a href="https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924">again</a>`,
+		"/api/volumes/brief/json/htid:uc1.b3656924|htid:mdp.39015012345678": `{
+			"htid:uc1.b3656924": {"records": {"008": {"titles": ["Shi ji"], "publishDates": ["1888"]}},
+				"items": [{"orig": "University of California", "htid": "uc1.b3656924", "fromRecord": "008", "enumcron": "v.2"}]},
+			"htid:mdp.39015012345678": {"records": {"009": {"titles": ["Han shu"]}},
+				"items": [{"orig": "University of Michigan", "htid": "mdp.39015012345678", "fromRecord": "009", "enumcron": false}]}
+		}`,
+	})
+	s := NewHathiTrust()
+	s.Endpoint = srv.URL + "/cgi/ls"
+	s.BibEndpoint = srv.URL + "/api/volumes/brief/json/"
+
+	hits, err := s.Search(context.Background(), Query{Text: "shi ji"})
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{
+		{Title: "Shi ji v.2", Date: "1888", Institution: "HathiTrust (University of California)", URL: "https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924"},
+		{Title: "Han shu", Institution: "HathiTrust (University of Michigan)", URL: "https://babel.hathitrust.org/cgi/pt?id=mdp.39015012345678"},
+	}, hits)
+	assert.Contains(t, queries["/cgi/ls"], "lmt=ft")
+}
+
+func TestPrinceton(t *testing.T) {
+	srv, queries := serve(t, map[string]string{
+		"/catalog.json": `{"data": [
+			{"id": "9946093213506421", "attributes": {"title_display": {"attributes": {"value": "Zhou yi"}}, "pub_created_display": {"attributes": {"value": ["Beijing, 1750"]}}}},
+			{"id": "99125", "attributes": {"title_display": "Not digitised"}},
+			{"id": "99126", "attributes": {"title": "Shi jing"}}
+		]}`,
+		"/graphql": `{"data": {"resourcesByOrangelightIds": [
+			{"manifestUrl": "https://figgy.princeton.edu/concern/scanned_resources/1/manifest", "orangelightId": "9946093213506421",
+			 "members": [{"__typename": "FileSet"}, {"__typename": "FileSet"}, {"__typename": "FileSet"}]},
+			{"manifestUrl": "https://figgy.princeton.edu/concern/scanned_resources/2/manifest", "orangelightId": "99126",
+			 "members": [{"__typename": "ScannedResource"}, {"__typename": "ScannedResource"}]}
+		]}}`,
+	})
+	s := NewPrinceton()
+	s.Endpoint = srv.URL + "/catalog.json"
+	s.GraphQLEndpoint = srv.URL + "/graphql"
+
+	hits, err := s.Search(context.Background(), Query{Text: "zhou yi"})
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{
+		{Title: "Zhou yi", Date: "Beijing, 1750", URL: "https://catalog.princeton.edu/catalog/9946093213506421", Pages: 3},
+		{Title: "Shi jing", URL: "https://catalog.princeton.edu/catalog/99126", Volumes: 2},
+	}, hits)
+
+	var gql struct {
+		Variables struct {
+			Ids []string `json:"ids"`
+		} `json:"variables"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(queries["/graphql"]), &gql))
+	assert.Equal(t, []string{"9946093213506421", "99125", "99126"}, gql.Variables.Ids)
+}
+
+func TestIIIF(t *testing.T) {
+	srv, queries := serve(t, map[string]string{
+		"/manifest.json": `{
+			"label": "Lunyu",
+			"attribution": "Example Library",
+			"service": [{"@context": "http://iiif.io/api/search/1/context.json", "@id": "{{server}}/search", "profile": "http://iiif.io/api/search/1/search"}],
+			"sequences": [{"canvases": [{"@id": "{{server}}/c1"}, {"@id": "{{server}}/c2"}, {"@id": "{{server}}/c3"}]}]
+		}`,
+		"/v3.json": `{
+			"label": {"en": ["Mengzi"]},
+			"provider": [{"label": {"en": ["Other Library"]}}],
+			"service": [{"id": "{{server}}/search2", "type": "SearchService2"}],
+			"items": [{"id": "{{server}}/p1"}, {"id": "{{server}}/p2"}]
+		}`,
+		"/search": `{"resources": [
+			{"on": "{{server}}/c3#xywh=1,2,3,4", "resource": {"chars": "學而時習之"}},
+			{"on": {"full": "{{server}}/c1"}, "resource": {"chars": "有朋自遠方來"}}
+		]}`,
+		"/search2": `{"items": [{"target": {"source": {"id": "{{server}}/p2"}}, "body": {"value": "孟子見梁惠王"}}]}`,
+	})
+	s := NewIIIF()
+
+	hits, err := s.Search(context.Background(), Query{Text: "學", Within: srv.URL + "/manifest.json"})
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, Hit{Title: "Lunyu", Institution: "Example Library", URL: srv.URL + "/manifest.json", Pages: 3, Page: 3, Snippet: "學而時習之"}, hits[0])
+	assert.Equal(t, 1, hits[1].Page)
+	assert.Equal(t, "q=%E5%AD%B8", queries["/search"])
+
+	hits, err = s.Search(context.Background(), Query{Text: "孟子", Within: srv.URL + "/v3.json"})
+	require.NoError(t, err)
+	assert.Equal(t, []Hit{{Title: "Mengzi", Institution: "Other Library", URL: srv.URL + "/v3.json", Pages: 2, Page: 2, Snippet: "孟子見梁惠王"}}, hits)
+
+	_, err = s.Search(context.Background(), Query{Text: "x"})
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	srv, _ := serve(t, map[string]string{"/search/": `{"results": [{"title": "  Qing\n atlas ", "url": "https://www.loc.gov/item/1/"}]}`})
+	Register("test-loc", func() Searcher { return &LoC{Endpoint: srv.URL + "/search/"} })
+	Register("test-down", func() Searcher { return &LoC{Endpoint: srv.URL + "/missing/"} })
+
+	results, err := Run(context.Background(), []string{"test-loc", "test-down"}, Query{Text: "atlas"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, []Hit{{Site: "test-loc", Title: "Qing atlas", Institution: "Library of Congress", URL: "https://www.loc.gov/item/1/"}}, results[0].Hits)
+	assert.Error(t, results[1].Err)
+
+	_, err = Run(context.Background(), []string{"nowhere"}, Query{Text: "atlas"})
+	assert.ErrorContains(t, err, "ndl")
+}
@@ -2,8 +2,9 @@ package util
 
 import (
 	"bookget/config"
-	"crypto/tls"
-	"log"
+	"bookget/pkg/gohttp"
+	"bookget/pkg/i18n"
+	"io"
 	"net/http"
 	"path/filepath"
 	"regexp"
@@ -58,19 +59,12 @@ func isRangeFormat(url string) bool {
 }
 
 func determineContentTypeByRequest(url string) string {
-	// 创建一次性使用的HTTP客户端
-	client := &http.Client{
-		Timeout: config.Conf.Timeout * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
-			DisableKeepAlives: true,
-			Proxy:             http.ProxyFromEnvironment,
-		},
-	}
+	// 共享连接池，避免每个 URL 重新握手
+	client := gohttp.PooledClient(gohttp.DefaultTransportKey, nil, config.Conf.Timeout*time.Second)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Printf("创建请求失败: %v", err)
+		i18n.Logln("probe.request_failed", err)
 		return "bookget"
 	}
 
@@ -79,10 +73,12 @@ func determineContentTypeByRequest(url string) string {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		log.Printf("请求失败: %v", err)
+		i18n.Logln("probe.request_failed", err)
 		return "bookget"
 	}
 	defer resp.Body.Close()
+	// 读完响应体，连接才能放回连接池
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
 
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
@@ -0,0 +1,242 @@
+package version
+
+import (
+	"bookget/pkg/gohttp"
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// ChecksumsName is the sha256sum listing attached to every release
+	ChecksumsName = "SHA256SUMS"
+	// SignatureName is the Ed25519 signature of ChecksumsName
+	SignatureName = ChecksumsName + ".sig"
+)
+
+// AssetName is the release file of a platform, as the Makefile names it
+func AssetName(goos, goarch string) string {
+	switch goos + "-" + goarch {
+	case "linux-amd64":
+		return "bookget-linux"
+	case "linux-arm64":
+		return "bookget-linux-arm64"
+	case "darwin-amd64":
+		return "bookget-macos"
+	case "darwin-arm64":
+		return "bookget-macos-arm64"
+	case "windows-amd64":
+		return "bookget.exe"
+	}
+	if goos == "windows" {
+		return "bookget-" + goarch + ".exe"
+	}
+	return "bookget-" + goos + "-" + goarch
+}
+
+// Updater installs a release over the running binary
+type Updater struct {
+	Client *http.Client
+	// PublicKey, when set, must have signed the release's SHA256SUMS
+	PublicKey ed25519.PublicKey
+	// Asset is the file to install, AssetName of this platform by default
+	Asset string
+}
+
+// NewUpdater returns an updater for this platform
+func NewUpdater(publicKey ed25519.PublicKey) *Updater {
+	return &Updater{
+		Client:    gohttp.PooledClient(gohttp.DefaultTransportKey, nil, 10*time.Minute),
+		PublicKey: publicKey,
+		Asset:     AssetName(runtime.GOOS, runtime.GOARCH),
+	}
+}
+
+// Apply downloads the asset of release, checks it against SHA256SUMS and
+// replaces exe with it. exe is left alone on any error.
+func (u *Updater) Apply(release *Release, exe string) error {
+	asset, ok := release.Asset(u.Asset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s for this platform", release.TagName, u.Asset)
+	}
+	sumsAsset, ok := release.Asset(ChecksumsName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s, refusing to install a binary that can't be verified", release.TagName, ChecksumsName)
+	}
+	sums, err := u.fetch(sumsAsset.URL, 1<<20)
+	if err != nil {
+		return err
+	}
+	if u.PublicKey != nil {
+		sigAsset, ok := release.Asset(SignatureName)
+		if !ok {
+			return fmt.Errorf("release %s has no %s, but a public key is configured", release.TagName, SignatureName)
+		}
+		sig, err := u.fetch(sigAsset.URL, 1024)
+		if err != nil {
+			return err
+		}
+		if !ed25519.Verify(u.PublicKey, sums, decodeSignature(sig)) {
+			return fmt.Errorf("%s of release %s is not signed by the configured key", ChecksumsName, release.TagName)
+		}
+	}
+	want, err := lookupChecksum(sums, asset.Name)
+	if err != nil {
+		return err
+	}
+
+	// The new binary is written next to exe, so the final rename stays on one file system
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = u.download(asset.URL, tmp, want)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(0755)
+	if fi, err := os.Stat(exe); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return replaceFile(tmp.Name(), exe)
+}
+
+func (u *Updater) get(url string) (*http.Response, error) {
+	resp, err := u.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: status %d", url, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (u *Updater) fetch(url string, limit int64) ([]byte, error) {
+	resp, err := u.get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// download writes url to w and checks its SHA-256 against want
+func (u *Updater) download(url string, w io.Writer, want string) error {
+	resp, err := u.get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("SHA-256 of %s is %s, %s lists %s", url, got, ChecksumsName, want)
+	}
+	return nil
+}
+
+// decodeSignature accepts the raw 64 bytes written by openssl pkeyutl -sign or their base64
+func decodeSignature(sig []byte) []byte {
+	if len(sig) == ed25519.SignatureSize {
+		return sig
+	}
+	if b, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(sig))); err == nil {
+		return b
+	}
+	return sig
+}
+
+// lookupChecksum finds name in sha256sum output, "<hex>  <name>" or "<hex> *<name>" per line
+func lookupChecksum(sums []byte, name string) (string, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		file := baseName(strings.TrimPrefix(fields[1], "*"))
+		if file == name && len(fields[0]) == sha256.Size*2 {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s does not list %s", ChecksumsName, name)
+}
+
+// baseName is the file name of a listed path, which may carry directories
+func baseName(p string) string {
+	if i := strings.LastIndexAny(p, `/\`); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
+
+// replaceFile moves src over dst. Windows can't overwrite a running executable
+// but can rename it, so the old one is moved aside to dst.old first.
+func replaceFile(src, dst string) error {
+	if runtime.GOOS != "windows" {
+		return os.Rename(src, dst)
+	}
+	old := dst + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		_ = os.Rename(old, dst)
+		return err
+	}
+	return nil
+}
+
+// ParsePublicKey reads an Ed25519 public key: base64 of the 32 key bytes or of
+// its DER form, or a PEM "PUBLIC KEY" block as written by openssl pkey -pubout.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		der = block.Bytes
+	} else {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("public key: %w", err)
+		}
+		if len(b) == ed25519.PublicKeySize {
+			return b, nil
+		}
+		der = b
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("public key: not an Ed25519 key")
+	}
+	return pub, nil
+}
@@ -0,0 +1,163 @@
+package version
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseServer stands in for the GitHub releases API of owner/bookget
+func releaseServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("/repos/owner/bookget/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		release := Release{TagName: "v99.0101", HTMLURL: srv.URL + "/releases/v99.0101"}
+		for name := range files {
+			release.Assets = append(release.Assets, Asset{Name: name, URL: srv.URL + "/download/" + name})
+		}
+		_ = json.NewEncoder(w).Encode(release)
+	})
+	mux.HandleFunc("/download/{name}", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.PathValue("name")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	})
+	return srv
+}
+
+func checksums(files map[string][]byte) []byte {
+	var sums []byte
+	for name, data := range files {
+		h := sha256.Sum256(data)
+		sums = append(sums, hex.EncodeToString(h[:])+"  "+name+"\n"...)
+	}
+	return sums
+}
+
+func TestSelfUpdate(t *testing.T) {
+	binary := []byte("#!/bin/sh\necho new\n")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sums := checksums(map[string][]byte{"bookget-linux": binary, "bookget.exe": []byte("other")})
+
+	install := func(t *testing.T, files map[string][]byte, key ed25519.PublicKey) (string, error) {
+		srv := releaseServer(t, files)
+		c := NewChecker("25.0701", "owner/bookget", srv.URL+"/")
+		c.CachePath = ""
+		c.Client = srv.Client()
+		release, err := c.Latest()
+		require.NoError(t, err)
+		assert.Equal(t, "99.0101", release.Version())
+		assert.Equal(t, srv.URL+"/releases/v99.0101", c.ReleaseURL(release))
+
+		exe := filepath.Join(t.TempDir(), "bookget")
+		require.NoError(t, os.WriteFile(exe, []byte("old"), 0755))
+		u := &Updater{Client: srv.Client(), PublicKey: key, Asset: "bookget-linux"}
+		err = u.Apply(release, exe)
+		data, _ := os.ReadFile(exe)
+		entries, _ := os.ReadDir(filepath.Dir(exe))
+		assert.Len(t, entries, 1, "no temporary file left behind")
+		return string(data), err
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		data, err := install(t, map[string][]byte{"bookget-linux": binary, ChecksumsName: sums}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, string(binary), data)
+	})
+	t.Run("signed", func(t *testing.T) {
+		sig := ed25519.Sign(priv, sums)
+		data, err := install(t, map[string][]byte{"bookget-linux": binary, ChecksumsName: sums, SignatureName: sig}, pub)
+		require.NoError(t, err)
+		assert.Equal(t, string(binary), data)
+	})
+	t.Run("tampered", func(t *testing.T) {
+		data, err := install(t, map[string][]byte{"bookget-linux": []byte("evil"), ChecksumsName: sums}, nil)
+		assert.ErrorContains(t, err, "SHA-256")
+		assert.Equal(t, "old", data)
+	})
+	t.Run("no checksums", func(t *testing.T) {
+		data, err := install(t, map[string][]byte{"bookget-linux": binary}, nil)
+		assert.ErrorContains(t, err, ChecksumsName)
+		assert.Equal(t, "old", data)
+	})
+	t.Run("unsigned", func(t *testing.T) {
+		data, err := install(t, map[string][]byte{"bookget-linux": binary, ChecksumsName: sums}, pub)
+		assert.ErrorContains(t, err, SignatureName)
+		assert.Equal(t, "old", data)
+	})
+	t.Run("wrong key", func(t *testing.T) {
+		other, _, _ := ed25519.GenerateKey(rand.Reader)
+		sig := ed25519.Sign(priv, sums)
+		data, err := install(t, map[string][]byte{"bookget-linux": binary, ChecksumsName: sums, SignatureName: sig}, other)
+		assert.ErrorContains(t, err, "not signed")
+		assert.Equal(t, "old", data)
+	})
+}
+
+func TestCheckForUpdate(t *testing.T) {
+	srv := releaseServer(t, nil)
+	c := NewChecker("25.0701", "owner/bookget", srv.URL)
+	c.CachePath = filepath.Join(t.TempDir(), CacheFileName)
+	c.Client = srv.Client()
+
+	latest, url, newer, err := c.CheckForUpdate()
+	require.NoError(t, err)
+	assert.Equal(t, "99.0101", latest)
+	assert.Equal(t, srv.URL+"/releases/v99.0101", url)
+	assert.True(t, newer)
+
+	// Answered from the cache while the server is gone
+	srv.Close()
+	latest, _, newer, err = c.CheckForUpdate()
+	require.NoError(t, err)
+	assert.Equal(t, "99.0101", latest)
+	assert.True(t, newer)
+
+	// Another repo doesn't use that cache
+	c.Repo = "other/bookget"
+	_, _, _, err = c.CheckForUpdate()
+	assert.Error(t, err)
+}
+
+func TestNewer(t *testing.T) {
+	assert.True(t, Newer("25.0815", "25.0701"))
+	assert.True(t, Newer("v26.0101", "25.1231"))
+	assert.True(t, Newer("25.0701.1", "25.0701"))
+	assert.False(t, Newer("25.0701", "25.0701"))
+	assert.False(t, Newer("25.0601", "25.0701"))
+	assert.False(t, Newer("", "25.0701"))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	raw := "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo="
+	key, err := ParsePublicKey(raw)
+	require.NoError(t, err)
+	assert.Len(t, key, ed25519.PublicKeySize)
+
+	pem := "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEA11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=\n-----END PUBLIC KEY-----\n"
+	fromPEM, err := ParsePublicKey(pem)
+	require.NoError(t, err)
+	assert.Equal(t, key, fromPEM)
+
+	fromDER, err := ParsePublicKey("MCowBQYDK2VwAyEA11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=")
+	require.NoError(t, err)
+	assert.Equal(t, key, fromDER)
+
+	_, err = ParsePublicKey("not a key")
+	assert.Error(t, err)
+}
@@ -2,12 +2,14 @@ package version
 
 import (
 	"bookget/config"
+	"bookget/pkg/gohttp"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,108 +17,162 @@ import (
 const (
 	CacheFileName        = ".bookget_version_cache"
 	DefaultCheckInterval = 24 * time.Hour
+
+	// DefaultRepo publishes the releases of this fork
+	DefaultRepo = "storytracer/bookget"
+	// DefaultEndpoint is the GitHub API; Gitea and mirrors answer the same releases path
+	DefaultEndpoint = "https://api.github.com"
 )
 
+// Checker asks a releases API for the latest release. It only reads; nothing
+// outside its own cache file is written or removed.
 type Checker struct {
 	CurrentVersion string
-	RepoOwner      string
-	RepoName       string
+	Repo           string // owner/name
+	Endpoint       string // API base, {Endpoint}/repos/{Repo}/releases/latest
 	CachePath      string
-	LastChecked    time.Time
+	Client         *http.Client
 }
 
 type cache struct {
 	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	Source      string    `json:"source"`
 	LastChecked time.Time `json:"last_checked"`
 }
 
-type githubRelease struct {
-	TagName string `json:"tag_name"`
+// Release is the part of a GitHub release that bookget needs
+type Release struct {
+	TagName string  `json:"tag_name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
 }
 
-func NewChecker(currentVersion, repoOwner, repoName string) *Checker {
-	cachePath, _ := getCachePath()
-	return &Checker{
-		CurrentVersion: currentVersion,
-		RepoOwner:      repoOwner,
-		RepoName:       repoName,
-		CachePath:      cachePath,
-	}
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
 }
 
-func (c *Checker) CheckForUpdate() (string, bool, error) {
-	// Check if this check should be skipped
-	if time.Since(c.LastChecked) < DefaultCheckInterval {
-		return "", false, nil
-	}
+// Version is the tag without its v prefix
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
 
-	// Get latest version
-	latestVersion, err := c.getLatestVersion()
-	if err != nil {
-		return "", false, fmt.Errorf("failed to get latest version: %w", err)
+// Asset returns the asset called name
+func (r *Release) Asset(name string) (Asset, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a, true
+		}
 	}
+	return Asset{}, false
+}
 
-	c.LastChecked = time.Now()
-
-	// Compare versions
-	if !c.compareVersions(latestVersion) {
-		return latestVersion, true, nil
+// NewChecker returns a checker of repo at endpoint; empty values mean DefaultRepo and DefaultEndpoint
+func NewChecker(currentVersion, repo, endpoint string) *Checker {
+	if repo == "" {
+		repo = DefaultRepo
+	}
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	cachePath, _ := getCachePath()
+	return &Checker{
+		CurrentVersion: currentVersion,
+		Repo:           repo,
+		Endpoint:       strings.TrimRight(endpoint, "/"),
+		CachePath:      cachePath,
+		Client:         gohttp.PooledClient(gohttp.DefaultTransportKey, nil, 30*time.Second),
 	}
-
-	return latestVersion, false, nil
 }
 
-func (c *Checker) getLatestVersion() (string, error) {
-	// First try to read from cache
-	if cached, err := c.readCache(); err == nil && cached != nil {
-		return cached.Version, nil
+// CheckForUpdate returns the latest version and its page, asking the API at most once per DefaultCheckInterval
+func (c *Checker) CheckForUpdate() (latest string, url string, newer bool, err error) {
+	if cached, err := c.readCache(); err == nil && cached != nil &&
+		cached.Source == c.source() && time.Since(cached.LastChecked) < DefaultCheckInterval {
+		return cached.Version, cached.URL, Newer(cached.Version, c.CurrentVersion), nil
 	}
 
-	// Get latest version from GitHub API
-	version, err := c.fetchFromGitHub()
+	release, err := c.Latest()
 	if err != nil {
-		// If API fails but cache exists, return cached version
-		if cached, err := c.readCache(); err == nil && cached != nil {
-			return cached.Version, nil
-		}
-		return "", err
+		return "", "", false, fmt.Errorf("failed to get latest version: %w", err)
 	}
-
-	// Update cache
-	if err := c.writeCache(version); err != nil {
-		return "", fmt.Errorf("failed to update cache: %w", err)
-	}
-
-	return version, nil
+	url = c.ReleaseURL(release)
+	// A cache that can't be written only means asking again next time
+	_ = c.writeCache(release.Version(), url)
+	return release.Version(), url, Newer(release.Version(), c.CurrentVersion), nil
 }
 
-func (c *Checker) fetchFromGitHub() (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", c.RepoOwner, c.RepoName)
-	resp, err := http.Get(url)
+// Latest fetches the latest release from the API
+func (c *Checker) Latest() (*Release, error) {
+	url := c.source() + "/releases/latest"
+	resp, err := c.Client.Get(url)
 	if err != nil {
-		return "", fmt.Errorf("GitHub API request failed: %w", err)
+		return nil, fmt.Errorf("releases API request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("GitHub API returned non-200 status code: %d", resp.StatusCode)
+		return nil, fmt.Errorf("releases API returned status %d for %s", resp.StatusCode, url)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
 	if err != nil {
-		return "", fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	var release githubRelease
+	var release Release
 	if err := json.Unmarshal(body, &release); err != nil {
-		return "", fmt.Errorf("failed to parse JSON: %w", err)
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("no release tag in %s", url)
+	}
+	return &release, nil
+}
 
-	return strings.TrimPrefix(release.TagName, "v"), nil
+// ReleaseURL is the page of release for people, falling back to the GitHub releases page
+func (c *Checker) ReleaseURL(release *Release) string {
+	if release != nil && release.HTMLURL != "" {
+		return release.HTMLURL
+	}
+	return "https://github.com/" + c.Repo + "/releases/latest"
 }
 
-func (c *Checker) compareVersions(latest string) bool {
-	return c.CurrentVersion == latest
+func (c *Checker) source() string {
+	return c.Endpoint + "/repos/" + c.Repo
+}
+
+// Newer reports whether latest is a later version than current. Versions are
+// compared by their dot separated parts, numerically where both are numbers.
+func Newer(latest, current string) bool {
+	l := strings.Split(strings.TrimPrefix(latest, "v"), ".")
+	c := strings.Split(strings.TrimPrefix(current, "v"), ".")
+	for i := 0; i < max(len(l), len(c)); i++ {
+		a, b := versionPart(l, i), versionPart(c, i)
+		if a == b {
+			continue
+		}
+		x, errX := strconv.Atoi(a)
+		y, errY := strconv.Atoi(b)
+		if errX == nil && errY == nil {
+			if x == y {
+				continue
+			}
+			return x > y
+		}
+		return a > b
+	}
+	return false
+}
+
+func versionPart(parts []string, i int) string {
+	if i < len(parts) && parts[i] != "" {
+		return parts[i]
+	}
+	return "0"
 }
 
 func (c *Checker) readCache() (*cache, error) {
@@ -140,13 +196,15 @@ func (c *Checker) readCache() (*cache, error) {
 	return &data, nil
 }
 
-func (c *Checker) writeCache(version string) error {
+func (c *Checker) writeCache(version, url string) error {
 	if c.CachePath == "" {
 		return nil
 	}
 
 	data := cache{
 		Version:     version,
+		URL:         url,
+		Source:      c.source(),
 		LastChecked: time.Now(),
 	}
 
@@ -160,5 +218,8 @@ func (c *Checker) writeCache(version string) error {
 
 func getCachePath() (string, error) {
 	homeDir := config.UserHomeDir()
+	if homeDir == "" {
+		return "", nil
+	}
 	return filepath.Join(homeDir, CacheFileName), nil
 }
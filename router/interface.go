@@ -5,7 +5,9 @@ import (
 	"bookget/app"
 	"bookget/config"
 	"bookget/pkg/util"
+	"context"
 	"errors"
+	"net/url"
 	"strings"
 	"sync"
 )
@@ -19,227 +21,116 @@ var (
 	doInit sync.Once
 )
 
-// FactoryRouter factory function for creating routers
-func FactoryRouter(siteID string, sUrl string) (map[string]interface{}, error) {
-	// Auto-detection logic
-	if config.Conf.DownloaderMode == 1 {
-		siteID = "bookget"
-	} else if config.Conf.DownloaderMode == 2 || strings.Contains(sUrl, ".json") {
-		siteID = "iiif.io"
-	}
+// Route is the site FactoryRouter picks for a URL, and why
+type Route struct {
+	SiteID string // Key of Router; empty when only the Content-Type can tell
+	Site   *Site
+	Reason string
+	URL    string // The URL the adapter gets: sUrl, or the canonical URL of its record
+}
 
-	if strings.Contains(sUrl, "tiles/infos.json") {
-		siteID = "dzicnlib"
+// register fills Router from Sites, one adapter per host. These adapters
+// only answer questions about URLs; each download gets its own.
+func register() {
+	for i := range Sites {
+		for _, host := range Sites[i].Hosts {
+			Router[host] = Sites[i].New(context.Background())
+			siteOf[host] = &Sites[i]
+		}
 	}
+}
 
-	// Initialize routers (thread-safe)
-	doInit.Do(func() {
-		//[China] National Library of China
-		Router["read.nlc.cn"] = app.NewChinaNlc()
-		Router["mylib.nlc.cn"] = app.NewChinaNlc()
-		Router["guji.nlc.cn"] = app.NewNlcGuji()
-
-		//[China] Taiwan Chinese E-book Repository
-		Router["taiwanebook.ncl.edu.tw"] = app.NewHuawen()
-
-		//[China] Chinese University of Hong Kong Library
-		Router["repository.lib.cuhk.edu.hk"] = app.NewCuhk()
-
-		//[China] Hong Kong University of Science and Technology Library
-		Router["lbezone.hkust.edu.hk"] = app.NewUsthk()
-
-		//[China] Luoyang City Library
-		Router["111.7.82.29:8090"] = app.NewLuoyang()
-
-		//[China] Wenzhou City Library
-		Router["oyjy.wzlib.cn"] = app.NewWzlib()
-		Router["arcgxhpv7cw0.db.wzlib.cn"] = app.NewWzlib()
-
-		//[China] Shenzhen Library - Ancient Books
-		Router["yun.szlib.org.cn"] = app.NewSzLib()
-
-		//[China] Guangzhou Dadian
-		Router["gzdd.gzlib.gov.cn"] = app.NewGzlib()
-		Router["gzdd.gzlib.org.cn"] = app.NewGzlib()
-
-		//[China] Tianyi Pavilion Museum Ancient Books Digitization Platform
-		Router["gj.tianyige.com.cn"] = app.NewTianyige()
-
-		//[China] Jiangsu Colleges Precious Ancient Books Digital Library
-		Router["jsgxgj.nju.edu.cn"] = app.NewNjuedu()
-
-		//[China] China Roots Network - National Library
-		Router["ouroots.nlc.cn"] = app.NewOuroots()
-
-		//[China] National Center for Philosophy and Social Sciences Documentation
-		Router["www.ncpssd.org"] = app.NewNcpssd()
-		Router["www.ncpssd.cn"] = app.NewNcpssd()
-
-		//[China] Shandong University of Traditional Chinese Medicine Digital Ancient Books Library
-		Router["gjsztsg.sdutcm.edu.cn"] = app.NewSdutcm()
-		//[China] Shandong Province Ancient Books Digital Resource Platform
-		Router["guji.sdlib.com"] = app.NewSdlib()
-
-		//[China] Tianjin Library Historical Literature Digital Resource Database
-		Router["lswx.tjl.tj.cn:8001"] = app.NewTjlswx()
-
-		//[China] Yunnan Digital Local Gazetteer
-		Router["dfz.yn.gov.cn"] = app.NewYndfz()
-
-		//[China] University of Hong Kong Digital Library
-		Router["digitalrepository.lib.hku.hk"] = app.NewHkulib()
-
-		//[China] Zhucheng City Library, Shandong Province
-		Router["124.134.220.209:8100"] = app.NewZhuCheng()
-		//[China] Central Academy of Fine Arts
-		Router["dlibgate.cafa.edu.cn"] = app.NewCafaEdu()
-		Router["dlib.cafa.edu.cn"] = app.NewCafaEdu()
-
-		//[China] Anti-Japanese War and Sino-Japanese Relations Literature Database Platform
-		Router["www.modernhistory.org.cn"] = app.NewWar1931()
-		//}}} -----------------------------------------------------------------
-
-		//---------------Japan--------------------------------------------------
-		//[Japan] National Diet Library
-		Router["dl.ndl.go.jp"] = app.NewNdlJP()
-
-		//[Japan] e-Museum National Treasures
-		Router["emuseum.nich.go.jp"] = app.NewEmuseum()
-
-		//[Japan] Imperial Household Agency Archives and Mausolea Department (Chinese Books Collection)
-		Router["db2.sido.keio.ac.jp"] = app.NewKeio()
-
-		//[Japan] University of Tokyo Institute for Oriental Culture (Chinese Rare Books Database)
-		Router["shanben.ioc.u-tokyo.ac.jp"] = app.NewUtokyo()
-
-		//[Japan] National Archives of Japan (Cabinet Library)
-		Router["www.digital.archives.go.jp"] = app.NewNationaljp()
-
-		//[Japan] Toyo Bunko (Oriental Library)
-		Router["dsr.nii.ac.jp"] = app.NewNiiac()
-
-		//[Japan] Waseda University Library
-		Router["archive.wul.waseda.ac.jp"] = app.NewWaseda()
-
-		//[Japan] Kokusho Database (Classical Books)
-		Router["kokusho.nijl.ac.jp"] = app.NewKokusho()
-
-		//[Japan] Kyoto University Institute for Research in Humanities - Digital Library Museum of Oriental Studies
-		Router["kanji.zinbun.kyoto-u.ac.jp"] = app.NewKyotou()
-
-		//[Japan] Komazawa University Electronic Rare Books Collection
-		Router["repo.komazawa-u.ac.jp"] = app.NewIiifRouter()
-
-		//[Japan] Kansai University Library
-		Router["www.iiif.ku-orcas.kansai-u.ac.jp"] = app.NewIiifRouter()
-
-		//[Japan] Keio University Library
-		Router["dcollections.lib.keio.ac.jp"] = app.NewIiifRouter()
-
-		//[Japan] National Museum of Japanese History
-		Router["khirin-a.rekihaku.ac.jp"] = app.NewKhirin()
-
-		//[Japan] Yonezawa City Library
-		Router["www.library.yonezawa.yamagata.jp"] = app.NewYonezawa()
-		Router["webarchives.tnm.jp"] = app.NewTnm()
-
-		//[Japan] Ryukoku University
-		Router["da.library.ryukoku.ac.jp"] = app.NewRyukoku()
-		//}}} -----------------------------------------------------------------
-
-		//{{{---------------United States, Europe--------------------------------------------------
-		//[United States] Harvard University Library
-		Router["iiif.lib.harvard.edu"] = app.NewHarvard()
-		Router["listview.lib.harvard.edu"] = app.NewHarvard()
-		Router["curiosity.lib.harvard.edu"] = app.NewHarvard()
-
-		//[United States] HathiTrust Digital Library
-		Router["babel.hathitrust.org"] = app.NewHathitrust()
-
-		//[United States] Princeton University Library
-		Router["catalog.princeton.edu"] = app.NewPrinceton()
-		Router["dpul.princeton.edu"] = app.NewPrinceton()
-
-		//[United States] Library of Congress
-		Router["www.loc.gov"] = app.NewLoc()
-
-		//[United States] Stanford University Library
-
-		//[United States] Utah Genealogy (FamilySearch)
-		Router["www.familysearch.org"] = app.NewFamilysearch()
-
-		//[Germany] Berlin State Library
-		Router["digital.staatsbibliothek-berlin.de"] = app.NewBerlin()
-
-		//[Germany] Bavarian State Library East Asian Digital Collections
-		Router["ostasien.digitale-sammlungen.de"] = app.NewSammlungen()
-		Router["www.digitale-sammlungen.de"] = app.NewSammlungen()
-
-		//[United Kingdom] Oxford University Bodleian Library
-		Router["digital.bodleian.ox.ac.uk"] = app.NewOxacuk()
-
-		//[United Kingdom] British Library Manuscripts
-		Router["www.bl.uk"] = app.NewBluk()
-
-		//Smithsonian Institution
-		Router["ids.si.edu"] = app.NewSiEdu()
-		Router["www.si.edu"] = app.NewSiEdu()
-		Router["iiif.si.edu"] = app.NewSiEdu()
-		Router["asia.si.edu"] = app.NewSiEdu()
-
-		//[United States] UC Berkeley East Asian Library
-		Router["digicoll.lib.berkeley.edu"] = app.NewBerkeley()
-
-		//[Austria] Austrian National Library
-		Router["digital.onb.ac.at"] = app.NewOnbDigital()
-		//}}} -----------------------------------------------------------------
-
-		//{{{---------------Others--------------------------------------------------
-		//International Dunhuang Project
-		Router["idp.nlc.cn"] = app.NewIdp()
-		Router["idp.bl.uk"] = app.NewIdp()
-		Router["idp.orientalstudies.ru"] = app.NewIdp()
-		Router["idp.afc.ryukoku.ac.jp"] = app.NewIdp()
-		Router["idp.bbaw.de"] = app.NewIdp()
-		Router["idp.bnf.fr"] = app.NewIdp()
-		Router["idp.korea.ac.kr"] = app.NewIdp()
-
-		//[Korea]
-		Router["kyudb.snu.ac.kr"] = app.NewKyudbSnu()
-		Router["lod.nl.go.kr"] = app.NewLodNLGoKr()
+var siteOf = make(map[string]*Site)
 
-		//[Korea] Korea University
-		Router["kostma.korea.ac.kr"] = app.NewKorea()
+// Resolve picks the site of sUrl like FactoryRouter does, without going online
+func Resolve(siteID string, sUrl string) Route {
+	doInit.Do(register)
 
-		//[Russia] Russian State Library
-		Router["viewer.rsl.ru"] = app.NewRslRu()
+	reason := ""
+	if config.Conf.DownloaderMode == 1 {
+		siteID, reason = "bookget", "-m 1 sends every URL to the generic image downloader"
+	} else if config.Conf.DownloaderMode == 2 {
+		siteID, reason = "iiif.io", "-m 2 reads every URL as an IIIF manifest"
+	} else if strings.Contains(sUrl, ".json") {
+		siteID, reason = "iiif.io", "the URL contains .json, so it is read as an IIIF manifest"
+	}
+	if strings.Contains(sUrl, "tiles/infos.json") {
+		siteID, reason = "dzicnlib", "the URL contains tiles/infos.json (DZI tiles)"
+	}
 
-		//[Vietnam] Vietnamese Han-Nom Ancient Books Digital Preservation Project
-		Router["lib.nomfoundation.org"] = app.NewNomfoundation()
+	site, ok := siteOf[siteID]
+	if reason == "" {
+		// Viewer pages, permalinks and resolvers of a listed site's records
+		// reach its adapter as the record's canonical URL
+		if rec, found := app.Normalize(sUrl); found && rec.URL != sUrl {
+			if u, err := url.Parse(rec.URL); err == nil && siteOf[u.Host] != nil {
+				siteID, site, ok = u.Host, siteOf[u.Host], true
+				reason = "the URL is the " + rec.Site + " record " + rec.ID + ", read as " + rec.URL
+				sUrl = rec.URL
+			}
+		}
+	}
+	if !ok {
+		return Route{Reason: "host " + siteID + " is not a listed site", URL: sUrl}
+	}
+	if reason == "" {
+		reason = "host " + siteID + " is listed for " + site.Name
+	}
+	return Route{SiteID: siteID, Site: site, Reason: reason, URL: sUrl}
+}
 
-		//[Vietnam] National Library of Vietnam Han-Nom Library
-		Router["hannom.nlv.gov.vn"] = app.NewHannomNlv()
-		//}}} -----------------------------------------------------------------
+// FactoryRouter downloads the book at sUrl with a new adapter of its site.
+// ctx is the book's, see app.NewBookContext.
+func FactoryRouter(ctx context.Context, siteID string, sUrl string) (map[string]interface{}, error) {
+	route := Resolve(siteID, sUrl)
+	siteID, sUrl = route.SiteID, route.URL
+	if siteID == "" {
+		siteID = siteByContentType(sUrl)
+	}
+	site, ok := siteOf[siteID]
+	if !ok {
+		return nil, errors.New("unsupported URL: " + sUrl)
+	}
+	return site.New(ctx).GetRouterInit(sUrl)
+}
 
-		Router["bookget"] = app.NewImageDownloader()
-		Router["dzicnlib"] = app.NewDziCnLib()
-		Router["iiif.io"] = app.NewIiifRouter()
-	})
+// SiteOf is the site FactoryRouter downloads sUrl with, "" if none
+func SiteOf(siteID string, sUrl string) string {
+	if siteID = Resolve(siteID, sUrl).SiteID; siteID != "" {
+		return siteID
+	}
+	return siteByContentType(sUrl)
+}
 
-	// Check if router exists
-	if _, ok := Router[siteID]; !ok {
-		urlType := util.GetHeaderContentType(sUrl)
-		if urlType == "json" {
-			siteID = "iiif.io"
-		} else if urlType == "bookget" {
-			siteID = "bookget"
-		}
+// siteByContentType picks the site of unlisted hosts by the Content-Type
+func siteByContentType(sUrl string) string {
+	switch util.GetHeaderContentType(sUrl) {
+	case "json":
+		return "iiif.io"
+	case "bookget":
+		return "bookget"
+	}
+	return ""
+}
 
-		if _, ok := Router[siteID]; !ok {
-			return nil, errors.New("unsupported URL: " + sUrl)
+// SimilarHosts returns the listed hosts in the same domain as host, e.g.
+// read.nlc.cn for www.nlc.cn
+func SimilarHosts(host string) []string {
+	doInit.Do(register)
+	domain := host
+	if i := strings.LastIndexByte(domain, ':'); i > 0 {
+		domain = domain[:i]
+	}
+	if labels := strings.Split(domain, "."); len(labels) > 2 {
+		domain = strings.Join(labels[len(labels)-2:], ".")
+	}
+	var hosts []string
+	for i := range Sites {
+		for _, h := range Sites[i].Hosts {
+			if h != host && (h == domain || strings.HasSuffix(h, "."+domain)) {
+				hosts = append(hosts, h)
+			}
 		}
 	}
-
-	return Router[siteID].GetRouterInit(sUrl)
-
+	return hosts
 }
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"bookget/app"
+	"bookget/config"
+	"bookget/pkg/gohttp"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const notRecorded = "no fixtures in app/testdata yet"
+
+// unrecordedSites are the sites whose fixtures are not recorded yet, and why.
+// Record one with network access, then take it off the list:
+//
+//	BOOKGET_FIXTURES=record go test ./router -run 'TestSiteReplay/<site name>'
+var unrecordedSites = map[string]string{
+	"National Library of China":                                         notRecorded,
+	"National Library of China, Ancient Books":                          notRecorded,
+	"Taiwan Chinese E-book Repository":                                  notRecorded,
+	"Hong Kong University of Science and Technology Library":            notRecorded,
+	"Luoyang City Library":                                              notRecorded,
+	"Wenzhou City Library":                                              notRecorded,
+	"Shenzhen Library, Ancient Books":                                   notRecorded,
+	"Guangzhou Dadian":                                                  notRecorded,
+	"Jiangsu Colleges Precious Ancient Books Digital Library":           notRecorded,
+	"China Roots Network (National Library of China)":                   notRecorded,
+	"Shandong Province Ancient Books Digital Resource Platform":         notRecorded,
+	"Tianjin Library Historical Literature":                             notRecorded,
+	"Yunnan Digital Local Gazetteer":                                    notRecorded,
+	"University of Hong Kong Digital Library":                           notRecorded,
+	"Zhucheng City Library":                                             notRecorded,
+	"Central Academy of Fine Arts":                                      notRecorded,
+	"Anti-Japanese War and Sino-Japanese Relations Literature Database": notRecorded,
+	"e-Museum National Treasures":                                       notRecorded,
+	"Keio University, Chinese Books of the Imperial Household Agency":   notRecorded,
+	"University of Tokyo Institute for Oriental Culture":                notRecorded,
+	"National Archives of Japan (Cabinet Library)":                      notRecorded,
+	"Toyo Bunko":                notRecorded,
+	"Waseda University Library": notRecorded,
+	"Kokusho Database":          notRecorded,
+	"Kyoto University, Institute for Research in Humanities": notRecorded,
+	"National Museum of Japanese History":                    notRecorded,
+	"Yonezawa City Library":                                  notRecorded,
+	"Tokyo National Museum":                                  notRecorded,
+	"Ryukoku University":                                     notRecorded,
+	"HathiTrust Digital Library":                             notRecorded,
+	"Princeton University Library":                           notRecorded,
+	"Berlin State Library":                                   notRecorded,
+	"Bavarian State Library, East Asian Digital Collections": notRecorded,
+	"Bodleian Libraries, University of Oxford":               notRecorded,
+	"British Library Manuscripts":                            notRecorded,
+	"Smithsonian Institution":                                notRecorded,
+	"UC Berkeley East Asian Library":                         notRecorded,
+	"Austrian National Library":                              notRecorded,
+	"International Dunhuang Project":                         notRecorded,
+	"Kyujanggak Institute, Seoul National University":        notRecorded,
+	"Korea University":                                       notRecorded,
+	"Russian State Library":                                  notRecorded,
+	"Vietnamese Nom Preservation Foundation":                 notRecorded,
+	"National Library of Vietnam, Han-Nom Library":           notRecorded,
+	"DZI tiles (Chinese provincial libraries)":               notRecorded,
+}
+
+// TestSiteReplay downloads the first two pages of the first example of every
+// site from the fixtures in app/testdata/<host>/. A site without fixtures
+// fails, unless it is listed in unrecordedSites. Sites that need cookies, a
+// login or bookget-gui can't be recorded unattended.
+func TestSiteReplay(t *testing.T) {
+	savedConf, savedFixtures := config.Conf, gohttp.Fixtures
+	defer func() { config.Conf, gohttp.Fixtures = savedConf, savedFixtures }()
+	dir, err := filepath.Abs(filepath.Join("..", "app", "testdata"))
+	require.NoError(t, err)
+	gohttp.Fixtures = gohttp.FixturePolicy{Mode: gohttp.FixtureModeFromEnv(), Dir: dir}
+	config.Conf = config.Input{
+		Format:    "full/full/0/default.jpg",
+		FileExt:   ".jpg",
+		UserAgent: "bookget-test",
+		Threads:   1,
+		PageRate:  1,
+		PageCheck: "off",
+	}
+	require.NoError(t, config.SetRanges("1-2", "1"))
+
+	for _, s := range Sites {
+		if len(s.Examples) == 0 {
+			continue
+		}
+		s := s
+		t.Run(s.Name, func(t *testing.T) {
+			if s.Auth != AuthNone || s.GUI {
+				t.Skip("needs " + s.Auth + " cookies or bookget-gui")
+			}
+			example := s.Examples[0]
+			u, err := url.Parse(example)
+			require.NoError(t, err)
+			if gohttp.Fixtures.Mode == gohttp.FixtureReplay {
+				_, err = os.Stat(gohttp.FixtureHostDir(u.Host))
+				reason, listed := unrecordedSites[s.Name]
+				switch {
+				case err != nil && !listed:
+					t.Fatalf("no fixtures for %s in %s; record them or list the site in unrecordedSites", example, gohttp.FixtureHostDir(u.Host))
+				case err != nil:
+					t.Skip(reason + ": " + example)
+				case listed:
+					t.Fatalf("%s is recorded, take it off unrecordedSites", s.Name)
+				}
+			}
+
+			config.Conf.Directory = t.TempDir()
+			book := app.NewBookContext(context.Background(), example)
+			_, err = FactoryRouter(book, u.Host, example)
+			require.NoError(t, err)
+			pages := 0
+			_ = filepath.WalkDir(config.Conf.Directory, func(p string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					pages++
+				}
+				return err
+			})
+			assert.Positive(t, pages, "no page saved from %s", example)
+		})
+	}
+}
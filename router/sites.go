@@ -0,0 +1,459 @@
+package router
+
+import (
+	"bookget/app"
+	"context"
+)
+
+// Auth requirements of a site
+const (
+	AuthNone   = ""
+	AuthCookie = "cookie" // cookie.txt from a browser that passed the site's check
+	AuthLogin  = "login"  // A user account
+)
+
+// Site describes one supported source
+type Site struct {
+	Name     string   `json:"name"`
+	Country  string   `json:"country,omitempty"`
+	Hosts    []string `json:"hosts"`           // Keys of Router: host[:port], or a generic downloader
+	Match    string   `json:"match,omitempty"` // Other URLs routed here, or a note on routing
+	Examples []string `json:"examples,omitempty"`
+	Auth     string   `json:"auth,omitempty"`
+	GUI      bool     `json:"gui,omitempty"` // Needs bookget-gui for the human check or login
+
+	New func(ctx context.Context) RouterInit `json:"-"` // A new adapter for the book of ctx
+}
+
+// Sites lists every adapter in the order of the README
+var Sites = []Site{
+	//{{{---------------China--------------------------------------------------
+	{
+		Name:    "National Library of China",
+		Country: "China",
+		Hosts:   []string{"read.nlc.cn", "mylib.nlc.cn"},
+		Examples: []string{
+			"http://read.nlc.cn/allSearch/searchDetail?searchType=1002&showType=1&indexName=data_892&fid=411999021002",
+		},
+		New: func(ctx context.Context) RouterInit { return app.NewChinaNlc(ctx) },
+	},
+	{
+		Name:     "National Library of China, Ancient Books",
+		Country:  "China",
+		Hosts:    []string{"guji.nlc.cn"},
+		Examples: []string{"https://guji.nlc.cn/guji/pmgj/gjyxxq?metadataId=1001165"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNlcGuji(ctx) },
+	},
+	{
+		Name:     "Taiwan Chinese E-book Repository",
+		Country:  "China",
+		Hosts:    []string{"taiwanebook.ncl.edu.tw"},
+		Examples: []string{"https://taiwanebook.ncl.edu.tw/zh-tw/book/NCL-9910010010/reader"},
+		New:      func(ctx context.Context) RouterInit { return app.NewHuawen(ctx) },
+	},
+	{
+		Name:     "Chinese University of Hong Kong Library",
+		Country:  "China",
+		Hosts:    []string{"repository.lib.cuhk.edu.hk"},
+		Examples: []string{"https://repository.lib.cuhk.edu.hk/sc/item/cuhk-412225"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewCuhk(ctx) },
+	},
+	{
+		Name:     "Hong Kong University of Science and Technology Library",
+		Country:  "China",
+		Hosts:    []string{"lbezone.hkust.edu.hk"},
+		Examples: []string{"https://lbezone.hkust.edu.hk/bib/b1129168"},
+		New:      func(ctx context.Context) RouterInit { return app.NewUsthk(ctx) },
+	},
+	{
+		Name:     "Luoyang City Library",
+		Country:  "China",
+		Hosts:    []string{"111.7.82.29:8090"},
+		Examples: []string{"http://111.7.82.29:8090/cms/GuJi/guji_detail.html?type=1&id=3102"},
+		New:      func(ctx context.Context) RouterInit { return app.NewLuoyang(ctx) },
+	},
+	{
+		Name:     "Wenzhou City Library",
+		Country:  "China",
+		Hosts:    []string{"oyjy.wzlib.cn", "arcgxhpv7cw0.db.wzlib.cn"},
+		Examples: []string{"https://oyjy.wzlib.cn/detail/?id=137913"},
+		New:      func(ctx context.Context) RouterInit { return app.NewWzlib(ctx) },
+	},
+	{
+		Name:     "Shenzhen Library, Ancient Books",
+		Country:  "China",
+		Hosts:    []string{"yun.szlib.org.cn"},
+		Examples: []string{"https://yun.szlib.org.cn/stgj2021/srchshow?book_id=2104"},
+		New:      func(ctx context.Context) RouterInit { return app.NewSzLib(ctx) },
+	},
+	{
+		Name:     "Guangzhou Dadian",
+		Country:  "China",
+		Hosts:    []string{"gzdd.gzlib.gov.cn", "gzdd.gzlib.org.cn"},
+		Examples: []string{"https://gzdd.gzlib.org.cn/Hrcanton/Search/ResultDetail?BookId=GZDD0000107"},
+		New:      func(ctx context.Context) RouterInit { return app.NewGzlib(ctx) },
+	},
+	{
+		Name:     "Tianyi Pavilion Museum",
+		Country:  "China",
+		Hosts:    []string{"gj.tianyige.com.cn"},
+		Examples: []string{"https://gj.tianyige.com.cn/catalogDetail?catalogId=2b3f0cce6ca7fd4b8d6b2a9a5a0d3bfc"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewTianyige(ctx) },
+	},
+	{
+		Name:     "Jiangsu Colleges Precious Ancient Books Digital Library",
+		Country:  "China",
+		Hosts:    []string{"jsgxgj.nju.edu.cn"},
+		Examples: []string{"http://jsgxgj.nju.edu.cn/jsgxgj/reader.html?bookId=1231"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNjuedu(ctx) },
+	},
+	{
+		Name:     "China Roots Network (National Library of China)",
+		Country:  "China",
+		Hosts:    []string{"ouroots.nlc.cn"},
+		Examples: []string{"http://ouroots.nlc.cn/user/catalogDetail.html?A0000003270"},
+		New:      func(ctx context.Context) RouterInit { return app.NewOuroots(ctx) },
+	},
+	{
+		Name:     "National Center for Philosophy and Social Sciences Documentation",
+		Country:  "China",
+		Hosts:    []string{"www.ncpssd.org", "www.ncpssd.cn"},
+		Examples: []string{"https://www.ncpssd.cn/Literature/articleinfo?id=GJ10017&type=Ancient&barcodenum=70050810"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewNcpssd(ctx) },
+	},
+	{
+		Name:     "Shandong University of Traditional Chinese Medicine",
+		Country:  "China",
+		Hosts:    []string{"gjsztsg.sdutcm.edu.cn"},
+		Examples: []string{"https://gjsztsg.sdutcm.edu.cn/index/book/detail?id=1b2c3d"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewSdutcm(ctx) },
+	},
+	{
+		Name:     "Shandong Province Ancient Books Digital Resource Platform",
+		Country:  "China",
+		Hosts:    []string{"guji.sdlib.com"},
+		Examples: []string{"https://guji.sdlib.com/#/bookDetail?resId=JK00001"},
+		New:      func(ctx context.Context) RouterInit { return app.NewSdlib(ctx) },
+	},
+	{
+		Name:     "Tianjin Library Historical Literature",
+		Country:  "China",
+		Hosts:    []string{"lswx.tjl.tj.cn:8001"},
+		Examples: []string{"http://lswx.tjl.tj.cn:8001/front/mz/resDetail?drid=10256"},
+		New:      func(ctx context.Context) RouterInit { return app.NewTjlswx(ctx) },
+	},
+	{
+		Name:     "Yunnan Digital Local Gazetteer",
+		Country:  "China",
+		Hosts:    []string{"dfz.yn.gov.cn"},
+		Examples: []string{"http://dfz.yn.gov.cn/index.php/book/read?id=2553"},
+		New:      func(ctx context.Context) RouterInit { return app.NewYndfz(ctx) },
+	},
+	{
+		Name:     "University of Hong Kong Digital Library",
+		Country:  "China",
+		Hosts:    []string{"digitalrepository.lib.hku.hk"},
+		Examples: []string{"https://digitalrepository.lib.hku.hk/catalog/q524n4370"},
+		New:      func(ctx context.Context) RouterInit { return app.NewHkulib(ctx) },
+	},
+	{
+		Name:     "Zhucheng City Library",
+		Country:  "China",
+		Hosts:    []string{"124.134.220.209:8100"},
+		Examples: []string{"http://124.134.220.209:8100/detail.jsp?type=1&id=1253"},
+		New:      func(ctx context.Context) RouterInit { return app.NewZhuCheng(ctx) },
+	},
+	{
+		Name:     "Central Academy of Fine Arts",
+		Country:  "China",
+		Hosts:    []string{"dlibgate.cafa.edu.cn", "dlib.cafa.edu.cn"},
+		Examples: []string{"https://dlibgate.cafa.edu.cn/ebook/item/1b867e68"},
+		New:      func(ctx context.Context) RouterInit { return app.NewCafaEdu(ctx) },
+	},
+	{
+		Name:     "Anti-Japanese War and Sino-Japanese Relations Literature Database",
+		Country:  "China",
+		Hosts:    []string{"www.modernhistory.org.cn"},
+		Examples: []string{"https://www.modernhistory.org.cn/#/DocumentDetails_tsh?fileCode=9ebd0a1dc7a9a6ff"},
+		New:      func(ctx context.Context) RouterInit { return app.NewWar1931(ctx) },
+	},
+	//}}} -----------------------------------------------------------------
+
+	//{{{---------------Japan--------------------------------------------------
+	{
+		Name:     "National Diet Library",
+		Country:  "Japan",
+		Hosts:    []string{"dl.ndl.go.jp"},
+		Examples: []string{"https://dl.ndl.go.jp/pid/1287288"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNdlJP(ctx) },
+	},
+	{
+		Name:     "e-Museum National Treasures",
+		Country:  "Japan",
+		Hosts:    []string{"emuseum.nich.go.jp"},
+		Examples: []string{"https://emuseum.nich.go.jp/detail?langId=ja&webView=&content_base_id=100168&content_part_id=0&content_pict_id=0"},
+		New:      func(ctx context.Context) RouterInit { return app.NewEmuseum(ctx) },
+	},
+	{
+		Name:     "Keio University, Chinese Books of the Imperial Household Agency",
+		Country:  "Japan",
+		Hosts:    []string{"db2.sido.keio.ac.jp"},
+		Examples: []string{"https://db2.sido.keio.ac.jp/kanseki/bib_frame?id=007387-001"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKeio(ctx) },
+	},
+	{
+		Name:     "University of Tokyo Institute for Oriental Culture",
+		Country:  "Japan",
+		Hosts:    []string{"shanben.ioc.u-tokyo.ac.jp"},
+		Examples: []string{"http://shanben.ioc.u-tokyo.ac.jp/main_p.php?nu=C5613401&order=rn_no&no=00870"},
+		New:      func(ctx context.Context) RouterInit { return app.NewUtokyo(ctx) },
+	},
+	{
+		Name:     "National Archives of Japan (Cabinet Library)",
+		Country:  "Japan",
+		Hosts:    []string{"www.digital.archives.go.jp"},
+		Examples: []string{"https://www.digital.archives.go.jp/DAS/meta/listPhoto?LANG=default&BID=F1000000000000095226&ID=&NO=&TYPE=dljpeg&DL_TYPE=jpeg"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNationaljp(ctx) },
+	},
+	{
+		Name:     "Toyo Bunko",
+		Country:  "Japan",
+		Hosts:    []string{"dsr.nii.ac.jp"},
+		Examples: []string{"http://dsr.nii.ac.jp/toyobunko/II-11-D-802/V-1/"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNiiac(ctx) },
+	},
+	{
+		Name:     "Waseda University Library",
+		Country:  "Japan",
+		Hosts:    []string{"archive.wul.waseda.ac.jp"},
+		Examples: []string{"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/"},
+		New:      func(ctx context.Context) RouterInit { return app.NewWaseda(ctx) },
+	},
+	{
+		Name:     "Kokusho Database",
+		Country:  "Japan",
+		Hosts:    []string{"kokusho.nijl.ac.jp"},
+		Examples: []string{"https://kokusho.nijl.ac.jp/biblio/100270332"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKokusho(ctx) },
+	},
+	{
+		Name:     "Kyoto University, Institute for Research in Humanities",
+		Country:  "Japan",
+		Hosts:    []string{"kanji.zinbun.kyoto-u.ac.jp"},
+		Examples: []string{"http://kanji.zinbun.kyoto-u.ac.jp/db-machine/toho/html/A002menu.html"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKyotou(ctx) },
+	},
+	{
+		Name:    "Komazawa University, Kansai University and Keio University Libraries (IIIF)",
+		Country: "Japan",
+		Hosts:   []string{"repo.komazawa-u.ac.jp", "www.iiif.ku-orcas.kansai-u.ac.jp", "dcollections.lib.keio.ac.jp"},
+		Match:   "Manifest URLs (.json) of these hosts go to the IIIF adapter as well",
+		New:     func(ctx context.Context) RouterInit { return app.NewIiifRouter(ctx) },
+	},
+	{
+		Name:     "National Museum of Japanese History",
+		Country:  "Japan",
+		Hosts:    []string{"khirin-a.rekihaku.ac.jp"},
+		Examples: []string{"https://khirin-a.rekihaku.ac.jp/sohanshiki/h-172-1"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKhirin(ctx) },
+	},
+	{
+		Name:     "Yonezawa City Library",
+		Country:  "Japan",
+		Hosts:    []string{"www.library.yonezawa.yamagata.jp"},
+		Examples: []string{"https://www.library.yonezawa.yamagata.jp/dg/AA001_view.html"},
+		New:      func(ctx context.Context) RouterInit { return app.NewYonezawa(ctx) },
+	},
+	{
+		Name:     "Tokyo National Museum",
+		Country:  "Japan",
+		Hosts:    []string{"webarchives.tnm.jp"},
+		Examples: []string{"https://webarchives.tnm.jp/dlib/detail/2580"},
+		New:      func(ctx context.Context) RouterInit { return app.NewTnm(ctx) },
+	},
+	{
+		Name:     "Ryukoku University",
+		Country:  "Japan",
+		Hosts:    []string{"da.library.ryukoku.ac.jp"},
+		Examples: []string{"https://da.library.ryukoku.ac.jp/page/10000"},
+		New:      func(ctx context.Context) RouterInit { return app.NewRyukoku(ctx) },
+	},
+	//}}} -----------------------------------------------------------------
+
+	//{{{---------------United States, Europe--------------------------------------------------
+	{
+		Name:     "Harvard University Library",
+		Country:  "United States",
+		Hosts:    []string{"iiif.lib.harvard.edu", "listview.lib.harvard.edu", "curiosity.lib.harvard.edu"},
+		Examples: []string{"https://iiif.lib.harvard.edu/manifests/view/drs:53262215"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewHarvard(ctx) },
+	},
+	{
+		Name:     "HathiTrust Digital Library",
+		Country:  "United States",
+		Hosts:    []string{"babel.hathitrust.org"},
+		Examples: []string{"https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924"},
+		New:      func(ctx context.Context) RouterInit { return app.NewHathitrust(ctx) },
+	},
+	{
+		Name:     "Princeton University Library",
+		Country:  "United States",
+		Hosts:    []string{"catalog.princeton.edu", "dpul.princeton.edu"},
+		Examples: []string{"https://catalog.princeton.edu/catalog/9946093213506421"},
+		New:      func(ctx context.Context) RouterInit { return app.NewPrinceton(ctx) },
+	},
+	{
+		Name:     "Library of Congress",
+		Country:  "United States",
+		Hosts:    []string{"www.loc.gov"},
+		Examples: []string{"https://www.loc.gov/item/2014514163/"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewLoc(ctx) },
+	},
+	{
+		Name:     "FamilySearch",
+		Country:  "United States",
+		Hosts:    []string{"www.familysearch.org"},
+		Examples: []string{"https://www.familysearch.org/ark:/61903/3:1:3QSQ-G9MC-ZSQ7-3"},
+		Auth:     AuthLogin,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewFamilysearch(ctx) },
+	},
+	{
+		Name:     "Berlin State Library",
+		Country:  "Germany",
+		Hosts:    []string{"digital.staatsbibliothek-berlin.de"},
+		Examples: []string{"https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630&PHYSID=PHYS_0001"},
+		New:      func(ctx context.Context) RouterInit { return app.NewBerlin(ctx) },
+	},
+	{
+		Name:     "Bavarian State Library, East Asian Digital Collections",
+		Country:  "Germany",
+		Hosts:    []string{"ostasien.digitale-sammlungen.de", "www.digitale-sammlungen.de"},
+		Examples: []string{"https://www.digitale-sammlungen.de/en/view/bsb11129280?page=1"},
+		New:      func(ctx context.Context) RouterInit { return app.NewSammlungen(ctx) },
+	},
+	{
+		Name:     "Bodleian Libraries, University of Oxford",
+		Country:  "United Kingdom",
+		Hosts:    []string{"digital.bodleian.ox.ac.uk"},
+		Examples: []string{"https://digital.bodleian.ox.ac.uk/objects/2fea788e-2aa2-4f08-b6d9-648c00486220/"},
+		New:      func(ctx context.Context) RouterInit { return app.NewOxacuk(ctx) },
+	},
+	{
+		Name:     "British Library Manuscripts",
+		Country:  "United Kingdom",
+		Hosts:    []string{"www.bl.uk"},
+		Examples: []string{"http://www.bl.uk/manuscripts/Viewer.aspx?ref=or_8210!s2_f001r"},
+		New:      func(ctx context.Context) RouterInit { return app.NewBluk(ctx) },
+	},
+	{
+		Name:     "Smithsonian Institution",
+		Country:  "United States",
+		Hosts:    []string{"ids.si.edu", "www.si.edu", "iiif.si.edu", "asia.si.edu"},
+		Examples: []string{"https://ids.si.edu/ids/manifest/FS-F1904.61_006", "https://asia.si.edu/object/F1904.61/"},
+		New:      func(ctx context.Context) RouterInit { return app.NewSiEdu(ctx) },
+	},
+	{
+		Name:     "UC Berkeley East Asian Library",
+		Country:  "United States",
+		Hosts:    []string{"digicoll.lib.berkeley.edu"},
+		Examples: []string{"https://digicoll.lib.berkeley.edu/record/74092"},
+		New:      func(ctx context.Context) RouterInit { return app.NewBerkeley(ctx) },
+	},
+	{
+		Name:     "Austrian National Library",
+		Country:  "Austria",
+		Hosts:    []string{"digital.onb.ac.at"},
+		Examples: []string{"https://digital.onb.ac.at/RepViewer/viewer.faces?doc=DTL_2893716"},
+		New:      func(ctx context.Context) RouterInit { return app.NewOnbDigital(ctx) },
+	},
+	//}}} -----------------------------------------------------------------
+
+	//{{{---------------Others--------------------------------------------------
+	{
+		Name:  "International Dunhuang Project",
+		Hosts: []string{"idp.nlc.cn", "idp.bl.uk", "idp.orientalstudies.ru", "idp.afc.ryukoku.ac.jp", "idp.bbaw.de", "idp.bnf.fr", "idp.korea.ac.kr"},
+		Examples: []string{
+			"http://idp.nlc.cn/database/oo_scroll_h.a4d?uid=1234567890",
+		},
+		New: func(ctx context.Context) RouterInit { return app.NewIdp(ctx) },
+	},
+	{
+		Name:     "Kyujanggak Institute, Seoul National University",
+		Country:  "Korea",
+		Hosts:    []string{"kyudb.snu.ac.kr"},
+		Examples: []string{"https://kyudb.snu.ac.kr/book/view.do?book_cd=GK00000_00"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKyudbSnu(ctx) },
+	},
+	{
+		Name:     "National Library of Korea (Linked Open Data)",
+		Country:  "Korea",
+		Hosts:    []string{"lod.nl.go.kr"},
+		Examples: []string{"https://lod.nl.go.kr/resource/CNTS-00047981911"},
+		Auth:     AuthCookie,
+		GUI:      true,
+		New:      func(ctx context.Context) RouterInit { return app.NewLodNLGoKr(ctx) },
+	},
+	{
+		Name:     "Korea University",
+		Country:  "Korea",
+		Hosts:    []string{"kostma.korea.ac.kr"},
+		Examples: []string{"https://kostma.korea.ac.kr/viewer/viewerDes?uci=RIKS+CRMA+KSM-WC.1802.0000-20090729.AS_SA_244"},
+		New:      func(ctx context.Context) RouterInit { return app.NewKorea(ctx) },
+	},
+	{
+		Name:     "Russian State Library",
+		Country:  "Russia",
+		Hosts:    []string{"viewer.rsl.ru"},
+		Examples: []string{"https://viewer.rsl.ru/ru/rsl01004088050"},
+		New:      func(ctx context.Context) RouterInit { return app.NewRslRu(ctx) },
+	},
+	{
+		Name:     "Vietnamese Nom Preservation Foundation",
+		Country:  "Vietnam",
+		Hosts:    []string{"lib.nomfoundation.org"},
+		Examples: []string{"http://lib.nomfoundation.org/collection/1/volume/1025/"},
+		New:      func(ctx context.Context) RouterInit { return app.NewNomfoundation(ctx) },
+	},
+	{
+		Name:     "National Library of Vietnam, Han-Nom Library",
+		Country:  "Vietnam",
+		Hosts:    []string{"hannom.nlv.gov.vn"},
+		Examples: []string{"https://hannom.nlv.gov.vn/hannom/cgi-bin/hannom?a=d&d=BTHNaaaaa1234"},
+		New:      func(ctx context.Context) RouterInit { return app.NewHannomNlv(ctx) },
+	},
+	//}}} -----------------------------------------------------------------
+
+	{
+		Name:  "Generic image downloader",
+		Hosts: []string{"bookget"},
+		Match: "-m 1, or a URL whose Content-Type is an image",
+		New:   func(ctx context.Context) RouterInit { return app.NewImageDownloader(ctx) },
+	},
+	{
+		Name:     "DZI tiles (Chinese provincial libraries)",
+		Hosts:    []string{"dzicnlib"},
+		Match:    "URLs containing tiles/infos.json",
+		Examples: []string{"https://guji.sclib.org/medias/1122/tiles/infos.json"},
+		New:      func(ctx context.Context) RouterInit { return app.NewDziCnLib(ctx) },
+	},
+	{
+		Name:     "IIIF manifest",
+		Hosts:    []string{"iiif.io"},
+		Match:    "-m 2, URLs containing .json, or a URL whose Content-Type is JSON",
+		Examples: []string{"https://catalog.lib.kyushu-u.ac.jp/image/manifest/1/820/1446033.json"},
+		New:      func(ctx context.Context) RouterInit { return app.NewIiifRouter(ctx) },
+	},
+}
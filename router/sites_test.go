@@ -0,0 +1,98 @@
+package router
+
+import (
+	"bookget/app"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteExamples(t *testing.T) {
+	seen := make(map[string]string)
+	for _, s := range Sites {
+		for _, host := range s.Hosts {
+			assert.NotContains(t, seen, host, "%s is listed by %s and %s", host, seen[host], s.Name)
+			seen[host] = s.Name
+		}
+		for _, example := range s.Examples {
+			u, err := url.Parse(example)
+			require.NoError(t, err, example)
+			route := Resolve(u.Host, example)
+			require.NotNil(t, route.Site, example)
+			assert.Equal(t, s.Name, route.Site.Name, example)
+			if id, ok := app.BookIdOf(Router[route.SiteID], example); ok {
+				assert.NotEmpty(t, id, example)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	route := Resolve("www.nlc.cn", "https://www.nlc.cn/web/index.shtml")
+	assert.Empty(t, route.SiteID)
+	assert.Contains(t, SimilarHosts("www.nlc.cn"), "read.nlc.cn")
+
+	route = Resolve("dl.ndl.go.jp", "https://dl.ndl.go.jp/api/iiif/1287288/manifest.json")
+	assert.Equal(t, "iiif.io", route.SiteID)
+	assert.Equal(t, "https://dl.ndl.go.jp/api/iiif/1287288/manifest.json", route.URL)
+}
+
+func TestResolveRecord(t *testing.T) {
+	// Permalinks and resolvers go to the adapter of their record, as its canonical URL
+	for sUrl, want := range map[string]string{
+		"https://hdl.handle.net/2027/uc1.b3656924":                     "https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924",
+		"https://lccn.loc.gov/2014514163":                              "https://www.loc.gov/item/2014514163/",
+		"https://mdz-nbn-resolving.de/urn:nbn:de:bvb:12-bsb11129280-3": "https://www.digitale-sammlungen.de/en/view/bsb11129280",
+		"https://search.rsl.ru/ru/record/01004088050":                  "https://viewer.rsl.ru/ru/rsl01004088050",
+	} {
+		u, _ := url.Parse(sUrl)
+		route := Resolve(u.Host, sUrl)
+		require.NotNil(t, route.Site, sUrl)
+		assert.Equal(t, want, route.URL, sUrl)
+		assert.Equal(t, siteOf[route.SiteID], route.Site, sUrl)
+	}
+
+	// Viewer pages of listed hosts reach their adapter as the canonical URL too
+	for sUrl, want := range map[string]struct{ id, url string }{
+		"https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924;view=1up;seq=7":       {"uc1.b3656924", "https://babel.hathitrust.org/cgi/pt?id=uc1.b3656924"},
+		"https://digital.staatsbibliothek-berlin.de/werkansicht/?PPN=3303598630":   {"PPN3303598630", "https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630"},
+		"https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630": {"PPN3303598630", "https://digital.staatsbibliothek-berlin.de/werkansicht?PPN=PPN3303598630"},
+		"https://repository.lib.cuhk.edu.hk/en/islandora/object/cuhk%3A412225":     {"cuhk-412225", "https://repository.lib.cuhk.edu.hk/en/item/cuhk-412225"},
+		"https://repository.lib.cuhk.edu.hk/sc/item/cuhk-412225":                   {"cuhk-412225", "https://repository.lib.cuhk.edu.hk/en/item/cuhk-412225"},
+		"https://listview.lib.harvard.edu/lists/drs-53262215":                      {"drs:53262215", "https://iiif.lib.harvard.edu/manifests/view/drs:53262215"},
+		"https://www.digitale-sammlungen.de/en/details/bsb11129280":                {"bsb11129280", "https://www.digitale-sammlungen.de/en/view/bsb11129280"},
+	} {
+		u, _ := url.Parse(sUrl)
+		route := Resolve(u.Host, sUrl)
+		require.NotNil(t, route.Site, sUrl)
+		assert.Equal(t, want.url, route.URL, sUrl)
+		id, _ := app.BookIdOf(Router[route.SiteID], route.URL)
+		assert.Equal(t, want.id, id, sUrl)
+	}
+
+	// A volume or a page of a Waseda book is downloaded as it is, not as the whole book
+	for _, sUrl := range []string{
+		"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/ri08_01899_0001/",
+		"https://archive.wul.waseda.ac.jp/kosho/ri08/ri08_01899/ri08_01899_0001/ri08_01899_0001_p0003.jpg",
+	} {
+		route := Resolve("archive.wul.waseda.ac.jp", sUrl)
+		require.NotNil(t, route.Site, sUrl)
+		assert.Equal(t, sUrl, route.URL, sUrl)
+	}
+
+	// The canonical URL of each example's record is downloaded by the same site
+	for _, s := range Sites {
+		for _, example := range s.Examples {
+			rec, ok := app.Normalize(example)
+			if !ok {
+				continue
+			}
+			u, _ := url.Parse(rec.URL)
+			route := Resolve(u.Host, rec.URL)
+			require.NotNil(t, route.Site, rec.URL)
+			assert.Equal(t, s.Name, route.Site.Name, rec.URL)
+		}
+	}
+}